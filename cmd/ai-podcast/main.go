@@ -1,16 +1,41 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/radio-t/ai-podcast/internal/ai"
 	"github.com/radio-t/ai-podcast/internal/audio"
 	"github.com/radio-t/ai-podcast/internal/content"
+	"github.com/radio-t/ai-podcast/internal/control"
+	"github.com/radio-t/ai-podcast/internal/feed"
+	"github.com/radio-t/ai-podcast/internal/logging"
+	"github.com/radio-t/ai-podcast/internal/progress"
+	"github.com/radio-t/ai-podcast/internal/script"
+	"github.com/radio-t/ai-podcast/internal/tlsconf"
 	"github.com/radio-t/ai-podcast/podcast"
 )
 
@@ -18,6 +43,40 @@ import (
 //go:generate moq -out mocks/openai_client.go -pkg mocks -skip-ensure -fmt goimports -stub . OpenAIClient
 //go:generate moq -out mocks/audio_processor.go -pkg mocks -skip-ensure -fmt goimports -stub . AudioProcessor
 
+// modes of -tts-chars-mode
+const (
+	ttsCharsReject = "reject"
+	ttsCharsTrim   = "trim"
+)
+
+// gender and voice of speakers not among the hosts, unless -default-gender and -default-voice are set
+const (
+	defaultGender = "female"
+	defaultVoice  = "nova"
+)
+
+// stdoutOutput as -mp3 writes the audio to stdout for piping
+const stdoutOutput = "-"
+
+// defaultMount is the Icecast mount point streamed to without -mount
+const defaultMount = "/podcast.mp3"
+
+// default name and max slug length of files in the per-episode folder of -output-dir
+const (
+	outputDirEpisode = "episode.mp3"
+	outputDirSlugLen = 60
+)
+
+// channel of the feed created by -feed, an existing feed keeps its own
+const (
+	feedTitle       = "AI Podcast"
+	feedDescription = "Articles discussed by AI hosts"
+	feedCategory    = "Technology"
+)
+
+// revision is set at build time with -ldflags "-X main.revision=..."
+var revision = "unknown"
+
 // ArticleFetcher defines the interface for fetching articles (consumer side)
 type ArticleFetcher interface {
 	Fetch(url string) (content, title string, err error)
@@ -26,488 +85,3573 @@ type ArticleFetcher interface {
 // OpenAIClient defines the interface for OpenAI API interactions (consumer side)
 type OpenAIClient interface {
 	GenerateDiscussion(params podcast.GenerateDiscussionParams) (podcast.Discussion, error)
-	GenerateSpeech(text, voice string) ([]byte, error)
+	GenerateSpeech(text, voice, emotion, model string) ([]byte, error)
 }
 
 // AudioProcessor defines the interface for audio processing operations (consumer side)
 type AudioProcessor interface {
 	Play(filename string) error
 	Concatenate(files []string, outputFile string) error
+	ConcatenateTo(files []string, w io.Writer) error
 	StreamToIcecast(inputFile string, config podcast.Config) error
 	StreamFromConcat(concatFile string, config podcast.Config) error
+	StreamFromReader(r io.Reader, config podcast.Config) error
+	InsertSilence(durationMs int, tempDir string) (string, error)
+	Duration(filename string) (float64, error)
+	Trim(inputFile, outputFile string, start, duration float64) error
+	Audiogram(inputFile, outputFile string, width, height int) error
+	MixWithBackground(speechFile, musicFile, outputFile string, musicGainDB float64) error
+	Normalize(inputFiles []string, tempDir string) ([]string, error)
+	ChangeTempo(inputFiles []string, tempo float64, tempDir string) ([]string, error)
+	ConcatenateWithCrossfade(files []string, outputFile string, fadeMs int) error
+	WriteTags(filename string, tags audio.Tags) error
 }
 
 func main() {
+	config, opts, err := configFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.estimate {
+		if err := podcast.ValidateDuration(config.TargetDuration); err != nil {
+			log.Fatalf("Invalid -duration: %v", err)
+		}
+		printEstimate(os.Stdout, config.TargetDuration, config.MessagesPerMinute, config.SplitEpisodes)
+		return
+	}
+
+	if config, err = applyConfigFiles(config, opts.configFile, opts.dumpConfigFile); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	// a saved config may send the audio or the progress events to stdout, the messages follow it
+	config.MessageOut = messageOutput(config.OutputFile, config.ProgressJSON)
+	config.Logger = logging.New(config.MessageOut, opts.level)
+
+	// Ctrl-C or SIGTERM cancels the run, stopping requests and ffmpeg so the deferred cleanup removes temp files.
+	// a second signal kills the program right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	// run the application
+	if err := run(ctx, config); err != nil {
+		log.Fatalf("Application error: %v", err)
+	}
+}
+
+// cliOptions are the command line settings of main besides the run config
+type cliOptions struct {
+	estimate       bool       // print the projected length of -duration and exit
+	configFile     string     // config saved by -dump-config to run with
+	dumpConfigFile string     // file the effective config is saved to
+	level          slog.Level // verbosity of the progress messages
+}
+
+// configFromFlags declares and parses the command line flags and returns the run config built from them.
+// with -estimate only the duration settings are set and nothing else is required.
+func configFromFlags() (podcast.Config, cliOptions, error) {
+	// parse command line flags
 	// parse command line flags
 	articleURL := flag.String("url", "", "URL of the article to discuss")
+	urlList := flag.String("url-list", "", "File with article URLs to process one by one, one URL per line")
+	checkpoint := flag.String("checkpoint", "", "File recording processed URLs of -url-list (default: <url-list>.done)")
 	icecastURL := flag.String("icecast", "localhost:8000", "Icecast server URL")
-	icecastMount := flag.String("mount", "/podcast.mp3", "Icecast mount point")
+	var mountList []string
+	flag.Func("mount", "Icecast mount point, /path or /path:bitrate e.g. /podcast-low.mp3:64k, repeat to stream to several mounts at once (default: /podcast.mp3)",
+		func(value string) error {
+			mountList = append(mountList, value)
+			return nil
+		})
 	icecastUser := flag.String("user", "source", "Icecast username")
 	icecastPass := flag.String("pass", "hackme", "Icecast password")
+	icecastCredentials := flag.String("icecast-credentials", "", "File with Icecast credentials as user:pass, overrides -user and -pass (optional)")
 	apiKey := flag.String("apikey", "", "OpenAI API key")
 	targetDuration := flag.Int("duration", 10, "Target podcast duration in minutes")
+	messagesPerMinute := flag.Int("messages-per-minute", content.MessagesPerMinute, "Lines of the discussion requested per minute of -duration, the length is set by its word budget")
 	dryRun := flag.Bool("dry", false, "Dry run: play locally instead of streaming to Icecast")
-	outputFile := flag.String("mp3", "", "Output MP3 file path (optional)")
+	outputFile := flag.String("mp3", "", "Output MP3 file path, - writes to stdout (optional)")
+	outputDir := flag.String("output-dir", "", "Save each episode with its files into a <date>-<title> folder of this directory (optional)")
+	audiogram := flag.String("audiogram", "", "Render a waveform of the saved episode for sharing, a .png picture or an .mp4 video (optional)")
+	audiogramSize := flag.String("audiogram-size", "1280x720", "Width and height of the -audiogram in pixels")
+	teaser := flag.Duration("teaser", 0, "Also save a teaser clip of this length cut after the intro, e.g. 30s (requires -mp3)")
+	adBreakPos := flag.String("ad-break", "0.5", "Ad break position: fraction of the episode (0.5) or minutes of speech (5m)")
+	introFile := flag.String("intro", "", "Jingle audio file played at the start of every episode (optional)")
+	outroFile := flag.String("outro", "", "Outro audio file played at the end of every episode (optional)")
+	artist := flag.String("artist", "", "Artist tag of the saved episode, e.g. the show or author name (optional)")
+	album := flag.String("album", "", "Album tag of the saved episode, e.g. the show name (optional)")
+	coverFile := flag.String("cover", "", "JPEG or PNG picture attached to the saved episode as its cover art (optional)")
+	musicFile := flag.String("music", "", "Background music mixed quietly under the saved episode, looped when shorter (optional)")
+	cleanOutput := flag.String("clean-output", "", "Also save the episode without the -music bed to this mp3 file (optional)")
+	musicGain := flag.Float64("music-gain", -20, "Volume of the -music bed in dB, negative values duck it under the speech")
+	normalize := flag.Bool("normalize", false, "Normalize the loudness of every speech segment (EBU R128) before streaming or saving")
+	crossfadeMs := flag.Int("crossfade-ms", 0, "Crossfade between segments of the saved episode in milliseconds, 0 for hard cuts")
+	pauseMs := flag.Int("pause-ms", 0, "Silence in milliseconds between the lines of the hosts, 0 for none")
+	adAudio := flag.String("ad-audio", "", "Pre-recorded ad audio file inserted at the ad break")
+	adText := flag.String("ad-text", "", "Ad text to synthesize at the ad break")
+	adSilenceMs := flag.Int("ad-silence-ms", 0, "Silence in milliseconds before and after the ad")
+	splitEpisodes := flag.Int("split-episodes", 1, "Split the article into N episodes with numbered output files")
+	scriptPDF := flag.String("script-pdf", "", "Save the discussion as a printable script PDF (optional)")
+	subtitles := flag.String("subtitles", "", "Save subtitles next to the -mp3 file, srt or vtt (optional)")
+	chapters := flag.Bool("chapters", false, "Add chapter markers at the message boundaries to the -mp3 file and save them next to it")
+	feedFile := flag.String("feed", "", "Add the saved episode to this RSS podcast feed, created when missing (optional)")
+	feedURL := flag.String("feed-url", "", "Public url of the -feed directory the episode links are relative to, required with -feed")
+	transcript := flag.String("transcript", "", "Save the discussion as a JSON transcript with estimated timings (optional)")
+	streamAhead := flag.Int("stream-ahead", 0, "Max segments generated ahead of a live Icecast stream, 0 generates all before streaming")
+	streamRetries := flag.Int("stream-retries", content.IcecastReconnects, "Reconnects of an Icecast stream refused or dropped by the server, 0 fails right away")
+	streamRetryDelay := flag.Duration("stream-retry-delay", content.IcecastReconnectDelay, "Wait before the first Icecast reconnect, doubled on every next one")
+	streamFormat := flag.String("format", "mp3", "Icecast stream format: mp3, ogg or opus")
+	concatMode := flag.String("concat-mode", "auto", "How segments are joined: auto, copy or reencode")
+	bitrate := flag.String("bitrate", "", "Transcode the saved episode and the mp3 stream to this bitrate, e.g. 128k, instead of copying the segments (optional)")
+	sampleRate := flag.Int("sample-rate", 0, "Sample rate in Hz of the -bitrate output, 0 keeps the rate of the segments")
+	channels := flag.Int("channels", 0, "Channels of the -bitrate output, 1 or 2, 0 keeps the channels of the segments")
+	ffmpegPath := flag.String("ffmpeg", "ffmpeg", "Path to the ffmpeg executable, ffprobe is expected next to it")
+	renderURL := flag.String("render-url", "", "Headless-render service URL to fetch JS-heavy articles through (optional)")
+	openAIRetries := flag.Int("openai-retries", content.OpenAIRateLimitRetries, "Retries of OpenAI requests failing with rate limits, server or network errors")
+	parseRetries := flag.Int("parse-retries", content.DiscussionParseRetries,
+		"Times the model is asked to fix a discussion which can't be parsed, 0 fails right away")
+	baseURL := flag.String("base-url", content.OpenAIBaseURL, "Root of the OpenAI-compatible API, e.g. http://localhost:11434/v1 for Ollama")
+	chatModel := flag.String("chat-model", content.OpenAIChatModel, "OpenAI model generating the discussion")
+	fallbackChatModel := flag.String("fallback-chat-model", "", "Chat model with a larger context, retried when the article doesn't fit (optional)")
+	ttsModel := flag.String("tts-model", "", "OpenAI model synthesizing speech (default: "+content.OpenAITTSModel+
+		", "+content.OpenAISpeechModel+" for -tts-backend speech)")
+	ttsBackend := flag.String("tts-backend", string(ai.TTSBackendChat), "TTS endpoint: chat (audio output of chat completions) or speech (/audio/speech)")
+	language := flag.String("language", string(content.LanguageRussian), "Language of the episode: ru, en or es")
+	chatTimeout := flag.Duration("chat-timeout", content.OpenAIChatTimeout, "Deadline of each discussion generation request attempt")
+	ttsTimeout := flag.Duration("tts-timeout", content.OpenAITTSTimeout, "Deadline of each speech synthesis request attempt")
+	openAIUserAgent := flag.String("openai-user-agent", "", "User-Agent for OpenAI requests (default: ai-podcast/<revision>)")
+	sampleOnly := flag.Bool("sample-only", false, "Synthesize only the first message as a quality sample, then stop")
+	scriptOnly := flag.Bool("script-only", false, "Generate the discussion and print it as \"Name: line\" lines, without any speech synthesis")
+	scriptFile := flag.String("script", "", "Voice this discussion script, \"Name: line\" lines or a JSON array, instead of generating one (optional)")
+	scriptOut := flag.String("script-out", "", "Write the -script-only discussion to this file instead of stdout (optional)")
+	recommendedLength := flag.Int("recommended-length", content.RecommendedArticleTextLength,
+		"Warn when the extracted article is shorter than this many characters, 0 disables the warning")
+	fetchUserAgent := flag.String("user-agent", content.FetchUserAgent, "User-Agent of article requests, for sites refusing generic bots")
+	var headerList []string
+	flag.Func("header", "Extra header of article requests as \"Name: value\", e.g. a Cookie or Referer, repeat for more. "+
+		"Sent to every article host, each -url-list article included",
+		func(value string) error {
+			headerList = append(headerList, value)
+			return nil
+		})
+	maxRedirects := flag.Int("max-redirects", content.MaxFetchRedirects, "Redirects of an article request followed before the fetch fails, each has to stay on http or https")
+	blockPrivateIPs := flag.Bool("block-private-ips", false, "Refuse article URLs resolving to private, loopback or link-local addresses")
+	fetchDelay := flag.Duration("fetch-delay", 0, "Minimum delay between article requests to the same host, e.g. 2s")
+	cacheDir := flag.String("cache-dir", "", "Keep extracted articles in this directory and reuse them instead of downloading again (optional)")
+	cacheTTL := flag.Duration("cache-ttl", 24*time.Hour, "Age after which an article of -cache-dir is downloaded again, 0 keeps it forever")
+	noCache := flag.Bool("no-cache", false, "Download every article, ignoring -cache-dir")
+	maxArticleTokens := flag.Int("max-article-tokens", 0, "Limit the article sent to the model by estimated tokens instead of -max-article-chars, 0 keeps the char cap")
+	maxArticleChars := flag.Int("max-article-chars", content.MaxArticleChars, "Max characters of the article sent to the model, cut on a sentence or word boundary")
+	concurrency := flag.Int("concurrency", 0, "Parallel operations per pipeline stage: article fetches, TTS workers, ffprobe runs (default: 1, 3 TTS workers)")
+	fetchConcurrency := flag.Int("fetch-concurrency", 0, "Articles of -url-list fetched ahead in parallel, at most this many ahead of the current one, overrides -concurrency")
+	ttsConcurrency := flag.Int("tts-concurrency", 0, "Parallel speech generation requests, overrides -concurrency (default: 3)")
+	ffmpegConcurrency := flag.Int("ffmpeg-concurrency", 0, "Parallel ffprobe runs inspecting segments, overrides -concurrency")
+	workDir := flag.String("work-dir", "", "Keep segment files in this directory instead of a temporary one (optional)")
+	resumeFrom := flag.Int("resume-from-segment", -1, "Resume Icecast streaming from segment N of -work-dir, without regenerating, 0 from the start, -1 to disable")
+	resumeDir := flag.String("resume-dir", "", "Keep the discussion and its segments in this directory and reuse them when the run is restarted (optional)")
+	controlAddr := flag.String("control-addr", "", "Listen address of the live stream control endpoint, e.g. :8090 (optional)")
+	maxConsecutive := flag.Int("max-consecutive", 0, "Max turns in a row by one host, longer runs are merged, 0 for no limit")
+	reduceFillers := flag.String("reduce-fillers", "", "Thin out repeated filler words before synthesis: light, medium or strong")
+	maxTTSChars := flag.Int("max-tts-chars", 0, "Max characters sent to TTS per episode, 0 for no limit")
+	ttsCharsMode := flag.String("tts-chars-mode", "reject", "What to do when the discussion exceeds -max-tts-chars: reject or trim")
+	balanceQuotes := flag.Bool("balance-quotes", false, "Drop unmatched quotes and brackets from the generated discussion before synthesis")
+	catchphrases := flag.Bool("catchphrases", false, "Hosts open and close each episode with their signature catchphrases")
+	voiceIntro := flag.Bool("voice-intro", false, "Start with each host introducing themselves in their own voice")
+	caCert := flag.String("ca-cert", "", "PEM file with extra CA certificates trusted for OpenAI and article requests (optional)")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "Skip TLS certificate verification for OpenAI and article requests (unsafe)")
+	tagSegments := flag.Bool("tag-segments", false, "Write segment index and host into each segment's ID3 title, for debugging")
+	adjustRounds := flag.Int("adjust-rounds", 0, "Times the model is asked to shorten or lengthen a discussion off -duration, 0 keeps it as generated")
+	durationTolerance := flag.Float64("duration-tolerance", content.DurationTolerance, "Deviation from -duration accepted by -adjust-rounds, as a fraction")
+	fillToTarget := flag.Bool("fill-to-target", false, "Request follow-up turns while the discussion is shorter than the target duration")
+	introHost := flag.String("intro-host", "", "Name of the host asked in the prompt to deliver the article intro (optional)")
+	fallbackVoice := flag.String("default-voice", defaultVoice, "TTS voice of speakers not among the hosts")
+	fallbackGender := flag.String("default-gender", defaultGender, "Gender of speakers not among the hosts: male or female")
+	hostsFile := flag.String("hosts", "", "JSON or YAML file with the hosts, used instead of the built-in ones (optional)")
+	hostWeights := flag.String("host-weights", "", "Relative speaking weights of hosts, e.g. Алексей=2,Мария=1 (optional)")
+	voiceCompare := flag.String("voice-compare", "", "Synthesize one line in each of these comma-separated voices into voice_<name>.mp3 files, then exit")
+	voiceCompareText := flag.String("voice-compare-text", "", "Line for -voice-compare, the first message of the discussion when empty")
+	skipPreflight := flag.Bool("skip-preflight", false, "Skip the tiny TTS request checking the API key and voices before the run")
+	configFile := flag.String("config", "", "Run with the configuration saved by -dump-config, other flags except secrets and -no-cache are ignored")
+	dumpConfigFile := flag.String("dump-config", "", "Save the effective configuration without secrets to this JSON file (optional)")
+	costReport := flag.String("cost-report", "", "Save the token usage and estimated API cost of the run to this JSON file (optional)")
+	progressJSON := flag.String("progress-json", "", "Write progress events as newline-delimited JSON to this file, - for stdout with messages moved to stderr (optional)")
+	pricesFile := flag.String("prices", "", "JSON file with model prices in USD overriding the built-in ones (optional)")
+	streamChat := flag.Bool("stream-chat", true, "Stream the discussion and synthesize its lines while the model writes the rest")
+	estimate := flag.Bool("estimate", false, "Print the projected message count and duration for -duration and exit, without calling the API")
+	logLevel := flag.String("log-level", "info", "Verbosity of progress messages: debug, info, warn or error")
+	quiet := flag.Bool("quiet", false, "Print errors only, same as -log-level error")
 	flag.Parse()
 
-	if *articleURL == "" {
-		log.Fatal("Please provide an article URL with -url")
+	// progress messages go to stdout, debug adds the details of every segment, -quiet keeps the errors only
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		return podcast.Config{}, cliOptions{}, fmt.Errorf("invalid -log-level: %w", err)
 	}
-
-	if *apiKey == "" {
-		// try to get from environment
-		*apiKey = os.Getenv("OPENAI_API_KEY")
-		if *apiKey == "" {
-			log.Fatal("Please provide an OpenAI API key with -apikey or OPENAI_API_KEY environment variable")
-		}
+	if *quiet {
+		level = slog.LevelError
+	}
+	opts := cliOptions{estimate: *estimate, configFile: *configFile, dumpConfigFile: *dumpConfigFile, level: level}
+	if *estimate {
+		return podcast.Config{TargetDuration: *targetDuration, MessagesPerMinute: *messagesPerMinute,
+			SplitEpisodes: *splitEpisodes}, opts, nil
 	}
 
-	// define hosts with Russian names and distinct characters
-	hosts := []podcast.Host{
-		{
-			Name:      "Алексей",
-			Gender:    "male",
-			Character: "молодой техно-оптимист",
-			Voice:     "onyx",
-		},
-		{
-			Name:      "Мария",
-			Gender:    "female",
-			Character: "аналитик, любит данные",
-			Voice:     "nova",
-		},
-		{
-			Name:      "Дмитрий",
-			Gender:    "male",
-			Character: "скептик, видел всякое",
-			Voice:     "echo",
-		},
+	hosts, err := configuredHosts(*hostsFile, *hostWeights)
+	if err != nil {
+		return podcast.Config{}, opts, fmt.Errorf("invalid hosts: %w", err)
+	}
+	fetchHeaders, err := parseHeaders(headerList)
+	if err != nil {
+		return podcast.Config{}, opts, fmt.Errorf("invalid -header value: %w", err)
+	}
+	icecastMount, icecastTargets, err := parseMounts(mountList)
+	if err != nil {
+		return podcast.Config{}, opts, fmt.Errorf("invalid -mount value: %w", err)
+	}
+	adBreak, err := newAdBreak(podcast.AdBreak{AudioFile: *adAudio, Text: *adText, SilenceMs: *adSilenceMs}, *adBreakPos)
+	if err != nil {
+		return podcast.Config{}, opts, fmt.Errorf("invalid -ad-break value: %w", err)
 	}
 
 	config := podcast.Config{
-		Hosts:          hosts,
-		ArticleURL:     *articleURL,
-		IcecastURL:     *icecastURL,
-		IcecastMount:   *icecastMount,
-		IcecastUser:    *icecastUser,
-		IcecastPass:    *icecastPass,
-		OpenAIAPIKey:   *apiKey,
-		TargetDuration: *targetDuration,
-		DryRun:         *dryRun,
-		OutputFile:     *outputFile,
+		Logger:            logging.New(messageOutput(*outputFile, *progressJSON), level),
+		Hosts:             hosts,
+		ArticleURL:        *articleURL,
+		URLList:           *urlList,
+		Checkpoint:        checkpointFile(*urlList, *checkpoint),
+		IcecastURL:        *icecastURL,
+		IcecastMount:      icecastMount,
+		IcecastTargets:    icecastTargets,
+		IcecastUser:       *icecastUser,
+		IcecastPass:       *icecastPass,
+		StreamRetries:     *streamRetries,
+		StreamRetryDelay:  *streamRetryDelay,
+		OpenAIAPIKey:      openAIKey(*apiKey),
+		OpenAIUserAgent:   userAgent(*openAIUserAgent),
+		OpenAIRetries:     *openAIRetries,
+		ParseRetries:      *parseRetries,
+		OpenAIBaseURL:     *baseURL,
+		ChatModel:         *chatModel,
+		FallbackChatModel: *fallbackChatModel,
+		TTSModel:          *ttsModel,
+		TTSBackend:        *ttsBackend,
+		Language:          *language,
+		ChatTimeout:       *chatTimeout,
+		TTSTimeout:        *ttsTimeout,
+		RenderURL:         *renderURL,
+		TargetDuration:    *targetDuration,
+		MessagesPerMinute: *messagesPerMinute,
+		DryRun:            *dryRun,
+		OutputFile:        *outputFile,
+		OutputDir:         *outputDir,
+		Teaser:            *teaser,
+		Audiogram:         *audiogram,
+		AudiogramSize:     *audiogramSize,
+		AdBreak:           adBreak,
+		IntroFile:         *introFile,
+		OutroFile:         *outroFile,
+		MusicFile:         *musicFile,
+		CleanOutput:       *cleanOutput,
+		Artist:            *artist,
+		Album:             *album,
+		CoverFile:         *coverFile,
+		MusicGain:         *musicGain,
+		Normalize:         *normalize,
+		CrossfadeMs:       *crossfadeMs,
+		PauseMs:           *pauseMs,
+		SplitEpisodes:     *splitEpisodes,
+		SampleOnly:        *sampleOnly,
+		ScriptOnly:        *scriptOnly,
+		ScriptOut:         *scriptOut,
+		ScriptFile:        *scriptFile,
+		VoiceCompare:      *voiceCompare,
+		VoiceCompareText:  *voiceCompareText,
+		Preflight:         !*skipPreflight,
+		ConcatMode:        *concatMode,
+		Bitrate:           *bitrate,
+		SampleRate:        *sampleRate,
+		Channels:          *channels,
+		FFmpegPath:        *ffmpegPath,
+		StreamAhead:       *streamAhead,
+		ScriptPDF:         *scriptPDF,
+		OutputTranscript:  *transcript,
+		FeedFile:          *feedFile,
+		FeedURL:           *feedURL,
+		Subtitles:         *subtitles,
+		Chapters:          *chapters,
+		IntroHost:         *introHost,
+		RecommendedLength: *recommendedLength,
+		MaxArticleTokens:  *maxArticleTokens,
+		MaxArticleChars:   *maxArticleChars,
+		FetchDelay:        *fetchDelay,
+		FetchUserAgent:    *fetchUserAgent,
+		FetchHeaders:      fetchHeaders,
+		MaxRedirects:      *maxRedirects,
+		BlockPrivateIPs:   *blockPrivateIPs,
+		CacheDir:          *cacheDir,
+		CacheTTL:          *cacheTTL,
+		NoCache:           *noCache,
+		ControlAddr:       *controlAddr,
+		MaxTTSChars:       *maxTTSChars,
+		ReduceFillers:     *reduceFillers,
+		MaxConsecutive:    *maxConsecutive,
+		DefaultVoice:      *fallbackVoice,
+		DefaultGender:     *fallbackGender,
+		TTSCharsMode:      *ttsCharsMode,
+		VoiceIntro:        *voiceIntro,
+		Catchphrases:      *catchphrases,
+		BalanceQuotes:     *balanceQuotes,
+		StreamFormat:      *streamFormat,
+		FillToTarget:      *fillToTarget,
+		AdjustRounds:      *adjustRounds,
+		DurationTolerance: *durationTolerance,
+		StreamChat:        *streamChat,
+		CostReport:        *costReport,
+		ProgressJSON:      *progressJSON,
+		PricesFile:        *pricesFile,
+		CACert:            *caCert,
+		SkipTLSVerify:     *insecureSkipVerify,
+		TagSegments:       *tagSegments,
+		WorkDir:           *workDir,
+		ResumeStream:      *resumeFrom != -1,
+		ResumeFromSegment: *resumeFrom,
+		ResumeDir:         *resumeDir,
+		Concurrency: podcast.ResolveConcurrency(*concurrency, podcast.ConcurrencyConfig{
+			Fetch: *fetchConcurrency, TTS: *ttsConcurrency, FFmpeg: *ffmpegConcurrency,
+		}),
 	}
-
-	// run the application
-	if err := run(config); err != nil {
-		log.Fatalf("Application error: %v", err)
+	if *icecastCredentials != "" {
+		if config.IcecastUser, config.IcecastPass, err = readIcecastCredentials(*icecastCredentials); err != nil {
+			return podcast.Config{}, opts, fmt.Errorf("invalid -icecast-credentials file: %w", err)
+		}
 	}
+	return config, opts, requireInput(config, *configFile)
 }
 
-func run(config podcast.Config) error {
-	// create services
-	articleFetcher := content.NewHTTPArticleFetcher(nil)
-	openAI := ai.NewOpenAIService(config.OpenAIAPIKey, nil)
-	audioProcessor := audio.NewFFmpegAudioProcessor()
-
-	return runWithDependencies(config, articleFetcher, openAI, audioProcessor)
+// requireInput checks the flags give something to discuss and the OpenAI API key, a config file has the input
+func requireInput(config podcast.Config, configFile string) error {
+	noVoiceCompare := config.VoiceCompare == "" || config.VoiceCompareText == ""
+	if configFile == "" && config.ArticleURL == "" && config.URLList == "" && config.ScriptFile == "" && noVoiceCompare {
+		return errors.New("please provide an article URL with -url, a file of URLs with -url-list or a discussion with -script")
+	}
+	if config.OpenAIAPIKey == "" {
+		return errors.New("please provide an OpenAI API key with -apikey or OPENAI_API_KEY environment variable")
+	}
+	return nil
 }
 
-func runWithDependencies(config podcast.Config, articleFetcher ArticleFetcher, openAI OpenAIClient, audioProcessor AudioProcessor) error {
-	// 1. Fetch and extract article text
-	articleText, title, err := articleFetcher.Fetch(config.ArticleURL)
-	if err != nil {
-		return fmt.Errorf("error fetching article: %w", err)
+// applyConfigFiles replaces the config with the one loaded from -config, keeping the secrets given with flags
+// or the environment, then saves the resulting config to -dump-config. empty paths are skipped.
+func applyConfigFiles(config podcast.Config, configFile, dumpFile string) (podcast.Config, error) {
+	logger := loggerOf(config.Logger)
+	if configFile != "" {
+		loaded, err := loadConfig(configFile)
+		if err != nil {
+			return config, err
+		}
+		loaded.OpenAIAPIKey, loaded.IcecastPass = config.OpenAIAPIKey, config.IcecastPass
+		loaded.FetchHeaders = restoreHeaders(loaded.FetchHeaders, config.FetchHeaders, logger)
+		loaded.NoCache = loaded.NoCache || config.NoCache // -no-cache overrides the cache of a saved config
+		loaded.Logger = config.Logger
+		config = loaded
 	}
+	if dumpFile != "" {
+		if err := dumpConfig(dumpFile, config); err != nil {
+			return config, err
+		}
+		logger.Info("Configuration saved", "file", dumpFile)
+	}
+	return config, nil
+}
 
-	fmt.Printf("Successfully fetched article: %s\n", title)
+// configDump is the file of -dump-config: the config and the voices the speakers are synthesized with.
+// the voices are derived from the config for reference, -config ignores them.
+type configDump struct {
+	podcast.Config
+	HostMap     map[string]podcast.HostInfo // gender, voice and TTS model of every configured host
+	DefaultHost podcast.HostInfo            // gender, voice and TTS model of a speaker who isn't a configured host
+}
 
-	// 2. Generate discussion using LLM
-	fmt.Printf("Generating a %d-minute podcast discussion...\n", config.TargetDuration)
-	discussionParams := podcast.GenerateDiscussionParams{
-		ArticleText:    articleText,
-		Title:          title,
-		Hosts:          config.Hosts,
-		TargetDuration: config.TargetDuration,
+// dumpConfig writes the config as indented JSON with the OpenAI API key, the Icecast password and the values
+// of the -header headers left empty, so the file can be shared along with the episode and loaded with -config
+// to reproduce the run. the resolved host map is added to tell which voice a speaker got.
+func dumpConfig(path string, config podcast.Config) error {
+	config.OpenAIAPIKey, config.IcecastPass = "", ""
+	if config.FetchHeaders != nil {
+		headers := make(map[string]string, len(config.FetchHeaders))
+		for name := range config.FetchHeaders {
+			headers[name] = "" // cookies and tokens are secrets too
+		}
+		config.FetchHeaders = headers
 	}
-	discussion, err := openAI.GenerateDiscussion(discussionParams)
+	dump := configDump{Config: config}
+	dump.HostMap, dump.DefaultHost = resolvedHosts(config)
+	data, err := json.MarshalIndent(dump, "", "  ")
 	if err != nil {
-		return fmt.Errorf("error generating discussion: %w", err)
+		return fmt.Errorf("failed to encode config: %w", err)
 	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
 
-	fmt.Printf("Generated discussion with %d messages\n", len(discussion.Messages))
-
-	// 3. Generate speech and stream/play/save
-	generateParams := podcast.GenerateAndStreamParams{
-		Discussion: discussion,
-		Config:     config,
+// restoreHeaders returns the headers of a loaded config with the values given by -header. a header left empty
+// by dumpConfig and not given again is dropped, an empty Cookie or Authorization would be sent otherwise.
+func restoreHeaders(loaded, flags map[string]string, logger *slog.Logger) map[string]string {
+	if len(loaded) == 0 && len(flags) == 0 {
+		return nil
 	}
-	if config.DryRun || config.OutputFile != "" {
-		err = generateAndPlayLocally(generateParams, openAI, audioProcessor)
-		if err != nil {
-			return fmt.Errorf("error playing podcast locally: %w", err)
-		}
-	} else {
-		err = generateAndStreamToIcecast(generateParams, openAI, audioProcessor)
-		if err != nil {
-			return fmt.Errorf("error streaming podcast: %w", err)
+	headers := make(map[string]string, len(loaded)+len(flags))
+	for name, value := range loaded {
+		if value == "" {
+			if _, ok := flags[name]; !ok {
+				logger.Warn("Header of the saved config has no value, pass it with -header", "header", name)
+			}
+			continue
 		}
+		headers[name] = value
 	}
+	maps.Copy(headers, flags)
+	return headers
+}
 
-	return nil
+// loadConfig reads a config saved by dumpConfig, unknown fields are rejected to catch typos in edited files
+func loadConfig(path string) (podcast.Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the command line
+	if err != nil {
+		return podcast.Config{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	var dump configDump
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&dump); err != nil {
+		return podcast.Config{}, fmt.Errorf("failed to decode config from %s: %w", path, err)
+	}
+	return dump.Config, nil
 }
 
-// generateAndStreamToIcecast generates speech for each message and streams to Icecast
-func generateAndStreamToIcecast(params podcast.GenerateAndStreamParams, openAI OpenAIClient, audioProcessor AudioProcessor) error {
-	// create text processor
-	textProcessor := content.NewTextProcessor()
+// newAdBreak sets the position of an enabled ad break from the -ad-break value, a disabled one is returned as is
+func newAdBreak(adBreak podcast.AdBreak, position string) (podcast.AdBreak, error) {
+	if !adBreak.Enabled() {
+		return adBreak, nil
+	}
+	var err error
+	adBreak.Position, adBreak.AfterMinutes, err = parseAdBreakPosition(position)
+	return adBreak, err
+}
 
-	// map host names to their gender and voice
-	hostMap := podcast.CreateHostMap(params.Config.Hosts)
+// checkpointFile returns the -checkpoint file, <url-list>.done by default when a URL list is processed
+func checkpointFile(urlList, checkpoint string) string {
+	if urlList != "" && checkpoint == "" {
+		return urlList + ".done"
+	}
+	return checkpoint
+}
+
+// userAgent returns the User-Agent given with -openai-user-agent, or ai-podcast/<revision> without it
+func userAgent(agent string) string {
+	if agent != "" {
+		return agent
+	}
+	return content.OpenAIUserAgent + "/" + revision
+}
 
-	// estimate total discussion duration
-	totalEstimatedDuration := textProcessor.EstimateTotalDuration(params.Discussion.Messages)
-	fmt.Printf("Estimated podcast duration: %.1f minutes\n", totalEstimatedDuration/60.0)
+// parseHeaders parses "Name: value" headers of -header into a map, a repeated name keeps the last value
+func parseHeaders(list []string) (map[string]string, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(list))
+	for _, header := range list {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
 
-	// calculate speech speed
-	speechSpeed := textProcessor.CalculateSpeechSpeed(totalEstimatedDuration, params.Config.TargetDuration)
-	if speechSpeed != 1.0 {
-		fmt.Printf("Adjusting speech speed to %.2f to match target duration\n", speechSpeed)
+// parseMounts parses "/path" or "/path:bitrate" mounts of -mount. the first mount is returned as the primary one,
+// the targets list every mount only when there are several or a bitrate is set, one plain mount needs no list.
+func parseMounts(list []string) (mount string, targets []podcast.IcecastTarget, err error) {
+	if len(list) == 0 {
+		return defaultMount, nil, nil
+	}
+	targets = make([]podcast.IcecastTarget, 0, len(list))
+	withBitrate := false
+	for _, value := range list {
+		target := podcast.IcecastTarget{Mount: strings.TrimSpace(value)}
+		if i := strings.LastIndex(value, ":"); i >= 0 {
+			target = podcast.IcecastTarget{Mount: strings.TrimSpace(value[:i]), Bitrate: strings.TrimSpace(value[i+1:])}
+			if target.Bitrate == "" {
+				return "", nil, fmt.Errorf("invalid mount %q, expected /path or /path:bitrate", value)
+			}
+			withBitrate = true
+		}
+		if target.Mount == "" {
+			return "", nil, fmt.Errorf("invalid mount %q, expected /path or /path:bitrate", value)
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 1 && !withBitrate {
+		return targets[0].Mount, nil, nil
 	}
+	return targets[0].Mount, targets, nil
+}
 
-	// create a temporary directory to store the audio segments
-	tempDir, err := os.MkdirTemp("", "podcast")
+// openAIKey returns the key given with -apikey, or the OPENAI_API_KEY environment variable without it
+func openAIKey(apiKey string) string {
+	if apiKey != "" {
+		return apiKey
+	}
+	return os.Getenv("OPENAI_API_KEY")
+}
+
+func run(ctx context.Context, config podcast.Config) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	config, closeProgress, err := setupOutputs(config)
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return err
 	}
-	defer os.RemoveAll(tempDir)
+	defer closeProgress()
 
-	// generate speech for all messages
-	segmentsParams := podcast.GenerateSpeechSegmentsParams{
-		Messages: params.Discussion.Messages,
-		HostMap:  hostMap,
-		TempDir:  tempDir,
+	// create services
+	fetchClient, openAIClient, err := httpClients(config)
+	if err != nil {
+		return err
 	}
-	audioFiles, err := generateSpeechSegments(segmentsParams, openAI)
+	articleFetcher := newArticleFetcher(config, fetchClient)
+	articleFetcher.SetContext(ctx)
+	openAI, err := newOpenAIService(config, openAIClient)
 	if err != nil {
 		return err
 	}
+	openAI.SetContext(ctx)
+	defer func() { reportCost(openAI.CostReport(), config) }()
+	audioProcessor, err := newAudioProcessor(config)
+	if err != nil {
+		return err
+	}
+	audioProcessor.SetContext(ctx)
+	if err := checkAudioDependencies(config, audioProcessor); err != nil {
+		return err
+	}
 
-	// create concat file for ffmpeg
-	concatFile, err := audio.CreateConcatFile(tempDir, audioFiles)
+	if config.ControlAddr != "" {
+		var stopControl func()
+		config, stopControl = startControl(config)
+		defer stopControl()
+	}
+
+	if config.URLList != "" {
+		if config.OutputFile == stdoutOutput {
+			return errors.New("-mp3 - writes a single episode to stdout, it can't be combined with -url-list")
+		}
+		return runURLList(ctx, config, articleFetcher, openAI, audioProcessor)
+	}
+	return runWithDependencies(ctx, config, articleFetcher, openAI, audioProcessor)
+}
+
+// setupOutputs sets the destinations of the messages, of the audio of -mp3 - and of the -progress-json events.
+// the returned function closes the progress output.
+func setupOutputs(config podcast.Config) (podcast.Config, func(), error) {
+	if config.MessageOut == nil {
+		config.MessageOut = messageOutput(config.OutputFile, config.ProgressJSON)
+	}
+	if config.OutputFile == stdoutOutput {
+		config.AudioOut = os.Stdout // stdout carries the audio only, MessageOut is stderr then
+	}
+	if config.ProgressJSON == "" {
+		return config, func() {}, nil
+	}
+	out, closeProgress, err := progressOutput(config.ProgressJSON)
 	if err != nil {
-		return fmt.Errorf("failed to create concat file: %w", err)
+		return config, nil, err
 	}
+	config.Progress = progress.New(out)
+	return config, closeProgress, nil
+}
 
-	// stream to Icecast
-	fmt.Printf("Streaming to Icecast server at %s%s...\n", params.Config.IcecastURL, params.Config.IcecastMount)
-	err = audioProcessor.StreamFromConcat(concatFile, params.Config)
+// httpClients returns the clients of the article fetches and of the OpenAI requests with the custom TLS transport,
+// nil clients keep the defaults when no TLS setting is given
+func httpClients(config podcast.Config) (*http.Client, ai.HTTPClient, error) {
+	transport, err := tlsconf.Transport(config.CACert, config.SkipTLSVerify)
 	if err != nil {
-		return fmt.Errorf("failed to stream from concat: %w", err)
+		return nil, nil, fmt.Errorf("invalid TLS settings: %w", err)
 	}
+	if transport == nil {
+		return nil, nil, nil
+	}
+	if config.SkipTLSVerify {
+		loggerOf(config.Logger).Warn("TLS certificate verification is disabled")
+	}
+	fetchClient := &http.Client{Timeout: content.FetchHTTPTimeout, Transport: transport}
+	// OpenAI requests get per-attempt deadlines of -chat-timeout and -tts-timeout
+	return fetchClient, &http.Client{Transport: transport}, nil
+}
 
-	fmt.Println("Podcast streaming completed successfully!")
-	return nil
+// newArticleFetcher makes the article fetcher with the user agent, headers, cache, limits and render service
+// of the config
+func newArticleFetcher(config podcast.Config, client *http.Client) *content.HTTPArticleFetcher {
+	fetchOptions := []content.FetcherOption{content.WithUserAgent(config.FetchUserAgent), content.WithHeaders(config.FetchHeaders),
+		content.WithLogger(config.Logger)}
+	if !config.NoCache {
+		fetchOptions = append(fetchOptions, content.WithCache(config.CacheDir, config.CacheTTL))
+	}
+	if config.BlockPrivateIPs {
+		fetchOptions = append(fetchOptions, content.WithBlockPrivateIPs())
+	}
+	articleFetcher := content.NewHTTPArticleFetcher(client, fetchOptions...)
+	if config.RenderURL != "" {
+		articleFetcher.SetRenderURL(config.RenderURL)
+	}
+	articleFetcher.SetRecommendedLength(config.RecommendedLength)
+	articleFetcher.SetMaxArticleTokens(config.MaxArticleTokens)
+	articleFetcher.SetMaxArticleChars(config.MaxArticleChars)
+	articleFetcher.SetFetchDelay(config.FetchDelay)
+	articleFetcher.SetMaxRedirects(config.MaxRedirects)
+	return articleFetcher
 }
 
-// generateSpeechSegments generates speech for all messages in the discussion
-func generateSpeechSegments(params podcast.GenerateSpeechSegmentsParams, openAI OpenAIClient) ([]string, error) {
-	audioFiles := make([]string, 0, len(params.Messages))
+// checkAudioDependencies fails the run on a missing ffmpeg or player before any API call is paid for,
+// a script needs neither
+func checkAudioDependencies(config podcast.Config, audioProcessor *audio.FFmpegAudioProcessor) error {
+	if config.ScriptOnly {
+		return nil
+	}
+	return audioProcessor.CheckDependencies(config.DryRun || (config.SampleOnly && config.OutputFile == ""))
+}
 
-	for i, msg := range params.Messages {
-		// get voice for the host
-		voice := "nova" // default
-		if info, ok := params.HostMap[msg.Host]; ok {
-			voice = info.Voice
+// startControl serves the control endpoint at config.ControlAddr and returns the config feeding the stream
+// segment by segment, as pausing needs, with the function stopping the server
+func startControl(config podcast.Config) (podcast.Config, func()) {
+	logger := loggerOf(config.Logger)
+	ctrl := control.New(config.Logger)
+	server := &http.Server{
+		Addr:              config.ControlAddr,
+		Handler:           ctrl.Handler(),
+		ReadHeaderTimeout: content.ControlReadHeaderTimeout,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Control endpoint failed", "err", err)
 		}
+	}()
+	logger.Info("Control endpoint listening, POST /pause, POST /resume, GET /status", "addr", config.ControlAddr)
 
-		fmt.Printf("Generating speech for %s (message %d/%d)...\n",
-			msg.Host, i+1, len(params.Messages))
-
-		// generate speech with OpenAI TTS
-		audioData, err := openAI.GenerateSpeech(msg.Content, voice)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate speech for message %d: %w", i, err)
-		}
+	config.Paused = ctrl.Paused
+	if config.StreamAhead <= 0 {
+		config.StreamAhead = content.PreGeneratedSegmentsBuffer
+	}
+	return config, func() { _ = server.Close() }
+}
 
-		// create a file for the audio
-		filename := fmt.Sprintf("%s/segment_%03d.mp3", params.TempDir, i)
-		err = os.WriteFile(filename, audioData, 0o600)
-		if err != nil {
-			return nil, fmt.Errorf("failed to write audio data: %w", err)
-		}
-		audioFiles = append(audioFiles, filename)
+// loggerOf returns the logger, the default one when it's not set, e.g. in a config built without main
+func loggerOf(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
 	}
+	return logger
+}
 
-	return audioFiles, nil
+// messageOutput returns the destination of progress messages, reports and ffmpeg output: stdout,
+// or stderr when stdout carries the audio of -mp3 - or the events of -progress-json -
+func messageOutput(outputFile, progressJSON string) io.Writer {
+	if outputFile == stdoutOutput || progressJSON == stdoutOutput {
+		return os.Stderr
+	}
+	return os.Stdout
 }
 
-// generateAndPlayLocally generates speech for each message and plays it locally
-func generateAndPlayLocally(params podcast.GenerateAndStreamParams, openAI OpenAIClient, audioProcessor AudioProcessor) error {
-	startTime := time.Now()
-	fmt.Println("Starting local generation/playback...")
+// progressOutput opens the -progress-json destination, with "-" the events go to stdout
+func progressOutput(path string) (out io.Writer, closeFn func(), err error) {
+	if path == stdoutOutput {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path) // #nosec G304 -- output path is provided by the user
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create progress file: %w", err)
+	}
+	return f, func() { _ = f.Close() }, nil
+}
 
-	// create a temporary directory to store the audio segments
-	tempDir, err := os.MkdirTemp("", "podcast")
+// newOpenAIService creates the OpenAI service with the API, models, retries, timeouts and prices of the config
+func newOpenAIService(config podcast.Config, client ai.HTTPClient) (*ai.OpenAIService, error) {
+	prices, err := ai.LoadPrices(config.PricesFile)
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, fmt.Errorf("invalid -prices: %w", err)
 	}
-	defer os.RemoveAll(tempDir)
-	fmt.Printf("Created temporary directory: %s\n", tempDir)
+	if config.ChatTimeout < 0 {
+		return nil, fmt.Errorf("invalid -chat-timeout %s, must not be negative", config.ChatTimeout)
+	}
+	if config.TTSTimeout < 0 {
+		return nil, fmt.Errorf("invalid -tts-timeout %s, must not be negative", config.TTSTimeout)
+	}
+	openAI := ai.NewOpenAIService(config.OpenAIAPIKey, client)
+	if config.TTSBackend != "" {
+		ttsBackend, err := ai.ParseTTSBackend(config.TTSBackend)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -tts-backend: %w", err)
+		}
+		openAI.SetTTSBackend(ttsBackend)
+	}
+	if config.OpenAIUserAgent != "" {
+		openAI.SetUserAgent(config.OpenAIUserAgent)
+	}
+	openAI.SetLogger(config.Logger)
+	openAI.SetBaseURL(config.OpenAIBaseURL)
+	openAI.SetMaxRetries(config.OpenAIRetries)
+	openAI.SetParseRetries(config.ParseRetries)
+	openAI.SetMessagesPerMinute(config.MessagesPerMinute)
+	openAI.SetLanguage(episodeLanguage(config.Language))
+	openAI.SetVoiceStyles(voiceStyles(config.Hosts))
+	openAI.SetChatModel(config.ChatModel)
+	openAI.SetFallbackChatModel(config.FallbackChatModel)
+	openAI.SetTTSModel(config.TTSModel)
+	openAI.SetChatTimeout(config.ChatTimeout)
+	openAI.SetTTSTimeout(config.TTSTimeout)
+	openAI.SetPrices(prices)
+	return openAI, nil
+}
 
-	// map host names to their gender and voice
-	hostMap := podcast.CreateHostMap(params.Config.Hosts)
+// voiceStyles maps the voices of the hosts to their characters, the speaking style of voices without a built-in one.
+// when hosts share a voice, the character of the first one is used.
+func voiceStyles(hosts []podcast.Host) map[string]string {
+	styles := make(map[string]string, len(hosts))
+	for _, host := range hosts {
+		if _, ok := styles[host.Voice]; !ok && strings.TrimSpace(host.Character) != "" {
+			styles[host.Voice] = host.Character
+		}
+	}
+	return styles
+}
 
-	// create channels for communication between main thread and background workers
-	requestChan := make(chan podcast.SpeechGenerationRequest, len(params.Discussion.Messages))
-	resultChan := make(chan podcast.SpeechSegment, len(params.Discussion.Messages))
-	stopChan := make(chan struct{})
+// reportCost prints the estimated cost of the API requests made during the run and saves it to -cost-report.
+// it runs when the pipeline fails too, the requests made until then are billed anyway.
+func reportCost(report ai.CostReport, config podcast.Config) {
+	logger := loggerOf(config.Logger)
+	if len(report.Models) == 0 {
+		return
+	}
+	printCostReport(config.MessageOut, report)
+	if config.CostReport == "" {
+		return
+	}
+	if err := saveCostReport(config.CostReport, report); err != nil {
+		logger.Warn("Can't save the cost report", "err", err)
+		return
+	}
+	logger.Info("Cost report saved", "file", config.CostReport)
+}
 
-	// create a buffer for pre-generated segments
-	segmentBuffer := make([]podcast.SpeechSegment, 0, content.PreGeneratedSegmentsBuffer)
-	bufferMutex := sync.Mutex{}
+// printCostReport writes the total estimated cost with the usage and cost of every model
+func printCostReport(w io.Writer, report ai.CostReport) {
+	fmt.Fprintf(w, "\nEstimated API cost: $%.4f\n", report.TotalUSD)
+	for _, model := range report.Models {
+		usage := fmt.Sprintf("%d prompt and %d completion tokens", model.PromptTokens, model.CompletionTokens)
+		if model.TTSChars > 0 {
+			usage += fmt.Sprintf(", %d characters of speech", model.TTSChars)
+		}
+		cost := fmt.Sprintf("$%.4f", model.CostUSD)
+		if !model.Priced {
+			cost = "no price, add the model with -prices"
+		}
+		fmt.Fprintf(w, "  %s: %d requests, %s, %s\n", model.Model, model.Requests, usage, cost)
+	}
+}
 
-	// start background worker for speech generation
-	fmt.Println("Starting background worker for speech generation...")
-	workerParams := podcast.SpeechGenerationWorkerParams{
-		RequestChan: requestChan,
-		ResultChan:  resultChan,
-		StopChan:    stopChan,
+// saveCostReport writes the cost report as indented JSON
+func saveCostReport(path string, report ai.CostReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cost report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write cost report: %w", err)
 	}
-	go speechGenerationWorker(workerParams, openAI)
+	return nil
+}
 
-	// start pre-generating segments
-	fmt.Println("Starting pre-generation of segments...")
-	currentIndex := 0
-	for i := 0; i < content.PreGeneratedSegmentsBuffer && currentIndex < len(params.Discussion.Messages); i++ {
-		msg := params.Discussion.Messages[currentIndex]
-		reqParams := podcast.CreateSpeechRequestParams{
-			Msg:     msg,
-			Index:   currentIndex,
-			HostMap: hostMap,
-			APIKey:  params.Config.OpenAIAPIKey,
+// newAudioProcessor creates the ffmpeg audio processor with the concat mode, stream format, encoding and concurrency
+// of the config
+func newAudioProcessor(config podcast.Config) (*audio.FFmpegAudioProcessor, error) {
+	audioProcessor := audio.NewFFmpegAudioProcessor(audio.WithFFmpegPath(config.FFmpegPath), audio.WithStdout(config.MessageOut),
+		audio.WithLogger(config.Logger))
+	audioProcessor.SetConcurrency(config.Concurrency.FFmpeg)
+	if config.ConcatMode != "" {
+		mode, err := audio.ParseConcatMode(config.ConcatMode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -concat-mode: %w", err)
 		}
-		req := createSpeechRequest(reqParams)
-		fmt.Printf("Requesting generation of message %d from %s...\n", currentIndex, msg.Host)
-		requestChan <- req
-		currentIndex++
+		audioProcessor.SetConcatMode(mode)
+	}
+	if config.StreamFormat != "" {
+		format, err := audio.ParseStreamFormat(config.StreamFormat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -format: %w", err)
+		}
+		audioProcessor.SetStreamFormat(format)
 	}
+	audioProcessor.SetEncoding(audio.Encoding{Bitrate: config.Bitrate, SampleRate: config.SampleRate, Channels: config.Channels})
+	return audioProcessor, nil
+}
 
-	// process messages and play audio
-	processParams := podcast.ProcessSegmentsParams{
-		Discussion:    params.Discussion,
-		Config:        params.Config,
-		RequestChan:   requestChan,
-		ResultChan:    resultChan,
-		StopChan:      stopChan,
-		SegmentBuffer: &segmentBuffer,
-		BufferMutex:   &bufferMutex,
-		CurrentIndex:  &currentIndex,
-		TempDir:       tempDir,
+// runURLList processes every article of the URL list not yet recorded in the checkpoint.
+// each processed URL is recorded right away, so a re-run after a failure resumes with the failed one.
+func runURLList(ctx context.Context, config podcast.Config, articleFetcher ArticleFetcher, openAI OpenAIClient, audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	urls, err := content.ReadURLList(config.URLList)
+	if err != nil {
+		return err
 	}
-	audioFiles, err := processSegments(processParams, audioProcessor)
+	checkpoint, err := content.LoadCheckpoint(config.Checkpoint)
 	if err != nil {
-		close(stopChan)
 		return err
 	}
 
-	close(stopChan)
-	fmt.Println("Finished processing all segments")
-
-	// if output file is specified, concatenate all segments
-	if params.Config.OutputFile != "" {
-		fmt.Printf("\nSaving podcast to %s...\n", params.Config.OutputFile)
-		err = audioProcessor.Concatenate(audioFiles, params.Config.OutputFile)
-		if err != nil {
-			return fmt.Errorf("failed to concatenate audio files: %w", err)
+	if config.Preflight && !config.ScriptOnly {
+		if err := preflightTTS(config, openAI); err != nil {
+			return err
 		}
-		fmt.Printf("Podcast saved to %s\n", params.Config.OutputFile)
+		config.Preflight = false // checked once for the whole list
 	}
 
-	totalDuration := time.Since(startTime)
-	fmt.Printf("\nTotal processing time: %.1f seconds (%.1f minutes)\n", totalDuration.Seconds(), totalDuration.Minutes())
-
-	if params.Config.DryRun {
-		fmt.Println("\nPodcast playback completed successfully!")
-	} else {
-		fmt.Println("\nPodcast generation completed successfully!")
+	if config.Concurrency.Fetch > 1 {
+		var pending []string
+		for _, articleURL := range urls {
+			if !checkpoint.Done(articleURL) {
+				pending = append(pending, articleURL)
+			}
+		}
+		prefetcher := newPrefetchFetcher(articleFetcher, pending, config.Concurrency.Fetch)
+		defer prefetcher.Stop()
+		articleFetcher = prefetcher
+	}
+
+	for i, articleURL := range urls {
+		if checkpoint.Done(articleURL) {
+			logger.Info("Skipping already processed article", "url", articleURL)
+			continue
+		}
+		logger.Info("Processing article", "article", fmt.Sprintf("%d/%d", i+1, len(urls)), "url", articleURL)
+
+		articleConfig := config
+		articleConfig.ArticleURL = articleURL
+		if config.OutputDir == "" {
+			// with -output-dir every article gets its own folder, files need no numbers
+			articleConfig.OutputFile = numberedOutputFile(config.OutputFile, i+1)
+			articleConfig.ScriptPDF = numberedOutputFile(config.ScriptPDF, i+1)
+			articleConfig.ScriptOut = numberedOutputFile(config.ScriptOut, i+1)
+			articleConfig.OutputTranscript = numberedOutputFile(config.OutputTranscript, i+1)
+			articleConfig.Audiogram = numberedOutputFile(config.Audiogram, i+1)
+			articleConfig.CleanOutput = numberedOutputFile(config.CleanOutput, i+1)
+		}
+		if err := runWithDependencies(ctx, articleConfig, articleFetcher, openAI, audioProcessor); err != nil {
+			return fmt.Errorf("article %s: %w", articleURL, err)
+		}
+		if err := checkpoint.Mark(articleURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefetchFetcher fetches articles of a URL list ahead in parallel while episodes are generated one by one.
+// the articles are taken in list order, URLs not prefetched are fetched on demand.
+type prefetchFetcher struct {
+	fetcher ArticleFetcher
+	results map[string]chan fetchedArticle
+	ahead   chan struct{} // a slot per article fetched or being fetched and not taken yet
+	stop    chan struct{}
+}
+
+// fetchedArticle is the outcome of a prefetched ArticleFetcher.Fetch call
+type fetchedArticle struct {
+	content, title string
+	err            error
+}
+
+// newPrefetchFetcher starts fetching urls in list order with up to workers requests at a time,
+// at most workers articles ahead of the one taken last, so a long list isn't fetched all at once
+func newPrefetchFetcher(fetcher ArticleFetcher, urls []string, workers int) *prefetchFetcher {
+	p := &prefetchFetcher{fetcher: fetcher, results: make(map[string]chan fetchedArticle, len(urls)),
+		ahead: make(chan struct{}, workers), stop: make(chan struct{})}
+	queue := make(chan string, len(urls))
+	for _, u := range urls {
+		if _, ok := p.results[u]; ok {
+			continue // duplicate URL, fetched once
+		}
+		p.results[u] = make(chan fetchedArticle, 1)
+		queue <- u
+	}
+	close(queue)
+
+	for range workers {
+		go func() {
+			for u := range queue {
+				select {
+				case <-p.stop:
+					return
+				case p.ahead <- struct{}{}:
+				}
+				content, title, err := fetcher.Fetch(u)
+				p.results[u] <- fetchedArticle{content: content, title: title, err: err}
+			}
+		}()
+	}
+	return p
+}
+
+// Fetch returns the prefetched article, waiting for it when the fetch is still in progress.
+// taking the article frees its slot, so the next article of the list is fetched.
+func (p *prefetchFetcher) Fetch(articleURL string) (content, title string, err error) {
+	ch, ok := p.results[articleURL]
+	if !ok {
+		return p.fetcher.Fetch(articleURL)
+	}
+	select {
+	case article := <-ch:
+		<-p.ahead
+		return article.content, article.title, article.err
+	case <-p.stop:
+		return "", "", errors.New("prefetch stopped")
+	}
+}
+
+// Stop makes workers skip articles not fetched yet
+func (p *prefetchFetcher) Stop() {
+	close(p.stop)
+}
+
+func runWithDependencies(ctx context.Context, config podcast.Config, articleFetcher ArticleFetcher, openAI OpenAIClient, audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+	if config.ResumeStream {
+		return resumeStream(config, audioProcessor)
+	}
+	if config.VoiceCompare != "" {
+		return compareVoices(config, articleFetcher, openAI)
+	}
+	if config.Preflight && !config.ScriptOnly {
+		if err := preflightTTS(config, openAI); err != nil {
+			return err
+		}
+	}
+	if config.ScriptFile != "" {
+		return runScript(ctx, config, openAI, audioProcessor)
+	}
+
+	// 1. Fetch and extract article text
+	config.Progress.Start(progress.StageFetch, 1)
+	articleText, title, err := articleFetcher.Fetch(config.ArticleURL)
+	if err != nil {
+		return fmt.Errorf("error fetching article: %w", err)
+	}
+	config.Progress.End(progress.StageFetch, 1)
+
+	logger.Info("Successfully fetched article", "title", title)
+
+	if config.OutputDir != "" {
+		if config, err = withOutputDir(config, title); err != nil {
+			return err
+		}
+	}
+
+	if config.SplitEpisodes <= 1 {
+		discussionParams := podcast.GenerateDiscussionParams{
+			ArticleText:       articleText,
+			Title:             title,
+			Hosts:             config.Hosts,
+			TargetDuration:    config.TargetDuration,
+			IntroHost:         config.IntroHost,
+			FillToTarget:      config.FillToTarget,
+			BalanceQuotes:     config.BalanceQuotes,
+			AdjustRounds:      config.AdjustRounds,
+			DurationTolerance: config.DurationTolerance,
+		}
+		return runEpisode(ctx, config, discussionParams, openAI, audioProcessor)
+	}
+
+	// split the article into a miniseries, each part becomes its own numbered episode
+	parts := content.NewTextProcessor().SplitIntoParts(articleText, config.SplitEpisodes)
+	logger.Info("Splitting article into episodes", "episodes", len(parts))
+	for i, part := range parts {
+		episodeConfig := config
+		episodeConfig.OutputFile = numberedOutputFile(config.OutputFile, i+1)
+		episodeConfig.ScriptPDF = numberedOutputFile(config.ScriptPDF, i+1)
+		episodeConfig.ScriptOut = numberedOutputFile(config.ScriptOut, i+1)
+		episodeConfig.OutputTranscript = numberedOutputFile(config.OutputTranscript, i+1)
+		episodeConfig.Audiogram = numberedOutputFile(config.Audiogram, i+1)
+		episodeConfig.CleanOutput = numberedOutputFile(config.CleanOutput, i+1)
+		discussionParams := podcast.GenerateDiscussionParams{
+			ArticleText:       part,
+			Title:             title,
+			Hosts:             config.Hosts,
+			TargetDuration:    config.TargetDuration,
+			Part:              i + 1,
+			TotalParts:        len(parts),
+			IntroHost:         config.IntroHost,
+			FillToTarget:      config.FillToTarget,
+			BalanceQuotes:     config.BalanceQuotes,
+			AdjustRounds:      config.AdjustRounds,
+			DurationTolerance: config.DurationTolerance,
+		}
+		logger.Info("Generating episode", "episode", fmt.Sprintf("%d/%d", i+1, len(parts)))
+		if err := runEpisode(ctx, episodeConfig, discussionParams, openAI, audioProcessor); err != nil {
+			return fmt.Errorf("episode %d: %w", i+1, err)
+		}
+		if config.SampleOnly {
+			break // the sample comes from the first episode only
+		}
+	}
+
+	return nil
+}
+
+// validateConfig checks flag values and combinations before anything is fetched or generated,
+// every problem is reported at once, so they aren't fixed one at a time
+func validateConfig(config podcast.Config) error {
+	var errs []error
+	if err := validateIntroHost(config.IntroHost, config.Hosts); err != nil {
+		errs = append(errs, fmt.Errorf("invalid -intro-host: %w", err))
+	}
+	if config.MaxTTSChars > 0 && config.TTSCharsMode != ttsCharsReject && config.TTSCharsMode != ttsCharsTrim {
+		errs = append(errs, fmt.Errorf("invalid -tts-chars-mode %q, expected %s or %s", config.TTSCharsMode, ttsCharsReject, ttsCharsTrim))
+	}
+	// errors.Join skips nil, so the checks returning an error are appended as is
+	errs = append(errs, validateDefaultHost(config.DefaultVoice, config.DefaultGender))
+	if _, err := content.ParseFillerIntensity(config.ReduceFillers); err != nil {
+		errs = append(errs, fmt.Errorf("invalid -reduce-fillers: %w", err))
+	}
+	if _, err := content.ParseLanguage(config.Language); err != nil {
+		errs = append(errs, fmt.Errorf("invalid -language: %w", err))
+	}
+	if config.ScriptFile != "" && config.URLList != "" {
+		errs = append(errs, errors.New("-script voices a single discussion, it can't be combined with -url-list"))
+	}
+	if config.ResumeDir != "" && config.WorkDir != "" {
+		errs = append(errs, errors.New("-resume-dir keeps segments in a directory per discussion, it can't be combined with -work-dir"))
+	}
+	errs = append(errs, validatePacing(config), validateJingles(config), validateOutputs(config))
+	return errors.Join(errs...)
+}
+
+// validatePacing checks the pause between the lines and the rounds adjusting the discussion to the duration
+func validatePacing(config podcast.Config) error {
+	var errs []error
+	if config.PauseMs < 0 {
+		errs = append(errs, fmt.Errorf("invalid -pause-ms %d, must not be negative", config.PauseMs))
+	}
+	if config.AdjustRounds < 0 {
+		errs = append(errs, fmt.Errorf("invalid -adjust-rounds %d, must not be negative", config.AdjustRounds))
+	}
+	if config.AdjustRounds > 0 && (config.DurationTolerance <= 0 || config.DurationTolerance >= 1) {
+		errs = append(errs, fmt.Errorf("invalid -duration-tolerance %g, expected a fraction between 0 and 1, e.g. 0.15", config.DurationTolerance))
+	}
+	return errors.Join(errs...)
+}
+
+// validateOutputs checks the options of files saved along with the episode, every problem is reported at once
+func validateOutputs(config podcast.Config) error {
+	var errs []error
+	savedFile := config.OutputDir != "" || (config.OutputFile != "" && config.OutputFile != stdoutOutput)
+	if config.OutputFile == stdoutOutput && config.OutputDir != "" {
+		errs = append(errs, errors.New("-mp3 - writes the episode to stdout, it can't be combined with -output-dir"))
+	}
+	if config.OutputFile == stdoutOutput && config.SplitEpisodes > 1 {
+		errs = append(errs, errors.New("-mp3 - writes a single episode to stdout, it can't be combined with -split-episodes"))
+	}
+	if config.Teaser > 0 && !savedFile {
+		errs = append(errs, errors.New("-teaser is cut from the saved episode, it requires -mp3 with a file path"))
+	}
+	errs = append(errs, validateSubtitles(config, savedFile))
+	if config.Chapters && !savedFile {
+		errs = append(errs, errors.New("-chapters are added to the saved episode, it requires -mp3 with a file path"))
+	}
+	errs = append(errs, validateMusic(config, savedFile), validateCrossfade(config, savedFile), validateFeed(config, savedFile),
+		validateCover(config, savedFile), validateAudiogram(config, savedFile))
+	return errors.Join(errs...)
+}
+
+// validateSubtitles checks the -subtitles format and that there is a saved episode to put them next to
+func validateSubtitles(config podcast.Config, savedFile bool) error {
+	if config.Subtitles == "" {
+		return nil
+	}
+	if config.Subtitles != content.SubtitlesSRT && config.Subtitles != content.SubtitlesVTT {
+		return fmt.Errorf("invalid -subtitles %q, expected %s or %s", config.Subtitles, content.SubtitlesSRT, content.SubtitlesVTT)
+	}
+	if !savedFile {
+		return errors.New("-subtitles are saved next to the episode, it requires -mp3 with a file path")
+	}
+	return nil
+}
+
+// validateMusic checks the -music bed has a saved episode to be mixed into and -music-gain keeps it under the speech
+func validateMusic(config podcast.Config, savedFile bool) error {
+	if config.MusicFile != "" && !savedFile {
+		return errors.New("-music is mixed into the saved episode, it requires -mp3 with a file path")
+	}
+	if config.MusicGain > 0 {
+		return fmt.Errorf("invalid -music-gain %g, expected 0 dB or lower to keep the music under the speech", config.MusicGain)
+	}
+	if config.CleanOutput != "" && config.MusicFile == "" {
+		return errors.New("-clean-output is the episode without the -music bed, it requires -music")
+	}
+	if config.CleanOutput != "" && filepath.Clean(config.CleanOutput) == filepath.Clean(config.OutputFile) {
+		return errors.New("-clean-output must differ from -mp3, the mixed episode is saved there")
+	}
+	return nil
+}
+
+// validateCrossfade checks -crossfade-ms isn't negative and has a saved episode to be applied to,
+// streams are joined by the concat demuxer without crossfades
+func validateCrossfade(config podcast.Config, savedFile bool) error {
+	if config.CrossfadeMs < 0 {
+		return fmt.Errorf("invalid -crossfade-ms %d, must not be negative", config.CrossfadeMs)
+	}
+	if config.CrossfadeMs > 0 && !savedFile {
+		return errors.New("-crossfade-ms is applied when the episode is saved, it requires -mp3 with a file path")
+	}
+	return nil
+}
+
+// validateFeed checks -feed has a saved episode to publish and -feed-url is an http or https url,
+// RSS requires absolute enclosure urls, so -feed can't go without it
+func validateFeed(config podcast.Config, savedFile bool) error {
+	if config.FeedFile != "" && !savedFile {
+		return errors.New("-feed publishes the saved episode, it requires -mp3 with a file path")
+	}
+	if config.FeedFile != "" && config.FeedURL == "" {
+		return errors.New("-feed requires -feed-url, the public url of the feed directory")
+	}
+	if config.FeedURL == "" {
+		return nil
+	}
+	if config.FeedFile == "" {
+		return errors.New("-feed-url requires -feed")
+	}
+	if u, err := url.Parse(config.FeedURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid -feed-url %q, expected an http or https url", config.FeedURL)
+	}
+	return nil
+}
+
+// validateAudiogram checks the -audiogram extension and -audiogram-size, savedFile reports whether the episode is saved
+func validateAudiogram(config podcast.Config, savedFile bool) error {
+	if config.Audiogram == "" {
+		return nil
+	}
+	if !savedFile {
+		return errors.New("-audiogram is rendered from the saved episode, it requires -mp3 with a file path")
+	}
+	if ext := strings.ToLower(filepath.Ext(config.Audiogram)); ext != ".png" && ext != ".mp4" {
+		return fmt.Errorf("invalid -audiogram %q, expected a .png or .mp4 file", config.Audiogram)
+	}
+	if _, _, err := audio.ParseAudiogramSize(config.AudiogramSize); err != nil {
+		return fmt.Errorf("invalid -audiogram-size: %w", err)
+	}
+	return nil
+}
+
+// validateCover checks the -cover is a jpeg or png picture which can be read and there is a saved episode to attach it to
+func validateCover(config podcast.Config, savedFile bool) error {
+	if config.CoverFile == "" {
+		return nil
+	}
+	if !savedFile {
+		return errors.New("-cover is attached to the saved episode, it requires -mp3 with a file path")
+	}
+	if ext := strings.ToLower(filepath.Ext(config.CoverFile)); ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return fmt.Errorf("invalid -cover %q, expected a .jpg or .png picture", config.CoverFile)
+	}
+	info, err := os.Stat(config.CoverFile)
+	if err != nil {
+		return fmt.Errorf("invalid -cover: %w", err)
+	}
+	if info.IsDir() || info.Size() == 0 {
+		return fmt.Errorf("invalid -cover: %s is not a picture", config.CoverFile)
+	}
+	return nil
+}
+
+// validateJingles checks the -intro, -outro and -music files can be read, so a typo fails the run before anything is generated
+func validateJingles(config podcast.Config) error {
+	jingles := []struct{ flag, file string }{{"-intro", config.IntroFile}, {"-outro", config.OutroFile}, {"-music", config.MusicFile}}
+	for _, jingle := range jingles {
+		if jingle.file == "" {
+			continue
+		}
+		f, err := os.Open(jingle.file) // #nosec G304 -- path comes from the command line
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", jingle.flag, err)
+		}
+		info, err := f.Stat()
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", jingle.flag, err)
+		}
+		if info.IsDir() || info.Size() == 0 {
+			return fmt.Errorf("invalid %s: %s is not an audio file", jingle.flag, jingle.file)
+		}
+	}
+	return nil
+}
+
+// runEpisode generates the discussion for a single episode and streams, plays or saves it.
+// with -resume-dir the generated discussion is kept there and a restarted run voices it again
+// instead of asking the model for a new one, so the segments of the discussion are reused.
+func runEpisode(ctx context.Context, config podcast.Config, discussionParams podcast.GenerateDiscussionParams, openAI OpenAIClient,
+	audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	discussionFile := ""
+	if config.ResumeDir != "" {
+		discussionFile = savedDiscussionFile(config.ResumeDir, discussionParams)
+		if discussion, ok := loadDiscussion(discussionFile, logger); ok {
+			logger.Info("Reusing discussion of a previous run", "file", discussionFile, "messages", len(discussion.Messages))
+			return produceEpisode(ctx, config, discussion, openAI, audioProcessor)
+		}
+	}
+
+	// 2. Generate discussion using LLM
+	logger.Info("Generating podcast discussion", "minutes", config.TargetDuration)
+	config.Progress.Start(progress.StageGenerate, 0)
+	discussion, openAI, err := generateDiscussion(config, discussionParams, openAI)
+	if err != nil {
+		return fmt.Errorf("error generating discussion: %w", err)
+	}
+	config.Progress.End(progress.StageGenerate, len(discussion.Messages))
+	if discussionFile != "" {
+		if err := saveDiscussion(discussionFile, discussion); err != nil {
+			return err
+		}
+	}
+	return produceEpisode(ctx, config, discussion, openAI, audioProcessor)
+}
+
+// savedDiscussionFile returns the file in the resume dir keeping the discussion generated with the params,
+// named by a hash of the article part, the hosts and the duration the discussion is generated from
+func savedDiscussionFile(resumeDir string, params podcast.GenerateDiscussionParams) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%q %q %d/%d %d\n", params.Title, params.ArticleText, params.Part, params.TotalParts, params.TargetDuration)
+	for _, host := range params.Hosts {
+		_, _ = fmt.Fprintf(h, "%q %q %q %q\n", host.Name, host.Gender, host.Character, host.Voice)
+	}
+	return filepath.Join(resumeDir, "discussion_"+hex.EncodeToString(h.Sum(nil))[:16]+".json")
+}
+
+// loadDiscussion reads a discussion kept by saveDiscussion, a missing or broken file isn't reused
+func loadDiscussion(filename string, logger *slog.Logger) (podcast.Discussion, bool) {
+	data, err := os.ReadFile(filename) // #nosec G304 -- discussion file in the resume directory
+	if err != nil {
+		return podcast.Discussion{}, false
+	}
+	var discussion podcast.Discussion
+	if err := json.Unmarshal(data, &discussion); err != nil || len(discussion.Messages) == 0 {
+		logger.Warn("Can't reuse the discussion of a previous run, generating a new one", "file", filename, "err", err)
+		return podcast.Discussion{}, false
+	}
+	return discussion, true
+}
+
+// saveDiscussion keeps the generated discussion in the resume dir for a restarted run
+func saveDiscussion(filename string, discussion podcast.Discussion) error {
+	data, err := json.MarshalIndent(discussion, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding discussion: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o750); err != nil {
+		return fmt.Errorf("error creating resume dir: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return fmt.Errorf("error saving discussion: %w", err)
+	}
+	return nil
+}
+
+// runScript voices the discussion of -script instead of generating one, the article isn't fetched
+func runScript(ctx context.Context, config podcast.Config, openAI OpenAIClient, audioProcessor AudioProcessor) error {
+	discussion, err := loadScript(config.ScriptFile, config.Hosts, loggerOf(config.Logger))
+	if err != nil {
+		return err
+	}
+	loggerOf(config.Logger).Info("Loaded discussion script", "file", config.ScriptFile, "messages", len(discussion.Messages))
+	if config.OutputDir != "" {
+		if config, err = withOutputDir(config, discussion.Title); err != nil {
+			return err
+		}
+	}
+	return produceEpisode(ctx, config, discussion, openAI, audioProcessor)
+}
+
+// loadScript reads a discussion script, "Name: content" lines or a JSON array in the format of the model response,
+// titled by the file name. speakers which aren't configured hosts are reported, they get the default voice.
+func loadScript(filename string, hosts []podcast.Host, logger *slog.Logger) (podcast.Discussion, error) {
+	data, err := os.ReadFile(filename) // #nosec G304 -- path comes from the command line
+	if err != nil {
+		return podcast.Discussion{}, fmt.Errorf("failed to read script: %w", err)
+	}
+	messages, skipped, err := ai.ParseScript(string(data))
+	if err != nil {
+		return podcast.Discussion{}, fmt.Errorf("invalid script %s: %w", filename, err)
+	}
+	if skipped > 0 {
+		logger.Warn("Skipped unparsable lines of the script", "file", filename, "skipped", skipped, "kept", len(messages))
+	}
+
+	hostMap := podcast.CreateHostMap(hosts)
+	unknown := map[string]bool{}
+	for _, msg := range messages {
+		if _, ok := hostMap[msg.Host]; !ok && !unknown[msg.Host] {
+			unknown[msg.Host] = true
+			logger.Warn("Script speaker is not a configured host, the default voice is used", "speaker", msg.Host)
+		}
+	}
+
+	title := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return podcast.Discussion{Title: title, Messages: messages}, nil
+}
+
+// produceEpisode cleans up the discussion and streams, plays or saves it
+func produceEpisode(ctx context.Context, config podcast.Config, discussion podcast.Discussion, openAI OpenAIClient,
+	audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	discussion.Messages = cleanupMessages(discussion.Messages, config)
+
+	if config.ScriptPDF != "" {
+		if err := script.SavePDF(config.ScriptPDF, discussion, config.Hosts, episodeLanguage(config.Language)); err != nil {
+			return fmt.Errorf("error saving script: %w", err)
+		}
+		logger.Info("Script saved", "file", config.ScriptPDF)
+	}
+
+	if config.ScriptOnly {
+		return writeScript(discussion, config)
+	}
+
+	if config.SampleOnly {
+		// keep only the opening message, it's enough to judge voice and tone
+		if len(discussion.Messages) > 1 {
+			discussion.Messages = discussion.Messages[:1]
+		}
+		if config.OutputFile == "" {
+			config.DryRun = true
+		}
+		logger.Info("Sample mode: synthesizing only the first message")
+	}
+
+	var introMessages int
+	var err error
+	if discussion.Messages, introMessages, err = arrangeMessages(discussion.Messages, config); err != nil {
+		return err
+	}
+
+	if err := saveTimedText(discussion, config); err != nil {
+		return err
+	}
+
+	// 3. Generate speech and stream/play/save
+	generateParams := podcast.GenerateAndStreamParams{
+		Discussion:    discussion,
+		Config:        config,
+		IntroMessages: introMessages,
+	}
+	if config.DryRun || config.OutputFile != "" {
+		err = generateAndPlayLocally(generateParams, openAI, audioProcessor)
+		if err != nil {
+			return fmt.Errorf("error playing podcast locally: %w", err)
+		}
+		if config.FeedFile != "" {
+			return addToFeed(discussion, config, audioProcessor)
+		}
+	} else {
+		err = generateAndStreamToIcecast(ctx, generateParams, openAI, audioProcessor)
+		if err != nil {
+			return fmt.Errorf("error streaming podcast: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeScript writes the discussion as "Name: content" lines to -script-out, or to the message output without it
+func writeScript(discussion podcast.Discussion, config podcast.Config) error {
+	if config.ScriptOut == "" {
+		out := config.MessageOut
+		if out == nil {
+			out = os.Stdout
+		}
+		if err := script.WriteDialog(out, discussion.Messages); err != nil {
+			return fmt.Errorf("error writing script: %w", err)
+		}
+		return nil
+	}
+	if err := script.SaveDialog(config.ScriptOut, discussion.Messages); err != nil {
+		return fmt.Errorf("error writing script: %w", err)
+	}
+	loggerOf(config.Logger).Info("Script saved", "file", config.ScriptOut, "messages", len(discussion.Messages))
+	return nil
+}
+
+// cleanupMessages sanitizes the generated messages, assigns the intro host, merges long runs of turns
+// and reduces fillers as configured, then reports hosts whose share of turns or speaking time is off their weights.
+// a hand-written -script is only sanitized, its lines are voiced as written.
+func cleanupMessages(messages []podcast.Message, config podcast.Config) []podcast.Message {
+	logger := loggerOf(config.Logger)
+	tp := content.NewTextProcessor()
+	messages = tp.SanitizeMessages(messages)
+	if config.ScriptFile == "" {
+		messages = rewriteMessages(messages, config)
+	}
+	logger.Info("Generated discussion", "messages", len(messages))
+	for _, d := range tp.CheckTurnWeights(messages, config.Hosts) {
+		logger.Warn("Host turns are off the host weights", "host", d.Host, "turns", fmt.Sprintf("%.0f%%", d.Actual*100),
+			"expected", fmt.Sprintf("%.0f%%", d.Expected*100))
+	}
+	for _, d := range tp.CheckSpeakingShares(messages, config.Hosts) {
+		logger.Warn("Host dominates the speaking time", "host", d.Host, "time", fmt.Sprintf("%.0f%%", d.Actual*100),
+			"expected", fmt.Sprintf("%.0f%%", d.Expected*100))
+	}
+	return messages
+}
+
+// rewriteMessages merges long runs of turns and reduces fillers as configured
+func rewriteMessages(messages []podcast.Message, config podcast.Config) []podcast.Message {
+	logger := loggerOf(config.Logger)
+	tp := content.NewTextProcessor()
+	if config.MaxConsecutive > 0 {
+		merged := tp.MergeConsecutive(messages, config.MaxConsecutive)
+		if removed := len(messages) - len(merged); removed > 0 {
+			logger.Info("Merged turns of the same host", "merged", removed, "max_in_row", config.MaxConsecutive)
+		}
+		messages = merged
+	}
+	if config.ReduceFillers != "" {
+		before := tp.TTSChars(messages)
+		messages = tp.ReduceFillers(messages, content.FillerIntensity(config.ReduceFillers))
+		logger.Info("Filler reduction done", "removed_chars", before-tp.TTSChars(messages))
+	}
+	return messages
+}
+
+// arrangeMessages adds the ad break, voice intros and catchphrases around the discussion, balances quotes
+// of a generated discussion and applies the TTS character cap. it returns the messages with the number of intro messages before the discussion.
+func arrangeMessages(messages []podcast.Message, config podcast.Config) (result []podcast.Message, introMessages int, err error) {
+	if config.AdBreak.Enabled() && !config.SampleOnly {
+		messages = insertAdBreak(messages, config.AdBreak, config.Language)
+	}
+
+	introMessages = 1 // the article intro opens the discussion
+	if config.VoiceIntro && !config.SampleOnly {
+		intros := voiceIntroMessages(config.Hosts, config.Language)
+		messages = append(intros, messages...)
+		introMessages += len(intros)
+	}
+
+	if config.Catchphrases && !config.SampleOnly {
+		intros, outros := catchphraseMessages(config.Hosts)
+		messages = append(append(intros, messages...), outros...)
+		introMessages += len(intros)
+	}
+
+	if messages, err = limitTTSChars(messages, config.MaxTTSChars, config.TTSCharsMode, loggerOf(config.Logger)); err != nil {
+		return nil, 0, err
+	}
+	return messages, introMessages, nil
+}
+
+// saveTimedText saves the JSON transcript and subtitles of the discussion, when enabled
+func saveTimedText(discussion podcast.Discussion, config podcast.Config) error {
+	if config.OutputTranscript != "" {
+		if err := saveTranscript(discussion, config); err != nil {
+			return err
+		}
+	}
+	if config.Subtitles != "" {
+		if err := saveSubtitles(discussion.Messages, config.Subtitles, config.OutputFile, episodeLanguage(config.Language), loggerOf(config.Logger)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSubtitles writes subtitles of the messages next to the episode, e.g. podcast.srt for podcast.mp3
+func saveSubtitles(messages []podcast.Message, format, outputFile string, lang content.Language, logger *slog.Logger) error {
+	subtitles, err := content.BuildSubtitles(messages, format, lang)
+	if err != nil {
+		return fmt.Errorf("error building subtitles: %w", err)
+	}
+	filename := strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "." + format
+	if err := os.WriteFile(filename, []byte(subtitles), 0o600); err != nil {
+		return fmt.Errorf("error saving subtitles: %w", err)
+	}
+	logger.Info("Subtitles saved", "file", filename)
+	return nil
+}
+
+// addToFeed adds the saved episode to the -feed RSS feed, the description links the discussed article.
+// the duration is measured from the saved file, the transcript estimate is used when it can't be.
+func addToFeed(discussion podcast.Discussion, config podcast.Config, audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	transcript := script.NewTranscript(discussion, func(string) string { return "" }, episodeLanguage(config.Language))
+	episode, err := feed.NewEpisode(config.FeedFile, config.OutputFile, config.FeedURL, transcript)
+	if err != nil {
+		return fmt.Errorf("error adding episode to feed: %w", err)
+	}
+	if seconds, err := audioProcessor.Duration(config.OutputFile); err == nil && seconds > 0 {
+		episode.Duration = time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	} else {
+		logger.Warn("Can't measure the episode, the feed duration is estimated", "file", config.OutputFile, "err", err)
+	}
+	episode.Description = config.ArticleURL
+	if episode.Description == "" {
+		episode.Description = discussion.Title
+	}
+
+	channel := feed.Channel{Title: feedTitle, Link: config.FeedURL, Description: feedDescription, Language: string(episodeLanguage(config.Language)),
+		Category: feedCategory}
+	if err := feed.Update(config.FeedFile, channel, episode); err != nil {
+		return fmt.Errorf("error adding episode to feed: %w", err)
+	}
+	logger.Info("Episode added to feed", "file", config.FeedFile)
+	return nil
+}
+
+// saveTranscript writes the JSON transcript of the discussion with the voice of each host
+func saveTranscript(discussion podcast.Discussion, config podcast.Config) error {
+	hostMap := podcast.CreateHostMap(config.Hosts)
+	transcript := script.NewTranscript(discussion, func(host string) string {
+		return lookupHost(hostMap, host, fallbackHost(config)).Voice
+	}, episodeLanguage(config.Language))
+	if err := script.SaveTranscript(config.OutputTranscript, transcript); err != nil {
+		return fmt.Errorf("error saving transcript: %w", err)
+	}
+	loggerOf(config.Logger).Info("Transcript saved", "file", config.OutputTranscript)
+	return nil
+}
+
+// episodeLanguage returns the -language of the episode, Russian for an unknown one, which is rejected by validateConfig
+func episodeLanguage(language string) content.Language {
+	if lang, err := content.ParseLanguage(language); err == nil {
+		return lang
+	}
+	return content.LanguageRussian
+}
+
+// textProcessorFor returns a text processor estimating speech durations in the language
+func textProcessorFor(language string) *content.TextProcessor {
+	tp := content.NewTextProcessor()
+	tp.SetLanguage(episodeLanguage(language))
+	return tp
+}
+
+// generateAndStreamToIcecast generates speech for each message and streams to Icecast
+func generateAndStreamToIcecast(ctx context.Context, params podcast.GenerateAndStreamParams, openAI OpenAIClient, audioProcessor AudioProcessor) error {
+	logger := loggerOf(params.Config.Logger)
+	// map host names to their gender and voice
+	hostMap := podcast.CreateHostMap(params.Config.Hosts)
+
+	// calculate speech speed from the estimated duration
+	speechSpeed := episodeSpeechSpeed(params.Discussion.Messages, params.Config)
+
+	// create a directory to store the audio segments
+	tempDir, cleanup, err := episodeSegmentDir(params)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// the discussion is kept with the segments, -resume-from-segment streams the rest of it the same way
+	if params.Config.WorkDir != "" && params.Config.ResumeDir == "" {
+		if err := saveDiscussion(filepath.Join(tempDir, streamedDiscussionFile), params.Discussion); err != nil {
+			return err
+		}
+	}
+
+	if params.Config.StreamAhead > 0 {
+		if err := streamSegmentsWithBackpressure(params, hostMap, tempDir, speechSpeed, openAI, audioProcessor); err != nil {
+			return err
+		}
+		logger.Info("Podcast streaming completed successfully!")
+		return nil
+	}
+
+	// generate speech for all messages
+	segmentsParams := podcast.GenerateSpeechSegmentsParams{
+		Messages:       params.Discussion.Messages,
+		HostMap:        hostMap,
+		Fallback:       fallbackHost(params.Config),
+		TempDir:        tempDir,
+		TargetDuration: params.Config.TargetDuration,
+		Speed:          speechSpeed,
+		TagSegments:    params.Config.TagSegments,
+		Resume:         params.Config.ResumeDir != "",
+		Concurrency:    params.Config.Concurrency.TTS,
+		Normalize:      params.Config.Normalize,
+		Language:       params.Config.Language,
+		Progress:       params.Config.Progress,
+		Logger:         params.Config.Logger,
+	}
+	audioFiles, err := generateSpeechSegments(ctx, segmentsParams, openAI, audioProcessor)
+	if err != nil {
+		return err
+	}
+
+	audioFiles, err = addSilence(audioFiles, params.Discussion.Messages, params.Config, tempDir, audioProcessor)
+	if err != nil {
+		return err
+	}
+
+	// create concat file for ffmpeg
+	concatFile, err := audio.CreateConcatFile(tempDir, withJingles(audioFiles, params.Config))
+	if err != nil {
+		return fmt.Errorf("failed to create concat file: %w", err)
+	}
+
+	// stream to Icecast
+	logger.Info("Streaming to Icecast server", "server", icecastServers(params.Config))
+	params.Config.Progress.Start(progress.StageStream, len(audioFiles))
+	err = audioProcessor.StreamFromConcat(concatFile, params.Config)
+	if err != nil {
+		return fmt.Errorf("failed to stream from concat: %w", err)
+	}
+	params.Config.Progress.End(progress.StageStream, len(audioFiles))
+
+	logger.Info("Podcast streaming completed successfully!")
+	return nil
+}
+
+// icecastServers returns the server and mount of every Icecast target, with its bitrate when set
+func icecastServers(config podcast.Config) string {
+	servers := make([]string, 0, len(config.Mounts()))
+	for _, target := range config.Mounts() {
+		server := config.IcecastURL + target.Mount
+		if target.Bitrate != "" {
+			server += " at " + target.Bitrate
+		}
+		servers = append(servers, server)
+	}
+	return strings.Join(servers, ", ")
+}
+
+// episodeSegmentDir returns the directory for segment files of the episode. with resume dir set it is
+// a subdirectory named by the discussion key, so a restarted run of the same discussion finds its segments.
+func episodeSegmentDir(params podcast.GenerateAndStreamParams) (dir string, cleanup func(), err error) {
+	if params.Config.ResumeDir != "" {
+		return segmentDir(filepath.Join(params.Config.ResumeDir, discussionKey(params.Discussion)), loggerOf(params.Config.Logger))
+	}
+	return segmentDir(params.Config.WorkDir, loggerOf(params.Config.Logger))
+}
+
+// discussionKey returns a short hash of everything that goes into the segments of the discussion
+func discussionKey(discussion podcast.Discussion) string {
+	h := sha256.New()
+	for _, msg := range discussion.Messages {
+		_, _ = fmt.Fprintf(h, "%q %q %q %t %q\n", msg.Host, msg.Emotion, msg.Content, msg.Ad, msg.AudioFile)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// reusableSegment returns the audio of a segment file kept from a previous run.
+// the file must exist and start with an mp3 header, a partially written or missing file is synthesized again.
+func reusableSegment(filename string) ([]byte, bool) {
+	if filename == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(filename) // #nosec G304 -- segment file in the resume directory
+	if err != nil || !audio.HasMP3Header(data) {
+		return nil, false
+	}
+	return data, true
+}
+
+// segmentFileName returns the file of the segment with the given index
+func segmentFileName(dir string, index int) string {
+	return fmt.Sprintf("%s/segment_%03d.mp3", dir, index)
+}
+
+// segmentDir returns the directory for segment files. the work dir is created if needed and kept after the run,
+// so a broken stream can be resumed from its files, otherwise a temporary directory is removed by cleanup.
+func segmentDir(workDir string, logger *slog.Logger) (dir string, cleanup func(), err error) {
+	if workDir != "" {
+		if err := os.MkdirAll(workDir, 0o750); err != nil {
+			return "", nil, fmt.Errorf("failed to create work directory: %w", err)
+		}
+		logger.Info("Keeping segments", "dir", workDir)
+		return workDir, func() {}, nil
+	}
+	tempDir, err := os.MkdirTemp("", "podcast")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	return tempDir, func() { _ = os.RemoveAll(tempDir) }, nil
+}
+
+// streamedDiscussionFile is the discussion of a stream kept in -work-dir with its segments
+const streamedDiscussionFile = "discussion.json"
+
+// resumeStream streams the rest of the discussion of a previous run kept in the work dir, starting from
+// message config.ResumeFromSegment. nothing is fetched or generated: the kept segments go through the same tempo,
+// normalization, pauses, ad silence and jingles as in the interrupted stream, the intro jingle only from the start.
+func resumeStream(config podcast.Config, audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	discussion, allFiles, err := loadResumedSegments(config)
+	if err != nil {
+		return err
+	}
+	from := config.ResumeFromSegment
+	audioFiles, err := rebuildResumedSegments(discussion, allFiles, config, audioProcessor)
+	if err != nil {
+		return err
+	}
+	if from > 0 {
+		config.IntroFile = "" // the intro jingle went out before the stream broke
+	}
+	concatFile, err := audio.CreateConcatFile(config.WorkDir, withJingles(audioFiles, config))
+	if err != nil {
+		return fmt.Errorf("failed to create concat file: %w", err)
+	}
+
+	logger.Info("Resuming stream", "segment", from, "left", len(discussion.Messages)-from)
+	logger.Info("Streaming to Icecast server", "server", icecastServers(config))
+	if err := audioProcessor.StreamFromConcat(concatFile, config); err != nil {
+		return fmt.Errorf("failed to stream from concat: %w", err)
+	}
+	logger.Info("Podcast streaming completed successfully!")
+	return nil
+}
+
+// loadResumedSegments checks the resume flags and returns the discussion kept in the work dir with the files
+// of all its segments, the ones from config.ResumeFromSegment on must be there and playable
+func loadResumedSegments(config podcast.Config) (podcast.Discussion, []string, error) {
+	if config.WorkDir == "" {
+		return podcast.Discussion{}, nil, errors.New("-resume-from-segment requires -work-dir with the segments of the interrupted run")
+	}
+	if config.ResumeFromSegment < 0 {
+		return podcast.Discussion{}, nil, fmt.Errorf("invalid -resume-from-segment %d, must not be negative, -1 disables it",
+			config.ResumeFromSegment)
+	}
+	if config.DryRun || config.OutputFile != "" {
+		return podcast.Discussion{}, nil, errors.New("-resume-from-segment applies to Icecast streaming only")
+	}
+
+	discussion, err := loadStreamedDiscussion(config.WorkDir)
+	if err != nil {
+		return podcast.Discussion{}, nil, err
+	}
+	from := config.ResumeFromSegment
+	if from >= len(discussion.Messages) {
+		return podcast.Discussion{}, nil, fmt.Errorf("no segments from %d, the discussion in %s has %d",
+			from, config.WorkDir, len(discussion.Messages))
+	}
+
+	allFiles := make([]string, len(discussion.Messages))
+	for i := range discussion.Messages {
+		allFiles[i] = segmentFileName(config.WorkDir, i)
+	}
+	for _, filename := range allFiles[from:] {
+		if _, ok := reusableSegment(filename); !ok {
+			return podcast.Discussion{}, nil, fmt.Errorf("segment %s of the interrupted run is missing or broken", filename)
+		}
+	}
+	return discussion, allFiles, nil
+}
+
+// rebuildResumedSegments returns the files streamed from config.ResumeFromSegment on: the kept segments
+// at the tempo and loudness of the interrupted stream, with its pauses and ad silence
+func rebuildResumedSegments(discussion podcast.Discussion, allFiles []string, config podcast.Config,
+	audioProcessor AudioProcessor) ([]string, error) {
+	from := config.ResumeFromSegment
+	messages, audioFiles := discussion.Messages[from:], allFiles[from:]
+	// the speed of every segment is found as in the interrupted run, measured over the whole discussion
+	// unless the segments were streamed ahead at a single speed
+	speeds := []float64{episodeSpeechSpeed(discussion.Messages, config)}
+	if config.TargetDuration > 0 && config.StreamAhead == 0 {
+		speedParams := podcast.GenerateSpeechSegmentsParams{Messages: discussion.Messages, TargetDuration: config.TargetDuration,
+			Speed: speeds[0], Language: config.Language, Logger: config.Logger}
+		speeds = segmentSpeeds(speedParams, allFiles, audioProcessor)[from:]
+	}
+	audioFiles, err := tempoSegments(audioFiles, messages, speeds, config.WorkDir, audioProcessor)
+	if err != nil {
+		return nil, err
+	}
+	if audioFiles, err = normalizeSegments(audioFiles, config.Normalize, config.WorkDir, audioProcessor); err != nil {
+		return nil, err
+	}
+	return addSilence(audioFiles, messages, config, config.WorkDir, audioProcessor)
+}
+
+// loadStreamedDiscussion reads the discussion kept in the work dir by the interrupted stream
+func loadStreamedDiscussion(workDir string) (podcast.Discussion, error) {
+	filename := filepath.Join(workDir, streamedDiscussionFile)
+	data, err := os.ReadFile(filename) // #nosec G304 -- discussion file in the work directory
+	if err != nil {
+		return podcast.Discussion{}, fmt.Errorf("failed to read the discussion of the interrupted run: %w", err)
+	}
+	var discussion podcast.Discussion
+	if err := json.Unmarshal(data, &discussion); err != nil {
+		return podcast.Discussion{}, fmt.Errorf("failed to decode %s: %w", filename, err)
+	}
+	return discussion, nil
+}
+
+// generateSpeechSegments generates speech for all messages in the discussion, files are ordered as the messages.
+// with a target duration set, segments are measured and the speed recomputed at every checkpoint applies
+// to the segments after it.
+func generateSpeechSegments(ctx context.Context, params podcast.GenerateSpeechSegmentsParams, openAI OpenAIClient,
+	audioProcessor AudioProcessor) ([]string, error) {
+	params.Progress.Start(progress.StageTTS, len(params.Messages))
+	audioFiles, err := generateSegmentFiles(ctx, params, openAI)
+	if err != nil {
+		return nil, err
+	}
+	params.Progress.End(progress.StageTTS, len(params.Messages))
+	speeds := []float64{params.Speed}
+	if params.TargetDuration > 0 {
+		speeds = segmentSpeeds(params, audioFiles, audioProcessor)
+	}
+	if audioFiles, err = tempoSegments(audioFiles, params.Messages, speeds, params.TempDir, audioProcessor); err != nil {
+		return nil, err
+	}
+	return normalizeSegments(audioFiles, params.Normalize, params.TempDir, audioProcessor)
+}
+
+// episodeSpeechSpeed estimates the duration of the messages and returns the speech speed factor
+// stretching it to the target duration, 1.0 without a target
+func episodeSpeechSpeed(messages []podcast.Message, config podcast.Config) float64 {
+	logger := loggerOf(config.Logger)
+	textProcessor := textProcessorFor(config.Language)
+	totalEstimatedDuration := textProcessor.EstimateTotalDuration(messages)
+	logger.Info("Estimated podcast duration", "minutes", fmt.Sprintf("%.1f", totalEstimatedDuration/60.0))
+
+	speechSpeed := textProcessor.CalculateSpeechSpeed(totalEstimatedDuration, config.TargetDuration)
+	if speechSpeed != 1.0 {
+		logger.Info("Adjusting speech speed to match target duration", "speed", fmt.Sprintf("%.2f", speechSpeed),
+			"tempo", fmt.Sprintf("%.2f", speechTempo(speechSpeed)))
+	}
+	return speechSpeed
+}
+
+// speechTempo returns the playback tempo applying the speech speed factor. the factor scales the duration,
+// e.g. 1.2 stretches the speech by a fifth, so the tempo is its inverse
+func speechTempo(speed float64) float64 {
+	if speed <= 0 {
+		return 1.0
+	}
+	return 1.0 / speed
+}
+
+// tempoSegments returns copies of the synthesized segment files played at the tempo of their speech speed factor,
+// speeds are per file, a single speed applies to all of them. files of pre-recorded messages and the ones
+// at the speed 1.0 are returned as is. files are ordered as messages.
+func tempoSegments(files []string, messages []podcast.Message, speeds []float64, tempDir string,
+	audioProcessor AudioProcessor) ([]string, error) {
+	// segments of the same tempo are changed in one call
+	groups := make(map[float64][]int)
+	var tempos []float64
+	for i := range files {
+		if i < len(messages) && messages[i].AudioFile != "" {
+			continue // jingles and recorded ads keep their pace
+		}
+		speed := speeds[0]
+		if len(speeds) > 1 {
+			speed = speeds[i]
+		}
+		tempo := speechTempo(speed)
+		if tempo == 1.0 {
+			continue
+		}
+		if _, ok := groups[tempo]; !ok {
+			tempos = append(tempos, tempo)
+		}
+		groups[tempo] = append(groups[tempo], i)
+	}
+	if len(tempos) == 0 {
+		return files, nil
+	}
+
+	result := slices.Clone(files)
+	for _, tempo := range tempos {
+		indexes := groups[tempo]
+		speech := make([]string, 0, len(indexes))
+		for _, index := range indexes {
+			speech = append(speech, files[index])
+		}
+		changed, err := audioProcessor.ChangeTempo(speech, tempo, tempDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to change speech tempo: %w", err)
+		}
+		if len(changed) != len(speech) {
+			return nil, fmt.Errorf("failed to change speech tempo: %d files for %d segments", len(changed), len(speech))
+		}
+		for i, index := range indexes {
+			result[index] = changed[i]
+		}
+	}
+	return result, nil
+}
+
+// normalizeSegments returns the loudness normalized copies of the segment files with normalize set,
+// the files as is otherwise
+func normalizeSegments(files []string, normalize bool, tempDir string, audioProcessor AudioProcessor) ([]string, error) {
+	if !normalize {
+		return files, nil
+	}
+	normalized, err := audioProcessor.Normalize(files, tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize segments: %w", err)
+	}
+	return normalized, nil
+}
+
+// generateSegmentFiles synthesizes the messages with up to params.Concurrency requests in flight,
+// each file lands at the index of its message whatever order the requests complete in.
+// the first failure or the cancellation of ctx stops dispatching the remaining messages and is returned
+// once requests in flight are done.
+func generateSegmentFiles(ctx context.Context, params podcast.GenerateSpeechSegmentsParams, openAI OpenAIClient) ([]string, error) {
+	audioFiles := make([]string, len(params.Messages))
+	dispatchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var firstErr error
+	var errOnce sync.Once
+	jobs := make(chan int)
+	for range max(params.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				msg := params.Messages[i]
+				loggerOf(params.Logger).Info("Generating speech", "host", msg.Host, "message", fmt.Sprintf("%d/%d", i+1, len(params.Messages)))
+				host := lookupHost(params.HostMap, msg.Host, params.Fallback)
+				filename, err := generateSegmentFile(i, msg, host, params.TempDir, params.TagSegments, params.Resume, openAI, loggerOf(params.Logger))
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				audioFiles[i] = filename
+				params.Progress.Segment(progress.StageTTS, i, len(params.Messages), msg.Host)
+			}
+		}()
+	}
+
+dispatch:
+	for i := range params.Messages {
+		if dispatchCtx.Err() != nil {
+			break // a worker failed or the run is canceled, don't race the send below against the cancellation
+		}
+		select {
+		case <-dispatchCtx.Done():
+			break dispatch
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("speech generation canceled: %w", err)
+	}
+	return audioFiles, nil
+}
+
+// segmentSpeeds measures the segments in order and returns the speech speed factor of every segment.
+// the speed is recomputed at every checkpoint from the durations measured so far and applies to the segments
+// after it, so the episode converges on the target even when the estimates were off. pre-recorded messages
+// keep their pace, their measured duration is taken off the target up front.
+func segmentSpeeds(params podcast.GenerateSpeechSegmentsParams, audioFiles []string, audioProcessor AudioProcessor) []float64 {
+	textProcessor := textProcessorFor(params.Language)
+	target := float64(params.TargetDuration * 60)
+	var remainingEstimate float64
+	for i, msg := range params.Messages[:len(audioFiles)] {
+		if msg.AudioFile == "" {
+			remainingEstimate += textProcessor.EstimateAudioDuration(msg.Content)
+			continue
+		}
+		if measured, err := audioProcessor.Duration(audioFiles[i]); err == nil {
+			target -= measured
+		}
+	}
+
+	speed := content.NewSpeedController(target, params.Speed, content.SpeedCheckpointSegments)
+	speeds := make([]float64, len(audioFiles))
+	for i, filename := range audioFiles {
+		speeds[i] = 1.0
+		if params.Messages[i].AudioFile != "" {
+			continue
+		}
+		speeds[i] = speed.Speed()
+		estimated := textProcessor.EstimateAudioDuration(params.Messages[i].Content)
+		remainingEstimate -= estimated
+		measured, err := audioProcessor.Duration(filename)
+		if err != nil || measured <= 0 {
+			measured = estimated // can't measure, assume the estimate was right
+		}
+		if newSpeed, ok := speed.Record(measured, estimated, remainingEstimate); ok {
+			loggerOf(params.Logger).Info("Speech speed checkpoint", "segments", i+1, "speed", fmt.Sprintf("%.2f", newSpeed))
+		}
+	}
+	return speeds
+}
+
+// generateSegmentFile synthesizes the message with the given voice and writes the audio to a segment file.
+// with resume set a valid segment file left by a previous run is used as is.
+func generateSegmentFile(index int, msg podcast.Message, host podcast.HostInfo, tempDir string, tagSegments, resume bool,
+	openAI OpenAIClient, logger *slog.Logger) (string, error) {
+	filename := segmentFileName(tempDir, index)
+	if resume {
+		if _, ok := reusableSegment(filename); ok {
+			logger.Info("Reusing segment", "segment", index, "file", filename)
+			return filename, nil
+		}
+	}
+
+	// generate speech with OpenAI TTS
+	audioData, err := synthesizeMessage(msg, host, openAI)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate speech for message %d: %w", index, err)
+	}
+
+	// create a file for the audio
+	if err := writeSegmentFile(filename, audioData, index, msg.Host, tagSegments); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// writeSegmentFile writes segment audio, with tag set the segment index and host go into the ID3 title
+// so temp files reveal their intended order and speaker
+func writeSegmentFile(filename string, audioData []byte, index int, host string, tag bool) error {
+	if tag {
+		audioData = audio.WithID3Title(audioData, fmt.Sprintf("%03d %s", index, host))
+	}
+	if err := os.WriteFile(filename, audioData, 0o600); err != nil {
+		return fmt.Errorf("failed to write audio data: %w", err)
+	}
+	return nil
+}
+
+// streamSegmentsWithBackpressure generates segments and feeds them into a live Icecast stream as they are ready.
+// at most params.Config.StreamAhead segments are generated ahead of the stream position, generation waits
+// for the stream to consume a segment before starting the next one.
+func streamSegmentsWithBackpressure(params podcast.GenerateAndStreamParams, hostMap map[string]podcast.HostInfo,
+	tempDir string, speed float64, openAI OpenAIClient, audioProcessor AudioProcessor) error {
+	logger := loggerOf(params.Config.Logger)
+	messages := params.Discussion.Messages
+	pauseFile, err := pauseSilence(params.Config.PauseMs, tempDir, audioProcessor)
+	if err != nil {
+		return err
+	}
+	slots := make(chan struct{}, params.Config.StreamAhead) // taken per generated segment, freed once streamed
+	ready := make(chan []string, params.Config.StreamAhead) // files of each generated message, in order
+	done := make(chan struct{})
+	pr, pw := io.Pipe()
+
+	var wg sync.WaitGroup
+	var genErr error
+
+	// generator produces segments while there is a free slot
+	generator := &streamGenerator{params: params, hostMap: hostMap, tempDir: tempDir, speed: speed, pauseFile: pauseFile,
+		openAI: openAI, audioProcessor: audioProcessor}
+	params.Config.Progress.Start(progress.StageTTS, len(messages))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		genErr = generator.run(slots, ready, done)
+	}()
+
+	// feeder writes generated segments to the stream input, blocking while the stream catches up
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = pw.CloseWithError(feedSegments(pw, ready, slots, params.Config, tempDir, audioProcessor))
+	}()
+
+	logger.Info("Streaming to Icecast server", "server", icecastServers(params.Config),
+		"ahead", params.Config.StreamAhead)
+	params.Config.Progress.Start(progress.StageStream, len(messages))
+	streamErr := audioProcessor.StreamFromReader(pr, params.Config)
+
+	// stop generation and unblock the feeder if the stream ended early
+	close(done)
+	_ = pr.Close()
+	wg.Wait()
+
+	if genErr != nil {
+		return genErr
+	}
+	if streamErr != nil {
+		return fmt.Errorf("failed to stream segments: %w", streamErr)
+	}
+	params.Config.Progress.End(progress.StageStream, len(messages))
+	return nil
+}
+
+// streamGenerator generates the segments of a live stream one message at a time
+type streamGenerator struct {
+	params         podcast.GenerateAndStreamParams
+	hostMap        map[string]podcast.HostInfo
+	tempDir        string
+	speed          float64
+	pauseFile      string // silence between the messages, empty for none
+	silenceFile    string // silence around an ad, made for the first one
+	openAI         OpenAIClient
+	audioProcessor AudioProcessor
+}
+
+// run generates the files of each message while there is a free slot and sends them to ready in order.
+// it returns once all messages are sent, done is closed or a segment fails, ready is closed on return.
+func (g *streamGenerator) run(slots chan<- struct{}, ready chan<- []string, done <-chan struct{}) error {
+	defer close(ready)
+	messages := g.params.Discussion.Messages
+	for i, msg := range messages {
+		select {
+		case slots <- struct{}{}:
+		case <-done:
+			return nil
+		}
+		files, err := g.segment(i, msg)
+		if err != nil {
+			return err
+		}
+		g.params.Config.Progress.Segment(progress.StageTTS, i, len(messages), msg.Host)
+		ready <- files
+	}
+	g.params.Config.Progress.End(progress.StageTTS, len(messages))
+	return nil
+}
+
+// segment returns the files of the message: its speech at the tempo and loudness of the episode,
+// the silence around an ad and the pause before every message but the first
+func (g *streamGenerator) segment(i int, msg podcast.Message) ([]string, error) {
+	config := g.params.Config
+	logger := loggerOf(config.Logger)
+	logger.Info("Generating speech", "host", msg.Host, "message", fmt.Sprintf("%d/%d", i+1, len(g.params.Discussion.Messages)))
+	host := lookupHost(g.hostMap, msg.Host, fallbackHost(config))
+	filename, err := generateSegmentFile(i, msg, host, g.tempDir, config.TagSegments, config.ResumeDir != "", g.openAI, logger)
+	if err != nil {
+		return nil, err
+	}
+	files, err := tempoSegments([]string{filename}, []podcast.Message{msg}, []float64{g.speed}, g.tempDir, g.audioProcessor)
+	if err != nil {
+		return nil, err
+	}
+	if files, err = normalizeSegments(files, config.Normalize, g.tempDir, g.audioProcessor); err != nil {
+		return nil, err
+	}
+	if msg.Ad && config.AdBreak.SilenceMs > 0 {
+		if g.silenceFile == "" {
+			if g.silenceFile, err = g.audioProcessor.InsertSilence(config.AdBreak.SilenceMs, g.tempDir); err != nil {
+				return nil, fmt.Errorf("failed to create ad silence: %w", err)
+			}
+		}
+		files = []string{g.silenceFile, files[0], g.silenceFile}
+	}
+	if i > 0 && g.pauseFile != "" {
+		files = append([]string{g.pauseFile}, files...)
+	}
+	return files, nil
+}
+
+// feedSegments writes the -intro jingle, the files of each generated message and the -outro file to the stream input.
+// a slot is freed once the files of a message are written. on failure the slot of the current message is freed too,
+// the outro is written only when all messages are.
+func feedSegments(w io.Writer, ready <-chan []string, slots <-chan struct{}, config podcast.Config, tempDir string,
+	audioProcessor AudioProcessor) error {
+	if config.IntroFile != "" {
+		if err := writeFiles(w, []string{config.IntroFile}); err != nil {
+			return err
+		}
+	}
+	for files := range ready {
+		err := holdWhilePaused(w, config.Paused, tempDir, audioProcessor, loggerOf(config.Logger))
+		if err == nil {
+			err = writeFiles(w, files)
+		}
+		<-slots
+		if err != nil {
+			return err
+		}
+	}
+	if config.OutroFile != "" {
+		return writeFiles(w, []string{config.OutroFile})
+	}
+	return nil
+}
+
+// writeFiles writes the content of the files to w one after another
+func writeFiles(w io.Writer, files []string) error {
+	for _, file := range files {
+		data, err := os.ReadFile(file) // #nosec G304 -- segment files are created internally, jingles come from the command line
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withJingles returns the segment files with the -intro file first and the -outro file last, when set
+func withJingles(files []string, config podcast.Config) []string {
+	result := make([]string, 0, len(files)+2)
+	if config.IntroFile != "" {
+		result = append(result, config.IntroFile)
+	}
+	result = append(result, files...)
+	if config.OutroFile != "" {
+		result = append(result, config.OutroFile)
+	}
+	return result
+}
+
+// holdWhilePaused feeds silence into the stream while it is paused, so Icecast listeners stay connected.
+// returns once the stream is resumed or the stream input is closed.
+func holdWhilePaused(w io.Writer, paused func() bool, tempDir string, audioProcessor AudioProcessor, logger *slog.Logger) error {
+	if paused == nil || !paused() {
+		return nil
+	}
+
+	logger.Info("Stream paused, feeding silence until resumed")
+	silenceFile, err := audioProcessor.InsertSilence(content.PauseSilenceMs, tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to create pause silence: %w", err)
+	}
+	data, err := os.ReadFile(silenceFile) // #nosec G304 -- silence file is created internally
+	if err != nil {
+		return fmt.Errorf("failed to read pause silence: %w", err)
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("pause silence %s is empty", silenceFile)
+	}
+
+	for paused() {
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write pause silence: %w", err)
+		}
+	}
+	logger.Info("Stream resumed")
+	return nil
+}
+
+// generateAndPlayLocally generates speech for each message and plays it locally
+func generateAndPlayLocally(params podcast.GenerateAndStreamParams, openAI OpenAIClient, audioProcessor AudioProcessor) error {
+	logger := loggerOf(params.Config.Logger)
+	startTime := time.Now()
+	logger.Info("Starting local generation/playback")
+
+	// create a directory to store the audio segments
+	tempDir, cleanup, err := episodeSegmentDir(params)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	logger.Debug("Using segment directory", "dir", tempDir)
+
+	// map host names to their gender and voice
+	hostMap := podcast.CreateHostMap(params.Config.Hosts)
+
+	// calculate speech speed from the estimated duration
+	speechSpeed := episodeSpeechSpeed(params.Discussion.Messages, params.Config)
+
+	// create channels for communication between main thread and background workers
+	requestChan := make(chan podcast.SpeechGenerationRequest, len(params.Discussion.Messages))
+	resultChan := make(chan podcast.SpeechSegment, len(params.Discussion.Messages))
+	stopChan := make(chan struct{})
+
+	// create a buffer for pre-generated segments
+	segmentBuffer := make([]podcast.SpeechSegment, 0, content.PreGeneratedSegmentsBuffer)
+	bufferMutex := sync.Mutex{}
+
+	// start background worker for speech generation
+	logger.Debug("Starting background worker for speech generation")
+	workerParams := podcast.SpeechGenerationWorkerParams{
+		RequestChan: requestChan,
+		ResultChan:  resultChan,
+		StopChan:    stopChan,
+		Logger:      params.Config.Logger,
+	}
+	workers := max(params.Config.Concurrency.TTS, 1)
+	for range workers {
+		go speechGenerationWorker(workerParams, openAI)
+	}
+
+	// start pre-generating segments, enough to keep every worker busy
+	logger.Debug("Starting pre-generation of segments")
+	currentIndex := 0
+	for i := 0; i < max(content.PreGeneratedSegmentsBuffer, workers) && currentIndex < len(params.Discussion.Messages); i++ {
+		msg := params.Discussion.Messages[currentIndex]
+		reqParams := podcast.CreateSpeechRequestParams{
+			Msg:      msg,
+			Index:    currentIndex,
+			HostMap:  hostMap,
+			Fallback: fallbackHost(params.Config),
+			APIKey:   params.Config.OpenAIAPIKey,
+			Resume:   resumeSegmentFile(params.Config, tempDir, currentIndex),
+			Speed:    speechSpeed,
+		}
+		req := createSpeechRequest(reqParams)
+		logger.Debug("Requesting generation", "message", currentIndex, "host", msg.Host)
+		requestChan <- req
+		currentIndex++
+	}
+
+	// process messages and play audio
+	processParams := podcast.ProcessSegmentsParams{
+		Discussion:    params.Discussion,
+		Config:        params.Config,
+		RequestChan:   requestChan,
+		ResultChan:    resultChan,
+		StopChan:      stopChan,
+		SegmentBuffer: &segmentBuffer,
+		BufferMutex:   &bufferMutex,
+		CurrentIndex:  &currentIndex,
+		TempDir:       tempDir,
+		Speed:         speechSpeed,
+	}
+	audioFiles, err := processSegments(processParams, audioProcessor)
+	if err != nil {
+		close(stopChan)
+		return err
+	}
+
+	close(stopChan)
+	logger.Debug("Finished processing all segments")
+
+	if audioFiles, err = normalizeSegments(audioFiles, params.Config.Normalize, tempDir, audioProcessor); err != nil {
+		return err
+	}
+	segmentFiles := audioFiles
+	audioFiles, err = addSilence(audioFiles, params.Discussion.Messages, params.Config, tempDir, audioProcessor)
+	if err != nil {
+		return err
+	}
+	if params.Config.Chapters {
+		episodeFiles := withJingles(audioFiles, params.Config)
+		if err := saveChapters(params.Discussion.Messages, segmentFiles, episodeFiles, params.Config, audioProcessor); err != nil {
+			return err
+		}
+	}
+
+	if err := saveEpisode(audioFiles, params, audioProcessor); err != nil {
+		return err
+	}
+
+	totalDuration := time.Since(startTime)
+	logger.Info("Total processing time", "took", totalDuration.Round(time.Second))
+
+	if params.Config.DryRun {
+		logger.Info("Podcast playback completed successfully!")
+	} else {
+		logger.Info("Podcast generation completed successfully!")
+	}
+	return nil
+}
+
+// saveEpisode concatenates the segments with the jingles into the output file or stdout, when an output is set,
+// and cuts the teaser from the saved episode
+func saveEpisode(audioFiles []string, params podcast.GenerateAndStreamParams, audioProcessor AudioProcessor) error {
+	logger := loggerOf(params.Config.Logger)
+	episodeFiles := withJingles(audioFiles, params.Config)
+	switch params.Config.OutputFile {
+	case "":
+		return nil
+	case stdoutOutput:
+		logger.Info("Writing podcast to stdout")
+		out := params.Config.AudioOut
+		if out == nil {
+			out = os.Stdout
+		}
+		params.Config.Progress.Start(progress.StageConcat, len(episodeFiles))
+		if err := audioProcessor.ConcatenateTo(episodeFiles, out); err != nil {
+			return fmt.Errorf("failed to concatenate audio files: %w", err)
+		}
+		params.Config.Progress.End(progress.StageConcat, len(episodeFiles))
+		return nil
+	}
+
+	logger.Info("Saving podcast", "file", params.Config.OutputFile)
+	params.Config.Progress.Start(progress.StageConcat, len(episodeFiles))
+	if err := concatenateEpisode(episodeFiles, params.Config, audioProcessor); err != nil {
+		return err
+	}
+	params.Config.Progress.End(progress.StageConcat, len(episodeFiles))
+	if err := tagEpisode(params.Discussion, params.Config, audioProcessor); err != nil {
+		return err
+	}
+	logger.Info("Podcast saved", "file", params.Config.OutputFile)
+
+	if params.Config.Teaser > 0 {
+		introSegments := params.IntroMessages
+		if params.Config.PauseMs > 0 {
+			introSegments *= 2 // every intro message is followed by a pause
+		}
+		if params.Config.IntroFile != "" {
+			introSegments++ // the teaser skips the jingle too
+		}
+		if err := saveTeaser(episodeFiles, introSegments, params.Config, audioProcessor); err != nil {
+			return err
+		}
+	}
+	if params.Config.Audiogram != "" {
+		return saveAudiogram(params.Config, audioProcessor)
+	}
+	return nil
+}
+
+// tagEpisode writes the discussion title, -artist, -album, the publish date, the -cover and the -chapters
+// into the ID3 tag of the saved episode
+func tagEpisode(discussion podcast.Discussion, config podcast.Config, audioProcessor AudioProcessor) error {
+	tags := audio.Tags{Title: discussion.Title, Artist: config.Artist, Album: config.Album,
+		Date: time.Now().Format(time.DateOnly), Cover: config.CoverFile}
+	if config.Chapters {
+		tags.Chapters = chaptersFile(config.OutputFile)
+	}
+	if err := audioProcessor.WriteTags(config.OutputFile, tags); err != nil {
+		return fmt.Errorf("failed to tag episode: %w", err)
+	}
+	return nil
+}
+
+// saveChapters writes the chapters of the episode next to it, e.g. podcast_chapters.txt for podcast.mp3
+func saveChapters(messages []podcast.Message, segmentFiles, episodeFiles []string, config podcast.Config,
+	audioProcessor AudioProcessor) error {
+	chapters := content.BuildChapters(messageTimings(messages, segmentFiles, episodeFiles, config, audioProcessor))
+	filename := chaptersFile(config.OutputFile)
+	if err := os.WriteFile(filename, []byte(chapters), 0o600); err != nil {
+		return fmt.Errorf("error saving chapters: %w", err)
+	}
+	loggerOf(config.Logger).Info("Chapters saved", "file", filename)
+	return nil
+}
+
+// chaptersFile returns the ffmpeg metadata file with the chapters of the episode, e.g. podcast_chapters.txt for podcast.mp3
+func chaptersFile(outputFile string) string {
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "_chapters.txt"
+}
+
+// messageTimings places the messages on the episode timeline by the measured durations of the episode files,
+// so jingles, pauses, ad silences and the tempo of the segments are accounted for. segmentFiles are the files
+// of the messages in order, crossfaded files overlap by -crossfade-ms. a segment which can't be measured
+// takes its estimated duration, another file is taken as empty.
+func messageTimings(messages []podcast.Message, segmentFiles, episodeFiles []string, config podcast.Config,
+	audioProcessor AudioProcessor) []content.TimedMessage {
+	textProcessor := textProcessorFor(config.Language)
+	fade := float64(config.CrossfadeMs) / 1000
+	durations := make(map[string]float64) // the pause and silence files repeat
+	timed := make([]content.TimedMessage, 0, len(messages))
+	var start float64
+	for i, file := range episodeFiles {
+		if i > 0 {
+			start -= fade
+		}
+		next := len(timed)
+		segment := next < len(messages) && next < len(segmentFiles) && file == segmentFiles[next]
+		duration, ok := durations[file]
+		if !ok {
+			measured, err := audioProcessor.Duration(file)
+			switch {
+			case err == nil && measured > 0:
+				duration = measured
+			case segment:
+				duration = textProcessor.EstimateAudioDuration(messages[next].Content)
+			default:
+				loggerOf(config.Logger).Warn("Can't measure episode file, chapters after it may be early", "file", file, "err", err)
+			}
+			durations[file] = duration
+		}
+		if segment {
+			timed = append(timed, content.TimedMessage{Message: messages[next], Start: start, End: start + duration})
+		}
+		start += duration
+	}
+	return timed
+}
+
+// concatenateEpisode joins the episode files into the output file, crossfading them by -crossfade-ms.
+// with -music the files are joined into a speech-only file next to it first, the music bed is mixed under it
+// into the output file. the speech-only file is removed afterwards, unless it's the -clean-output.
+func concatenateEpisode(files []string, config podcast.Config, audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	if config.MusicFile == "" {
+		return joinSegments(files, config.OutputFile, config.CrossfadeMs, audioProcessor, logger)
+	}
+
+	speechFile := config.CleanOutput
+	if speechFile == "" {
+		speechFile = strings.TrimSuffix(config.OutputFile, filepath.Ext(config.OutputFile)) + "_speech.mp3"
+		defer os.Remove(speechFile)
+	}
+	if err := joinSegments(files, speechFile, config.CrossfadeMs, audioProcessor, logger); err != nil {
+		return err
+	}
+	if config.CleanOutput != "" {
+		logger.Info("Clean dialogue saved", "file", config.CleanOutput)
+	}
+	logger.Info("Mixing background music", "file", config.MusicFile, "gain_db", config.MusicGain)
+	if err := audioProcessor.MixWithBackground(speechFile, config.MusicFile, config.OutputFile, config.MusicGain); err != nil {
+		return fmt.Errorf("failed to add background music: %w", err)
+	}
+	return nil
+}
+
+// joinSegments concatenates the files into outputFile, crossfading them when fadeMs is set
+func joinSegments(files []string, outputFile string, fadeMs int, audioProcessor AudioProcessor, logger *slog.Logger) error {
+	if fadeMs > 0 {
+		logger.Info("Crossfading segments", "ms", fadeMs)
+		if err := audioProcessor.ConcatenateWithCrossfade(files, outputFile, fadeMs); err != nil {
+			return fmt.Errorf("failed to concatenate audio files: %w", err)
+		}
+		return nil
+	}
+	if err := audioProcessor.Concatenate(files, outputFile); err != nil {
+		return fmt.Errorf("failed to concatenate audio files: %w", err)
+	}
+	return nil
+}
+
+// saveAudiogram renders the waveform of the saved episode into the -audiogram file
+func saveAudiogram(config podcast.Config, audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	width, height, err := audio.ParseAudiogramSize(config.AudiogramSize)
+	if err != nil {
+		return fmt.Errorf("invalid -audiogram-size: %w", err)
+	}
+	logger.Info("Rendering audiogram", "size", fmt.Sprintf("%dx%d", width, height), "file", config.Audiogram)
+	if err := audioProcessor.Audiogram(config.OutputFile, config.Audiogram, width, height); err != nil {
+		return fmt.Errorf("failed to save audiogram: %w", err)
+	}
+	logger.Info("Audiogram saved", "file", config.Audiogram)
+	return nil
+}
+
+// speechGenerationWorker processes requests from the request channel and sends results to the result channel
+func speechGenerationWorker(params podcast.SpeechGenerationWorkerParams, openAI OpenAIClient) {
+	logger := loggerOf(params.Logger)
+	for {
+		select {
+		case <-params.StopChan:
+			logger.Debug("Background worker stopped")
+			return
+		case req := <-params.RequestChan:
+			if audioData, ok := reusableSegment(req.Resume); ok {
+				logger.Info("Reusing segment", "segment", req.Index, "file", req.Resume)
+				params.ResultChan <- podcast.SpeechSegment{AudioData: audioData, Host: req.Msg.Host, Index: req.Index,
+					Msg: req.Msg, Resumed: true, Speed: req.Speed}
+				continue
+			}
+			segmentStartTime := time.Now()
+			logger.Info("Generating speech", "host", req.Msg.Host, "message", req.Index+1)
+			host := podcast.HostInfo{Gender: req.Gender, Voice: req.Voice, TTSModel: req.TTSModel}
+			audioData, err := synthesizeMessage(req.Msg, host, openAI)
+			if err != nil {
+				logger.Error("Can't generate speech", "message", req.Index+1, "err", err)
+			} else {
+				segmentDuration := time.Since(segmentStartTime)
+				logger.Debug("Generated speech", "message", req.Index+1, "took", segmentDuration.Round(100*time.Millisecond))
+			}
+			params.ResultChan <- podcast.SpeechSegment{
+				AudioData: audioData,
+				Host:      req.Msg.Host,
+				Index:     req.Index,
+				Error:     err,
+				Msg:       req.Msg,
+				Speed:     req.Speed,
+			}
+		}
+	}
+}
+
+// processSegments handles the main loop of processing speech segments
+func processSegments(params podcast.ProcessSegmentsParams, audioProcessor AudioProcessor) ([]string, error) {
+	logger := loggerOf(params.Config.Logger)
+
+	playedIndex := 0
+	audioFiles := make([]string, 0, len(params.Discussion.Messages))
+	hostMap := podcast.CreateHostMap(params.Config.Hosts)
+
+	params.Config.Progress.Start(progress.StageTTS, len(params.Discussion.Messages))
+	logger.Debug("Starting main processing loop")
+	for playedIndex < len(params.Discussion.Messages) {
+		// start generating the next segment if we're not at the end
+		if *params.CurrentIndex < len(params.Discussion.Messages) {
+			msg := params.Discussion.Messages[*params.CurrentIndex]
+			reqParams := podcast.CreateSpeechRequestParams{
+				Msg:      msg,
+				Index:    *params.CurrentIndex,
+				HostMap:  hostMap,
+				Fallback: fallbackHost(params.Config),
+				APIKey:   params.Config.OpenAIAPIKey,
+				Resume:   resumeSegmentFile(params.Config, params.TempDir, *params.CurrentIndex),
+				Speed:    params.Speed,
+			}
+			req := createSpeechRequest(reqParams)
+			logger.Debug("Requesting generation", "message", *params.CurrentIndex, "host", msg.Host)
+			params.RequestChan <- req
+			*params.CurrentIndex++
+		}
+
+		// wait for the next segment with a timeout
+		logger.Debug("Waiting for next segment", "played", fmt.Sprintf("%d/%d", playedIndex, len(params.Discussion.Messages)))
+		var segment podcast.SpeechSegment
+		select {
+		case segment = <-params.ResultChan:
+			logger.Debug("Received segment", "segment", segment.Index, "host", segment.Host)
+		case <-time.After(content.SpeechGenerationTimeout):
+			logger.Error("Timeout waiting for speech generation")
+			return nil, fmt.Errorf("timeout waiting for speech generation")
+		}
+
+		if segment.Error != nil {
+			logger.Error("Segment failed", "segment", segment.Index, "err", segment.Error)
+			return nil, fmt.Errorf("failed to generate speech for message %d: %w", segment.Index, segment.Error)
+		}
+
+		// add segment to buffer
+		params.BufferMutex.Lock()
+		*params.SegmentBuffer = append(*params.SegmentBuffer, segment)
+		params.BufferMutex.Unlock()
+
+		// process segments in order, parallel workers may have delivered several that are next in line
+		for {
+			orderedParams := podcast.ProcessOrderedSegmentParams{
+				SegmentBuffer: params.SegmentBuffer,
+				BufferMutex:   params.BufferMutex,
+				PlayedIndex:   playedIndex,
+				TempDir:       params.TempDir,
+				Config:        params.Config,
+			}
+			processedSegment, err := processOrderedSegment(orderedParams, audioProcessor)
+			if err != nil {
+				return nil, err
+			}
+			if processedSegment == nil {
+				break
+			}
+			audioFiles = append(audioFiles, *processedSegment)
+			params.Config.Progress.Segment(progress.StageTTS, playedIndex, len(params.Discussion.Messages),
+				params.Discussion.Messages[playedIndex].Host)
+			playedIndex++
+		}
+	}
+
+	params.Config.Progress.End(progress.StageTTS, len(params.Discussion.Messages))
+	return audioFiles, nil
+}
+
+// processOrderedSegment processes a segment in the correct order
+func processOrderedSegment(params podcast.ProcessOrderedSegmentParams, audioProcessor AudioProcessor) (*string, error) {
+	logger := loggerOf(params.Config.Logger)
+
+	params.BufferMutex.Lock()
+	foundIndex := -1
+	for i, seg := range *params.SegmentBuffer {
+		if seg.Index == params.PlayedIndex {
+			foundIndex = i
+			break
+		}
+	}
+
+	if foundIndex == -1 {
+		params.BufferMutex.Unlock()
+		return nil, nil // wait for the right segment
+	}
+
+	// remove the segment from buffer
+	nextSegment := (*params.SegmentBuffer)[foundIndex]
+	*params.SegmentBuffer = append((*params.SegmentBuffer)[:foundIndex], (*params.SegmentBuffer)[foundIndex+1:]...)
+	params.BufferMutex.Unlock()
+
+	// create a temporary file for the audio, a reused segment is already in place
+	filename := segmentFileName(params.TempDir, params.PlayedIndex)
+	if !nextSegment.Resumed {
+		logger.Debug("Writing segment", "segment", params.PlayedIndex, "file", filename)
+		err := writeSegmentFile(filename, nextSegment.AudioData, params.PlayedIndex, nextSegment.Host, params.Config.TagSegments)
+		if err != nil {
+			logger.Error("Can't write segment", "segment", params.PlayedIndex, "err", err)
+			return nil, err
+		}
+	}
+
+	// the segment is played and saved at the tempo of the requested speed
+	files, err := tempoSegments([]string{filename}, []podcast.Message{nextSegment.Msg}, []float64{nextSegment.Speed}, params.TempDir,
+		audioProcessor)
+	if err != nil {
+		return nil, err
+	}
+	filename = files[0]
+
+	// play the current segment if dry run is enabled
+	if params.Config.DryRun {
+		playParams := podcast.PlaySegmentParams{
+			Segment:  nextSegment,
+			Index:    params.PlayedIndex,
+			Filename: filename,
+			Logger:   params.Config.Logger,
+		}
+		if err := playSegment(playParams, audioProcessor); err != nil {
+			return nil, err
+		}
+	}
+
+	return &filename, nil
+}
+
+// playSegment plays a single audio segment
+func playSegment(params podcast.PlaySegmentParams, audioProcessor AudioProcessor) error {
+	logger := loggerOf(params.Logger)
+	textProcessor := content.NewTextProcessor()
+	playStartTime := time.Now()
+	logger.Info("Playing audio", "host", params.Segment.Host, "message", params.Index+1)
+	logger.Info("Text: " + textProcessor.TruncateString(params.Segment.Msg.Content, content.DisplayTruncateLength))
+
+	err := audioProcessor.Play(params.Filename)
+	if err != nil {
+		logger.Error("Can't play segment", "segment", params.Index, "err", err)
+		return fmt.Errorf("failed to play audio: %w", err)
+	}
+
+	playDuration := time.Since(playStartTime)
+	logger.Debug("Segment playback completed", "segment", params.Index, "took", playDuration.Round(100*time.Millisecond))
+	return nil
+}
+
+// createSpeechRequest creates a speech generation request for the given message
+func createSpeechRequest(params podcast.CreateSpeechRequestParams) podcast.SpeechGenerationRequest {
+	info := lookupHost(params.HostMap, params.Msg.Host, params.Fallback)
+	speed := params.Speed
+	if speed <= 0 {
+		speed = 1.0
+	}
+	return podcast.SpeechGenerationRequest{
+		Msg:      params.Msg,
+		Index:    params.Index,
+		Gender:   info.Gender,
+		Voice:    info.Voice,
+		Speed:    speed,
+		APIKey:   params.APIKey,
+		Resume:   params.Resume,
+		TTSModel: info.TTSModel,
+	}
+}
+
+// resumeSegmentFile returns the segment file to reuse with resume dir set, empty otherwise
+func resumeSegmentFile(config podcast.Config, tempDir string, index int) string {
+	if config.ResumeDir == "" {
+		return ""
+	}
+	return segmentFileName(tempDir, index)
+}
+
+// fallbackHost returns the configured gender and voice of speakers missing from the host map
+func fallbackHost(config podcast.Config) podcast.HostInfo {
+	return podcast.HostInfo{Gender: config.DefaultGender, Voice: config.DefaultVoice}
+}
+
+// lookupHost returns the gender and voice of the host, speakers missing from the map get the fallback.
+// empty fallback fields default to a female speaker with the nova voice.
+func lookupHost(hostMap map[string]podcast.HostInfo, name string, fallback podcast.HostInfo) podcast.HostInfo {
+	if info, ok := hostMap[name]; ok {
+		return info
+	}
+	if fallback.Gender == "" {
+		fallback.Gender = defaultGender
+	}
+	if fallback.Voice == "" {
+		fallback.Voice = defaultVoice
+	}
+	return fallback
+}
+
+// resolvedHosts returns the gender, voice and TTS model every configured host is synthesized with,
+// and the ones of the speakers missing from the hosts, with the defaults lookupHost applies to them
+func resolvedHosts(config podcast.Config) (map[string]podcast.HostInfo, podcast.HostInfo) {
+	model := resolvedTTSModel(config)
+	hostMap := podcast.CreateHostMap(config.Hosts)
+	for name, info := range hostMap {
+		if info.TTSModel == "" {
+			info.TTSModel = model
+			hostMap[name] = info
+		}
+	}
+	fallback := lookupHost(nil, "", fallbackHost(config))
+	fallback.TTSModel = model
+	return hostMap, fallback
+}
+
+// resolvedTTSModel returns the global TTS model, -tts-model or the default model of the TTS backend
+func resolvedTTSModel(config podcast.Config) string {
+	if config.TTSModel != "" {
+		return config.TTSModel
+	}
+	if backend, err := ai.ParseTTSBackend(config.TTSBackend); err == nil && backend == ai.TTSBackendSpeech {
+		return content.OpenAISpeechModel
+	}
+	return content.OpenAITTSModel
+}
+
+// validateDefaultHost checks the -default-voice and -default-gender values, empty values keep the built-in defaults
+func validateDefaultHost(voice, gender string) error {
+	if voice != "" {
+		if err := ai.ValidateVoice(voice); err != nil {
+			return fmt.Errorf("invalid -default-voice: %w", err)
+		}
+	}
+	if gender != "" && gender != "male" && gender != "female" {
+		return fmt.Errorf("invalid -default-gender %q, expected male or female", gender)
+	}
+	return nil
+}
+
+// readIcecastCredentials reads Icecast credentials from a file, either a single user:pass line
+// or user=... and pass=... lines. blank lines and # comments are skipped, whitespace is trimmed.
+func readIcecastCredentials(path string) (user, pass string, err error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the command line
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	for _, line := range lines {
+		key, value, isField := credentialField(line)
+		switch {
+		case !isField && len(lines) == 1:
+			var ok bool
+			if user, pass, ok = strings.Cut(line, ":"); !ok {
+				return "", "", errors.New("expected user:pass")
+			}
+		case !isField:
+			return "", "", fmt.Errorf("invalid line %q, expected user=... or pass=...", line)
+		case key == "user":
+			user = value
+		default:
+			pass = value
+		}
+	}
+
+	user, pass = strings.TrimSpace(user), strings.TrimSpace(pass)
+	if user == "" || pass == "" {
+		return "", "", errors.New("both user and password are required")
+	}
+	return user, pass, nil
+}
+
+// credentialField splits a user=... or pass=... line, a password may contain '=' so other lines are not fields
+func credentialField(line string) (key, value string, ok bool) {
+	key, value, found := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !found || (key != "user" && key != "pass") {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// preflightTTS synthesizes a tiny line with each host voice, so a bad API key, model or voice
+// fails the run before the article is fetched and the discussion is generated
+func preflightTTS(config podcast.Config, openAI OpenAIClient) error {
+	var voices []podcast.HostInfo // distinct voice and model pairs
+	for _, host := range config.Hosts {
+		if voice := (podcast.HostInfo{Voice: host.Voice, TTSModel: host.TTSModel}); !slices.Contains(voices, voice) {
+			voices = append(voices, voice)
+		}
+	}
+	if len(voices) == 0 {
+		voices = append(voices, podcast.HostInfo{Voice: lookupHost(nil, "", fallbackHost(config)).Voice})
+	}
+
+	loggerOf(config.Logger).Info("Preflight: checking TTS", "voices", len(voices))
+	text := episodeLanguage(config.Language).CheckText()
+	for _, voice := range voices {
+		if _, err := openAI.GenerateSpeech(text, voice.Voice, "", voice.TTSModel); err != nil {
+			return fmt.Errorf("preflight TTS check with voice %s failed, check the API key and voices "+
+				"(-skip-preflight disables the check): %w", voice.Voice, err)
+		}
 	}
 	return nil
 }
 
-// speechGenerationWorker processes requests from the request channel and sends results to the result channel
-func speechGenerationWorker(params podcast.SpeechGenerationWorkerParams, openAI OpenAIClient) {
-	for {
-		select {
-		case <-params.StopChan:
-			fmt.Println("Background worker stopped")
-			return
-		case req := <-params.RequestChan:
-			segmentStartTime := time.Now()
-			fmt.Printf("Generating speech for message %d from %s...\n", req.Index, req.Msg.Host)
-			audioData, err := openAI.GenerateSpeech(req.Msg.Content, req.Voice)
-			if err != nil {
-				fmt.Printf("Error generating speech for message %d: %v\n", req.Index, err)
-			} else {
-				segmentDuration := time.Since(segmentStartTime)
-				fmt.Printf("Successfully generated speech for message %d (took %.1f seconds)\n", req.Index, segmentDuration.Seconds())
-			}
-			params.ResultChan <- podcast.SpeechSegment{
-				AudioData: audioData,
-				Host:      req.Msg.Host,
-				Index:     req.Index,
-				Error:     err,
-				Msg:       req.Msg,
-			}
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
 		}
 	}
+	return result
 }
 
-// processSegments handles the main loop of processing speech segments
-func processSegments(params podcast.ProcessSegmentsParams, audioProcessor AudioProcessor) ([]string, error) {
+// compareVoices synthesizes the same line in each voice of config.VoiceCompare into voice_<name>.mp3 files
+// in the work directory, or the current one. without VoiceCompareText the line is the first message of
+// the discussion generated for the article.
+func compareVoices(config podcast.Config, articleFetcher ArticleFetcher, openAI OpenAIClient) error {
+	logger := loggerOf(config.Logger)
+	voices := splitList(config.VoiceCompare)
+	if len(voices) == 0 {
+		return errors.New("invalid -voice-compare: no voices given")
+	}
+	for _, voice := range voices {
+		if err := ai.ValidateVoice(voice); err != nil {
+			return fmt.Errorf("invalid -voice-compare: %w", err)
+		}
+	}
 
-	playedIndex := 0
-	audioFiles := make([]string, 0, len(params.Discussion.Messages))
-	hostMap := podcast.CreateHostMap(params.Config.Hosts)
+	msg := podcast.Message{Content: config.VoiceCompareText}
+	if msg.Content == "" {
+		var err error
+		if msg, err = firstMessage(config, articleFetcher, openAI); err != nil {
+			return err
+		}
+	}
 
-	fmt.Println("Starting main processing loop...")
-	for playedIndex < len(params.Discussion.Messages) {
-		// start generating the next segment if we're not at the end
-		if *params.CurrentIndex < len(params.Discussion.Messages) {
-			msg := params.Discussion.Messages[*params.CurrentIndex]
-			reqParams := podcast.CreateSpeechRequestParams{
-				Msg:     msg,
-				Index:   *params.CurrentIndex,
-				HostMap: hostMap,
-				APIKey:  params.Config.OpenAIAPIKey,
-			}
-			req := createSpeechRequest(reqParams)
-			fmt.Printf("Requesting generation of message %d from %s...\n", *params.CurrentIndex, msg.Host)
-			params.RequestChan <- req
-			*params.CurrentIndex++
+	dir := config.WorkDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create voice comparison directory: %w", err)
+	}
+
+	logger.Info("Comparing voices", "voices", len(voices), "text", msg.Content)
+	for _, voice := range voices {
+		audioData, err := openAI.GenerateSpeech(msg.Content, voice, msg.Emotion, "")
+		if err != nil {
+			return fmt.Errorf("failed to generate speech with voice %s: %w", voice, err)
+		}
+		filename := filepath.Join(dir, "voice_"+voice+".mp3")
+		if err := os.WriteFile(filename, audioData, 0o600); err != nil {
+			return fmt.Errorf("failed to write voice sample: %w", err)
 		}
+		logger.Info("Voice saved", "voice", voice, "file", filename)
+	}
+	return nil
+}
 
-		// wait for the next segment with a timeout
-		fmt.Printf("Waiting for next segment (played %d/%d)...\n", playedIndex, len(params.Discussion.Messages))
-		var segment podcast.SpeechSegment
-		select {
-		case segment = <-params.ResultChan:
-			fmt.Printf("Received segment %d from %s\n", segment.Index, segment.Host)
-		case <-time.After(content.SpeechGenerationTimeout):
-			fmt.Println("Timeout waiting for speech generation!")
-			return nil, fmt.Errorf("timeout waiting for speech generation")
+// firstMessage fetches the article and returns the opening message of the discussion generated for it
+func firstMessage(config podcast.Config, articleFetcher ArticleFetcher, openAI OpenAIClient) (podcast.Message, error) {
+	articleText, title, err := articleFetcher.Fetch(config.ArticleURL)
+	if err != nil {
+		return podcast.Message{}, fmt.Errorf("error fetching article: %w", err)
+	}
+	discussion, err := openAI.GenerateDiscussion(podcast.GenerateDiscussionParams{
+		ArticleText:    articleText,
+		Title:          title,
+		Hosts:          config.Hosts,
+		TargetDuration: config.TargetDuration,
+		IntroHost:      config.IntroHost,
+		BalanceQuotes:  config.BalanceQuotes,
+	})
+	if err != nil {
+		return podcast.Message{}, fmt.Errorf("error generating discussion: %w", err)
+	}
+	messages := content.NewTextProcessor().SanitizeMessages(discussion.Messages)
+	if len(messages) == 0 {
+		return podcast.Message{}, errors.New("generated discussion has no messages")
+	}
+	return messages[0], nil
+}
+
+// printEstimate prints the projected discussion size for the target duration without calling the API,
+// zero perMinute is the default MessagesPerMinute
+func printEstimate(w io.Writer, duration, perMinute, episodes int) {
+	if perMinute <= 0 {
+		perMinute = content.MessagesPerMinute
+	}
+	messages := podcast.EstimateMessageCount(duration, perMinute)
+	fmt.Fprintf(w, "Projected discussion: %d messages, about %d minutes of speech (%d messages per minute)\n",
+		messages, duration, perMinute)
+	if episodes > 1 {
+		fmt.Fprintf(w, "Split into %d episodes: %d messages, about %d minutes in total\n",
+			episodes, messages*episodes, duration*episodes)
+	}
+}
+
+// withOutputDir moves the episode and the files saved along with it into a folder of -output-dir named by the date
+// and the article title, e.g. 2025-06-01-новый-релиз/episode.mp3. the folder is created when missing.
+// files keep the base names of -mp3, -transcript, -script-pdf, -script-out and -audiogram, the episode is episode.mp3 without -mp3.
+func withOutputDir(config podcast.Config, title string) (podcast.Config, error) {
+	slug := content.NewTextProcessor().Slug(title, outputDirSlugLen)
+	if slug == "" {
+		slug = "episode" // a title without letters or digits
+	}
+	dir := filepath.Join(config.OutputDir, time.Now().Format(time.DateOnly)+"-"+slug)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return config, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	inDir := func(file string) string {
+		if file == "" {
+			return ""
 		}
+		return filepath.Join(dir, filepath.Base(file))
+	}
+	if config.OutputFile == "" {
+		config.OutputFile = outputDirEpisode
+	}
+	config.OutputFile = inDir(config.OutputFile)
+	config.OutputTranscript = inDir(config.OutputTranscript)
+	config.ScriptPDF = inDir(config.ScriptPDF)
+	config.ScriptOut = inDir(config.ScriptOut)
+	config.Audiogram = inDir(config.Audiogram)
+	config.CleanOutput = inDir(config.CleanOutput)
+	loggerOf(config.Logger).Info("Saving episode files", "dir", dir)
+	return config, nil
+}
 
-		if segment.Error != nil {
-			fmt.Printf("Error in segment %d: %v\n", segment.Index, segment.Error)
-			return nil, fmt.Errorf("failed to generate speech for message %d: %w", segment.Index, segment.Error)
+// numberedOutputFile inserts the episode number before the extension, podcast.mp3 becomes podcast_2.mp3
+func numberedOutputFile(outputFile string, number int) string {
+	if outputFile == "" {
+		return ""
+	}
+	ext := filepath.Ext(outputFile)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(outputFile, ext), number, ext)
+}
+
+// teaserOutputFile returns the teaser path next to the episode, e.g. podcast_teaser.mp3 for podcast.mp3
+func teaserOutputFile(outputFile string) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "_teaser" + ext
+}
+
+// saveTeaser cuts the teaser from the saved episode, starting with the first exchange after the intro segments.
+// the intro is skipped only when something is left after it, an unknown intro length starts the teaser at 0.
+func saveTeaser(audioFiles []string, introSegments int, config podcast.Config, audioProcessor AudioProcessor) error {
+	logger := loggerOf(config.Logger)
+	if introSegments >= len(audioFiles) {
+		introSegments = 0
+	}
+	start := 0.0
+	for _, file := range audioFiles[:introSegments] {
+		duration, err := audioProcessor.Duration(file)
+		if err != nil {
+			logger.Warn("Can't measure the intro, the teaser starts at the beginning", "err", err)
+			start = 0
+			break
 		}
+		start += duration
+	}
 
-		// add segment to buffer
-		params.BufferMutex.Lock()
-		*params.SegmentBuffer = append(*params.SegmentBuffer, segment)
-		params.BufferMutex.Unlock()
+	teaserFile := teaserOutputFile(config.OutputFile)
+	logger.Info("Saving teaser", "length", config.Teaser, "start", fmt.Sprintf("%.1f", start), "file", teaserFile)
+	if err := audioProcessor.Trim(config.OutputFile, teaserFile, start, config.Teaser.Seconds()); err != nil {
+		return fmt.Errorf("failed to save teaser: %w", err)
+	}
+	logger.Info("Teaser saved", "file", teaserFile)
+	return nil
+}
+
+// generateDiscussion generates the discussion. with -stream-chat the dialog lines are synthesized as the model
+// streams them, it returns the client serving the speech synthesized ahead, or openAI as is without streaming.
+func generateDiscussion(config podcast.Config, params podcast.GenerateDiscussionParams,
+	openAI OpenAIClient) (podcast.Discussion, OpenAIClient, error) {
+	if !prefetchesSpeech(config) {
+		discussion, err := openAI.GenerateDiscussion(params)
+		return discussion, openAI, err
+	}
+
+	prefetcher := newSpeechPrefetcher(openAI, config)
+	lines := make(chan podcast.Message)
+	done := make(chan struct{})
+	go func() {
+		prefetcher.prefetch(lines)
+		close(done)
+	}()
+	params.Stream = lines
+	discussion, err := openAI.GenerateDiscussion(params)
+	close(lines)
+	<-done
+	if prefetched := prefetcher.count(); prefetched > 0 {
+		loggerOf(config.Logger).Info("Started speech while the discussion was written", "lines", prefetched)
+	}
+	return discussion, prefetcher, err
+}
+
+// prefetchesSpeech reports whether the dialog lines are synthesized while the discussion is streamed. it's off
+// when only some lines are synthesized, a TTS budget must be checked before any speech is requested,
+// the discussion may be rewritten to fit the duration, or the cleanup rewrites the streamed lines,
+// which would miss the speech synthesized ahead and be paid for twice.
+func prefetchesSpeech(config podcast.Config) bool {
+	rewritesLines := config.ReduceFillers != "" || config.MaxConsecutive > 0
+	return config.StreamChat && !config.SampleOnly && !config.ScriptOnly && config.AdjustRounds == 0 &&
+		config.VoiceCompare == "" && config.MaxTTSChars == 0 && !rewritesLines &&
+		config.ResumeDir == "" && !config.ResumeStream
+}
+
+// speechKey identifies a synthesized line, the same text in the same voice, delivery and model sounds the same
+type speechKey struct {
+	text, voice, emotion, model string
+}
+
+// prefetchedSpeech is the speech of a line synthesized ahead, done is closed once audio or err is set
+type prefetchedSpeech struct {
+	done  chan struct{}
+	audio []byte
+	err   error
+}
+
+// speechPrefetcher synthesizes the dialog lines streamed by the model while the rest of the discussion is written,
+// so the first segments are ready when the episode starts. GenerateSpeech returns the speech synthesized ahead
+// for the same line, a line not synthesized ahead is synthesized on demand.
+type speechPrefetcher struct {
+	OpenAIClient
+	hostMap  map[string]podcast.HostInfo
+	fallback podcast.HostInfo
+	workers  int
+
+	mu     sync.Mutex
+	speech map[speechKey]*prefetchedSpeech
+}
+
+// newSpeechPrefetcher makes a prefetcher synthesizing with openAI in the voices of the configured hosts
+func newSpeechPrefetcher(openAI OpenAIClient, config podcast.Config) *speechPrefetcher {
+	return &speechPrefetcher{
+		OpenAIClient: openAI,
+		hostMap:      podcast.CreateHostMap(config.Hosts),
+		fallback:     fallbackHost(config),
+		workers:      max(config.Concurrency.TTS, 1),
+		speech:       make(map[speechKey]*prefetchedSpeech),
+	}
+}
+
+// prefetch synthesizes the lines received from the channel with up to workers requests in flight,
+// it returns when the channel is closed and every received line is synthesized, so none outlives the episode.
+// the lines are queued as they arrive and only the synthesis waits for a free worker, a slow TTS never holds
+// the reader of the chat stream, which would run into the chat deadline.
+func (p *speechPrefetcher) prefetch(lines <-chan podcast.Message) {
+	queue := newLineQueue()
+	go func() {
+		for line := range lines {
+			queue.put(line)
+		}
+		queue.close()
+	}()
+
+	slots := make(chan struct{}, p.workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	tp := content.NewTextProcessor()
+	for {
+		line, ok := queue.get()
+		if !ok {
+			return
+		}
+		// the line is synthesized sanitized, as cleanupMessages leaves it
+		sanitized := tp.SanitizeMessages([]podcast.Message{line})
+		if len(sanitized) == 0 {
+			continue
+		}
+		msg := sanitized[0]
+		host := lookupHost(p.hostMap, msg.Host, p.fallback)
+		key := speechKey{text: msg.Content, voice: host.Voice, emotion: msg.Emotion, model: host.TTSModel}
+		p.mu.Lock()
+		if _, ok := p.speech[key]; ok {
+			p.mu.Unlock()
+			continue
+		}
+		entry := &prefetchedSpeech{done: make(chan struct{})}
+		p.speech[key] = entry
+		p.mu.Unlock()
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			entry.audio, entry.err = p.OpenAIClient.GenerateSpeech(key.text, key.voice, key.emotion, key.model)
+			close(entry.done)
+		}()
+	}
+}
+
+// lineQueue is an unbounded FIFO of the streamed dialog lines, put never blocks
+type lineQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	lines  []podcast.Message
+	closed bool
+}
+
+// newLineQueue makes an empty queue
+func newLineQueue() *lineQueue {
+	q := &lineQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// put appends the line to the queue
+func (q *lineQueue) put(line podcast.Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lines = append(q.lines, line)
+	q.cond.Signal()
+}
+
+// close marks the end of the lines, get returns the queued ones still
+func (q *lineQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// get returns the first queued line, waiting for one. false once the queue is closed and empty
+func (q *lineQueue) get() (podcast.Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.lines) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.lines) == 0 {
+		return podcast.Message{}, false
+	}
+	line := q.lines[0]
+	q.lines = q.lines[1:]
+	return line, true
+}
+
+// count returns the number of lines synthesized ahead
+func (p *speechPrefetcher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.speech)
+}
 
-		// process segments in order
-		orderedParams := podcast.ProcessOrderedSegmentParams{
-			SegmentBuffer: params.SegmentBuffer,
-			BufferMutex:   params.BufferMutex,
-			PlayedIndex:   playedIndex,
-			TempDir:       params.TempDir,
-			Config:        params.Config,
+// GenerateSpeech returns the speech synthesized ahead for the line, waiting for it when it's still in flight.
+// a line not synthesized ahead or failed then is synthesized now.
+func (p *speechPrefetcher) GenerateSpeech(text, voice, emotion, model string) ([]byte, error) {
+	key := speechKey{text: text, voice: voice, emotion: emotion, model: model}
+	p.mu.Lock()
+	entry, ok := p.speech[key]
+	p.mu.Unlock()
+	if ok {
+		<-entry.done
+		if entry.err == nil {
+			return entry.audio, nil
 		}
-		processedSegment, err := processOrderedSegment(orderedParams, audioProcessor)
+	}
+	return p.OpenAIClient.GenerateSpeech(text, voice, emotion, model)
+}
+
+// synthesizeMessage returns audio for the message with the voice and TTS model of the host,
+// reading pre-recorded audio when the message has it
+func synthesizeMessage(msg podcast.Message, host podcast.HostInfo, openAI OpenAIClient) ([]byte, error) {
+	if msg.AudioFile != "" {
+		audioData, err := os.ReadFile(msg.AudioFile)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to read audio file %s: %w", msg.AudioFile, err)
 		}
+		return audioData, nil
+	}
+	return openAI.GenerateSpeech(msg.Content, host.Voice, msg.Emotion, host.TTSModel)
+}
 
-		if processedSegment != nil {
-			audioFiles = append(audioFiles, *processedSegment)
-			playedIndex++
+// validateIntroHost checks the intro host is one of the configured hosts, empty name is allowed
+func validateIntroHost(name string, hosts []podcast.Host) error {
+	if name == "" {
+		return nil
+	}
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host.Name == name {
+			return nil
 		}
+		names = append(names, host.Name)
 	}
+	return fmt.Errorf("unknown host %q, expected one of: %s", name, strings.Join(names, ", "))
+}
 
-	return audioFiles, nil
+// limitTTSChars reports the projected TTS characters and enforces the cap before any TTS call.
+// in trim mode the discussion is cut at the last message fitting the cap, otherwise it's rejected.
+func limitTTSChars(messages []podcast.Message, maxChars int, mode string, logger *slog.Logger) ([]podcast.Message, error) {
+	tp := content.NewTextProcessor()
+	total := tp.TTSChars(messages)
+	if maxChars <= 0 {
+		logger.Info("Projected TTS characters", "chars", total)
+		return messages, nil
+	}
+	logger.Info("Projected TTS characters", "chars", total, "limit", maxChars)
+	if total <= maxChars {
+		return messages, nil
+	}
+
+	if mode != ttsCharsTrim {
+		return nil, fmt.Errorf("discussion needs %d TTS characters, over the -max-tts-chars limit of %d", total, maxChars)
+	}
+	trimmed := tp.TrimToTTSChars(messages, maxChars)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("first message alone needs more than %d TTS characters", maxChars)
+	}
+	logger.Info("Trimmed discussion", "from", len(messages), "to", len(trimmed), "chars", tp.TTSChars(trimmed))
+	return trimmed, nil
 }
 
-// processOrderedSegment processes a segment in the correct order
-func processOrderedSegment(params podcast.ProcessOrderedSegmentParams, audioProcessor AudioProcessor) (*string, error) {
+// voiceIntroMessages builds a one-line self-introduction in the episode language for each host, in host order
+func voiceIntroMessages(hosts []podcast.Host, language string) []podcast.Message {
+	lang := episodeLanguage(language)
+	messages := make([]podcast.Message, 0, len(hosts))
+	for _, host := range hosts {
+		messages = append(messages, podcast.Message{Host: host.Name, Content: lang.Greeting(host.Name, host.Character)})
+	}
+	return messages
+}
 
-	params.BufferMutex.Lock()
-	foundIndex := -1
-	for i, seg := range *params.SegmentBuffer {
-		if seg.Index == params.PlayedIndex {
-			foundIndex = i
-			break
+// catchphraseMessages returns the hosts' intro and outro catchphrases, in host order.
+// hosts without a catchphrase are skipped.
+func catchphraseMessages(hosts []podcast.Host) (intros, outros []podcast.Message) {
+	for _, host := range hosts {
+		if host.Intro != "" {
+			intros = append(intros, podcast.Message{Host: host.Name, Content: host.Intro})
+		}
+		if host.Outro != "" {
+			outros = append(outros, podcast.Message{Host: host.Name, Content: host.Outro})
 		}
 	}
+	return intros, outros
+}
 
-	if foundIndex == -1 {
-		params.BufferMutex.Unlock()
-		return nil, nil // wait for the right segment
+// defaultHosts are the built-in hosts with Russian names and distinct characters, used without -hosts
+func defaultHosts() []podcast.Host {
+	return []podcast.Host{
+		{
+			Name:      "Алексей",
+			Gender:    "male",
+			Character: "молодой техно-оптимист",
+			Voice:     "onyx",
+			Intro:     "Всем привет! Будущее уже наступило, и сегодня мы в этом убедимся.",
+			Outro:     "Оставайтесь любопытными, будущее за вами!",
+		},
+		{
+			Name:      "Мария",
+			Gender:    "female",
+			Character: "аналитик, любит данные",
+			Voice:     "nova",
+			Intro:     "Добрый день. Как всегда, начнём с фактов, а мнения оставим на потом.",
+			Outro:     "Проверяйте источники. До встречи!",
+		},
+		{
+			Name:      "Дмитрий",
+			Gender:    "male",
+			Character: "скептик, видел всякое",
+			Voice:     "echo",
+			Intro:     "Ну что ж, посмотрим, что нам пообещают на этот раз.",
+			Outro:     "Поживём — увидим. Я уже видел такое, и не раз.",
+		},
 	}
+}
 
-	// remove the segment from buffer
-	nextSegment := (*params.SegmentBuffer)[foundIndex]
-	*params.SegmentBuffer = append((*params.SegmentBuffer)[:foundIndex], (*params.SegmentBuffer)[foundIndex+1:]...)
-	params.BufferMutex.Unlock()
+// configuredHosts returns the hosts of the -hosts file, or the built-in ones without it, with weights applied
+func configuredHosts(hostsFile, weights string) ([]podcast.Host, error) {
+	hosts := defaultHosts()
+	if hostsFile != "" {
+		var err error
+		if hosts, err = loadHosts(hostsFile); err != nil {
+			return nil, fmt.Errorf("invalid -hosts: %w", err)
+		}
+	}
+	hosts, err := applyHostWeights(hosts, weights)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -host-weights: %w", err)
+	}
+	return hosts, nil
+}
 
-	// create a temporary file for the audio
-	filename := fmt.Sprintf("%s/segment_%03d.mp3", params.TempDir, params.PlayedIndex)
-	fmt.Printf("Writing segment %d to file %s...\n", params.PlayedIndex, filename)
-	err := os.WriteFile(filename, nextSegment.AudioData, 0o600)
+// loadHosts reads the list of hosts from a JSON or YAML file, by its extension, and validates them.
+// unknown fields are rejected, so a typo in a field name doesn't silently leave the field empty.
+func loadHosts(path string) ([]podcast.Host, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the command line
 	if err != nil {
-		fmt.Printf("Error writing segment %d: %v\n", params.PlayedIndex, err)
-		return nil, fmt.Errorf("failed to write audio data: %w", err)
+		return nil, fmt.Errorf("failed to read hosts: %w", err)
 	}
 
-	// play the current segment if dry run is enabled
-	if params.Config.DryRun {
-		playParams := podcast.PlaySegmentParams{
-			Segment:  nextSegment,
-			Index:    params.PlayedIndex,
-			Filename: filename,
+	var hosts []podcast.Host
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&hosts)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&hosts)
+	default:
+		return nil, fmt.Errorf("unsupported hosts file extension %q, expected .json, .yaml or .yml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode hosts from %s: %w", path, err)
+	}
+	if err := validateHosts(hosts); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// validateHosts checks every host has a name and a supported voice, and that names are unique,
+// as hosts are looked up by name. the error names the offending host by its position and name.
+func validateHosts(hosts []podcast.Host) error {
+	if len(hosts) == 0 {
+		return errors.New("no hosts defined")
+	}
+	seen := make(map[string]int, len(hosts))
+	for i, host := range hosts {
+		switch {
+		case strings.TrimSpace(host.Name) == "":
+			return fmt.Errorf("host %d has no name", i+1)
+		case host.Voice == "":
+			return fmt.Errorf("host %d (%s) has no voice", i+1, host.Name)
 		}
-		if err := playSegment(playParams, audioProcessor); err != nil {
+		if err := ai.ValidateVoice(host.Voice); err != nil {
+			return fmt.Errorf("host %d (%s): %w", i+1, host.Name, err)
+		}
+		if first, ok := seen[host.Name]; ok {
+			return fmt.Errorf("host %d (%s) has the same name as host %d, names must be unique", i+1, host.Name, first)
+		}
+		seen[host.Name] = i + 1
+	}
+	return nil
+}
+
+// applyHostWeights sets host turn weights from a comma-separated list of name=weight pairs.
+// hosts not listed keep the default weight, an empty list leaves the hosts unchanged.
+func applyHostWeights(hosts []podcast.Host, spec string) ([]podcast.Host, error) {
+	if strings.TrimSpace(spec) == "" {
+		return hosts, nil
+	}
+	result := make([]podcast.Host, len(hosts))
+	copy(result, hosts)
+	for _, pair := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pair %q, expected name=weight", strings.TrimSpace(pair))
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("invalid weight %q of %s, expected a positive number", strings.TrimSpace(value), name)
+		}
+		name = strings.TrimSpace(name)
+		if err := validateIntroHost(name, result); err != nil {
 			return nil, err
 		}
+		for i := range result {
+			if result[i].Name == name {
+				result[i].Weight = weight
+			}
+		}
 	}
+	return result, nil
+}
 
-	return &filename, nil
+// parseAdBreakPosition parses an ad break position given either as a fraction (0.5) or minutes of speech (5m)
+func parseAdBreakPosition(value string) (position, afterMinutes float64, err error) {
+	value = strings.TrimSpace(value)
+	if minutes, ok := strings.CutSuffix(value, "m"); ok {
+		afterMinutes, err = strconv.ParseFloat(minutes, 64)
+		if err != nil || afterMinutes <= 0 {
+			return 0, 0, fmt.Errorf("invalid minutes %q", value)
+		}
+		return 0, afterMinutes, nil
+	}
+
+	position, err = strconv.ParseFloat(value, 64)
+	if err != nil || position < 0 || position > 1 {
+		return 0, 0, fmt.Errorf("invalid fraction %q, expected a value between 0 and 1", value)
+	}
+	return position, 0, nil
 }
 
-// playSegment plays a single audio segment
-func playSegment(params podcast.PlaySegmentParams, audioProcessor AudioProcessor) error {
-	textProcessor := content.NewTextProcessor()
-	playStartTime := time.Now()
-	fmt.Printf("\nPlaying audio from %s (message %d)...\n", params.Segment.Host, params.Index+1)
-	fmt.Printf("Text: %s\n", textProcessor.TruncateString(params.Segment.Msg.Content, content.DisplayTruncateLength))
+// insertAdBreak inserts the ad message at the message boundary closest to the requested position.
+// the position is measured in estimated speech time of the language, so long messages move the ad accordingly.
+func insertAdBreak(messages []podcast.Message, adBreak podcast.AdBreak, language string) []podcast.Message {
+	textProcessor := textProcessorFor(language)
 
-	err := audioProcessor.Play(params.Filename)
-	if err != nil {
-		fmt.Printf("Error playing segment %d: %v\n", params.Index, err)
-		return fmt.Errorf("failed to play audio: %w", err)
+	targetSeconds := adBreak.Position * textProcessor.EstimateTotalDuration(messages)
+	if adBreak.AfterMinutes > 0 {
+		targetSeconds = adBreak.AfterMinutes * 60
 	}
 
-	playDuration := time.Since(playStartTime)
-	fmt.Printf("Segment %d playback completed in %.1f seconds\n", params.Index, playDuration.Seconds())
-	return nil
+	index := len(messages)
+	elapsed := 0.0
+	for i, msg := range messages {
+		if elapsed >= targetSeconds {
+			index = i
+			break
+		}
+		elapsed += textProcessor.EstimateAudioDuration(msg.Content)
+	}
+
+	adHost := episodeLanguage(language).AdHost()
+	adContent := adBreak.Text
+	if adContent == "" {
+		adContent = adHost
+	}
+	adMsg := podcast.Message{Host: adHost, Content: adContent, Ad: true, AudioFile: adBreak.AudioFile}
+
+	result := make([]podcast.Message, 0, len(messages)+1)
+	result = append(result, messages[:index]...)
+	result = append(result, adMsg)
+	return append(result, messages[index:]...)
 }
 
-// createSpeechRequest creates a speech generation request for the given message
-func createSpeechRequest(params podcast.CreateSpeechRequestParams) podcast.SpeechGenerationRequest {
-	gender := "female" // default
-	voice := "nova"    // default
-	if info, ok := params.HostMap[params.Msg.Host]; ok {
-		gender = info.Gender
-		voice = info.Voice
+// addSilence surrounds ad segments with -ad-silence-ms of silence and puts -pause-ms of silence between the segments
+// of consecutive messages, audioFiles must be aligned with messages
+func addSilence(audioFiles []string, messages []podcast.Message, config podcast.Config, tempDir string,
+	audioProcessor AudioProcessor) ([]string, error) {
+	adSilenceMs := config.AdBreak.SilenceMs
+	if (adSilenceMs <= 0 && config.PauseMs <= 0) || len(audioFiles) != len(messages) {
+		return audioFiles, nil
 	}
 
-	return podcast.SpeechGenerationRequest{
-		Msg:    params.Msg,
-		Index:  params.Index,
-		Gender: gender,
-		Voice:  voice,
-		Speed:  1.0,
-		APIKey: params.APIKey,
+	pauseFile, err := pauseSilence(config.PauseMs, tempDir, audioProcessor)
+	if err != nil {
+		return nil, err
+	}
+	adSilenceFile := ""
+	result := make([]string, 0, 2*len(audioFiles)+2)
+	for i, file := range audioFiles {
+		if i > 0 && pauseFile != "" {
+			result = append(result, pauseFile)
+		}
+		if !messages[i].Ad || adSilenceMs <= 0 {
+			result = append(result, file)
+			continue
+		}
+		if adSilenceFile == "" {
+			if adSilenceFile, err = audioProcessor.InsertSilence(adSilenceMs, tempDir); err != nil {
+				return nil, fmt.Errorf("failed to create ad silence: %w", err)
+			}
+		}
+		result = append(result, adSilenceFile, file, adSilenceFile)
+	}
+	return result, nil
+}
+
+// pauseSilence creates the silence put between messages, it returns an empty path when pauseMs isn't set
+func pauseSilence(pauseMs int, tempDir string, audioProcessor AudioProcessor) (string, error) {
+	if pauseMs <= 0 {
+		return "", nil
+	}
+	pauseFile, err := audioProcessor.InsertSilence(pauseMs, tempDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to create silence between messages: %w", err)
 	}
+	return pauseFile, nil
 }
@@ -1,13 +1,34 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/radio-t/ai-podcast/cmd/ai-podcast/mocks"
+	"github.com/radio-t/ai-podcast/internal/ai"
+	"github.com/radio-t/ai-podcast/internal/audio"
+	"github.com/radio-t/ai-podcast/internal/content"
+	"github.com/radio-t/ai-podcast/internal/control"
+	"github.com/radio-t/ai-podcast/internal/logging"
+	"github.com/radio-t/ai-podcast/internal/progress"
+	"github.com/radio-t/ai-podcast/internal/script"
 	"github.com/radio-t/ai-podcast/podcast"
 )
 
@@ -21,6 +42,7 @@ func TestCreateSpeechRequest(t *testing.T) {
 		name           string
 		msg            podcast.Message
 		index          int
+		fallback       podcast.HostInfo
 		expectedGender string
 		expectedVoice  string
 	}{
@@ -38,15 +60,40 @@ func TestCreateSpeechRequest(t *testing.T) {
 			expectedGender: "female",
 			expectedVoice:  "nova",
 		},
+		{
+			name:           "host not in map uses configured defaults",
+			msg:            podcast.Message{Host: "UnknownHost", Content: "Test content"},
+			index:          2,
+			fallback:       podcast.HostInfo{Gender: "male", Voice: "ash"},
+			expectedGender: "male",
+			expectedVoice:  "ash",
+		},
+		{
+			name:           "host in map ignores configured defaults",
+			msg:            podcast.Message{Host: "Host2", Content: "Test content"},
+			index:          3,
+			fallback:       podcast.HostInfo{Gender: "male", Voice: "ash"},
+			expectedGender: "female",
+			expectedVoice:  "nova",
+		},
+		{
+			name:           "partial configured defaults",
+			msg:            podcast.Message{Host: "UnknownHost", Content: "Test content"},
+			index:          4,
+			fallback:       podcast.HostInfo{Voice: "sage"},
+			expectedGender: "female",
+			expectedVoice:  "sage",
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			params := podcast.CreateSpeechRequestParams{
-				Msg:     test.msg,
-				Index:   test.index,
-				HostMap: hostMap,
-				APIKey:  "test-key",
+				Msg:      test.msg,
+				Index:    test.index,
+				HostMap:  hostMap,
+				Fallback: test.fallback,
+				APIKey:   "test-key",
 			}
 
 			req := createSpeechRequest(params)
@@ -78,7 +125,7 @@ func TestGenerateAndStreamWithMocks(t *testing.T) {
 		}, nil
 	}
 
-	mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+	mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 		return []byte("audio data"), nil
 	}
 
@@ -107,7 +154,7 @@ func TestGenerateAndStreamWithMocks(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "Test Discussion", discussion.Title)
 
-	audio, err := mockOpenAI.GenerateSpeech("test", "echo")
+	audio, err := mockOpenAI.GenerateSpeech("test", "echo", "", "")
 	require.NoError(t, err)
 	assert.Equal(t, []byte("audio data"), audio)
 
@@ -190,7 +237,7 @@ func TestRunWithDependencies(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			mockArticle := &mocks.ArticleFetcherMock{}
 			mockOpenAI := &mocks.OpenAIClientMock{}
-			mockAudio := &mocks.AudioProcessorMock{}
+			mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
 
 			// setup mocks
 			if test.fetchError {
@@ -219,7 +266,7 @@ func TestRunWithDependencies(t *testing.T) {
 				}
 			}
 
-			mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+			mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 				return []byte("audio data"), nil
 			}
 
@@ -246,7 +293,7 @@ func TestRunWithDependencies(t *testing.T) {
 				}
 			}
 
-			err := runWithDependencies(test.config, mockArticle, mockOpenAI, mockAudio)
+			err := runWithDependencies(t.Context(), test.config, mockArticle, mockOpenAI, mockAudio)
 
 			if test.expectedError != "" {
 				require.Error(t, err)
@@ -284,7 +331,7 @@ func TestGenerateAndStreamToIcecast(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			mockOpenAI := &mocks.OpenAIClientMock{}
-			mockAudio := &mocks.AudioProcessorMock{}
+			mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
 
 			hosts := []podcast.Host{
 				{Name: "host1", Voice: "nova", Gender: "female"},
@@ -308,11 +355,11 @@ func TestGenerateAndStreamToIcecast(t *testing.T) {
 			}
 
 			if test.speechError {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return nil, assert.AnError
 				}
 			} else {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return []byte("audio data"), nil
 				}
 			}
@@ -327,7 +374,7 @@ func TestGenerateAndStreamToIcecast(t *testing.T) {
 				}
 			}
 
-			err := generateAndStreamToIcecast(params, mockOpenAI, mockAudio)
+			err := generateAndStreamToIcecast(t.Context(), params, mockOpenAI, mockAudio)
 
 			if test.expectedError != "" {
 				require.Error(t, err)
@@ -337,6 +384,21 @@ func TestGenerateAndStreamToIcecast(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("discussion kept in the work dir", func(t *testing.T) {
+		workDir := t.TempDir()
+		discussion := podcast.Discussion{Title: "test discussion", Messages: []podcast.Message{{Host: "host1", Content: "hello"}}}
+		params := podcast.GenerateAndStreamParams{Discussion: discussion, Config: podcast.Config{WorkDir: workDir,
+			IcecastURL: "localhost:8000", IcecastMount: "/test"}}
+		mockOpenAI := &mocks.OpenAIClientMock{GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		}}
+		require.NoError(t, generateAndStreamToIcecast(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{}))
+
+		kept, err := loadStreamedDiscussion(workDir)
+		require.NoError(t, err)
+		assert.Equal(t, discussion, kept)
+	})
 }
 
 func TestGenerateAndPlayLocally(t *testing.T) {
@@ -398,11 +460,11 @@ func TestGenerateAndPlayLocally(t *testing.T) {
 			params.Config.OpenAIAPIKey = "test-key"
 
 			if test.speechError {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return nil, assert.AnError
 				}
 			} else {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return []byte("audio data"), nil
 				}
 			}
@@ -474,16 +536,16 @@ func TestGenerateSpeechSegments(t *testing.T) {
 			}
 
 			if test.speechError {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return nil, assert.AnError
 				}
 			} else {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return []byte("audio data"), nil
 				}
 			}
 
-			audioFiles, err := generateSpeechSegments(params, mockOpenAI)
+			audioFiles, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
 
 			if test.expectedError != "" {
 				require.Error(t, err)
@@ -499,6 +561,290 @@ func TestGenerateSpeechSegments(t *testing.T) {
 	}
 }
 
+func TestGenerateSpeechSegmentsFallbackVoice(t *testing.T) {
+	var voices []string
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			voices = append(voices, voice)
+			return []byte("audio data"), nil
+		},
+	}
+	params := podcast.GenerateSpeechSegmentsParams{
+		Messages: []podcast.Message{{Host: "host1", Content: "hello"}, {Host: "guest", Content: "world"}},
+		HostMap:  map[string]podcast.HostInfo{"host1": {Voice: "echo", Gender: "male"}},
+		Fallback: podcast.HostInfo{Voice: "coral", Gender: "female"},
+		TempDir:  t.TempDir(),
+	}
+
+	_, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", "coral"}, voices)
+}
+
+func TestGenerateSpeechSegmentsConcurrency(t *testing.T) {
+	messages := make([]podcast.Message, 8)
+	for i := range messages {
+		messages[i] = podcast.Message{Host: "host1", Content: fmt.Sprintf("msg%d", i)}
+	}
+	newParams := func() podcast.GenerateSpeechSegmentsParams {
+		return podcast.GenerateSpeechSegmentsParams{Messages: messages, TempDir: t.TempDir(), Concurrency: 3,
+			HostMap: map[string]podcast.HostInfo{"host1": {Voice: "nova"}}}
+	}
+
+	t.Run("files keep message order", func(t *testing.T) {
+		var inFlight, maxInFlight atomic.Int32
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					prev := maxInFlight.Load()
+					if n <= prev || maxInFlight.CompareAndSwap(prev, n) {
+						break
+					}
+				}
+				// earlier messages take longer, so requests complete in reverse order
+				var index int
+				_, _ = fmt.Sscanf(text, "msg%d", &index)
+				time.Sleep(time.Duration(len(messages)-index) * 5 * time.Millisecond)
+				return []byte("audio " + text), nil
+			},
+		}
+		params := newParams()
+		audioFiles, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
+		require.NoError(t, err)
+		require.Len(t, audioFiles, len(messages))
+		for i, filename := range audioFiles {
+			assert.Equal(t, segmentFileName(params.TempDir, i), filename)
+			data, err := os.ReadFile(filename) // #nosec G304 -- test file
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("audio msg%d", i), string(data))
+		}
+		assert.Equal(t, int32(3), maxInFlight.Load(), "up to the concurrency requests in flight")
+	})
+
+	t.Run("failure stops dispatching", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				if text == "msg1" {
+					return nil, errors.New("tts failed")
+				}
+				time.Sleep(20 * time.Millisecond)
+				return []byte("audio data"), nil
+			},
+		}
+		audioFiles, err := generateSpeechSegments(t.Context(), newParams(), mockOpenAI, &mocks.AudioProcessorMock{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to generate speech for message 1: tts failed")
+		assert.Nil(t, audioFiles)
+		assert.Less(t, len(mockOpenAI.GenerateSpeechCalls()), len(messages), "remaining messages are not synthesized")
+	})
+
+	t.Run("canceled run stops dispatching", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				if text == "msg1" {
+					cancel() // Ctrl-C or a shutdown from the control endpoint during the run
+				}
+				time.Sleep(20 * time.Millisecond)
+				return []byte("audio data"), nil
+			},
+		}
+		audioFiles, err := generateSpeechSegments(ctx, newParams(), mockOpenAI, &mocks.AudioProcessorMock{})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, audioFiles)
+		assert.Less(t, len(mockOpenAI.GenerateSpeechCalls()), len(messages), "remaining messages are not synthesized")
+	})
+}
+
+func TestProgressEvents(t *testing.T) {
+	messages := []podcast.Message{
+		{Host: "host1", Content: "msg0"}, {Host: "host2", Content: "msg1"}, {Host: "host1", Content: "msg2"},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio " + text), nil
+		},
+	}
+	readEvents := func(t *testing.T, data []byte) []progress.Event {
+		var events []progress.Event
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var event progress.Event
+			require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+			events = append(events, event)
+		}
+		return events
+	}
+	// checkSegments verifies one segment event per message between the start and end events of the stage
+	checkSegments := func(t *testing.T, events []progress.Event, stage string) {
+		require.Len(t, events, len(messages)+2)
+		assert.Equal(t, progress.Event{Stage: stage, Event: progress.EventStart, Total: len(messages)}, withoutElapsed(events[0]))
+		assert.Equal(t, progress.Event{Stage: stage, Event: progress.EventEnd, Total: len(messages)},
+			withoutElapsed(events[len(events)-1]))
+		var segments []progress.Event
+		for _, event := range events[1 : len(events)-1] {
+			segments = append(segments, withoutElapsed(event))
+		}
+		sort.Slice(segments, func(i, j int) bool { return segments[i].Index < segments[j].Index })
+		for i, event := range segments {
+			assert.Equal(t, progress.Event{Stage: stage, Event: progress.EventSegment, Index: i + 1, Total: len(messages),
+				Host: messages[i].Host}, event)
+		}
+	}
+
+	t.Run("generated segments", func(t *testing.T) {
+		var buf bytes.Buffer
+		params := podcast.GenerateSpeechSegmentsParams{Messages: messages, TempDir: t.TempDir(), Concurrency: 2,
+			HostMap:  map[string]podcast.HostInfo{"host1": {Voice: "nova"}, "host2": {Voice: "onyx"}},
+			Progress: progress.New(&buf)}
+		_, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
+		require.NoError(t, err)
+		checkSegments(t, readEvents(t, buf.Bytes()), progress.StageTTS)
+	})
+
+	t.Run("played and saved segments", func(t *testing.T) {
+		var buf bytes.Buffer
+		params := podcast.GenerateAndStreamParams{
+			Discussion: podcast.Discussion{Title: "test discussion", Messages: messages},
+			Config: podcast.Config{DryRun: true, OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+				Hosts:    []podcast.Host{{Name: "host1", Voice: "nova"}, {Name: "host2", Voice: "onyx"}},
+				Progress: progress.New(&buf)},
+		}
+		mockAudio := &mocks.AudioProcessorMock{
+			PlayFunc:        func(filename string) error { return nil },
+			ConcatenateFunc: func(files []string, outputFile string) error { return nil },
+		}
+		require.NoError(t, generateAndPlayLocally(params, mockOpenAI, mockAudio))
+		events := readEvents(t, buf.Bytes())
+		require.Len(t, events, len(messages)+4)
+		checkSegments(t, events[:len(messages)+2], progress.StageTTS)
+		for i, event := range events[1 : len(messages)+1] {
+			assert.Equal(t, i+1, event.Index, "played in order")
+		}
+		assert.Equal(t, progress.Event{Stage: progress.StageConcat, Event: progress.EventStart, Total: len(messages)},
+			withoutElapsed(events[len(messages)+2]))
+		assert.Equal(t, progress.Event{Stage: progress.StageConcat, Event: progress.EventEnd, Total: len(messages)},
+			withoutElapsed(events[len(messages)+3]))
+	})
+
+	t.Run("no events without an emitter", func(t *testing.T) {
+		params := podcast.GenerateSpeechSegmentsParams{Messages: messages, TempDir: t.TempDir(),
+			HostMap: map[string]podcast.HostInfo{"host1": {Voice: "nova"}}}
+		_, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
+		require.NoError(t, err)
+	})
+}
+
+// withoutElapsed returns the event with zero elapsed time, for comparing events of a real clock
+func withoutElapsed(event progress.Event) progress.Event {
+	event.ElapsedMs = 0
+	return event
+}
+
+func TestGenerateSpeechHostTTSModel(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx", TTSModel: "gpt-4o-audio-preview"}, {Name: "Мария", Voice: "nova"}}
+	messages := []podcast.Message{{Host: "Алексей", Content: "first"}, {Host: "Мария", Content: "second"}, {Host: "Гость", Content: "third"}}
+	newOpenAI := func() (*mocks.OpenAIClientMock, map[string]string) {
+		var mu sync.Mutex
+		models := make(map[string]string) // text to model
+		return &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				models[text] = model
+				return []byte("audio data"), nil
+			},
+		}, models
+	}
+	expected := map[string]string{"first": "gpt-4o-audio-preview", "second": "", "third": ""}
+
+	t.Run("segments", func(t *testing.T) {
+		mockOpenAI, models := newOpenAI()
+		params := podcast.GenerateSpeechSegmentsParams{Messages: messages, HostMap: podcast.CreateHostMap(hosts),
+			TempDir: t.TempDir()}
+		_, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
+		require.NoError(t, err)
+		assert.Equal(t, expected, models, "host override used, others keep the global model")
+	})
+
+	t.Run("local generation", func(t *testing.T) {
+		mockOpenAI, models := newOpenAI()
+		params := podcast.GenerateAndStreamParams{Discussion: podcast.Discussion{Messages: messages},
+			Config: podcast.Config{OutputFile: "out.mp3", Hosts: hosts}}
+		require.NoError(t, generateAndPlayLocally(params, mockOpenAI, &mocks.AudioProcessorMock{}))
+		assert.Equal(t, expected, models, "host override used, others keep the global model")
+	})
+
+	t.Run("preflight checks each voice and model", func(t *testing.T) {
+		mockOpenAI, _ := newOpenAI()
+		config := podcast.Config{Hosts: append(slices.Clone(hosts), podcast.Host{Name: "Дмитрий", Voice: "onyx"})}
+		require.NoError(t, preflightTTS(config, mockOpenAI))
+		calls := mockOpenAI.GenerateSpeechCalls()
+		require.Len(t, calls, 3, "onyx is checked with both models")
+		assert.Equal(t, "gpt-4o-audio-preview", calls[0].Model)
+		assert.Empty(t, calls[2].Model)
+		assert.Equal(t, "onyx", calls[2].Voice)
+	})
+}
+
+func TestValidateDefaultHost(t *testing.T) {
+	require.NoError(t, validateDefaultHost("", ""))
+	require.NoError(t, validateDefaultHost("shimmer", "male"))
+
+	err := validateDefaultHost("robot", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid -default-voice: unknown voice "robot"`)
+
+	err = validateDefaultHost("nova", "other")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid -default-gender "other"`)
+}
+
+func TestGenerateSpeechSegmentsTagSegments(t *testing.T) {
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	params := podcast.GenerateSpeechSegmentsParams{
+		Messages: []podcast.Message{
+			{Host: "host1", Content: "hello"},
+			{Host: "host2", Content: "world"},
+		},
+		HostMap: map[string]podcast.HostInfo{
+			"host1": {Voice: "nova", Gender: "female"},
+			"host2": {Voice: "echo", Gender: "male"},
+		},
+		TempDir: t.TempDir(),
+	}
+
+	t.Run("tagged", func(t *testing.T) {
+		params.TagSegments = true
+		audioFiles, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
+		require.NoError(t, err)
+		require.Len(t, audioFiles, 2)
+
+		for i, expected := range []string{"000 host1", "001 host2"} {
+			data, err := os.ReadFile(audioFiles[i])
+			require.NoError(t, err)
+			title, err := audio.ReadID3Title(data)
+			require.NoError(t, err)
+			assert.Equal(t, expected, title)
+			assert.True(t, bytes.HasSuffix(data, []byte("audio data")))
+		}
+	})
+
+	t.Run("untagged by default", func(t *testing.T) {
+		params.TagSegments = false
+		audioFiles, err := generateSpeechSegments(t.Context(), params, mockOpenAI, &mocks.AudioProcessorMock{})
+		require.NoError(t, err)
+		data, err := os.ReadFile(audioFiles[0])
+		require.NoError(t, err)
+		assert.Equal(t, []byte("audio data"), data)
+	})
+}
+
 func TestSpeechGenerationWorker(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -528,11 +874,11 @@ func TestSpeechGenerationWorker(t *testing.T) {
 			}
 
 			if test.speechError {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return nil, assert.AnError
 				}
 			} else {
-				mockOpenAI.GenerateSpeechFunc = func(text, voice string) ([]byte, error) {
+				mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
 					return []byte("audio data"), nil
 				}
 			}
@@ -805,3 +1151,3412 @@ func TestPlaySegment(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAdBreakPosition(t *testing.T) {
+	tests := []struct {
+		name            string
+		value           string
+		expectedPos     float64
+		expectedMinutes float64
+		expectedError   bool
+	}{
+		{name: "fraction", value: "0.5", expectedPos: 0.5},
+		{name: "minutes", value: "5m", expectedMinutes: 5},
+		{name: "fractional minutes", value: "2.5m", expectedMinutes: 2.5},
+		{name: "fraction above one", value: "1.5", expectedError: true},
+		{name: "negative minutes", value: "-1m", expectedError: true},
+		{name: "garbage", value: "middle", expectedError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pos, minutes, err := parseAdBreakPosition(test.value)
+			if test.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, test.expectedPos, pos, 0.001)
+			assert.InDelta(t, test.expectedMinutes, minutes, 0.001)
+		})
+	}
+}
+
+func TestReadIcecastCredentials(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedUser  string
+		expectedPass  string
+		expectedError string
+	}{
+		{name: "user:pass", content: "source:s3cret\n", expectedUser: "source", expectedPass: "s3cret"},
+		{name: "whitespace trimmed", content: "  \n source : s3cret \n\n", expectedUser: "source", expectedPass: "s3cret"},
+		{name: "colon and equals in password", content: "source:a:b=c", expectedUser: "source", expectedPass: "a:b=c"},
+		{name: "separate fields", content: "# icecast\nuser = dj\npass = s3cr=t\n", expectedUser: "dj", expectedPass: "s3cr=t"},
+		{name: "missing separator", content: "source", expectedError: "expected user:pass"},
+		{name: "empty password", content: "source:", expectedError: "both user and password are required"},
+		{name: "missing user field", content: "pass=s3cret", expectedError: "both user and password are required"},
+		{name: "unknown line", content: "user=dj\npassword=s3cret", expectedError: `invalid line "password=s3cret"`},
+		{name: "empty file", content: "\n", expectedError: "both user and password are required"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "icecast.cred")
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+
+			user, pass, err := readIcecastCredentials(path)
+			if test.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedUser, user)
+			assert.Equal(t, test.expectedPass, pass)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, _, err := readIcecastCredentials(filepath.Join(t.TempDir(), "missing"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read credentials")
+	})
+}
+
+func TestRunWithDependenciesVoiceCompare(t *testing.T) {
+	newOpenAI := func() *mocks.OpenAIClientMock {
+		return &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				return podcast.Discussion{Messages: []podcast.Message{
+					{Host: "Мария", Content: "первая реплика", Emotion: "excited"}, {Host: "Алексей", Content: "вторая"},
+				}}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte(voice + ": " + text), nil
+			},
+		}
+	}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	mockAudio := &mocks.AudioProcessorMock{}
+
+	t.Run("given text", func(t *testing.T) {
+		dir := t.TempDir()
+		mockOpenAI := newOpenAI()
+		config := podcast.Config{WorkDir: dir, VoiceCompare: "onyx, echo,ash", VoiceCompareText: "Привет!"}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		require.Len(t, mockOpenAI.GenerateSpeechCalls(), 3)
+		for _, voice := range []string{"onyx", "echo", "ash"} {
+			data, err := os.ReadFile(filepath.Join(dir, "voice_"+voice+".mp3")) // #nosec G304 -- test file
+			require.NoError(t, err)
+			assert.Equal(t, voice+": Привет!", string(data))
+		}
+		assert.Empty(t, mockOpenAI.GenerateDiscussionCalls(), "no discussion for a given text")
+		assert.Empty(t, mockAudio.PlayCalls())
+	})
+
+	t.Run("first message of the discussion", func(t *testing.T) {
+		dir := t.TempDir()
+		mockOpenAI := newOpenAI()
+		config := podcast.Config{ArticleURL: "http://example.com", WorkDir: dir, VoiceCompare: "nova,coral"}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		calls := mockOpenAI.GenerateSpeechCalls()
+		require.Len(t, calls, 2)
+		for _, call := range calls {
+			assert.Equal(t, "первая реплика", call.Text)
+			assert.Equal(t, "excited", call.Emotion)
+		}
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		assert.Equal(t, "voice_coral.mp3", files[0].Name())
+		assert.Equal(t, "voice_nova.mp3", files[1].Name())
+	})
+
+	t.Run("unknown voice", func(t *testing.T) {
+		mockOpenAI := newOpenAI()
+		config := podcast.Config{WorkDir: t.TempDir(), VoiceCompare: "onyx,robot", VoiceCompareText: "Привет!"}
+
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid -voice-compare: unknown voice "robot"`)
+		assert.Empty(t, mockOpenAI.GenerateSpeechCalls())
+	})
+
+	t.Run("speech failure", func(t *testing.T) {
+		mockOpenAI := newOpenAI()
+		mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
+			return nil, assert.AnError
+		}
+		config := podcast.Config{WorkDir: t.TempDir(), VoiceCompare: "onyx", VoiceCompareText: "Привет!"}
+
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to generate speech with voice onyx")
+	})
+
+	t.Run("no voices", func(t *testing.T) {
+		config := podcast.Config{WorkDir: t.TempDir(), VoiceCompare: " , ", VoiceCompareText: "Привет!"}
+		err := runWithDependencies(t.Context(), config, mockArticle, newOpenAI(), mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no voices given")
+	})
+}
+
+func TestRunWithDependenciesPreflight(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}, {Name: "Дмитрий", Voice: "onyx"}}
+	newMocks := func(speechErr error) (*mocks.ArticleFetcherMock, *mocks.OpenAIClientMock) {
+		mockArticle := &mocks.ArticleFetcherMock{
+			FetchFunc: func(url string) (string, string, error) {
+				return "article text", "Test Article", nil
+			},
+		}
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				return podcast.Discussion{Messages: []podcast.Message{{Host: "Мария", Content: "line"}}}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio data"), speechErr
+			},
+		}
+		return mockArticle, mockOpenAI
+	}
+
+	t.Run("each voice checked before the run", func(t *testing.T) {
+		mockArticle, mockOpenAI := newMocks(nil)
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3",
+			TargetDuration: 5, Preflight: true}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+		calls := mockOpenAI.GenerateSpeechCalls()
+		require.Len(t, calls, 3, "two preflight voices and the discussion line")
+		assert.Equal(t, "Проверка.", calls[0].Text)
+		assert.Equal(t, "onyx", calls[0].Voice)
+		assert.Equal(t, "Проверка.", calls[1].Text)
+		assert.Equal(t, "nova", calls[1].Voice)
+		assert.Equal(t, "line", calls[2].Text)
+	})
+
+	t.Run("failure aborts before fetching", func(t *testing.T) {
+		mockArticle, mockOpenAI := newMocks(assert.AnError)
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3",
+			TargetDuration: 5, Preflight: true}
+
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "preflight TTS check with voice onyx failed")
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Len(t, mockOpenAI.GenerateSpeechCalls(), 1)
+		assert.Empty(t, mockArticle.FetchCalls())
+		assert.Empty(t, mockOpenAI.GenerateDiscussionCalls())
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		mockArticle, mockOpenAI := newMocks(nil)
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3", TargetDuration: 5}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+		require.Len(t, mockOpenAI.GenerateSpeechCalls(), 1)
+		assert.Equal(t, "line", mockOpenAI.GenerateSpeechCalls()[0].Text)
+	})
+
+	t.Run("once per url list", func(t *testing.T) {
+		dir := t.TempDir()
+		listFile := filepath.Join(dir, "urls.txt")
+		require.NoError(t, os.WriteFile(listFile, []byte("http://a.example\nhttp://b.example\n"), 0o600))
+		mockArticle, mockOpenAI := newMocks(nil)
+		config := podcast.Config{Hosts: hosts[:1], URLList: listFile, Checkpoint: filepath.Join(dir, "urls.done"),
+			OutputFile: filepath.Join(dir, "podcast.mp3"), TargetDuration: 5, Preflight: true}
+
+		require.NoError(t, runURLList(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+		var texts []string
+		for _, call := range mockOpenAI.GenerateSpeechCalls() {
+			texts = append(texts, call.Text)
+		}
+		assert.Equal(t, []string{"Проверка.", "line", "line"}, texts)
+	})
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"onyx", "echo", "ash"}, splitList(" onyx, echo,,ash ,"))
+	assert.Empty(t, splitList(""))
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders([]string{"cookie: consent=yes; lang=ru", " Referer :https://news.example.com/", "X-Empty:"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Cookie":  "consent=yes; lang=ru",
+		"Referer": "https://news.example.com/",
+		"X-Empty": "",
+	}, headers)
+
+	headers, err = parseHeaders(nil)
+	require.NoError(t, err)
+	assert.Nil(t, headers)
+
+	for _, invalid := range []string{"Cookie", ": value", "Bad Name: value"} {
+		_, err = parseHeaders([]string{invalid})
+		assert.EqualError(t, err, fmt.Sprintf("invalid header %q, expected \"Name: value\"", invalid))
+	}
+}
+
+func TestParseMounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    []string
+		mount   string
+		targets []podcast.IcecastTarget
+	}{
+		{name: "default", mount: "/podcast.mp3"},
+		{name: "one plain mount", list: []string{"/live.mp3"}, mount: "/live.mp3"},
+		{name: "one mount with bitrate", list: []string{"/live.mp3:96k"}, mount: "/live.mp3",
+			targets: []podcast.IcecastTarget{{Mount: "/live.mp3", Bitrate: "96k"}}},
+		{name: "several mounts", list: []string{"/podcast.mp3:128k", " /podcast-low.mp3 : 64k", "/podcast-raw.mp3"},
+			mount: "/podcast.mp3", targets: []podcast.IcecastTarget{
+				{Mount: "/podcast.mp3", Bitrate: "128k"}, {Mount: "/podcast-low.mp3", Bitrate: "64k"}, {Mount: "/podcast-raw.mp3"},
+			}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mount, targets, err := parseMounts(test.list)
+			require.NoError(t, err)
+			assert.Equal(t, test.mount, mount)
+			assert.Equal(t, test.targets, targets)
+		})
+	}
+
+	for _, invalid := range []string{"", "/live.mp3:", ":64k"} {
+		_, _, err := parseMounts([]string{"/podcast.mp3", invalid})
+		assert.EqualError(t, err, fmt.Sprintf("invalid mount %q, expected /path or /path:bitrate", invalid))
+	}
+}
+
+func TestMessageOutput(t *testing.T) {
+	assert.Equal(t, os.Stdout, messageOutput("episode.mp3", "progress.jsonl"))
+	assert.Equal(t, os.Stderr, messageOutput("-", ""), "stdout carries the audio")
+	assert.Equal(t, os.Stderr, messageOutput("", "-"), "stdout carries the progress events")
+}
+
+func TestPrintEstimate(t *testing.T) {
+	var out bytes.Buffer
+	printEstimate(&out, 10, 0, 1)
+	assert.Equal(t, "Projected discussion: 20 messages, about 10 minutes of speech (2 messages per minute)\n", out.String())
+
+	out.Reset()
+	printEstimate(&out, 15, 2, 3)
+	assert.Contains(t, out.String(), "Projected discussion: 30 messages, about 15 minutes")
+	assert.Contains(t, out.String(), "Split into 3 episodes: 90 messages, about 45 minutes in total")
+
+	out.Reset()
+	printEstimate(&out, 1, 0, 1)
+	assert.Contains(t, out.String(), "Projected discussion: 4 messages, about 1 minutes", "a short episode gets the minimum")
+
+	out.Reset()
+	printEstimate(&out, 10, 3, 1)
+	assert.Equal(t, "Projected discussion: 30 messages, about 10 minutes of speech (3 messages per minute)\n", out.String())
+}
+
+func TestApplyHostWeights(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей"}, {Name: "Мария"}, {Name: "Дмитрий"}}
+	tests := []struct {
+		name          string
+		spec          string
+		expected      []float64
+		expectedError string
+	}{
+		{name: "empty", spec: "", expected: []float64{0, 0, 0}},
+		{name: "all hosts", spec: "Алексей=2,Мария=1,Дмитрий=0.5", expected: []float64{2, 1, 0.5}},
+		{name: "some hosts with spaces", spec: " Мария = 3 ", expected: []float64{0, 3, 0}},
+		{name: "unknown host", spec: "Иван=2", expectedError: `unknown host "Иван"`},
+		{name: "missing weight", spec: "Алексей", expectedError: "expected name=weight"},
+		{name: "zero weight", spec: "Алексей=0", expectedError: "expected a positive number"},
+		{name: "not a number", spec: "Алексей=много", expectedError: "expected a positive number"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := applyHostWeights(hosts, test.spec)
+			if test.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, result, len(hosts))
+			for i, weight := range test.expected {
+				assert.InDelta(t, weight, result[i].Weight, 0.001, result[i].Name)
+			}
+			assert.Zero(t, hosts[0].Weight, "input hosts are not modified")
+		})
+	}
+}
+
+func TestVoiceStyles(t *testing.T) {
+	hosts := []podcast.Host{
+		{Name: "Анна", Character: "ведущая, любит шутки", Voice: "coral"},
+		{Name: "Борис", Character: "скептик", Voice: "ash"},
+		{Name: "Вера", Character: "аналитик", Voice: "coral"},
+		{Name: "Глеб", Voice: "sage"},
+	}
+	assert.Equal(t, map[string]string{"coral": "ведущая, любит шутки", "ash": "скептик"}, voiceStyles(hosts),
+		"the first host of a shared voice wins, hosts without a character are skipped")
+	assert.Empty(t, voiceStyles(nil))
+}
+
+func TestConfiguredHosts(t *testing.T) {
+	t.Run("built-in hosts", func(t *testing.T) {
+		hosts, err := configuredHosts("", "Мария=2")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Алексей", "Мария", "Дмитрий"}, hostNames(hosts))
+		assert.InDelta(t, 2, hosts[1].Weight, 0.001)
+	})
+
+	t.Run("hosts file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts.json")
+		require.NoError(t, os.WriteFile(path, []byte(`[{"name": "Анна", "voice": "shimmer"}, {"name": "Борис", "voice": "echo"}]`), 0o600))
+		hosts, err := configuredHosts(path, "Борис=3")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Анна", "Борис"}, hostNames(hosts))
+		assert.InDelta(t, 3, hosts[1].Weight, 0.001)
+	})
+
+	t.Run("invalid hosts file", func(t *testing.T) {
+		_, err := configuredHosts(filepath.Join(t.TempDir(), "missing.yaml"), "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid -hosts: failed to read hosts")
+	})
+
+	t.Run("weight of a built-in host with a hosts file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hosts.yml")
+		require.NoError(t, os.WriteFile(path, []byte("- name: Анна\n  voice: shimmer\n"), 0o600))
+		_, err := configuredHosts(path, "Мария=2")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid -host-weights: unknown host "Мария"`)
+	})
+}
+
+// keepTempo stubs AudioProcessor.ChangeTempo returning the files as they are
+func keepTempo(inputFiles []string, _ float64, _ string) ([]string, error) {
+	return inputFiles, nil
+}
+
+// hostNames returns the names of hosts in order
+func hostNames(hosts []podcast.Host) []string {
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		names = append(names, host.Name)
+	}
+	return names
+}
+
+func TestDumpConfig(t *testing.T) {
+	config := podcast.Config{
+		Hosts:          []podcast.Host{{Name: "Алексей", Gender: "male", Voice: "onyx", Weight: 2}},
+		ArticleURL:     "https://example.com/article",
+		IcecastURL:     "localhost:8000",
+		IcecastMount:   "/podcast.mp3",
+		IcecastUser:    "source",
+		IcecastPass:    "hackme",
+		OpenAIAPIKey:   "sk-secret",
+		OpenAIBaseURL:  "http://localhost:11434/v1",
+		TargetDuration: 15,
+		OutputFile:     "episode.mp3",
+		Teaser:         30 * time.Second,
+		MusicGain:      -18,
+		AdBreak:        podcast.AdBreak{Text: "Реклама", Position: 0.5},
+		Concurrency:    podcast.ConcurrencyConfig{Fetch: 1, TTS: 3, FFmpeg: 1},
+		FetchHeaders:   map[string]string{"Cookie": "session=secret", "Referer": "https://news.example.com/"},
+		Paused:         func() bool { return false },
+		AudioOut:       io.Discard,
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, dumpConfig(path, config))
+
+	data, err := os.ReadFile(path) // #nosec G304 -- test file
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk-secret")
+	assert.NotContains(t, string(data), "hackme")
+	assert.NotContains(t, string(data), "session=secret")
+	assert.Contains(t, string(data), `"ArticleURL": "https://example.com/article"`)
+
+	loaded, err := loadConfig(path)
+	require.NoError(t, err)
+	expected := config
+	expected.OpenAIAPIKey, expected.IcecastPass, expected.Paused, expected.AudioOut = "", "", nil, nil
+	expected.FetchHeaders = map[string]string{"Cookie": "", "Referer": ""}
+	assert.Equal(t, expected, loaded, "round-trips without secrets and runtime fields")
+
+	t.Run("config file with secrets from flags", func(t *testing.T) {
+		flags := podcast.Config{ArticleURL: "https://example.com/other", OpenAIAPIKey: "sk-flag", IcecastPass: "flag-pass",
+			FetchHeaders: map[string]string{"Cookie": "session=flag", "Referer": "https://news.example.com/"}}
+		dump := filepath.Join(dir, "again.json")
+		result, err := applyConfigFiles(flags, path, dump)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/article", result.ArticleURL, "other flags are ignored")
+		assert.Equal(t, "sk-flag", result.OpenAIAPIKey)
+		assert.Equal(t, "flag-pass", result.IcecastPass)
+		assert.Equal(t, flags.FetchHeaders, result.FetchHeaders)
+
+		again, err := os.ReadFile(dump) // #nosec G304 -- test file
+		require.NoError(t, err)
+		assert.Equal(t, string(data), string(again), "reproduced run dumps the same config")
+	})
+
+	t.Run("header without value dropped", func(t *testing.T) {
+		flags := podcast.Config{FetchHeaders: map[string]string{"Cookie": "session=flag"}}
+		result, err := applyConfigFiles(flags, path, "")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"Cookie": "session=flag"}, result.FetchHeaders, "an empty Referer isn't sent")
+	})
+
+	t.Run("no-cache flag overrides the config file", func(t *testing.T) {
+		result, err := applyConfigFiles(podcast.Config{NoCache: true}, path, "")
+		require.NoError(t, err)
+		assert.True(t, result.NoCache)
+		result, err = applyConfigFiles(podcast.Config{}, path, "")
+		require.NoError(t, err)
+		assert.False(t, result.NoCache)
+	})
+
+	t.Run("no files", func(t *testing.T) {
+		result, err := applyConfigFiles(config, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, "sk-secret", result.OpenAIAPIKey)
+	})
+
+	t.Run("resolved host map", func(t *testing.T) {
+		hosts := podcast.Config{
+			Hosts: []podcast.Host{{Name: "Алексей", Gender: "male", Voice: "onyx"},
+				{Name: "Мария", Gender: "female", Voice: "nova", TTSModel: "tts-1-hd"}},
+			TTSBackend: "speech", DefaultVoice: "echo",
+		}
+		hostsFile := filepath.Join(dir, "hosts.json")
+		require.NoError(t, dumpConfig(hostsFile, hosts))
+		data, err := os.ReadFile(hostsFile) // #nosec G304 -- test file
+		require.NoError(t, err)
+		var dump configDump
+		require.NoError(t, json.Unmarshal(data, &dump))
+		assert.Equal(t, map[string]podcast.HostInfo{
+			"Алексей": {Gender: "male", Voice: "onyx", TTSModel: content.OpenAISpeechModel},
+			"Мария":   {Gender: "female", Voice: "nova", TTSModel: "tts-1-hd"},
+		}, dump.HostMap, "configured hosts with the global model of the backend filled in")
+		assert.Equal(t, podcast.HostInfo{Gender: defaultGender, Voice: "echo", TTSModel: content.OpenAISpeechModel},
+			dump.DefaultHost, "-default-voice and the built-in gender of unknown speakers")
+		assert.Equal(t, lookupHost(podcast.CreateHostMap(hosts.Hosts), "Гость", fallbackHost(hosts)).Voice, dump.DefaultHost.Voice)
+
+		loaded, err := loadConfig(hostsFile)
+		require.NoError(t, err, "the host map is accepted by -config")
+		assert.Equal(t, hosts.Hosts, loaded.Hosts)
+
+		hosts.TTSModel, hosts.DefaultGender = "gpt-4o-mini-tts", "male"
+		hostMap, fallback := resolvedHosts(hosts)
+		assert.Equal(t, "gpt-4o-mini-tts", hostMap["Алексей"].TTSModel, "-tts-model applies to hosts without their own")
+		assert.Equal(t, "tts-1-hd", hostMap["Мария"].TTSModel)
+		assert.Equal(t, podcast.HostInfo{Gender: "male", Voice: "echo", TTSModel: "gpt-4o-mini-tts"}, fallback)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.json")
+		require.NoError(t, os.WriteFile(bad, []byte(`{"ArticleURL": "https://example.com", "Voices": ["onyx"]}`), 0o600))
+		_, err := loadConfig(bad)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown field "Voices"`)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := applyConfigFiles(config, filepath.Join(dir, "missing.json"), "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read config")
+	})
+}
+
+func TestCostReport(t *testing.T) {
+	report := ai.PriceTable{"gpt-4o": {InputPerMillion: 2.5, OutputPerMillion: 10}, "gpt-4o-audio-preview": {CharsPerMillion: 60}}.Report(
+		[]ai.ModelUsage{
+			{Model: "gpt-4o", Requests: 2, PromptTokens: 10_000, CompletionTokens: 3_000},
+			{Model: "gpt-4o-audio-preview", Requests: 30, TTSChars: 20_000},
+			{Model: "llama3", Requests: 1, PromptTokens: 500, CompletionTokens: 100},
+		})
+
+	var out bytes.Buffer
+	printCostReport(&out, report)
+	assert.Equal(t, "\nEstimated API cost: $1.2550\n"+
+		"  gpt-4o: 2 requests, 10000 prompt and 3000 completion tokens, $0.0550\n"+
+		"  gpt-4o-audio-preview: 30 requests, 0 prompt and 0 completion tokens, 20000 characters of speech, $1.2000\n"+
+		"  llama3: 1 requests, 500 prompt and 100 completion tokens, no price, add the model with -prices\n", out.String())
+
+	path := filepath.Join(t.TempDir(), "cost.json")
+	require.NoError(t, saveCostReport(path, report))
+	data, err := os.ReadFile(path) // #nosec G304 -- test file
+	require.NoError(t, err)
+	var saved ai.CostReport
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, report, saved)
+	assert.Contains(t, string(data), `"total_usd": 1.255`)
+
+	err = saveCostReport(filepath.Join(t.TempDir(), "missing", "cost.json"), report)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write cost report")
+}
+
+func TestLoadHosts(t *testing.T) {
+	const yamlHosts = `- name: Анна
+  gender: female
+  character: ведущая
+  voice: shimmer
+  intro: Привет, я Анна
+  tts_model: tts-1-hd
+- name: Борис
+  voice: echo
+  weight: 2
+`
+	tests := []struct {
+		name          string
+		file          string
+		data          string
+		expectedError string
+	}{
+		{name: "yaml", file: "hosts.yaml", data: yamlHosts},
+		{name: "json", file: "hosts.json", data: `[{"name": "Анна", "gender": "female", "character": "ведущая", "voice": "shimmer",
+			"intro": "Привет, я Анна", "tts_model": "tts-1-hd"}, {"name": "Борис", "voice": "echo", "weight": 2}]`},
+		{name: "unknown field", file: "hosts.json", data: `[{"name": "Анна", "voise": "shimmer"}]`, expectedError: `unknown field "voise"`},
+		{name: "unknown yaml field", file: "hosts.yaml", data: "- name: Анна\n  voise: shimmer\n", expectedError: "field voise not found"},
+		{name: "malformed", file: "hosts.json", data: `[{"name": "Анна"`, expectedError: "failed to decode hosts"},
+		{name: "unsupported extension", file: "hosts.toml", data: "", expectedError: `unsupported hosts file extension ".toml"`},
+		{name: "empty", file: "hosts.json", data: "[]", expectedError: "no hosts defined"},
+		{name: "no name", file: "hosts.json", data: `[{"name": "Анна", "voice": "shimmer"}, {"voice": "echo"}]`,
+			expectedError: "host 2 has no name"},
+		{name: "no voice", file: "hosts.json", data: `[{"name": "Анна"}]`, expectedError: "host 1 (Анна) has no voice"},
+		{name: "unknown voice", file: "hosts.json", data: `[{"name": "Анна", "voice": "robot"}]`,
+			expectedError: `host 1 (Анна): unknown voice "robot"`},
+		{name: "duplicate name", file: "hosts.json", data: `[{"name": "Анна", "voice": "shimmer"}, {"name": "Анна", "voice": "echo"}]`,
+			expectedError: "host 2 (Анна) has the same name as host 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			require.NoError(t, os.WriteFile(path, []byte(test.data), 0o600))
+			hosts, err := loadHosts(path)
+			if test.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			expected := []podcast.Host{
+				{Name: "Анна", Gender: "female", Character: "ведущая", Voice: "shimmer", Intro: "Привет, я Анна", TTSModel: "tts-1-hd"},
+				{Name: "Борис", Voice: "echo", Weight: 2},
+			}
+			assert.Equal(t, expected, hosts)
+		})
+	}
+}
+
+func TestInsertAdBreak(t *testing.T) {
+	// each message is about 25 seconds of estimated speech
+	longText := strings.Repeat("слово ", 75)
+	messages := []podcast.Message{
+		{Host: "host1", Content: longText},
+		{Host: "host2", Content: longText},
+		{Host: "host1", Content: longText},
+		{Host: "host2", Content: longText},
+	}
+
+	tests := []struct {
+		name          string
+		adBreak       podcast.AdBreak
+		expectedIndex int
+	}{
+		{name: "middle by fraction", adBreak: podcast.AdBreak{Position: 0.45, Text: "buy now"}, expectedIndex: 2},
+		{name: "start by fraction", adBreak: podcast.AdBreak{Position: 0, Text: "buy now"}, expectedIndex: 0},
+		{name: "end by fraction", adBreak: podcast.AdBreak{Position: 1, Text: "buy now"}, expectedIndex: 4},
+		{name: "after one minute", adBreak: podcast.AdBreak{AfterMinutes: 1, Text: "buy now"}, expectedIndex: 3},
+		{name: "after more than episode", adBreak: podcast.AdBreak{AfterMinutes: 10, Text: "buy now"}, expectedIndex: 4},
+		{name: "audio file ad", adBreak: podcast.AdBreak{Position: 0.2, AudioFile: "ad.mp3"}, expectedIndex: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := insertAdBreak(messages, test.adBreak, "")
+			require.Len(t, result, len(messages)+1)
+
+			ad := result[test.expectedIndex]
+			assert.True(t, ad.Ad)
+			assert.Equal(t, "Реклама", ad.Host)
+			assert.Equal(t, test.adBreak.AudioFile, ad.AudioFile)
+			for i, msg := range result {
+				if i != test.expectedIndex {
+					assert.False(t, msg.Ad)
+				}
+			}
+		})
+	}
+
+	t.Run("label of the episode language", func(t *testing.T) {
+		result := insertAdBreak(messages, podcast.AdBreak{Position: 0.5}, "en")
+		assert.Equal(t, podcast.Message{Host: "Advertisement", Content: "Advertisement", Ad: true}, result[2])
+	})
+}
+
+func TestAddSilence(t *testing.T) {
+	messages := []podcast.Message{
+		{Host: "host1", Content: "hello"},
+		{Host: "Реклама", Content: "buy now", Ad: true},
+		{Host: "host2", Content: "world"},
+	}
+	audioFiles := []string{"segment_000.mp3", "segment_001.mp3", "segment_002.mp3"}
+
+	t.Run("silence around ad", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+				return "silence.mp3", nil
+			},
+		}
+		result, err := addSilence(audioFiles, messages, podcast.Config{AdBreak: podcast.AdBreak{SilenceMs: 500}}, "tmp", mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"segment_000.mp3", "silence.mp3", "segment_001.mp3", "silence.mp3", "segment_002.mp3"}, result)
+		require.Len(t, mockAudio.InsertSilenceCalls(), 1)
+		assert.Equal(t, 500, mockAudio.InsertSilenceCalls()[0].DurationMs)
+	})
+
+	t.Run("no silence configured", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{}
+		result, err := addSilence(audioFiles, messages, podcast.Config{}, "tmp", mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, audioFiles, result)
+		assert.Empty(t, mockAudio.InsertSilenceCalls())
+	})
+
+	t.Run("silence error", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+				return "", assert.AnError
+			},
+		}
+		_, err := addSilence(audioFiles, messages, podcast.Config{AdBreak: podcast.AdBreak{SilenceMs: 500}}, "tmp", mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create ad silence")
+	})
+
+	t.Run("pauses between messages", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+				return fmt.Sprintf("silence_%d.mp3", durationMs), nil
+			},
+		}
+		config := podcast.Config{PauseMs: 300, AdBreak: podcast.AdBreak{SilenceMs: 500}}
+		result, err := addSilence(audioFiles, messages, config, "tmp", mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"segment_000.mp3", "silence_300.mp3", "silence_500.mp3", "segment_001.mp3", "silence_500.mp3",
+			"silence_300.mp3", "segment_002.mp3"}, result)
+		require.Len(t, mockAudio.InsertSilenceCalls(), 2, "each silence is created once")
+	})
+
+	t.Run("pauses without ad silence", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+				return "pause.mp3", nil
+			},
+		}
+		result, err := addSilence(audioFiles, messages, podcast.Config{PauseMs: 300}, "tmp", mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"segment_000.mp3", "pause.mp3", "segment_001.mp3", "pause.mp3", "segment_002.mp3"}, result)
+	})
+
+	t.Run("pause error", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+				return "", assert.AnError
+			},
+		}
+		_, err := addSilence(audioFiles, messages, podcast.Config{PauseMs: 300}, "tmp", mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create silence between messages")
+	})
+
+	t.Run("negative pause rejected", func(t *testing.T) {
+		config := podcast.Config{Hosts: []podcast.Host{{Name: "host1", Voice: "onyx"}}, ArticleURL: "http://example.com", PauseMs: -100}
+		err := runWithDependencies(t.Context(), config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid -pause-ms -100, must not be negative")
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	hosts := []podcast.Host{{Name: "host1", Voice: "onyx"}}
+	require.NoError(t, validateConfig(podcast.Config{Hosts: hosts, OutputFile: "episode.mp3"}))
+
+	// every problem is reported at once, of the flags and of the saved outputs
+	err := validateConfig(podcast.Config{Hosts: hosts, PauseMs: -100, Language: "de", OutputFile: stdoutOutput,
+		OutputDir: "episodes", Chapters: true, FeedURL: "https://example.com/"})
+	require.Error(t, err)
+	for _, expected := range []string{
+		"invalid -pause-ms -100, must not be negative",
+		`invalid -language: unknown language "de"`,
+		"-mp3 - writes the episode to stdout, it can't be combined with -output-dir",
+		"-feed-url requires -feed",
+	} {
+		assert.Contains(t, err.Error(), expected)
+	}
+	assert.Len(t, strings.Split(err.Error(), "\n"), 4)
+}
+
+func TestRunWithDependenciesAdjustRounds(t *testing.T) {
+	hosts := []podcast.Host{{Name: "host1", Voice: "onyx"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) { return "article text", "Test Article", nil },
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{{Host: "host1", Content: "line"}}}, nil
+		},
+	}
+
+	config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", TargetDuration: 5, ScriptOnly: true,
+		ScriptOut: filepath.Join(t.TempDir(), "script.txt"), AdjustRounds: 2, DurationTolerance: 0.2}
+	require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+	require.Len(t, mockOpenAI.GenerateDiscussionCalls(), 1)
+	params := mockOpenAI.GenerateDiscussionCalls()[0].Params
+	assert.Equal(t, 2, params.AdjustRounds)
+	assert.InDelta(t, 0.2, params.DurationTolerance, 1e-9)
+
+	for _, test := range []struct {
+		rounds    int
+		tolerance float64
+		err       string
+	}{
+		{rounds: -1, tolerance: 0.15, err: "invalid -adjust-rounds -1, must not be negative"},
+		{rounds: 2, tolerance: 0, err: "invalid -duration-tolerance 0, expected a fraction between 0 and 1, e.g. 0.15"},
+		{rounds: 2, tolerance: 1.5, err: "invalid -duration-tolerance 1.5, expected a fraction between 0 and 1, e.g. 0.15"},
+	} {
+		config.AdjustRounds, config.DurationTolerance = test.rounds, test.tolerance
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{})
+		assert.EqualError(t, err, test.err)
+	}
+	config.AdjustRounds, config.DurationTolerance = 0, 0
+	assert.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}), "tolerance unused without rounds")
+}
+
+func TestSynthesizeMessageFromAudioFile(t *testing.T) {
+	adFile := filepath.Join(t.TempDir(), "ad.mp3")
+	require.NoError(t, os.WriteFile(adFile, []byte("ad audio"), 0o600))
+	mockOpenAI := &mocks.OpenAIClientMock{}
+
+	audioData, err := synthesizeMessage(podcast.Message{Host: "Реклама", Ad: true, AudioFile: adFile}, podcast.HostInfo{Voice: "nova"}, mockOpenAI)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ad audio"), audioData)
+	assert.Empty(t, mockOpenAI.GenerateSpeechCalls())
+}
+
+func TestSynthesizeMessageWithEmotion(t *testing.T) {
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+
+	_, err := synthesizeMessage(podcast.Message{Host: "host1", Content: "wow", Emotion: "excited"}, podcast.HostInfo{Voice: "onyx"}, mockOpenAI)
+	require.NoError(t, err)
+
+	calls := mockOpenAI.GenerateSpeechCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "wow", calls[0].Text)
+	assert.Equal(t, "onyx", calls[0].Voice)
+	assert.Equal(t, "excited", calls[0].Emotion)
+}
+
+func TestRunWithDependenciesSplitEpisodes(t *testing.T) {
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "first part of the article\nsecond part of the article\nthird part of the article", "article title", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{
+				Title:    params.Title,
+				Messages: []podcast.Message{{Host: "host1", Content: params.ArticleText}},
+			}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
+
+	config := podcast.Config{ArticleURL: "http://example.com", OutputFile: "podcast.mp3", TargetDuration: 5, SplitEpisodes: 3}
+	err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+	require.NoError(t, err)
+
+	discussionCalls := mockOpenAI.GenerateDiscussionCalls()
+	require.Len(t, discussionCalls, 3)
+	for i, call := range discussionCalls {
+		assert.Equal(t, i+1, call.Params.Part)
+		assert.Equal(t, 3, call.Params.TotalParts)
+	}
+	assert.Equal(t, "first part of the article", discussionCalls[0].Params.ArticleText)
+	assert.Equal(t, "third part of the article", discussionCalls[2].Params.ArticleText)
+
+	concatCalls := mockAudio.ConcatenateCalls()
+	require.Len(t, concatCalls, 3)
+	assert.Equal(t, "podcast_1.mp3", concatCalls[0].OutputFile)
+	assert.Equal(t, "podcast_2.mp3", concatCalls[1].OutputFile)
+	assert.Equal(t, "podcast_3.mp3", concatCalls[2].OutputFile)
+}
+
+func TestRunURLList(t *testing.T) {
+	dir := t.TempDir()
+	listFile := filepath.Join(dir, "urls.txt")
+	checkpointFile := filepath.Join(dir, "urls.done")
+	require.NoError(t, os.WriteFile(listFile, []byte("http://a.example\nhttp://b.example\nhttp://c.example\nhttp://d.example\n"), 0o600))
+	require.NoError(t, os.WriteFile(checkpointFile, []byte("http://a.example\nhttp://c.example\n"), 0o600))
+
+	newMocks := func(failURL string) (*mocks.ArticleFetcherMock, *mocks.OpenAIClientMock, *mocks.AudioProcessorMock) {
+		mockArticle := &mocks.ArticleFetcherMock{
+			FetchFunc: func(url string) (string, string, error) {
+				if url == failURL {
+					return "", "", fmt.Errorf("fetch failed")
+				}
+				return "article text", "Article " + url, nil
+			},
+		}
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{{Host: "host1", Content: "line"}}}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio data"), nil
+			},
+		}
+		return mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
+	}
+	config := podcast.Config{URLList: listFile, Checkpoint: checkpointFile, OutputFile: filepath.Join(dir, "podcast.mp3"),
+		TargetDuration: 5}
+
+	// the first run fails on d, only unprocessed urls are fetched
+	mockArticle, mockOpenAI, mockAudio := newMocks("http://d.example")
+	err := runURLList(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "article http://d.example: error fetching article: fetch failed")
+	fetchCalls := mockArticle.FetchCalls()
+	require.Len(t, fetchCalls, 2)
+	assert.Equal(t, "http://b.example", fetchCalls[0].URL)
+	assert.Equal(t, "http://d.example", fetchCalls[1].URL)
+	concatCalls := mockAudio.ConcatenateCalls()
+	require.Len(t, concatCalls, 1)
+	assert.Equal(t, filepath.Join(dir, "podcast_2.mp3"), concatCalls[0].OutputFile)
+
+	data, err := os.ReadFile(checkpointFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	assert.Equal(t, "http://a.example\nhttp://c.example\nhttp://b.example\n", string(data))
+
+	// the re-run picks up the failed url only
+	mockArticle, mockOpenAI, mockAudio = newMocks("")
+	require.NoError(t, runURLList(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+	fetchCalls = mockArticle.FetchCalls()
+	require.Len(t, fetchCalls, 1)
+	assert.Equal(t, "http://d.example", fetchCalls[0].URL)
+	concatCalls = mockAudio.ConcatenateCalls()
+	require.Len(t, concatCalls, 1)
+	assert.Equal(t, filepath.Join(dir, "podcast_4.mp3"), concatCalls[0].OutputFile)
+}
+
+func TestRunWithDependenciesAudiogram(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "Первый абзац.\nВторой абзац.", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{{Host: "Алексей", Content: "Привет."}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+
+	t.Run("rendered from the saved episode", func(t *testing.T) {
+		dir := t.TempDir()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(dir, "episode.mp3"),
+			Audiogram: filepath.Join(dir, "wave.mp4"), AudiogramSize: "1080x1080", SplitEpisodes: 2}
+		mockAudio := &mocks.AudioProcessorMock{}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		calls := mockAudio.AudiogramCalls()
+		require.Len(t, calls, 2)
+		for i, call := range calls {
+			assert.Equal(t, filepath.Join(dir, fmt.Sprintf("episode_%d.mp3", i+1)), call.InputFile)
+			assert.Equal(t, filepath.Join(dir, fmt.Sprintf("wave_%d.mp4", i+1)), call.OutputFile)
+			assert.Equal(t, 1080, call.Width)
+			assert.Equal(t, 1080, call.Height)
+		}
+	})
+
+	t.Run("render failure", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			Audiogram: "wave.png", AudiogramSize: "1280x720"}
+		mockAudio := &mocks.AudioProcessorMock{
+			AudiogramFunc: func(inputFile, outputFile string, width, height int) error {
+				return assert.AnError
+			},
+		}
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to save audiogram")
+	})
+
+	tests := []struct {
+		name        string
+		config      podcast.Config
+		expectedErr string
+	}{
+		{name: "no output file", config: podcast.Config{Audiogram: "wave.png", AudiogramSize: "1280x720"},
+			expectedErr: "-audiogram is rendered from the saved episode, it requires -mp3 with a file path"},
+		{name: "unsupported extension", config: podcast.Config{OutputFile: "episode.mp3", Audiogram: "wave.gif", AudiogramSize: "1280x720"},
+			expectedErr: `invalid -audiogram "wave.gif", expected a .png or .mp4 file`},
+		{name: "invalid size", config: podcast.Config{OutputFile: "episode.mp3", Audiogram: "wave.png", AudiogramSize: "big"},
+			expectedErr: "invalid -audiogram-size"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestRunWithDependenciesTags(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "Первый абзац.", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: "Новый релиз Go", Messages: []podcast.Message{{Host: "Алексей", Content: "Привет."}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	dir := t.TempDir()
+	cover := filepath.Join(dir, "cover.jpg")
+	require.NoError(t, os.WriteFile(cover, []byte("jpeg"), 0o600))
+
+	t.Run("saved episode tagged", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(dir, "episode.mp3"),
+			Artist: "AI Podcast", Album: "Радио-Т AI", CoverFile: cover}
+		mockAudio := &mocks.AudioProcessorMock{}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		calls := mockAudio.WriteTagsCalls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, filepath.Join(dir, "episode.mp3"), calls[0].Filename)
+		assert.Equal(t, audio.Tags{Title: "Новый релиз Go", Artist: "AI Podcast", Album: "Радио-Т AI",
+			Date: time.Now().Format(time.DateOnly), Cover: cover}, calls[0].Tags)
+	})
+
+	t.Run("tag failure", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(dir, "episode.mp3")}
+		mockAudio := &mocks.AudioProcessorMock{
+			WriteTagsFunc: func(filename string, tags audio.Tags) error {
+				return assert.AnError
+			},
+		}
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to tag episode")
+	})
+
+	tests := []struct {
+		name        string
+		config      podcast.Config
+		expectedErr string
+	}{
+		{name: "no output file", config: podcast.Config{CoverFile: cover},
+			expectedErr: "-cover is attached to the saved episode, it requires -mp3 with a file path"},
+		{name: "unsupported extension", config: podcast.Config{OutputFile: "episode.mp3", CoverFile: "cover.gif"},
+			expectedErr: `invalid -cover "cover.gif", expected a .jpg or .png picture`},
+		{name: "missing picture", config: podcast.Config{OutputFile: "episode.mp3", CoverFile: filepath.Join(dir, "missing.png")},
+			expectedErr: "invalid -cover"},
+		{name: "directory", config: podcast.Config{OutputFile: "episode.mp3", CoverFile: filepath.Join(dir, "covers.png")},
+			expectedErr: "is not a picture"},
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "covers.png"), 0o700))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestRunWithDependenciesStreamChat(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	messages := []podcast.Message{
+		{Host: "Алексей", Content: "Привет!"},
+		{Host: "Мария", Content: "Здравствуйте."},
+		{Host: "Мария", Content: "Начнём."},
+	}
+	newMocks := func() (*mocks.ArticleFetcherMock, *mocks.OpenAIClientMock) {
+		mockArticle := &mocks.ArticleFetcherMock{
+			FetchFunc: func(url string) (string, string, error) {
+				return "Article content", "Test Article", nil
+			},
+		}
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				if params.Stream != nil {
+					for _, msg := range messages {
+						params.Stream <- msg
+					}
+				}
+				return podcast.Discussion{Title: params.Title, Messages: messages}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio " + text), nil
+			},
+		}
+		return mockArticle, mockOpenAI
+	}
+
+	t.Run("lines synthesized while streamed are reused", func(t *testing.T) {
+		mockArticle, mockOpenAI := newMocks()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			StreamChat: true, Concurrency: podcast.ConcurrencyConfig{TTS: 2}}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+
+		require.Len(t, mockOpenAI.GenerateDiscussionCalls(), 1)
+		assert.NotNil(t, mockOpenAI.GenerateDiscussionCalls()[0].Params.Stream)
+		calls := mockOpenAI.GenerateSpeechCalls()
+		require.Len(t, calls, 3, "each line synthesized once")
+		voices := map[string]string{}
+		for _, call := range calls {
+			voices[call.Text] = call.Voice
+		}
+		assert.Equal(t, map[string]string{"Привет!": "onyx", "Здравствуйте.": "nova", "Начнём.": "nova"}, voices)
+	})
+
+	t.Run("streamed lines synthesized sanitized", func(t *testing.T) {
+		mockArticle, mockOpenAI := newMocks()
+		streamed := mockOpenAI.GenerateDiscussionFunc
+		mockOpenAI.GenerateDiscussionFunc = func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			discussion, err := streamed(params)
+			discussion.Messages = append([]podcast.Message{{Host: "Алексей", Content: " При\u200bвет! "}}, discussion.Messages[1:]...)
+			return discussion, err
+		}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			StreamChat: true}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+		assert.Len(t, mockOpenAI.GenerateSpeechCalls(), 3, "the sanitized line is found among the streamed ones")
+	})
+
+	t.Run("lines rewritten by the cleanup synthesized once", func(t *testing.T) {
+		mockArticle, mockOpenAI := newMocks()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			StreamChat: true, MaxConsecutive: 1}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+
+		var texts []string
+		for _, call := range mockOpenAI.GenerateSpeechCalls() {
+			texts = append(texts, call.Text)
+		}
+		assert.ElementsMatch(t, []string{"Привет!", "Здравствуйте. Начнём."}, texts, "no speech synthesized ahead for the merged lines")
+	})
+
+	t.Run("slow speech doesn't hold the chat stream", func(t *testing.T) {
+		mockArticle, mockOpenAI := newMocks()
+		lines := make([]podcast.Message, 6)
+		for i := range lines {
+			lines[i] = podcast.Message{Host: hosts[i%2].Name, Content: fmt.Sprintf("Реплика %d.", i)}
+		}
+		mockOpenAI.GenerateDiscussionFunc = func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			// the chat deadline covers reading the whole stream, a line not taken in time fails the request
+			deadline := time.After(300 * time.Millisecond)
+			for _, msg := range lines {
+				select {
+				case params.Stream <- msg:
+				case <-deadline:
+					return podcast.Discussion{}, errors.New("chat stream deadline exceeded")
+				}
+			}
+			return podcast.Discussion{Title: params.Title, Messages: lines}, nil
+		}
+		mockOpenAI.GenerateSpeechFunc = func(text, voice, emotion, model string) ([]byte, error) {
+			time.Sleep(100 * time.Millisecond)
+			return []byte("audio " + text), nil
+		}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			StreamChat: true, Concurrency: podcast.ConcurrencyConfig{TTS: 1}}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+		assert.Len(t, mockOpenAI.GenerateSpeechCalls(), len(lines), "every streamed line synthesized once")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		for name, config := range map[string]podcast.Config{
+			"no stream chat":  {},
+			"tts budget":      {StreamChat: true, MaxTTSChars: 1000, TTSCharsMode: ttsCharsReject},
+			"sample only":     {StreamChat: true, SampleOnly: true},
+			"adjust rounds":   {StreamChat: true, AdjustRounds: 2, DurationTolerance: 0.15},
+			"max consecutive": {StreamChat: true, MaxConsecutive: 2},
+			"reduce fillers":  {StreamChat: true, ReduceFillers: "light"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				mockArticle, mockOpenAI := newMocks()
+				config.Hosts, config.ArticleURL = hosts, "http://example.com"
+				config.OutputFile = filepath.Join(t.TempDir(), "episode.mp3")
+				require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+				assert.Nil(t, mockOpenAI.GenerateDiscussionCalls()[0].Params.Stream)
+			})
+		}
+	})
+}
+
+func TestRunWithDependenciesNormalize(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "Article content", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: "Привет."}, {Host: "Мария", Content: "Здравствуйте."}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	newAudio := func() *mocks.AudioProcessorMock {
+		return &mocks.AudioProcessorMock{
+			NormalizeFunc: func(inputFiles []string, tempDir string) ([]string, error) {
+				result := make([]string, 0, len(inputFiles))
+				for _, file := range inputFiles {
+					result = append(result, filepath.Join(tempDir, "normalized_"+filepath.Base(file)))
+				}
+				return result, nil
+			},
+		}
+	}
+
+	t.Run("saved episode joins the normalized segments", func(t *testing.T) {
+		workDir := t.TempDir()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			WorkDir: workDir, Normalize: true}
+		mockAudio := newAudio()
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		require.Len(t, mockAudio.NormalizeCalls(), 1)
+		assert.Equal(t, []string{filepath.Join(workDir, "segment_000.mp3"), filepath.Join(workDir, "segment_001.mp3")},
+			mockAudio.NormalizeCalls()[0].InputFiles, "every segment is normalized")
+		require.Len(t, mockAudio.ConcatenateCalls(), 1)
+		assert.Equal(t, []string{filepath.Join(workDir, "normalized_segment_000.mp3"), filepath.Join(workDir, "normalized_segment_001.mp3")},
+			mockAudio.ConcatenateCalls()[0].Files)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3")}
+		mockAudio := newAudio()
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		assert.Empty(t, mockAudio.NormalizeCalls())
+	})
+
+	t.Run("normalization failure", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			Normalize: true}
+		mockAudio := &mocks.AudioProcessorMock{
+			NormalizeFunc: func(inputFiles []string, tempDir string) ([]string, error) {
+				return nil, assert.AnError
+			},
+		}
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to normalize segments")
+		assert.Empty(t, mockAudio.ConcatenateCalls())
+	})
+}
+
+func TestRunWithDependenciesFeed(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "Article content", "Новый релиз", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: "Привет."}, {Host: "Мария", Content: "Здравствуйте."}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	mockAudio := &mocks.AudioProcessorMock{
+		ConcatenateFunc: func(files []string, outputFile string) error {
+			return os.WriteFile(outputFile, []byte("episode audio"), 0o600)
+		},
+		DurationFunc: func(filename string) (float64, error) {
+			return 754.4, nil
+		},
+	}
+
+	t.Run("saved episodes are added to the feed", func(t *testing.T) {
+		dir := t.TempDir()
+		feedFile := filepath.Join(dir, "feed.xml")
+		config := podcast.Config{Hosts: hosts, ArticleURL: "https://example.com/article", OutputDir: dir, FeedFile: feedFile,
+			FeedURL: "https://example.com/podcast/"}
+		readFeed := func() string {
+			data, err := os.ReadFile(feedFile) // #nosec G304 -- test file
+			require.NoError(t, err)
+			return string(data)
+		}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		feedXML := readFeed()
+		assert.Contains(t, feedXML, "<title>AI Podcast</title>")
+		assert.Contains(t, feedXML, "<title>Новый релиз</title>")
+		assert.Contains(t, feedXML, "<description>https://example.com/article</description>")
+		assert.Contains(t, feedXML, `length="13" type="audio/mpeg"`)
+		assert.Contains(t, feedXML, "<itunes:duration>00:12:34</itunes:duration>", "measured from the saved file")
+
+		config.ArticleURL = "https://example.com/other"
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		feedXML = readFeed()
+		assert.Equal(t, 1, strings.Count(feedXML, "<item>"), "the same episode folder is replaced, not duplicated")
+		assert.Contains(t, feedXML, "<description>https://example.com/other</description>")
+	})
+
+	tests := []struct {
+		name        string
+		config      podcast.Config
+		expectedErr string
+	}{
+		{name: "no output file", config: podcast.Config{FeedFile: "feed.xml", DryRun: true},
+			expectedErr: "-feed publishes the saved episode, it requires -mp3 with a file path"},
+		{name: "feed without feed url", config: podcast.Config{OutputFile: "episode.mp3", FeedFile: "feed.xml"},
+			expectedErr: "-feed requires -feed-url, the public url of the feed directory"},
+		{name: "feed url without feed", config: podcast.Config{OutputFile: "episode.mp3", FeedURL: "https://example.com/"},
+			expectedErr: "-feed-url requires -feed"},
+		{name: "invalid feed url", config: podcast.Config{OutputFile: "episode.mp3", FeedFile: "feed.xml", FeedURL: "example.com/podcast"},
+			expectedErr: `invalid -feed-url "example.com/podcast", expected an http or https url`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestRunWithDependenciesCrossfade(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "Article content", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: "Привет."}, {Host: "Мария", Content: "Здравствуйте."}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+
+	t.Run("saved episode is crossfaded", func(t *testing.T) {
+		workDir := t.TempDir()
+		outputFile := filepath.Join(t.TempDir(), "episode.mp3")
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: outputFile, WorkDir: workDir, CrossfadeMs: 150}
+		mockAudio := &mocks.AudioProcessorMock{}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		require.Len(t, mockAudio.ConcatenateWithCrossfadeCalls(), 1)
+		call := mockAudio.ConcatenateWithCrossfadeCalls()[0]
+		assert.Equal(t, []string{filepath.Join(workDir, "segment_000.mp3"), filepath.Join(workDir, "segment_001.mp3")}, call.Files)
+		assert.Equal(t, outputFile, call.OutputFile)
+		assert.Equal(t, 150, call.FadeMs)
+		assert.Empty(t, mockAudio.ConcatenateCalls())
+	})
+
+	t.Run("crossfade failure", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+			CrossfadeMs: 150}
+		mockAudio := &mocks.AudioProcessorMock{
+			ConcatenateWithCrossfadeFunc: func(files []string, outputFile string, fadeMs int) error {
+				return assert.AnError
+			},
+		}
+		require.Error(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+	})
+
+	tests := []struct {
+		name        string
+		config      podcast.Config
+		expectedErr string
+	}{
+		{name: "negative", config: podcast.Config{OutputFile: "episode.mp3", CrossfadeMs: -10},
+			expectedErr: "invalid -crossfade-ms -10, must not be negative"},
+		{name: "no output file", config: podcast.Config{CrossfadeMs: 150, DryRun: true},
+			expectedErr: "-crossfade-ms is applied when the episode is saved, it requires -mp3 with a file path"},
+		{name: "stdout output", config: podcast.Config{OutputFile: "-", CrossfadeMs: 150},
+			expectedErr: "-crossfade-ms is applied when the episode is saved"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestRunWithDependenciesMusic(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "Article content", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{{Host: "Алексей", Content: "Привет."}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	dir := t.TempDir()
+	music := filepath.Join(dir, "bed.mp3")
+	require.NoError(t, os.WriteFile(music, []byte("music"), 0o600))
+
+	t.Run("mixed under the saved episode", func(t *testing.T) {
+		output := filepath.Join(dir, "episode.mp3")
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: output, MusicFile: music, MusicGain: -18,
+			Audiogram: filepath.Join(dir, "wave.png"), AudiogramSize: "1280x720"}
+		mockAudio := &mocks.AudioProcessorMock{}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		speech := filepath.Join(dir, "episode_speech.mp3")
+		require.Len(t, mockAudio.ConcatenateCalls(), 1)
+		assert.Equal(t, speech, mockAudio.ConcatenateCalls()[0].OutputFile, "speech is joined into a separate file")
+		calls := mockAudio.MixWithBackgroundCalls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, speech, calls[0].SpeechFile)
+		assert.Equal(t, music, calls[0].MusicFile)
+		assert.Equal(t, output, calls[0].OutputFile)
+		assert.InDelta(t, -18, calls[0].MusicGainDB, 0.001)
+		require.Len(t, mockAudio.AudiogramCalls(), 1)
+		assert.Equal(t, output, mockAudio.AudiogramCalls()[0].InputFile, "audiogram shows the mixed episode")
+	})
+
+	t.Run("clean output kept with the mix", func(t *testing.T) {
+		output, clean := filepath.Join(dir, "mixed.mp3"), filepath.Join(dir, "clean.mp3")
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: output, MusicFile: music, MusicGain: -20,
+			CleanOutput: clean}
+		mockAudio := &mocks.AudioProcessorMock{
+			ConcatenateFunc: func(files []string, outputFile string) error {
+				return os.WriteFile(outputFile, []byte("speech"), 0o600)
+			},
+			MixWithBackgroundFunc: func(speechFile, musicFile, outputFile string, musicGainDB float64) error {
+				return os.WriteFile(outputFile, []byte("speech with music"), 0o600)
+			},
+		}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		require.Len(t, mockAudio.MixWithBackgroundCalls(), 1)
+		assert.Equal(t, clean, mockAudio.MixWithBackgroundCalls()[0].SpeechFile, "the clean dialogue is mixed")
+		data, err := os.ReadFile(clean) // #nosec G304 -- test file
+		require.NoError(t, err)
+		assert.Equal(t, "speech", string(data))
+		data, err = os.ReadFile(output) // #nosec G304 -- test file
+		require.NoError(t, err)
+		assert.Equal(t, "speech with music", string(data))
+	})
+
+	t.Run("mix failure", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(dir, "failed.mp3"),
+			MusicFile: music, MusicGain: -20}
+		mockAudio := &mocks.AudioProcessorMock{
+			MixWithBackgroundFunc: func(speechFile, musicFile, outputFile string, musicGainDB float64) error {
+				return assert.AnError
+			},
+		}
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to add background music")
+	})
+
+	tests := []struct {
+		name        string
+		config      podcast.Config
+		expectedErr string
+	}{
+		{name: "no output file", config: podcast.Config{MusicFile: music, MusicGain: -20, DryRun: true},
+			expectedErr: "-music is mixed into the saved episode, it requires -mp3 with a file path"},
+		{name: "missing music file", config: podcast.Config{OutputFile: "episode.mp3", MusicFile: filepath.Join(dir, "missing.mp3")},
+			expectedErr: "invalid -music"},
+		{name: "positive gain", config: podcast.Config{OutputFile: "episode.mp3", MusicFile: music, MusicGain: 3},
+			expectedErr: "invalid -music-gain 3, expected 0 dB or lower"},
+		{name: "clean output without music", config: podcast.Config{OutputFile: "episode.mp3", CleanOutput: "clean.mp3"},
+			expectedErr: "-clean-output is the episode without the -music bed, it requires -music"},
+		{name: "clean output over the mix", config: podcast.Config{OutputFile: "episode.mp3", MusicFile: music, CleanOutput: "./episode.mp3"},
+			expectedErr: "-clean-output must differ from -mp3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestRunWithDependenciesOutputDir(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	newMocks := func() (*mocks.ArticleFetcherMock, *mocks.OpenAIClientMock, *mocks.AudioProcessorMock) {
+		mockArticle := &mocks.ArticleFetcherMock{
+			FetchFunc: func(url string) (string, string, error) {
+				return "Первый абзац.\nВторой абзац.", "Новый релиз: Go 1.24!", nil
+			},
+		}
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+					{Host: "Алексей", Content: "Сегодня говорим о релизе."}, {Host: "Мария", Content: "Давайте начнём."},
+				}}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio data"), nil
+			},
+		}
+		return mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}
+	}
+	episodeDir := func(base string) string {
+		return filepath.Join(base, time.Now().Format(time.DateOnly)+"-новый-релиз-go-1-24")
+	}
+
+	t.Run("all files in the episode folder", func(t *testing.T) {
+		base := filepath.Join(t.TempDir(), "episodes", "nested")
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputDir: base,
+			OutputTranscript: "/elsewhere/transcript.json", ScriptPDF: "script.pdf", Subtitles: "vtt"}
+		mockArticle, mockOpenAI, mockAudio := newMocks()
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		dir := episodeDir(base)
+		concatCalls := mockAudio.ConcatenateCalls()
+		require.Len(t, concatCalls, 1)
+		assert.Equal(t, filepath.Join(dir, "episode.mp3"), concatCalls[0].OutputFile)
+		for _, name := range []string{"transcript.json", "script.pdf", "episode.vtt"} {
+			assert.FileExists(t, filepath.Join(dir, name))
+		}
+		entries, err := os.ReadDir(base)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "nothing is written outside the episode folder")
+	})
+
+	t.Run("split episodes share the folder", func(t *testing.T) {
+		base := t.TempDir()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputDir: base, OutputFile: "show.mp3",
+			OutputTranscript: "show.json", SplitEpisodes: 2}
+		mockArticle, mockOpenAI, mockAudio := newMocks()
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		dir := episodeDir(base)
+		concatCalls := mockAudio.ConcatenateCalls()
+		require.Len(t, concatCalls, 2)
+		assert.Equal(t, filepath.Join(dir, "show_1.mp3"), concatCalls[0].OutputFile)
+		assert.Equal(t, filepath.Join(dir, "show_2.mp3"), concatCalls[1].OutputFile)
+		assert.FileExists(t, filepath.Join(dir, "show_1.json"))
+		assert.FileExists(t, filepath.Join(dir, "show_2.json"))
+	})
+
+	t.Run("url list without numbering", func(t *testing.T) {
+		base := t.TempDir()
+		listFile := filepath.Join(t.TempDir(), "urls.txt")
+		require.NoError(t, os.WriteFile(listFile, []byte("http://a.example\nhttp://b.example\n"), 0o600))
+		config := podcast.Config{Hosts: hosts, URLList: listFile, Checkpoint: listFile + ".done", OutputDir: base}
+		mockArticle, mockOpenAI, mockAudio := newMocks()
+		mockArticle.FetchFunc = func(url string) (string, string, error) {
+			return "article text", "Статья " + strings.TrimPrefix(url, "http://"), nil
+		}
+		require.NoError(t, runURLList(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		concatCalls := mockAudio.ConcatenateCalls()
+		require.Len(t, concatCalls, 2)
+		date := time.Now().Format(time.DateOnly)
+		assert.Equal(t, filepath.Join(base, date+"-статья-a-example", "episode.mp3"), concatCalls[0].OutputFile)
+		assert.Equal(t, filepath.Join(base, date+"-статья-b-example", "episode.mp3"), concatCalls[1].OutputFile)
+	})
+
+	t.Run("title without letters", func(t *testing.T) {
+		config, err := withOutputDir(podcast.Config{OutputDir: t.TempDir()}, "???")
+		require.NoError(t, err)
+		assert.Equal(t, time.Now().Format(time.DateOnly)+"-episode", filepath.Base(filepath.Dir(config.OutputFile)))
+	})
+
+	t.Run("output dir is a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+		_, err := withOutputDir(podcast.Config{OutputDir: file}, "title")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create output directory")
+	})
+
+	t.Run("stdout output", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputDir: t.TempDir(), OutputFile: "-"}
+		err := runWithDependencies(t.Context(), config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't be combined with -output-dir")
+	})
+}
+
+func TestNumberedOutputFile(t *testing.T) {
+	assert.Equal(t, "podcast_2.mp3", numberedOutputFile("podcast.mp3", 2))
+	assert.Equal(t, "/out/dir/show_10.mp3", numberedOutputFile("/out/dir/show.mp3", 10))
+	assert.Equal(t, "noext_1", numberedOutputFile("noext", 1))
+	assert.Empty(t, numberedOutputFile("", 1))
+}
+
+func TestRunWithDependenciesSampleOnly(t *testing.T) {
+	tests := []struct {
+		name   string
+		config podcast.Config
+	}{
+		{
+			name:   "save sample to file",
+			config: podcast.Config{ArticleURL: "http://example.com", OutputFile: "sample.mp3", TargetDuration: 5, SampleOnly: true},
+		},
+		{
+			name:   "play sample without output file",
+			config: podcast.Config{ArticleURL: "http://example.com", TargetDuration: 5, SampleOnly: true},
+		},
+		{
+			name: "sample from the first episode of a series",
+			config: podcast.Config{ArticleURL: "http://example.com", OutputFile: "sample.mp3", TargetDuration: 5,
+				SampleOnly: true, SplitEpisodes: 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockArticle := &mocks.ArticleFetcherMock{
+				FetchFunc: func(url string) (string, string, error) {
+					return "first paragraph\nsecond paragraph\nthird paragraph", "Test Article", nil
+				},
+			}
+			mockOpenAI := &mocks.OpenAIClientMock{
+				GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+					return podcast.Discussion{
+						Title: params.Title,
+						Messages: []podcast.Message{
+							{Host: "host1", Content: "opening line"},
+							{Host: "host2", Content: "second line"},
+							{Host: "host1", Content: "third line"},
+						},
+					}, nil
+				},
+				GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+					return []byte("audio data"), nil
+				},
+			}
+			mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
+
+			err := runWithDependencies(t.Context(), test.config, mockArticle, mockOpenAI, mockAudio)
+			require.NoError(t, err)
+
+			assert.Len(t, mockOpenAI.GenerateDiscussionCalls(), 1)
+			speechCalls := mockOpenAI.GenerateSpeechCalls()
+			require.Len(t, speechCalls, 1)
+			assert.Equal(t, "opening line", speechCalls[0].Text)
+			assert.Empty(t, mockAudio.StreamFromConcatCalls())
+
+			if test.config.OutputFile == "" {
+				assert.Len(t, mockAudio.PlayCalls(), 1)
+				assert.Empty(t, mockAudio.ConcatenateCalls())
+				return
+			}
+			concatCalls := mockAudio.ConcatenateCalls()
+			require.Len(t, concatCalls, 1)
+			assert.Len(t, concatCalls[0].Files, 1)
+		})
+	}
+}
+
+func TestRunWithDependenciesScriptOnly(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		config podcast.Config
+		files  []string
+	}{
+		{
+			name:   "script file",
+			config: podcast.Config{ArticleURL: "http://example.com", TargetDuration: 5, ScriptOnly: true, Preflight: true, StreamChat: true},
+			files:  []string{"script.txt"},
+		},
+		{
+			name: "script of every episode",
+			config: podcast.Config{ArticleURL: "http://example.com", TargetDuration: 5, ScriptOnly: true, SplitEpisodes: 2,
+				OutputFile: "podcast.mp3"},
+			files: []string{"script_1.txt", "script_2.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.ScriptOut = filepath.Join(dir, "script.txt")
+			mockArticle := &mocks.ArticleFetcherMock{
+				FetchFunc: func(url string) (string, string, error) {
+					return "first paragraph\nsecond paragraph\nthird paragraph", "Test Article", nil
+				},
+			}
+			mockOpenAI := &mocks.OpenAIClientMock{
+				GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+					return podcast.Discussion{
+						Title: params.Title,
+						Messages: []podcast.Message{
+							{Host: "host1", Content: "opening line"},
+							{Host: "host2", Content: "second line", Emotion: "excited"},
+						},
+					}, nil
+				},
+			}
+			mockAudio := &mocks.AudioProcessorMock{}
+
+			err := runWithDependencies(t.Context(), test.config, mockArticle, mockOpenAI, mockAudio)
+			require.NoError(t, err)
+
+			assert.Len(t, mockOpenAI.GenerateDiscussionCalls(), len(test.files))
+			assert.Empty(t, mockOpenAI.GenerateSpeechCalls(), "no speech synthesized")
+			assert.Empty(t, mockAudio.ConcatenateCalls())
+			assert.Empty(t, mockAudio.PlayCalls())
+			assert.Empty(t, mockAudio.StreamFromConcatCalls())
+			for _, file := range test.files {
+				data, err := os.ReadFile(filepath.Join(dir, file))
+				require.NoError(t, err)
+				assert.Equal(t, "host1: opening line\nhost2 [excited]: second line\n", string(data))
+			}
+		})
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	dir := t.TempDir()
+
+	t.Run("dialog lines round trip", func(t *testing.T) {
+		messages := []podcast.Message{
+			{Host: "Алексей", Content: "Привет всем, сегодня обсуждаем новый релиз."},
+			{Host: "Мария", Content: "Да, в нём много интересного: скорость и новые API.", Emotion: "excited"},
+			{Host: "Алексей", Content: "Начнём с главного."},
+		}
+		filename := filepath.Join(dir, "edited-episode.txt")
+		require.NoError(t, script.SaveDialog(filename, messages))
+
+		var discussion podcast.Discussion
+		logs := captureLog(t, func(logger *slog.Logger) {
+			var err error
+			discussion, err = loadScript(filename, hosts, logger)
+			require.NoError(t, err)
+		})
+		assert.Equal(t, "edited-episode", discussion.Title)
+		assert.Equal(t, messages, discussion.Messages)
+		assert.Empty(t, logs, "all speakers are hosts")
+	})
+
+	t.Run("json array with unknown speaker", func(t *testing.T) {
+		filename := filepath.Join(dir, "script.json")
+		require.NoError(t, os.WriteFile(filename, []byte(`[
+			{"host": "Мария", "content": "Начинаем."},
+			{"host": "Гость", "content": "Спасибо за приглашение."},
+			{"host": "Гость", "content": "Рад быть здесь."}
+		]`), 0o600))
+
+		var discussion podcast.Discussion
+		logs := captureLog(t, func(logger *slog.Logger) {
+			var err error
+			discussion, err = loadScript(filename, hosts, logger)
+			require.NoError(t, err)
+		})
+		assert.Equal(t, []podcast.Message{
+			{Host: "Мария", Content: "Начинаем."},
+			{Host: "Гость", Content: "Спасибо за приглашение."},
+			{Host: "Гость", Content: "Рад быть здесь."},
+		}, discussion.Messages)
+		assert.Equal(t, "WARN  Script speaker is not a configured host, the default voice is used speaker=Гость\n", logs,
+			"reported once per speaker")
+	})
+
+	t.Run("unparsable lines skipped", func(t *testing.T) {
+		filename := filepath.Join(dir, "notes.txt")
+		require.NoError(t, os.WriteFile(filename, []byte("Черновик без говорящего\nМария: Одна строка.\n"), 0o600))
+		var discussion podcast.Discussion
+		logs := captureLog(t, func(logger *slog.Logger) {
+			var err error
+			discussion, err = loadScript(filename, hosts, logger)
+			require.NoError(t, err)
+		})
+		assert.Len(t, discussion.Messages, 1)
+		assert.Contains(t, logs, "Skipped unparsable lines of the script file="+filename+" skipped=1 kept=1")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := loadScript(filepath.Join(dir, "missing.txt"), hosts, slog.Default())
+		assert.ErrorContains(t, err, "failed to read script")
+
+		filename := filepath.Join(dir, "empty.txt")
+		require.NoError(t, os.WriteFile(filename, []byte("\n\n"), 0o600))
+		_, err = loadScript(filename, hosts, slog.Default())
+		assert.ErrorContains(t, err, "invalid script "+filename+": no valid dialog lines found")
+	})
+}
+
+func TestRunWithDependenciesScript(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "script.txt")
+	require.NoError(t, os.WriteFile(filename, []byte("host1: opening line\nhost2 [excited]: second line\n"), 0o600))
+	config := podcast.Config{
+		Hosts:          []podcast.Host{{Name: "host1", Voice: "onyx"}, {Name: "host2", Voice: "nova"}},
+		TargetDuration: 5,
+		DryRun:         true,
+		ScriptFile:     filename,
+	}
+	mockArticle := &mocks.ArticleFetcherMock{}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
+
+	err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+	require.NoError(t, err)
+
+	assert.Empty(t, mockArticle.FetchCalls(), "no article fetched")
+	assert.Empty(t, mockOpenAI.GenerateDiscussionCalls(), "no discussion generated")
+	var spoken []string
+	for _, call := range mockOpenAI.GenerateSpeechCalls() {
+		spoken = append(spoken, call.Voice+" "+call.Emotion+" "+call.Text)
+	}
+	sort.Strings(spoken)
+	assert.Equal(t, []string{"nova excited second line", "onyx  opening line"}, spoken)
+	assert.Len(t, mockAudio.PlayCalls(), 2, "every line played")
+
+	t.Run("lines voiced as written", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "script.txt")
+		require.NoError(t, os.WriteFile(filename, []byte("host1: Ну, это, ну, \"цитата.\nhost1: Ну, вот, второе.\n"+
+			"host1: Ну, третье.\nhost1: Ну, четвёртое\u200b.\n"), 0o600))
+		mockOpenAI := &mocks.OpenAIClientMock{GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		}}
+		config := config
+		config.ScriptFile, config.MaxConsecutive = filename, 2
+		config.ReduceFillers, config.BalanceQuotes = string(content.FillersStrong), true
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+		var spoken []string
+		for _, call := range mockOpenAI.GenerateSpeechCalls() {
+			spoken = append(spoken, call.Voice+" "+call.Text)
+		}
+		sort.Strings(spoken)
+		assert.Equal(t, []string{"onyx Ну, вот, второе.", "onyx Ну, третье.", "onyx Ну, четвёртое.", `onyx Ну, это, ну, "цитата.`},
+			spoken, "only sanitized, the hosts, turns, fillers and quotes are kept")
+	})
+
+	config.URLList = "urls.txt"
+	err = runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio)
+	assert.EqualError(t, err, "-script voices a single discussion, it can't be combined with -url-list")
+}
+
+// captureLog returns the lines fn logs with the logger it's given, warnings and errors only
+func captureLog(t *testing.T, fn func(logger *slog.Logger)) string {
+	t.Helper()
+	var buf bytes.Buffer
+	fn(logging.New(&buf, slog.LevelWarn))
+	return buf.String()
+}
+
+func TestRunWithDependenciesIntroHost(t *testing.T) {
+	hosts := []podcast.Host{
+		{Name: "Алексей", Gender: "male", Voice: "onyx"},
+		{Name: "Мария", Gender: "female", Voice: "nova"},
+	}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+
+	t.Run("intro host asked in the prompt, lines voiced as generated", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				return podcast.Discussion{
+					Title: params.Title,
+					Messages: []podcast.Message{
+						{Host: "Алексей", Content: "intro line"},
+						{Host: "Мария", Content: "second line"},
+					},
+				}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio data"), nil
+			},
+		}
+		mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3",
+			TargetDuration: 5, IntroHost: "Мария"}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+		discussionCalls := mockOpenAI.GenerateDiscussionCalls()
+		require.Len(t, discussionCalls, 1)
+		assert.Equal(t, "Мария", discussionCalls[0].Params.IntroHost)
+
+		speechCalls := mockOpenAI.GenerateSpeechCalls()
+		require.Len(t, speechCalls, 2)
+		assert.Equal(t, "intro line", speechCalls[0].Text)
+		assert.Equal(t, "onyx", speechCalls[0].Voice, "the host of the line isn't rewritten")
+		assert.Equal(t, "nova", speechCalls[1].Voice)
+	})
+
+	t.Run("unknown host rejected", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", IntroHost: "Пётр"}
+
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid -intro-host: unknown host "Пётр", expected one of: Алексей, Мария`)
+		assert.Empty(t, mockOpenAI.GenerateDiscussionCalls())
+	})
+}
+
+func TestRunWithDependenciesReduceFillers(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Gender: "male", Voice: "onyx"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+
+	t.Run("fillers thinned before synthesis", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				messages := make([]podcast.Message, 5)
+				for i := range messages {
+					messages[i] = podcast.Message{Host: "Алексей", Content: "Ну, это интересно."}
+				}
+				return podcast.Discussion{Title: params.Title, Messages: messages}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio data"), nil
+			},
+		}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3",
+			TargetDuration: 5, ReduceFillers: "strong"}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+
+		var texts []string
+		for _, call := range mockOpenAI.GenerateSpeechCalls() {
+			texts = append(texts, call.Text)
+		}
+		assert.Equal(t, []string{"Ну, это интересно.", "Это интересно.", "Ну, это интересно.", "Это интересно.",
+			"Это интересно."}, texts)
+	})
+
+	t.Run("unknown intensity rejected", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", ReduceFillers: "max"}
+
+		err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid -reduce-fillers: unknown filler intensity "max"`)
+		assert.Empty(t, mockOpenAI.GenerateDiscussionCalls())
+	})
+}
+
+func TestRunWithDependenciesMaxConsecutive(t *testing.T) {
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: "one"},
+				{Host: "Алексей", Content: "two"},
+				{Host: "Алексей", Content: "three"},
+				{Host: "Мария", Content: "four"},
+			}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	config := podcast.Config{Hosts: []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}},
+		ArticleURL: "http://example.com", OutputFile: "out.mp3", TargetDuration: 5, MaxConsecutive: 1}
+
+	require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+
+	var texts []string
+	for _, call := range mockOpenAI.GenerateSpeechCalls() {
+		texts = append(texts, call.Text)
+	}
+	assert.Equal(t, []string{"one two three", "four"}, texts)
+}
+
+func TestRunWithDependenciesStdoutOutput(t *testing.T) {
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}}
+
+	t.Run("audio written to the writer", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{{Host: "Алексей", Content: "hi"}}}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio data"), nil
+			},
+		}
+		mockAudio := &mocks.AudioProcessorMock{
+			ConcatenateToFunc: func(files []string, w io.Writer) error {
+				_, err := w.Write([]byte("mp3 bytes"))
+				return err
+			},
+		}
+		var out strings.Builder
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "-", AudioOut: &out}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		assert.Equal(t, "mp3 bytes", out.String())
+		require.Len(t, mockAudio.ConcatenateToCalls(), 1)
+		assert.Len(t, mockAudio.ConcatenateToCalls()[0].Files, 1)
+		assert.Empty(t, mockAudio.ConcatenateCalls(), "no file named - is written")
+	})
+
+	t.Run("split episodes rejected", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "-", SplitEpisodes: 2}
+		err := runWithDependencies(t.Context(), config, mockArticle, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't be combined with -split-episodes")
+	})
+}
+
+func TestRunWithDependenciesVoiceIntro(t *testing.T) {
+	hosts := []podcast.Host{
+		{Name: "Алексей", Gender: "male", Character: "молодой техно-оптимист", Voice: "onyx"},
+		{Name: "Мария", Gender: "female", Character: "аналитик, любит данные", Voice: "nova"},
+		{Name: "Дмитрий", Gender: "male", Voice: "echo"},
+	}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{
+				Title:    params.Title,
+				Messages: []podcast.Message{{Host: "Мария", Content: "discussion line"}},
+			}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
+	config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3",
+		TargetDuration: 5, VoiceIntro: true}
+
+	require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+	speechCalls := mockOpenAI.GenerateSpeechCalls()
+	require.Len(t, speechCalls, 4)
+	assert.Equal(t, "Привет, я Алексей, молодой техно-оптимист.", speechCalls[0].Text)
+	assert.Equal(t, "onyx", speechCalls[0].Voice)
+	assert.Equal(t, "Привет, я Мария, аналитик, любит данные.", speechCalls[1].Text)
+	assert.Equal(t, "nova", speechCalls[1].Voice)
+	assert.Equal(t, "Привет, я Дмитрий.", speechCalls[2].Text)
+	assert.Equal(t, "echo", speechCalls[2].Voice)
+	assert.Equal(t, "discussion line", speechCalls[3].Text)
+
+	concatCalls := mockAudio.ConcatenateCalls()
+	require.Len(t, concatCalls, 1)
+	assert.Len(t, concatCalls[0].Files, 4)
+
+	t.Run("english episode", func(t *testing.T) {
+		intros := voiceIntroMessages(hosts, "en")
+		require.Len(t, intros, 3)
+		assert.Equal(t, "Hi, I'm Алексей, молодой техно-оптимист.", intros[0].Content)
+		assert.Equal(t, "Hi, I'm Дмитрий.", intros[2].Content)
+
+		mockOpenAI := &mocks.OpenAIClientMock{GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		}}
+		require.NoError(t, preflightTTS(podcast.Config{Hosts: hosts[:1], Language: "en"}, mockOpenAI))
+		require.Len(t, mockOpenAI.GenerateSpeechCalls(), 1)
+		assert.Equal(t, "Check.", mockOpenAI.GenerateSpeechCalls()[0].Text)
+	})
+}
+
+func TestRunWithDependenciesCatchphrases(t *testing.T) {
+	hosts := []podcast.Host{
+		{Name: "Алексей", Voice: "onyx", Intro: "Будущее уже здесь!", Outro: "Оставайтесь любопытными!"},
+		{Name: "Мария", Voice: "nova"},
+		{Name: "Дмитрий", Voice: "echo", Intro: "Ну, посмотрим, что нам пообещают.", Outro: "Поживём — увидим."},
+	}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	newOpenAI := func() *mocks.OpenAIClientMock {
+		return &mocks.OpenAIClientMock{
+			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+				return podcast.Discussion{
+					Title:    params.Title,
+					Messages: []podcast.Message{{Host: "Мария", Content: "discussion line"}},
+				}, nil
+			},
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte("audio data"), nil
+			},
+		}
+	}
+	speech := func(mock *mocks.OpenAIClientMock) (texts, voices []string) {
+		for _, call := range mock.GenerateSpeechCalls() {
+			texts = append(texts, call.Text)
+			voices = append(voices, call.Voice)
+		}
+		return texts, voices
+	}
+
+	t.Run("catchphrases open and close the episode", func(t *testing.T) {
+		mockOpenAI := newOpenAI()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3",
+			TargetDuration: 5, Catchphrases: true}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+		texts, voices := speech(mockOpenAI)
+		assert.Equal(t, []string{"Будущее уже здесь!", "Ну, посмотрим, что нам пообещают.", "discussion line",
+			"Оставайтесь любопытными!", "Поживём — увидим."}, texts)
+		assert.Equal(t, []string{"onyx", "echo", "nova", "onyx", "echo"}, voices)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		mockOpenAI := newOpenAI()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: "out.mp3", TargetDuration: 5}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+		texts, _ := speech(mockOpenAI)
+		assert.Equal(t, []string{"discussion line"}, texts)
+	})
+
+	t.Run("before voice intros", func(t *testing.T) {
+		mockOpenAI := newOpenAI()
+		config := podcast.Config{Hosts: hosts[:1], ArticleURL: "http://example.com", OutputFile: "out.mp3",
+			TargetDuration: 5, Catchphrases: true, VoiceIntro: true}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}))
+		texts, _ := speech(mockOpenAI)
+		assert.Equal(t, []string{"Будущее уже здесь!", "Привет, я Алексей.", "discussion line", "Оставайтесь любопытными!"}, texts)
+	})
+}
+
+func TestRunWithDependenciesTeaser(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx", Intro: "Будущее уже здесь!"}, {Name: "Мария", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: "intro"}, {Host: "Мария", Content: "first"}, {Host: "Алексей", Content: "second"},
+			}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	newAudio := func() *mocks.AudioProcessorMock {
+		return &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo, DurationFunc: func(filename string) (float64, error) { return 4.5, nil }}
+	}
+	dir := t.TempDir()
+	output := filepath.Join(dir, "episode.mp3")
+
+	t.Run("starts after the intro", func(t *testing.T) {
+		mockAudio := newAudio()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: output,
+			TargetDuration: 5, Teaser: 30 * time.Second}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		require.Len(t, mockAudio.DurationCalls(), 1)
+		assert.Equal(t, "segment_000.mp3", filepath.Base(mockAudio.DurationCalls()[0].Filename))
+		require.Len(t, mockAudio.TrimCalls(), 1)
+		call := mockAudio.TrimCalls()[0]
+		assert.Equal(t, output, call.InputFile)
+		assert.Equal(t, filepath.Join(dir, "episode_teaser.mp3"), call.OutputFile)
+		assert.InDelta(t, 4.5, call.Start, 0.001)
+		assert.InDelta(t, 30, call.Duration, 0.001)
+	})
+
+	t.Run("catchphrases are part of the intro", func(t *testing.T) {
+		mockAudio := newAudio()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: output,
+			TargetDuration: 5, Teaser: 15 * time.Second, Catchphrases: true}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		require.Len(t, mockAudio.TrimCalls(), 1)
+		assert.InDelta(t, 9, mockAudio.TrimCalls()[0].Start, 0.001)
+		assert.InDelta(t, 15, mockAudio.TrimCalls()[0].Duration, 0.001)
+	})
+
+	t.Run("pauses are part of the intro", func(t *testing.T) {
+		mockAudio := newAudio()
+		mockAudio.InsertSilenceFunc = func(durationMs int, tempDir string) (string, error) {
+			return filepath.Join(tempDir, "pause.mp3"), nil
+		}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: output,
+			TargetDuration: 5, Teaser: 30 * time.Second, PauseMs: 400}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		require.Len(t, mockAudio.DurationCalls(), 2)
+		assert.Equal(t, "pause.mp3", filepath.Base(mockAudio.DurationCalls()[1].Filename))
+		require.Len(t, mockAudio.TrimCalls(), 1)
+		assert.InDelta(t, 9, mockAudio.TrimCalls()[0].Start, 0.001)
+	})
+
+	t.Run("unmeasured intro starts at the beginning", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo, DurationFunc: func(filename string) (float64, error) {
+			return 0, assert.AnError
+		}}
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: output,
+			TargetDuration: 5, Teaser: 30 * time.Second}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		require.Len(t, mockAudio.TrimCalls(), 1)
+		assert.Zero(t, mockAudio.TrimCalls()[0].Start)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		mockAudio := newAudio()
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: output, TargetDuration: 5}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		assert.Empty(t, mockAudio.TrimCalls())
+	})
+
+	t.Run("requires an output file", func(t *testing.T) {
+		for _, out := range []string{"", stdoutOutput} {
+			config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: out,
+				TargetDuration: 5, Teaser: 30 * time.Second}
+			err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, newAudio())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "-teaser")
+		}
+	})
+}
+
+func TestRunWithDependenciesMaxTTSChars(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		maxChars      int
+		expectedErr   string
+		expectedTexts []string
+	}{
+		{name: "under the cap", mode: "reject", maxChars: 100,
+			expectedTexts: []string{"first line", "second line", "third line"}},
+		{name: "rejected over the cap", mode: "reject", maxChars: 25,
+			expectedErr: "discussion needs 31 TTS characters, over the -max-tts-chars limit of 25"},
+		{name: "trimmed over the cap", mode: "trim", maxChars: 25, expectedTexts: []string{"first line", "second line"}},
+		{name: "nothing fits", mode: "trim", maxChars: 5, expectedErr: "first message alone needs more than 5 TTS characters"},
+		{name: "unknown mode", mode: "drop", maxChars: 25, expectedErr: `invalid -tts-chars-mode "drop", expected reject or trim`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockArticle := &mocks.ArticleFetcherMock{
+				FetchFunc: func(url string) (string, string, error) {
+					return "article text", "Test Article", nil
+				},
+			}
+			mockOpenAI := &mocks.OpenAIClientMock{
+				GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+					return podcast.Discussion{
+						Title: params.Title,
+						Messages: []podcast.Message{
+							{Host: "host1", Content: "first line"},
+							{Host: "host2", Content: "second line"},
+							{Host: "host1", Content: "third line"},
+						},
+					}, nil
+				},
+				GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+					return []byte("audio data"), nil
+				},
+			}
+			config := podcast.Config{ArticleURL: "http://example.com", OutputFile: "out.mp3", TargetDuration: 5,
+				MaxTTSChars: test.maxChars, TTSCharsMode: test.mode}
+
+			err := runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo})
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				assert.Empty(t, mockOpenAI.GenerateSpeechCalls(), "no TTS calls when rejected")
+				return
+			}
+			require.NoError(t, err)
+			texts := make([]string, 0, len(mockOpenAI.GenerateSpeechCalls()))
+			for _, call := range mockOpenAI.GenerateSpeechCalls() {
+				texts = append(texts, call.Text)
+			}
+			assert.Equal(t, test.expectedTexts, texts)
+		})
+	}
+}
+
+func TestGenerateSpeechSegmentsSpeedCheckpoints(t *testing.T) {
+	messages := make([]podcast.Message, 7)
+	for i := range messages {
+		messages[i] = podcast.Message{Host: "host1", Content: strings.Repeat("слово ", 75)}
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	tempos := make(map[string]float64)
+	mockAudio := &mocks.AudioProcessorMock{
+		DurationFunc: func(filename string) (float64, error) {
+			return 30, nil
+		},
+		ChangeTempoFunc: func(inputFiles []string, tempo float64, tempDir string) ([]string, error) {
+			for _, f := range inputFiles {
+				tempos[filepath.Base(f)] = tempo
+			}
+			return inputFiles, nil
+		},
+	}
+
+	params := podcast.GenerateSpeechSegmentsParams{
+		Messages:       messages,
+		HostMap:        map[string]podcast.HostInfo{"host1": {Voice: "voice1"}},
+		TempDir:        t.TempDir(),
+		TargetDuration: 3,
+		Speed:          1.0,
+	}
+	audioFiles, err := generateSpeechSegments(t.Context(), params, mockOpenAI, mockAudio)
+	require.NoError(t, err)
+	assert.Len(t, audioFiles, 7)
+
+	durationCalls := mockAudio.DurationCalls()
+	require.Len(t, durationCalls, 7)
+	for i, call := range durationCalls {
+		assert.Equal(t, audioFiles[i], call.Filename)
+	}
+	// seven 30s segments overshoot the 3 minute target, the first checkpoint speeds up the segments after it
+	assert.Equal(t, map[string]float64{"segment_003.mp3": 1.25, "segment_004.mp3": 1.25, "segment_005.mp3": 1.25,
+		"segment_006.mp3": 1.25}, tempos)
+
+	t.Run("no measurements without target duration", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{}
+		params.TargetDuration = 0
+		_, err := generateSpeechSegments(t.Context(), params, mockOpenAI, mockAudio)
+		require.NoError(t, err)
+		assert.Empty(t, mockAudio.DurationCalls())
+	})
+}
+
+func TestGenerateSpeechSegmentsTempo(t *testing.T) {
+	jingle := filepath.Join(t.TempDir(), "jingle.mp3")
+	require.NoError(t, os.WriteFile(jingle, []byte("jingle"), 0o600))
+	messages := []podcast.Message{
+		{Host: "host1", Content: "Привет"},
+		{Host: "host1", AudioFile: jingle},
+		{Host: "host1", Content: "Пока"},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	mockAudio := &mocks.AudioProcessorMock{
+		ChangeTempoFunc: func(inputFiles []string, tempo float64, tempDir string) ([]string, error) {
+			result := make([]string, len(inputFiles))
+			for i, f := range inputFiles {
+				result[i] = filepath.Join(tempDir, "tempo_"+filepath.Base(f))
+			}
+			return result, nil
+		},
+	}
+
+	tempDir := t.TempDir()
+	params := podcast.GenerateSpeechSegmentsParams{
+		Messages: messages,
+		HostMap:  map[string]podcast.HostInfo{"host1": {Voice: "voice1"}},
+		TempDir:  tempDir,
+		Speed:    1.2,
+	}
+	audioFiles, err := generateSpeechSegments(t.Context(), params, mockOpenAI, mockAudio)
+	require.NoError(t, err)
+
+	calls := mockAudio.ChangeTempoCalls()
+	require.Len(t, calls, 1)
+	assert.InDelta(t, 1/1.2, calls[0].Tempo, 1e-9, "speech stretched to the target is slowed down")
+	assert.Equal(t, []string{segmentFileName(tempDir, 0), segmentFileName(tempDir, 2)}, calls[0].InputFiles,
+		"pre-recorded audio keeps its pace")
+	assert.Equal(t, []string{filepath.Join(tempDir, "tempo_segment_000.mp3"), segmentFileName(tempDir, 1),
+		filepath.Join(tempDir, "tempo_segment_002.mp3")}, audioFiles)
+
+	t.Run("speed 1.0 keeps the segments", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{}
+		params.Speed = 1.0
+		files, err := generateSpeechSegments(t.Context(), params, mockOpenAI, mockAudio)
+		require.NoError(t, err)
+		assert.Empty(t, mockAudio.ChangeTempoCalls())
+		assert.Equal(t, []string{segmentFileName(tempDir, 0), segmentFileName(tempDir, 1), segmentFileName(tempDir, 2)}, files)
+	})
+
+	t.Run("ffmpeg failure", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{
+			ChangeTempoFunc: func(inputFiles []string, tempo float64, tempDir string) ([]string, error) {
+				return nil, errors.New("ffmpeg failed")
+			},
+		}
+		params.Speed = 0.8
+		_, err := generateSpeechSegments(t.Context(), params, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to change speech tempo: ffmpeg failed")
+	})
+}
+
+func TestRunWithDependenciesSpeechTempo(t *testing.T) {
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{{Host: "Алексей", Content: "Привет"}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+
+	// a couple of short lines are far below the target, the speech is slowed down as much as allowed
+	for _, dryRun := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dry run %v", dryRun), func(t *testing.T) {
+			mockAudio := &mocks.AudioProcessorMock{ChangeTempoFunc: keepTempo}
+			config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", TargetDuration: 10, DryRun: dryRun}
+			require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+
+			calls := mockAudio.ChangeTempoCalls()
+			require.NotEmpty(t, calls)
+			for _, call := range calls {
+				assert.InDelta(t, 1/1.2, call.Tempo, 1e-9)
+			}
+		})
+	}
+}
+
+func TestCreateSpeechRequestSpeed(t *testing.T) {
+	req := createSpeechRequest(podcast.CreateSpeechRequestParams{Msg: podcast.Message{Host: "host1"}, Speed: 0.8})
+	assert.InDelta(t, 0.8, req.Speed, 1e-9)
+
+	req = createSpeechRequest(podcast.CreateSpeechRequestParams{Msg: podcast.Message{Host: "host1"}})
+	assert.InDelta(t, 1.0, req.Speed, 1e-9, "1.0 when not set")
+}
+
+func TestStreamSegmentsWithBackpressure(t *testing.T) {
+	messages := make([]podcast.Message, 6)
+	for i := range messages {
+		messages[i] = podcast.Message{Host: "host1", Content: fmt.Sprintf("msg%d", i)}
+	}
+	newParams := func(msgs []podcast.Message) podcast.GenerateAndStreamParams {
+		return podcast.GenerateAndStreamParams{
+			Discussion: podcast.Discussion{Messages: msgs},
+			Config:     podcast.Config{StreamAhead: 2, AdBreak: podcast.AdBreak{SilenceMs: 500}},
+		}
+	}
+	hostMap := map[string]podcast.HostInfo{"host1": {Voice: "voice1"}}
+
+	t.Run("generation pauses when ahead buffer is full", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte(text), nil
+			},
+		}
+		generated := func() int { return len(mockOpenAI.GenerateSpeechCalls()) }
+
+		mockAudio := &mocks.AudioProcessorMock{
+			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+				// nothing consumed yet, generation stops after two segments
+				require.Eventually(t, func() bool { return generated() == 2 }, time.Second, 5*time.Millisecond)
+				time.Sleep(50 * time.Millisecond)
+				assert.Equal(t, 2, generated())
+
+				// consuming one segment frees a slot for exactly one more
+				buf := make([]byte, 4)
+				_, err := io.ReadFull(r, buf)
+				require.NoError(t, err)
+				assert.Equal(t, "msg0", string(buf))
+				require.Eventually(t, func() bool { return generated() == 3 }, time.Second, 5*time.Millisecond)
+				time.Sleep(50 * time.Millisecond)
+				assert.Equal(t, 3, generated())
+
+				rest, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, "msg1msg2msg3msg4msg5", string(rest))
+				return nil
+			},
+		}
+
+		err := streamSegmentsWithBackpressure(newParams(messages), hostMap, t.TempDir(), 1.0, mockOpenAI, mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, 6, generated())
+	})
+
+	t.Run("ad segments surrounded with silence", func(t *testing.T) {
+		tempDir := t.TempDir()
+		silenceFile := filepath.Join(tempDir, "silence.mp3")
+		require.NoError(t, os.WriteFile(silenceFile, []byte("..."), 0o600))
+
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte(text), nil
+			},
+		}
+		var streamed []byte
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, dir string) (string, error) {
+				return silenceFile, nil
+			},
+			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+				var err error
+				streamed, err = io.ReadAll(r)
+				return err
+			},
+		}
+
+		msgs := []podcast.Message{messages[0], {Host: "Реклама", Content: "ad", Ad: true}, messages[1]}
+		err := streamSegmentsWithBackpressure(newParams(msgs), hostMap, tempDir, 1.0, mockOpenAI, mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, "msg0...ad...msg1", string(streamed))
+		assert.Len(t, mockAudio.InsertSilenceCalls(), 1)
+	})
+
+	t.Run("pauses between messages", func(t *testing.T) {
+		tempDir := t.TempDir()
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte(text), nil
+			},
+		}
+		var streamed []byte
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, dir string) (string, error) {
+				file := filepath.Join(dir, fmt.Sprintf("silence_%d.mp3", durationMs))
+				return file, os.WriteFile(file, []byte(strings.Repeat(".", durationMs/100)), 0o600)
+			},
+			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+				var err error
+				streamed, err = io.ReadAll(r)
+				return err
+			},
+		}
+
+		params := newParams([]podcast.Message{messages[0], {Host: "Реклама", Content: "ad", Ad: true}, messages[1]})
+		params.Config.PauseMs = 200
+		err := streamSegmentsWithBackpressure(params, hostMap, tempDir, 1.0, mockOpenAI, mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, "msg0.......ad.......msg1", string(streamed))
+		assert.Len(t, mockAudio.InsertSilenceCalls(), 2)
+	})
+
+	t.Run("pause holds segment feeding until resumed", func(t *testing.T) {
+		tempDir := t.TempDir()
+		silenceFile := filepath.Join(tempDir, "pause.mp3")
+		require.NoError(t, os.WriteFile(silenceFile, []byte("_"), 0o600))
+
+		ctrl := control.New(nil)
+		ctrl.Pause()
+		params := newParams(messages[:2])
+		params.Config.Paused = ctrl.Paused
+
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte(text), nil
+			},
+		}
+		var streamed []byte
+		mockAudio := &mocks.AudioProcessorMock{
+			InsertSilenceFunc: func(durationMs int, dir string) (string, error) {
+				return silenceFile, nil
+			},
+			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+				// while paused only silence reaches the stream
+				buf := make([]byte, 100)
+				_, err := io.ReadFull(r, buf)
+				require.NoError(t, err)
+				assert.Equal(t, strings.Repeat("_", 100), string(buf))
+				assert.True(t, ctrl.Paused())
+
+				ctrl.Resume()
+				streamed, err = io.ReadAll(r)
+				return err
+			},
+		}
+
+		err := streamSegmentsWithBackpressure(params, hostMap, tempDir, 1.0, mockOpenAI, mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, "msg0msg1", strings.TrimLeft(string(streamed), "_"))
+		assert.Len(t, mockAudio.InsertSilenceCalls(), 1)
+	})
+
+	t.Run("generation error", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				if text == "msg2" {
+					return nil, assert.AnError
+				}
+				return []byte(text), nil
+			},
+		}
+		mockAudio := &mocks.AudioProcessorMock{
+			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+				data, err := io.ReadAll(r)
+				assert.Equal(t, "msg0msg1", string(data))
+				return err
+			},
+		}
+
+		err := streamSegmentsWithBackpressure(newParams(messages), hostMap, t.TempDir(), 1.0, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to generate speech for message 2")
+	})
+
+	t.Run("stream error stops generation", func(t *testing.T) {
+		mockOpenAI := &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte(text), nil
+			},
+		}
+		mockAudio := &mocks.AudioProcessorMock{
+			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+				return assert.AnError
+			},
+		}
+
+		err := streamSegmentsWithBackpressure(newParams(messages), hostMap, t.TempDir(), 1.0, mockOpenAI, mockAudio)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to stream segments")
+		assert.Less(t, len(mockOpenAI.GenerateSpeechCalls()), len(messages))
+	})
+}
+
+func TestJingles(t *testing.T) {
+	dir := t.TempDir()
+	introFile := filepath.Join(dir, "it's intro.mp3")
+	outroFile := filepath.Join(dir, "outro.mp3")
+	require.NoError(t, os.WriteFile(introFile, []byte("[intro]"), 0o600))
+	require.NoError(t, os.WriteFile(outroFile, []byte("[outro]"), 0o600))
+	messages := []podcast.Message{{Host: "host1", Content: "msg0"}, {Host: "host1", Content: "msg1"}}
+	hosts := []podcast.Host{{Name: "host1", Voice: "nova"}}
+	newOpenAI := func() *mocks.OpenAIClientMock {
+		return &mocks.OpenAIClientMock{
+			GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+				return []byte(text), nil
+			},
+		}
+	}
+
+	t.Run("concat file lists intro first and outro last", func(t *testing.T) {
+		var concat string
+		mockAudio := &mocks.AudioProcessorMock{
+			StreamFromConcatFunc: func(concatFile string, config podcast.Config) error {
+				data, err := os.ReadFile(concatFile) // #nosec G304 -- test file
+				concat = string(data)
+				return err
+			},
+		}
+		params := podcast.GenerateAndStreamParams{Discussion: podcast.Discussion{Messages: messages},
+			Config: podcast.Config{Hosts: hosts, IntroFile: introFile, OutroFile: outroFile}}
+		require.NoError(t, generateAndStreamToIcecast(t.Context(), params, newOpenAI(), mockAudio))
+
+		lines := strings.Split(strings.TrimSuffix(concat, "\n"), "\n")
+		require.Len(t, lines, 4)
+		assert.Equal(t, "file '"+dir+"/it'\\''s intro.mp3'", lines[0], "quote in the path escaped")
+		assert.True(t, strings.HasSuffix(lines[1], "segment_000.mp3'"), lines[1])
+		assert.True(t, strings.HasSuffix(lines[2], "segment_001.mp3'"), lines[2])
+		assert.Equal(t, "file '"+outroFile+"'", lines[3])
+	})
+
+	t.Run("saved episode and teaser", func(t *testing.T) {
+		mockAudio := &mocks.AudioProcessorMock{
+			DurationFunc: func(filename string) (float64, error) {
+				return 2, nil
+			},
+		}
+		params := podcast.GenerateAndStreamParams{Discussion: podcast.Discussion{Messages: messages}, IntroMessages: 1,
+			Config: podcast.Config{Hosts: hosts, OutputFile: filepath.Join(t.TempDir(), "episode.mp3"), IntroFile: introFile,
+				OutroFile: outroFile, Teaser: 10 * time.Second}}
+		require.NoError(t, generateAndPlayLocally(params, newOpenAI(), mockAudio))
+
+		concatCalls := mockAudio.ConcatenateCalls()
+		require.Len(t, concatCalls, 1)
+		files := concatCalls[0].Files
+		require.Len(t, files, 4)
+		assert.Equal(t, introFile, files[0])
+		assert.Equal(t, outroFile, files[3])
+		trimCalls := mockAudio.TrimCalls()
+		require.Len(t, trimCalls, 1)
+		assert.InDelta(t, 4, trimCalls[0].Start, 0.001, "the teaser skips the jingle and the article intro")
+	})
+
+	t.Run("live stream", func(t *testing.T) {
+		var streamed []byte
+		mockAudio := &mocks.AudioProcessorMock{
+			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+				var err error
+				streamed, err = io.ReadAll(r)
+				return err
+			},
+		}
+		params := podcast.GenerateAndStreamParams{Discussion: podcast.Discussion{Messages: messages},
+			Config: podcast.Config{StreamAhead: 1, IntroFile: introFile, OutroFile: outroFile}}
+		err := streamSegmentsWithBackpressure(params, podcast.CreateHostMap(hosts), t.TempDir(), 1.0, newOpenAI(), mockAudio)
+		require.NoError(t, err)
+		assert.Equal(t, "[intro]msg0msg1[outro]", string(streamed))
+	})
+
+	t.Run("validated up front", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			config      podcast.Config
+			expectedErr string
+		}{
+			{name: "missing intro", config: podcast.Config{IntroFile: filepath.Join(dir, "missing.mp3")}, expectedErr: "invalid -intro"},
+			{name: "outro is a directory", config: podcast.Config{OutroFile: dir}, expectedErr: "invalid -outro: " + dir + " is not an audio file"},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				test.config.Hosts = hosts
+				mockArticle := &mocks.ArticleFetcherMock{}
+				err := runWithDependencies(t.Context(), test.config, mockArticle, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				assert.Empty(t, mockArticle.FetchCalls(), "nothing is fetched")
+			})
+		}
+	})
+}
+
+func TestRunWithDependenciesResumeFromSegment(t *testing.T) {
+	// the interrupted stream kept 30 segments and its discussion, with an ad at segment 20
+	workDir := t.TempDir()
+	messages := make([]podcast.Message, 30)
+	for i := range messages {
+		messages[i] = podcast.Message{Host: "Алексей", Content: fmt.Sprintf("line %d", i)}
+		require.NoError(t, os.WriteFile(segmentFileName(workDir, i), append(slices.Clone(mp3Frame), "audio"...), 0o600))
+	}
+	messages[20] = podcast.Message{Host: "Реклама", Content: "ad", Ad: true, AudioFile: "ad.mp3"}
+	require.NoError(t, saveDiscussion(filepath.Join(workDir, streamedDiscussionFile), podcast.Discussion{Title: "Test", Messages: messages}))
+	hosts := []podcast.Host{{Name: "Алексей", Gender: "male", Voice: "onyx"}}
+
+	newAudio := func(concatContent *string) *mocks.AudioProcessorMock {
+		return &mocks.AudioProcessorMock{
+			StreamFromConcatFunc: func(concatFile string, config podcast.Config) error {
+				data, err := os.ReadFile(concatFile)
+				*concatContent = string(data)
+				return err
+			},
+			ChangeTempoFunc: func(inputFiles []string, tempo float64, tempDir string) ([]string, error) {
+				result := make([]string, len(inputFiles))
+				for i, f := range inputFiles {
+					result[i] = filepath.Join(tempDir, "tempo_"+filepath.Base(f))
+				}
+				return result, nil
+			},
+			InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+				return filepath.Join(tempDir, fmt.Sprintf("silence_%dms.mp3", durationMs)), nil
+			},
+		}
+	}
+	line := func(name string) string { return fmt.Sprintf("file '%s'", filepath.Join(workDir, name)) }
+
+	t.Run("concat file holds remaining segments only", func(t *testing.T) {
+		mockArticle := &mocks.ArticleFetcherMock{}
+		mockOpenAI := &mocks.OpenAIClientMock{}
+		var concatContent string
+		mockAudio := newAudio(&concatContent)
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", WorkDir: workDir, ResumeStream: true, ResumeFromSegment: 13}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, mockAudio))
+		require.Len(t, mockAudio.StreamFromConcatCalls(), 1)
+
+		lines := strings.Split(strings.TrimSpace(concatContent), "\n")
+		require.Len(t, lines, 17)
+		for i, l := range lines {
+			assert.Equal(t, line(fmt.Sprintf("segment_%03d.mp3", i+13)), l)
+		}
+		assert.Empty(t, mockArticle.FetchCalls(), "nothing is fetched on resume")
+		assert.Empty(t, mockOpenAI.GenerateDiscussionCalls(), "nothing is generated on resume")
+	})
+
+	t.Run("segments go through the stream pipeline", func(t *testing.T) {
+		jingleDir := t.TempDir()
+		intro, outro := filepath.Join(jingleDir, "intro.mp3"), filepath.Join(jingleDir, "outro.mp3")
+		for _, jingle := range []string{intro, outro} {
+			require.NoError(t, os.WriteFile(jingle, append(slices.Clone(mp3Frame), "jingle"...), 0o600))
+		}
+		var concatContent string
+		mockAudio := newAudio(&concatContent)
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", WorkDir: workDir, ResumeStream: true, ResumeFromSegment: 18,
+			TargetDuration: 5, StreamAhead: 2, PauseMs: 300, AdBreak: podcast.AdBreak{SilenceMs: 1000},
+			IntroFile: intro, OutroFile: outro}
+
+		require.NoError(t, runWithDependencies(t.Context(), config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, mockAudio))
+
+		// a few short lines are far below the target, the speech is slowed down as in the interrupted stream
+		calls := mockAudio.ChangeTempoCalls()
+		require.Len(t, calls, 1)
+		assert.InDelta(t, 1/1.2, calls[0].Tempo, 1e-9)
+		assert.NotContains(t, calls[0].InputFiles, segmentFileName(workDir, 20), "the recorded ad keeps its pace")
+		assert.Len(t, calls[0].InputFiles, 11)
+
+		expected := []string{line("tempo_segment_018.mp3"), line("silence_300ms.mp3"), line("tempo_segment_019.mp3"),
+			line("silence_300ms.mp3"), line("silence_1000ms.mp3"), line("segment_020.mp3"), line("silence_1000ms.mp3")}
+		lines := strings.Split(strings.TrimSpace(concatContent), "\n")
+		require.Len(t, lines, 12+2+11+1, "segments, ad silences and pauses, no intro when resumed mid-episode, the outro last")
+		assert.Equal(t, expected, lines[:len(expected)])
+		assert.Equal(t, fmt.Sprintf("file '%s'", outro), lines[len(lines)-1])
+	})
+
+	t.Run("segment 0 resumes from the start", func(t *testing.T) {
+		intro := filepath.Join(t.TempDir(), "intro.mp3")
+		require.NoError(t, os.WriteFile(intro, append(slices.Clone(mp3Frame), "jingle"...), 0o600))
+		var concatContent string
+		config := podcast.Config{Hosts: hosts, WorkDir: workDir, ResumeStream: true, ResumeFromSegment: 0, IntroFile: intro}
+		require.NoError(t, runWithDependencies(t.Context(), config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, newAudio(&concatContent)))
+		lines := strings.Split(strings.TrimSpace(concatContent), "\n")
+		require.Len(t, lines, 31)
+		assert.Equal(t, fmt.Sprintf("file '%s'", intro), lines[0], "the intro jingle opens the stream again")
+		assert.Equal(t, line("segment_000.mp3"), lines[1])
+	})
+
+	t.Run("stale segments after the discussion are left out", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(segmentFileName(workDir, 30), append(slices.Clone(mp3Frame), "stale"...), 0o600))
+		var concatContent string
+		config := podcast.Config{Hosts: hosts, WorkDir: workDir, ResumeStream: true, ResumeFromSegment: 28}
+		require.NoError(t, runWithDependencies(t.Context(), config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, newAudio(&concatContent)))
+		assert.Equal(t, line("segment_028.mp3")+"\n"+line("segment_029.mp3"), strings.TrimSpace(concatContent))
+	})
+
+	tests := []struct {
+		name        string
+		config      podcast.Config
+		expectedErr string
+	}{
+		{name: "work dir required", config: podcast.Config{ResumeStream: true, ResumeFromSegment: 13}, expectedErr: "requires -work-dir"},
+		{name: "negative index", config: podcast.Config{WorkDir: workDir, ResumeStream: true, ResumeFromSegment: -2},
+			expectedErr: "invalid -resume-from-segment -2"},
+		{name: "local output", config: podcast.Config{WorkDir: workDir, ResumeStream: true, ResumeFromSegment: 13, OutputFile: "out.mp3"},
+			expectedErr: "applies to Icecast streaming only"},
+		{name: "past the discussion", config: podcast.Config{WorkDir: workDir, ResumeStream: true, ResumeFromSegment: 30},
+			expectedErr: "no segments from 30, the discussion in " + workDir + " has 30"},
+		{name: "no discussion kept", config: podcast.Config{WorkDir: t.TempDir(), ResumeStream: true, ResumeFromSegment: 1},
+			expectedErr: "failed to read the discussion of the interrupted run"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			mockAudio := &mocks.AudioProcessorMock{}
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, mockAudio)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+			assert.Empty(t, mockAudio.StreamFromConcatCalls())
+		})
+	}
+
+	t.Run("missing segment", func(t *testing.T) {
+		require.NoError(t, os.Remove(segmentFileName(workDir, 25)))
+		err := runWithDependencies(t.Context(), podcast.Config{Hosts: hosts, WorkDir: workDir, ResumeStream: true, ResumeFromSegment: 20},
+			&mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "segment_025.mp3 of the interrupted run is missing or broken")
+	})
+}
+
+func TestSegmentDir(t *testing.T) {
+	t.Run("work dir is created and kept", func(t *testing.T) {
+		workDir := filepath.Join(t.TempDir(), "segments")
+		dir, cleanup, err := segmentDir(workDir, slog.Default())
+		require.NoError(t, err)
+		assert.Equal(t, workDir, dir)
+		cleanup()
+		assert.DirExists(t, workDir)
+	})
+
+	t.Run("temporary dir is removed", func(t *testing.T) {
+		dir, cleanup, err := segmentDir("", slog.Default())
+		require.NoError(t, err)
+		assert.DirExists(t, dir)
+		cleanup()
+		assert.NoDirExists(t, dir)
+	})
+}
+
+func TestPrefetchFetcher(t *testing.T) {
+	var active, peak int
+	var mu sync.Mutex
+	release := make(chan struct{})
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			mu.Lock()
+			active++
+			peak = max(peak, active)
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			active--
+			mu.Unlock()
+			if url == "http://fail.example" {
+				return "", "", assert.AnError
+			}
+			return "text of " + url, "title of " + url, nil
+		},
+	}
+
+	urls := []string{"http://a.example", "http://b.example", "http://fail.example", "http://d.example"}
+	prefetcher := newPrefetchFetcher(mockArticle, urls, 2)
+	defer prefetcher.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return active == 2
+	}, time.Second, time.Millisecond, "two articles fetched in parallel")
+	close(release)
+
+	for _, u := range []string{"http://a.example", "http://b.example", "http://d.example"} {
+		text, title, err := prefetcher.Fetch(u)
+		require.NoError(t, err)
+		assert.Equal(t, "text of "+u, text)
+		assert.Equal(t, "title of "+u, title)
+	}
+	_, _, err := prefetcher.Fetch("http://fail.example")
+	require.ErrorIs(t, err, assert.AnError)
+
+	text, _, err := prefetcher.Fetch("http://other.example")
+	require.NoError(t, err)
+	assert.Equal(t, "text of http://other.example", text, "unknown URL fetched on demand")
+
+	assert.Len(t, mockArticle.FetchCalls(), 5, "each URL fetched once")
+	mu.Lock()
+	assert.Equal(t, 2, peak)
+	mu.Unlock()
+
+	t.Run("limited ahead", func(t *testing.T) {
+		mockArticle := &mocks.ArticleFetcherMock{FetchFunc: func(url string) (string, string, error) {
+			return "text of " + url, "", nil
+		}}
+		urls := []string{"http://a.example", "http://b.example", "http://c.example", "http://d.example", "http://e.example"}
+		prefetcher := newPrefetchFetcher(mockArticle, urls, 2)
+		defer prefetcher.Stop()
+
+		assert.Eventually(t, func() bool { return len(mockArticle.FetchCalls()) == 2 }, time.Second, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		assert.Len(t, mockArticle.FetchCalls(), 2, "no more than two articles ahead")
+
+		text, _, err := prefetcher.Fetch("http://a.example")
+		require.NoError(t, err)
+		assert.Equal(t, "text of http://a.example", text)
+		assert.Eventually(t, func() bool { return len(mockArticle.FetchCalls()) == 3 }, time.Second, time.Millisecond,
+			"the taken article frees a slot")
+		time.Sleep(20 * time.Millisecond)
+		assert.Len(t, mockArticle.FetchCalls(), 3)
+	})
+}
+
+func TestGenerateAndPlayLocallyParallelTTS(t *testing.T) {
+	messages := make([]podcast.Message, 8)
+	for i := range messages {
+		messages[i] = podcast.Message{Host: "host1", Content: fmt.Sprintf("line %d", i)}
+	}
+	var mu sync.Mutex
+	active, peak := 0, 0
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			mu.Lock()
+			active++
+			peak = max(peak, active)
+			mu.Unlock()
+			// earlier lines take longer, so results arrive out of order
+			var index int
+			_, _ = fmt.Sscanf(text, "line %d", &index)
+			time.Sleep(time.Duration(8-index) * 3 * time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return []byte(text), nil
+		},
+	}
+	var written []string
+	mockAudio := &mocks.AudioProcessorMock{
+		ConcatenateFunc: func(files []string, outputFile string) error {
+			for _, f := range files {
+				data, err := os.ReadFile(f) // #nosec G304 -- test file
+				if err != nil {
+					return err
+				}
+				written = append(written, string(data))
+			}
+			return nil
+		},
+	}
+	params := podcast.GenerateAndStreamParams{
+		Discussion: podcast.Discussion{Messages: messages},
+		Config: podcast.Config{OutputFile: "out.mp3", Hosts: []podcast.Host{{Name: "host1", Voice: "nova"}},
+			Concurrency: podcast.ConcurrencyConfig{TTS: 4}},
+	}
+
+	require.NoError(t, generateAndPlayLocally(params, mockOpenAI, mockAudio))
+	expected := make([]string, len(messages))
+	for i := range messages {
+		expected[i] = fmt.Sprintf("line %d", i)
+	}
+	assert.Equal(t, expected, written, "segments keep the discussion order")
+	assert.Greater(t, peak, 1, "speech generated by parallel workers")
+	assert.LessOrEqual(t, peak, 4)
+}
+
+// mp3Frame is a valid mpeg-1 layer 3 frame header, prepended to fake audio of reusable segments
+var mp3Frame = []byte{0xff, 0xfb, 0x90, 0x64}
+
+func TestDiscussionKey(t *testing.T) {
+	discussion := podcast.Discussion{Messages: []podcast.Message{{Host: "Alice", Content: "Hello"}, {Host: "Bob", Content: "Hi"}}}
+	key := discussionKey(discussion)
+	assert.Len(t, key, 16)
+	assert.Equal(t, key, discussionKey(podcast.Discussion{Title: "other title", Messages: slices.Clone(discussion.Messages)}))
+
+	changed := podcast.Discussion{Messages: slices.Clone(discussion.Messages)}
+	changed.Messages[1].Emotion = "excited"
+	assert.NotEqual(t, key, discussionKey(changed))
+	assert.NotEqual(t, key, discussionKey(podcast.Discussion{Messages: discussion.Messages[:1]}))
+}
+
+func TestReusableSegment(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.mp3")
+	require.NoError(t, os.WriteFile(valid, append(slices.Clone(mp3Frame), "audio"...), 0o600))
+	invalid := filepath.Join(dir, "invalid.mp3")
+	require.NoError(t, os.WriteFile(invalid, []byte("<html>error</html>"), 0o600))
+	empty := filepath.Join(dir, "empty.mp3")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	data, ok := reusableSegment(valid)
+	assert.True(t, ok)
+	assert.Equal(t, append(slices.Clone(mp3Frame), "audio"...), data)
+	for _, filename := range []string{invalid, empty, filepath.Join(dir, "missing.mp3"), ""} {
+		_, ok := reusableSegment(filename)
+		assert.False(t, ok, filename)
+	}
+}
+
+func TestGenerateAndPlayLocallyResumeDir(t *testing.T) {
+	messages := []podcast.Message{{Host: "host1", Content: "line 0"}, {Host: "host1", Content: "line 1"}, {Host: "host1", Content: "line 2"}}
+	resumeDir := t.TempDir()
+	segmentsDir := filepath.Join(resumeDir, discussionKey(podcast.Discussion{Messages: messages}))
+	require.NoError(t, os.MkdirAll(segmentsDir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(segmentsDir, "segment_000.mp3"), append(slices.Clone(mp3Frame), "kept 0"...), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(segmentsDir, "segment_001.mp3"), []byte("partial"), 0o600))
+
+	var mu sync.Mutex
+	var synthesized []string
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			mu.Lock()
+			synthesized = append(synthesized, text)
+			mu.Unlock()
+			return append(slices.Clone(mp3Frame), text...), nil
+		},
+	}
+	var written []string
+	mockAudio := &mocks.AudioProcessorMock{
+		ConcatenateFunc: func(files []string, outputFile string) error {
+			written = written[:0]
+			for _, f := range files {
+				data, err := os.ReadFile(f) // #nosec G304 -- test file
+				if err != nil {
+					return err
+				}
+				written = append(written, string(data[len(mp3Frame):]))
+			}
+			return nil
+		},
+	}
+	params := podcast.GenerateAndStreamParams{
+		Discussion: podcast.Discussion{Messages: messages},
+		Config:     podcast.Config{OutputFile: "out.mp3", Hosts: []podcast.Host{{Name: "host1", Voice: "nova"}}, ResumeDir: resumeDir},
+	}
+
+	require.NoError(t, generateAndPlayLocally(params, mockOpenAI, mockAudio))
+	sort.Strings(synthesized)
+	assert.Equal(t, []string{"line 1", "line 2"}, synthesized, "valid segment reused, partial and missing ones synthesized")
+	assert.Equal(t, []string{"kept 0", "line 1", "line 2"}, written)
+
+	synthesized = nil
+	require.NoError(t, generateAndPlayLocally(params, mockOpenAI, mockAudio))
+	assert.Empty(t, synthesized, "restarted run reuses every segment")
+	assert.Equal(t, []string{"kept 0", "line 1", "line 2"}, written)
+}
+
+func TestRunWithDependenciesResumeDir(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "Article content", "Test Article", nil
+		},
+	}
+	generated := 0
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			generated++ // every discussion of the model is different
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: fmt.Sprintf("Discussion %d.", generated)}, {Host: "Мария", Content: "Да."}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return append(slices.Clone(mp3Frame), text...), nil
+		},
+	}
+	resumeDir := t.TempDir()
+	config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(t.TempDir(), "episode.mp3"),
+		ResumeDir: resumeDir}
+
+	require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+	synthesized := len(mockOpenAI.GenerateSpeechCalls())
+	assert.Positive(t, synthesized)
+	saved, err := filepath.Glob(filepath.Join(resumeDir, "discussion_*.json"))
+	require.NoError(t, err)
+	require.Len(t, saved, 1, "the discussion is kept in the resume dir")
+
+	require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+	assert.Len(t, mockOpenAI.GenerateDiscussionCalls(), 1, "the restarted run reuses the discussion")
+	assert.Len(t, mockOpenAI.GenerateSpeechCalls(), synthesized, "and every segment of it")
+
+	t.Run("broken discussion file generated again", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(saved[0], []byte("{"), 0o600))
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+		assert.Len(t, mockOpenAI.GenerateDiscussionCalls(), 2)
+	})
+}
+
+func TestGenerateAndStreamToIcecastResumeDir(t *testing.T) {
+	messages := []podcast.Message{{Host: "host1", Content: "line 0"}, {Host: "host1", Content: "line 1"}}
+	resumeDir := t.TempDir()
+	segmentsDir := filepath.Join(resumeDir, discussionKey(podcast.Discussion{Messages: messages}))
+	require.NoError(t, os.MkdirAll(segmentsDir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(segmentsDir, "segment_000.mp3"), append(slices.Clone(mp3Frame), "kept 0"...), 0o600))
+
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return append(slices.Clone(mp3Frame), text...), nil
+		},
+	}
+	mockAudio := &mocks.AudioProcessorMock{
+		StreamFromConcatFunc: func(concatFile string, config podcast.Config) error { return nil },
+		StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		},
+	}
+	for _, streamAhead := range []int{0, 1} {
+		params := podcast.GenerateAndStreamParams{
+			Discussion: podcast.Discussion{Messages: messages},
+			Config: podcast.Config{Hosts: []podcast.Host{{Name: "host1", Voice: "nova"}}, ResumeDir: resumeDir,
+				StreamAhead: streamAhead},
+		}
+		require.NoError(t, generateAndStreamToIcecast(t.Context(), params, mockOpenAI, mockAudio))
+	}
+
+	calls := mockOpenAI.GenerateSpeechCalls()
+	require.Len(t, calls, 1, "segment 0 reused, segment 1 synthesized once and reused by the second run")
+	assert.Equal(t, "line 1", calls[0].Text)
+	assert.FileExists(t, filepath.Join(segmentsDir, "segment_001.mp3"), "segments are kept after the run")
+
+	t.Run("can't be combined with work dir", func(t *testing.T) {
+		config := podcast.Config{Hosts: []podcast.Host{{Name: "host1", Voice: "nova"}}, ArticleURL: "http://example.com",
+			ResumeDir: resumeDir, WorkDir: t.TempDir()}
+		err := runWithDependencies(t.Context(), config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't be combined with -work-dir")
+	})
+}
+
+func TestRunWithDependenciesTranscript(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: "Сегодня говорим о новой статье."}, {Host: "Мария", Content: "Давайте начнём."},
+				{Host: "Гость", Content: "А я просто зашёл."},
+			}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+	dir := t.TempDir()
+	transcriptFile := filepath.Join(dir, "episode.json")
+	config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(dir, "episode.mp3"),
+		OutputTranscript: transcriptFile, DefaultVoice: "alloy"}
+
+	require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+	data, err := os.ReadFile(transcriptFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	var transcript script.Transcript
+	require.NoError(t, json.Unmarshal(data, &transcript))
+
+	assert.Equal(t, "Test Article", transcript.Title)
+	require.Len(t, transcript.Messages, 3)
+	voices := make([]string, 0, len(transcript.Messages))
+	for _, line := range transcript.Messages {
+		voices = append(voices, line.Voice)
+	}
+	assert.Equal(t, []string{"onyx", "nova", "alloy"}, voices, "speakers missing from the hosts get the default voice")
+	last := transcript.Messages[2]
+	assert.InDelta(t, transcript.Duration, last.Start+last.Duration, 1e-9)
+}
+
+func TestRunWithDependenciesLanguage(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Alex", Voice: "onyx"}, {Name: "Mary", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	line := "Today we talk about the new article."
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{{Host: "Alex", Content: line}}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+
+	t.Run("durations are estimated in the episode language", func(t *testing.T) {
+		dir := t.TempDir()
+		transcriptFile := filepath.Join(dir, "episode.json")
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(dir, "episode.mp3"),
+			OutputTranscript: transcriptFile, Language: "en"}
+		require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+
+		data, err := os.ReadFile(transcriptFile) // #nosec G304 -- test file
+		require.NoError(t, err)
+		var transcript script.Transcript
+		require.NoError(t, json.Unmarshal(data, &transcript))
+		english := content.NewTextProcessor()
+		english.SetLanguage(content.LanguageEnglish)
+		assert.InDelta(t, english.EstimateAudioDuration(line), transcript.Duration, 1e-9)
+		assert.Greater(t, transcript.Duration, content.NewTextProcessor().EstimateAudioDuration(line))
+	})
+
+	t.Run("unknown language rejected", func(t *testing.T) {
+		config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", Language: "fr"}
+		err := runWithDependencies(t.Context(), config, mockArticle, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid -language: unknown language "fr", expected ru, en or es`)
+	})
+}
+
+func TestRunWithDependenciesSubtitles(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	mockArticle := &mocks.ArticleFetcherMock{
+		FetchFunc: func(url string) (string, string, error) {
+			return "article text", "Test Article", nil
+		},
+	}
+	mockOpenAI := &mocks.OpenAIClientMock{
+		GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
+			return podcast.Discussion{Title: params.Title, Messages: []podcast.Message{
+				{Host: "Алексей", Content: strings.TrimSpace(strings.Repeat("тест ", 11))}, {Host: "Мария", Content: "Да."},
+			}}, nil
+		},
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio data"), nil
+		},
+	}
+
+	for _, format := range []string{"srt", "vtt"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			config := podcast.Config{Hosts: hosts, ArticleURL: "http://example.com", OutputFile: filepath.Join(dir, "episode.mp3"),
+				Subtitles: format}
+			require.NoError(t, runWithDependencies(t.Context(), config, mockArticle, mockOpenAI, &mocks.AudioProcessorMock{}))
+
+			data, err := os.ReadFile(filepath.Join(dir, "episode."+format)) // #nosec G304 -- test file
+			require.NoError(t, err)
+			expected, err := content.BuildSubtitles([]podcast.Message{
+				{Host: "Алексей", Content: strings.TrimSpace(strings.Repeat("тест ", 11))}, {Host: "Мария", Content: "Да."},
+			}, format, content.LanguageRussian)
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(data))
+		})
+	}
+
+	tests := []struct {
+		name        string
+		config      podcast.Config
+		expectedErr string
+	}{
+		{name: "unknown format", config: podcast.Config{OutputFile: "episode.mp3", Subtitles: "ass"},
+			expectedErr: `invalid -subtitles "ass", expected srt or vtt`},
+		{name: "no output file", config: podcast.Config{Subtitles: "srt"}, expectedErr: "requires -mp3 with a file path"},
+		{name: "stdout output", config: podcast.Config{OutputFile: "-", Subtitles: "vtt"}, expectedErr: "requires -mp3 with a file path"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestGenerateAndPlayLocallyChapters(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}, {Name: "Мария", Voice: "nova"}}
+	messages := []podcast.Message{
+		{Host: "Алексей", Content: "Сегодня обсуждаем новый релиз."},
+		{Host: "Мария", Content: "Да."},
+		{Host: "Мария", Content: "Интересно."},
+	}
+	openAI := &mocks.OpenAIClientMock{
+		GenerateSpeechFunc: func(text, voice, emotion, model string) ([]byte, error) {
+			return []byte("audio " + text), nil
+		},
+	}
+	// a 5s intro jingle, 0.5s pauses, the first message takes 21s and the others 3s
+	mockAudio := &mocks.AudioProcessorMock{
+		InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+			return filepath.Join(tempDir, "pause.mp3"), nil
+		},
+		DurationFunc: func(filename string) (float64, error) {
+			switch filepath.Base(filename) {
+			case "intro.mp3":
+				return 5, nil
+			case "pause.mp3":
+				return 0.5, nil
+			case "segment_000.mp3":
+				return 21, nil
+			}
+			return 3, nil
+		},
+	}
+
+	dir := t.TempDir()
+	params := podcast.GenerateAndStreamParams{Discussion: podcast.Discussion{Title: "Релиз", Messages: messages},
+		Config: podcast.Config{Hosts: hosts, OutputFile: filepath.Join(dir, "episode.mp3"), IntroFile: "intro.mp3",
+			PauseMs: 500, Chapters: true}}
+	require.NoError(t, generateAndPlayLocally(params, openAI, mockAudio))
+
+	chaptersFile := filepath.Join(dir, "episode_chapters.txt")
+	data, err := os.ReadFile(chaptersFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	assert.Equal(t, ";FFMETADATA1\n"+
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=5000\nEND=26000\ntitle=Алексей: Сегодня обсуждаем новый релиз.\n"+
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=26500\nEND=33000\ntitle=Мария: Да.\n", string(data),
+		"the chapters start after the jingle and the pauses")
+	calls := mockAudio.WriteTagsCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, chaptersFile, calls[0].Tags.Chapters, "the chapters are written into the episode tag")
+}
+
+func TestMessageTimings(t *testing.T) {
+	messages := []podcast.Message{{Host: "Алексей", Content: "Привет."}, {Host: "Мария", Content: "Привет всем."}}
+	segmentFiles := []string{"segment_000.mp3", "segment_001.mp3"}
+	episodeFiles := []string{"intro.mp3", "segment_000.mp3", "silence.mp3", "segment_001.mp3"}
+	durations := map[string]float64{"intro.mp3": 4, "segment_000.mp3": 10, "silence.mp3": 1}
+	mockAudio := &mocks.AudioProcessorMock{
+		DurationFunc: func(filename string) (float64, error) {
+			if d, ok := durations[filename]; ok {
+				return d, nil
+			}
+			return 0, assert.AnError
+		},
+	}
+
+	timed := messageTimings(messages, segmentFiles, episodeFiles, podcast.Config{}, mockAudio)
+	require.Len(t, timed, 2)
+	assert.InDelta(t, 4, timed[0].Start, 0.001)
+	assert.InDelta(t, 14, timed[0].End, 0.001)
+	assert.InDelta(t, 15, timed[1].Start, 0.001)
+	estimate := textProcessorFor("").EstimateAudioDuration(messages[1].Content)
+	assert.InDelta(t, 15+estimate, timed[1].End, 0.001, "an unmeasured segment takes its estimate")
+
+	timed = messageTimings(messages, segmentFiles, episodeFiles, podcast.Config{CrossfadeMs: 500}, mockAudio)
+	require.Len(t, timed, 2)
+	assert.InDelta(t, 3.5, timed[0].Start, 0.001, "crossfaded files overlap")
+	assert.InDelta(t, 13.5, timed[1].Start, 0.001)
+}
+
+func TestRunWithDependenciesChapters(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Voice: "onyx"}}
+	tests := []struct {
+		name   string
+		config podcast.Config
+	}{
+		{name: "no output file", config: podcast.Config{Chapters: true}},
+		{name: "stdout output", config: podcast.Config{OutputFile: "-", Chapters: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config.Hosts = hosts
+			err := runWithDependencies(t.Context(), test.config, &mocks.ArticleFetcherMock{}, &mocks.OpenAIClientMock{}, &mocks.AudioProcessorMock{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "-chapters are added to the saved episode, it requires -mp3 with a file path")
+		})
+	}
+}
@@ -4,8 +4,10 @@
 package mocks
 
 import (
+	"io"
 	"sync"
 
+	"github.com/radio-t/ai-podcast/internal/audio"
 	"github.com/radio-t/ai-podcast/podcast"
 )
 
@@ -15,18 +17,51 @@ import (
 //
 //		// make and configure a mocked main.AudioProcessor
 //		mockedAudioProcessor := &AudioProcessorMock{
+//			AudiogramFunc: func(inputFile string, outputFile string, width int, height int) error {
+//				panic("mock out the Audiogram method")
+//			},
+//			ChangeTempoFunc: func(inputFiles []string, tempo float64, tempDir string) ([]string, error) {
+//				panic("mock out the ChangeTempo method")
+//			},
 //			ConcatenateFunc: func(files []string, outputFile string) error {
 //				panic("mock out the Concatenate method")
 //			},
+//			ConcatenateToFunc: func(files []string, w io.Writer) error {
+//				panic("mock out the ConcatenateTo method")
+//			},
+//			ConcatenateWithCrossfadeFunc: func(files []string, outputFile string, fadeMs int) error {
+//				panic("mock out the ConcatenateWithCrossfade method")
+//			},
+//			DurationFunc: func(filename string) (float64, error) {
+//				panic("mock out the Duration method")
+//			},
+//			InsertSilenceFunc: func(durationMs int, tempDir string) (string, error) {
+//				panic("mock out the InsertSilence method")
+//			},
+//			MixWithBackgroundFunc: func(speechFile string, musicFile string, outputFile string, musicGainDB float64) error {
+//				panic("mock out the MixWithBackground method")
+//			},
+//			NormalizeFunc: func(inputFiles []string, tempDir string) ([]string, error) {
+//				panic("mock out the Normalize method")
+//			},
 //			PlayFunc: func(filename string) error {
 //				panic("mock out the Play method")
 //			},
 //			StreamFromConcatFunc: func(concatFile string, config podcast.Config) error {
 //				panic("mock out the StreamFromConcat method")
 //			},
+//			StreamFromReaderFunc: func(r io.Reader, config podcast.Config) error {
+//				panic("mock out the StreamFromReader method")
+//			},
 //			StreamToIcecastFunc: func(inputFile string, config podcast.Config) error {
 //				panic("mock out the StreamToIcecast method")
 //			},
+//			TrimFunc: func(inputFile string, outputFile string, start float64, duration float64) error {
+//				panic("mock out the Trim method")
+//			},
+//			WriteTagsFunc: func(filename string, tags audio.Tags) error {
+//				panic("mock out the WriteTags method")
+//			},
 //		}
 //
 //		// use mockedAudioProcessor in code that requires main.AudioProcessor
@@ -34,20 +69,73 @@ import (
 //
 //	}
 type AudioProcessorMock struct {
+	// AudiogramFunc mocks the Audiogram method.
+	AudiogramFunc func(inputFile string, outputFile string, width int, height int) error
+
+	// ChangeTempoFunc mocks the ChangeTempo method.
+	ChangeTempoFunc func(inputFiles []string, tempo float64, tempDir string) ([]string, error)
+
 	// ConcatenateFunc mocks the Concatenate method.
 	ConcatenateFunc func(files []string, outputFile string) error
 
+	// ConcatenateToFunc mocks the ConcatenateTo method.
+	ConcatenateToFunc func(files []string, w io.Writer) error
+
+	// ConcatenateWithCrossfadeFunc mocks the ConcatenateWithCrossfade method.
+	ConcatenateWithCrossfadeFunc func(files []string, outputFile string, fadeMs int) error
+
+	// DurationFunc mocks the Duration method.
+	DurationFunc func(filename string) (float64, error)
+
+	// InsertSilenceFunc mocks the InsertSilence method.
+	InsertSilenceFunc func(durationMs int, tempDir string) (string, error)
+
+	// MixWithBackgroundFunc mocks the MixWithBackground method.
+	MixWithBackgroundFunc func(speechFile string, musicFile string, outputFile string, musicGainDB float64) error
+
+	// NormalizeFunc mocks the Normalize method.
+	NormalizeFunc func(inputFiles []string, tempDir string) ([]string, error)
+
 	// PlayFunc mocks the Play method.
 	PlayFunc func(filename string) error
 
 	// StreamFromConcatFunc mocks the StreamFromConcat method.
 	StreamFromConcatFunc func(concatFile string, config podcast.Config) error
 
+	// StreamFromReaderFunc mocks the StreamFromReader method.
+	StreamFromReaderFunc func(r io.Reader, config podcast.Config) error
+
 	// StreamToIcecastFunc mocks the StreamToIcecast method.
 	StreamToIcecastFunc func(inputFile string, config podcast.Config) error
 
+	// TrimFunc mocks the Trim method.
+	TrimFunc func(inputFile string, outputFile string, start float64, duration float64) error
+
+	// WriteTagsFunc mocks the WriteTags method.
+	WriteTagsFunc func(filename string, tags audio.Tags) error
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// Audiogram holds details about calls to the Audiogram method.
+		Audiogram []struct {
+			// InputFile is the inputFile argument value.
+			InputFile string
+			// OutputFile is the outputFile argument value.
+			OutputFile string
+			// Width is the width argument value.
+			Width int
+			// Height is the height argument value.
+			Height int
+		}
+		// ChangeTempo holds details about calls to the ChangeTempo method.
+		ChangeTempo []struct {
+			// InputFiles is the inputFiles argument value.
+			InputFiles []string
+			// Tempo is the tempo argument value.
+			Tempo float64
+			// TempDir is the tempDir argument value.
+			TempDir string
+		}
 		// Concatenate holds details about calls to the Concatenate method.
 		Concatenate []struct {
 			// Files is the files argument value.
@@ -55,6 +143,52 @@ type AudioProcessorMock struct {
 			// OutputFile is the outputFile argument value.
 			OutputFile string
 		}
+		// ConcatenateTo holds details about calls to the ConcatenateTo method.
+		ConcatenateTo []struct {
+			// Files is the files argument value.
+			Files []string
+			// W is the w argument value.
+			W io.Writer
+		}
+		// ConcatenateWithCrossfade holds details about calls to the ConcatenateWithCrossfade method.
+		ConcatenateWithCrossfade []struct {
+			// Files is the files argument value.
+			Files []string
+			// OutputFile is the outputFile argument value.
+			OutputFile string
+			// FadeMs is the fadeMs argument value.
+			FadeMs int
+		}
+		// Duration holds details about calls to the Duration method.
+		Duration []struct {
+			// Filename is the filename argument value.
+			Filename string
+		}
+		// InsertSilence holds details about calls to the InsertSilence method.
+		InsertSilence []struct {
+			// DurationMs is the durationMs argument value.
+			DurationMs int
+			// TempDir is the tempDir argument value.
+			TempDir string
+		}
+		// MixWithBackground holds details about calls to the MixWithBackground method.
+		MixWithBackground []struct {
+			// SpeechFile is the speechFile argument value.
+			SpeechFile string
+			// MusicFile is the musicFile argument value.
+			MusicFile string
+			// OutputFile is the outputFile argument value.
+			OutputFile string
+			// MusicGainDB is the musicGainDB argument value.
+			MusicGainDB float64
+		}
+		// Normalize holds details about calls to the Normalize method.
+		Normalize []struct {
+			// InputFiles is the inputFiles argument value.
+			InputFiles []string
+			// TempDir is the tempDir argument value.
+			TempDir string
+		}
 		// Play holds details about calls to the Play method.
 		Play []struct {
 			// Filename is the filename argument value.
@@ -67,6 +201,13 @@ type AudioProcessorMock struct {
 			// Config is the config argument value.
 			Config podcast.Config
 		}
+		// StreamFromReader holds details about calls to the StreamFromReader method.
+		StreamFromReader []struct {
+			// R is the r argument value.
+			R io.Reader
+			// Config is the config argument value.
+			Config podcast.Config
+		}
 		// StreamToIcecast holds details about calls to the StreamToIcecast method.
 		StreamToIcecast []struct {
 			// InputFile is the inputFile argument value.
@@ -74,11 +215,131 @@ type AudioProcessorMock struct {
 			// Config is the config argument value.
 			Config podcast.Config
 		}
+		// Trim holds details about calls to the Trim method.
+		Trim []struct {
+			// InputFile is the inputFile argument value.
+			InputFile string
+			// OutputFile is the outputFile argument value.
+			OutputFile string
+			// Start is the start argument value.
+			Start float64
+			// Duration is the duration argument value.
+			Duration float64
+		}
+		// WriteTags holds details about calls to the WriteTags method.
+		WriteTags []struct {
+			// Filename is the filename argument value.
+			Filename string
+			// Tags is the tags argument value.
+			Tags audio.Tags
+		}
+	}
+	lockAudiogram                sync.RWMutex
+	lockChangeTempo              sync.RWMutex
+	lockConcatenate              sync.RWMutex
+	lockConcatenateTo            sync.RWMutex
+	lockConcatenateWithCrossfade sync.RWMutex
+	lockDuration                 sync.RWMutex
+	lockInsertSilence            sync.RWMutex
+	lockMixWithBackground        sync.RWMutex
+	lockNormalize                sync.RWMutex
+	lockPlay                     sync.RWMutex
+	lockStreamFromConcat         sync.RWMutex
+	lockStreamFromReader         sync.RWMutex
+	lockStreamToIcecast          sync.RWMutex
+	lockTrim                     sync.RWMutex
+	lockWriteTags                sync.RWMutex
+}
+
+// Audiogram calls AudiogramFunc.
+func (mock *AudioProcessorMock) Audiogram(inputFile string, outputFile string, width int, height int) error {
+	callInfo := struct {
+		InputFile  string
+		OutputFile string
+		Width      int
+		Height     int
+	}{
+		InputFile:  inputFile,
+		OutputFile: outputFile,
+		Width:      width,
+		Height:     height,
+	}
+	mock.lockAudiogram.Lock()
+	mock.calls.Audiogram = append(mock.calls.Audiogram, callInfo)
+	mock.lockAudiogram.Unlock()
+	if mock.AudiogramFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.AudiogramFunc(inputFile, outputFile, width, height)
+}
+
+// AudiogramCalls gets all the calls that were made to Audiogram.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.AudiogramCalls())
+func (mock *AudioProcessorMock) AudiogramCalls() []struct {
+	InputFile  string
+	OutputFile string
+	Width      int
+	Height     int
+} {
+	var calls []struct {
+		InputFile  string
+		OutputFile string
+		Width      int
+		Height     int
+	}
+	mock.lockAudiogram.RLock()
+	calls = mock.calls.Audiogram
+	mock.lockAudiogram.RUnlock()
+	return calls
+}
+
+// ChangeTempo calls ChangeTempoFunc.
+func (mock *AudioProcessorMock) ChangeTempo(inputFiles []string, tempo float64, tempDir string) ([]string, error) {
+	callInfo := struct {
+		InputFiles []string
+		Tempo      float64
+		TempDir    string
+	}{
+		InputFiles: inputFiles,
+		Tempo:      tempo,
+		TempDir:    tempDir,
+	}
+	mock.lockChangeTempo.Lock()
+	mock.calls.ChangeTempo = append(mock.calls.ChangeTempo, callInfo)
+	mock.lockChangeTempo.Unlock()
+	if mock.ChangeTempoFunc == nil {
+		var (
+			stringsOut []string
+			errOut     error
+		)
+		return stringsOut, errOut
 	}
-	lockConcatenate      sync.RWMutex
-	lockPlay             sync.RWMutex
-	lockStreamFromConcat sync.RWMutex
-	lockStreamToIcecast  sync.RWMutex
+	return mock.ChangeTempoFunc(inputFiles, tempo, tempDir)
+}
+
+// ChangeTempoCalls gets all the calls that were made to ChangeTempo.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.ChangeTempoCalls())
+func (mock *AudioProcessorMock) ChangeTempoCalls() []struct {
+	InputFiles []string
+	Tempo      float64
+	TempDir    string
+} {
+	var calls []struct {
+		InputFiles []string
+		Tempo      float64
+		TempDir    string
+	}
+	mock.lockChangeTempo.RLock()
+	calls = mock.calls.ChangeTempo
+	mock.lockChangeTempo.RUnlock()
+	return calls
 }
 
 // Concatenate calls ConcatenateFunc.
@@ -120,6 +381,251 @@ func (mock *AudioProcessorMock) ConcatenateCalls() []struct {
 	return calls
 }
 
+// ConcatenateTo calls ConcatenateToFunc.
+func (mock *AudioProcessorMock) ConcatenateTo(files []string, w io.Writer) error {
+	callInfo := struct {
+		Files []string
+		W     io.Writer
+	}{
+		Files: files,
+		W:     w,
+	}
+	mock.lockConcatenateTo.Lock()
+	mock.calls.ConcatenateTo = append(mock.calls.ConcatenateTo, callInfo)
+	mock.lockConcatenateTo.Unlock()
+	if mock.ConcatenateToFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.ConcatenateToFunc(files, w)
+}
+
+// ConcatenateToCalls gets all the calls that were made to ConcatenateTo.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.ConcatenateToCalls())
+func (mock *AudioProcessorMock) ConcatenateToCalls() []struct {
+	Files []string
+	W     io.Writer
+} {
+	var calls []struct {
+		Files []string
+		W     io.Writer
+	}
+	mock.lockConcatenateTo.RLock()
+	calls = mock.calls.ConcatenateTo
+	mock.lockConcatenateTo.RUnlock()
+	return calls
+}
+
+// ConcatenateWithCrossfade calls ConcatenateWithCrossfadeFunc.
+func (mock *AudioProcessorMock) ConcatenateWithCrossfade(files []string, outputFile string, fadeMs int) error {
+	callInfo := struct {
+		Files      []string
+		OutputFile string
+		FadeMs     int
+	}{
+		Files:      files,
+		OutputFile: outputFile,
+		FadeMs:     fadeMs,
+	}
+	mock.lockConcatenateWithCrossfade.Lock()
+	mock.calls.ConcatenateWithCrossfade = append(mock.calls.ConcatenateWithCrossfade, callInfo)
+	mock.lockConcatenateWithCrossfade.Unlock()
+	if mock.ConcatenateWithCrossfadeFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.ConcatenateWithCrossfadeFunc(files, outputFile, fadeMs)
+}
+
+// ConcatenateWithCrossfadeCalls gets all the calls that were made to ConcatenateWithCrossfade.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.ConcatenateWithCrossfadeCalls())
+func (mock *AudioProcessorMock) ConcatenateWithCrossfadeCalls() []struct {
+	Files      []string
+	OutputFile string
+	FadeMs     int
+} {
+	var calls []struct {
+		Files      []string
+		OutputFile string
+		FadeMs     int
+	}
+	mock.lockConcatenateWithCrossfade.RLock()
+	calls = mock.calls.ConcatenateWithCrossfade
+	mock.lockConcatenateWithCrossfade.RUnlock()
+	return calls
+}
+
+// Duration calls DurationFunc.
+func (mock *AudioProcessorMock) Duration(filename string) (float64, error) {
+	callInfo := struct {
+		Filename string
+	}{
+		Filename: filename,
+	}
+	mock.lockDuration.Lock()
+	mock.calls.Duration = append(mock.calls.Duration, callInfo)
+	mock.lockDuration.Unlock()
+	if mock.DurationFunc == nil {
+		var (
+			fOut   float64
+			errOut error
+		)
+		return fOut, errOut
+	}
+	return mock.DurationFunc(filename)
+}
+
+// DurationCalls gets all the calls that were made to Duration.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.DurationCalls())
+func (mock *AudioProcessorMock) DurationCalls() []struct {
+	Filename string
+} {
+	var calls []struct {
+		Filename string
+	}
+	mock.lockDuration.RLock()
+	calls = mock.calls.Duration
+	mock.lockDuration.RUnlock()
+	return calls
+}
+
+// InsertSilence calls InsertSilenceFunc.
+func (mock *AudioProcessorMock) InsertSilence(durationMs int, tempDir string) (string, error) {
+	callInfo := struct {
+		DurationMs int
+		TempDir    string
+	}{
+		DurationMs: durationMs,
+		TempDir:    tempDir,
+	}
+	mock.lockInsertSilence.Lock()
+	mock.calls.InsertSilence = append(mock.calls.InsertSilence, callInfo)
+	mock.lockInsertSilence.Unlock()
+	if mock.InsertSilenceFunc == nil {
+		var (
+			sOut   string
+			errOut error
+		)
+		return sOut, errOut
+	}
+	return mock.InsertSilenceFunc(durationMs, tempDir)
+}
+
+// InsertSilenceCalls gets all the calls that were made to InsertSilence.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.InsertSilenceCalls())
+func (mock *AudioProcessorMock) InsertSilenceCalls() []struct {
+	DurationMs int
+	TempDir    string
+} {
+	var calls []struct {
+		DurationMs int
+		TempDir    string
+	}
+	mock.lockInsertSilence.RLock()
+	calls = mock.calls.InsertSilence
+	mock.lockInsertSilence.RUnlock()
+	return calls
+}
+
+// MixWithBackground calls MixWithBackgroundFunc.
+func (mock *AudioProcessorMock) MixWithBackground(speechFile string, musicFile string, outputFile string, musicGainDB float64) error {
+	callInfo := struct {
+		SpeechFile  string
+		MusicFile   string
+		OutputFile  string
+		MusicGainDB float64
+	}{
+		SpeechFile:  speechFile,
+		MusicFile:   musicFile,
+		OutputFile:  outputFile,
+		MusicGainDB: musicGainDB,
+	}
+	mock.lockMixWithBackground.Lock()
+	mock.calls.MixWithBackground = append(mock.calls.MixWithBackground, callInfo)
+	mock.lockMixWithBackground.Unlock()
+	if mock.MixWithBackgroundFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.MixWithBackgroundFunc(speechFile, musicFile, outputFile, musicGainDB)
+}
+
+// MixWithBackgroundCalls gets all the calls that were made to MixWithBackground.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.MixWithBackgroundCalls())
+func (mock *AudioProcessorMock) MixWithBackgroundCalls() []struct {
+	SpeechFile  string
+	MusicFile   string
+	OutputFile  string
+	MusicGainDB float64
+} {
+	var calls []struct {
+		SpeechFile  string
+		MusicFile   string
+		OutputFile  string
+		MusicGainDB float64
+	}
+	mock.lockMixWithBackground.RLock()
+	calls = mock.calls.MixWithBackground
+	mock.lockMixWithBackground.RUnlock()
+	return calls
+}
+
+// Normalize calls NormalizeFunc.
+func (mock *AudioProcessorMock) Normalize(inputFiles []string, tempDir string) ([]string, error) {
+	callInfo := struct {
+		InputFiles []string
+		TempDir    string
+	}{
+		InputFiles: inputFiles,
+		TempDir:    tempDir,
+	}
+	mock.lockNormalize.Lock()
+	mock.calls.Normalize = append(mock.calls.Normalize, callInfo)
+	mock.lockNormalize.Unlock()
+	if mock.NormalizeFunc == nil {
+		var (
+			stringsOut []string
+			errOut     error
+		)
+		return stringsOut, errOut
+	}
+	return mock.NormalizeFunc(inputFiles, tempDir)
+}
+
+// NormalizeCalls gets all the calls that were made to Normalize.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.NormalizeCalls())
+func (mock *AudioProcessorMock) NormalizeCalls() []struct {
+	InputFiles []string
+	TempDir    string
+} {
+	var calls []struct {
+		InputFiles []string
+		TempDir    string
+	}
+	mock.lockNormalize.RLock()
+	calls = mock.calls.Normalize
+	mock.lockNormalize.RUnlock()
+	return calls
+}
+
 // Play calls PlayFunc.
 func (mock *AudioProcessorMock) Play(filename string) error {
 	callInfo := struct {
@@ -194,6 +700,45 @@ func (mock *AudioProcessorMock) StreamFromConcatCalls() []struct {
 	return calls
 }
 
+// StreamFromReader calls StreamFromReaderFunc.
+func (mock *AudioProcessorMock) StreamFromReader(r io.Reader, config podcast.Config) error {
+	callInfo := struct {
+		R      io.Reader
+		Config podcast.Config
+	}{
+		R:      r,
+		Config: config,
+	}
+	mock.lockStreamFromReader.Lock()
+	mock.calls.StreamFromReader = append(mock.calls.StreamFromReader, callInfo)
+	mock.lockStreamFromReader.Unlock()
+	if mock.StreamFromReaderFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.StreamFromReaderFunc(r, config)
+}
+
+// StreamFromReaderCalls gets all the calls that were made to StreamFromReader.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.StreamFromReaderCalls())
+func (mock *AudioProcessorMock) StreamFromReaderCalls() []struct {
+	R      io.Reader
+	Config podcast.Config
+} {
+	var calls []struct {
+		R      io.Reader
+		Config podcast.Config
+	}
+	mock.lockStreamFromReader.RLock()
+	calls = mock.calls.StreamFromReader
+	mock.lockStreamFromReader.RUnlock()
+	return calls
+}
+
 // StreamToIcecast calls StreamToIcecastFunc.
 func (mock *AudioProcessorMock) StreamToIcecast(inputFile string, config podcast.Config) error {
 	callInfo := struct {
@@ -232,3 +777,89 @@ func (mock *AudioProcessorMock) StreamToIcecastCalls() []struct {
 	mock.lockStreamToIcecast.RUnlock()
 	return calls
 }
+
+// Trim calls TrimFunc.
+func (mock *AudioProcessorMock) Trim(inputFile string, outputFile string, start float64, duration float64) error {
+	callInfo := struct {
+		InputFile  string
+		OutputFile string
+		Start      float64
+		Duration   float64
+	}{
+		InputFile:  inputFile,
+		OutputFile: outputFile,
+		Start:      start,
+		Duration:   duration,
+	}
+	mock.lockTrim.Lock()
+	mock.calls.Trim = append(mock.calls.Trim, callInfo)
+	mock.lockTrim.Unlock()
+	if mock.TrimFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.TrimFunc(inputFile, outputFile, start, duration)
+}
+
+// TrimCalls gets all the calls that were made to Trim.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.TrimCalls())
+func (mock *AudioProcessorMock) TrimCalls() []struct {
+	InputFile  string
+	OutputFile string
+	Start      float64
+	Duration   float64
+} {
+	var calls []struct {
+		InputFile  string
+		OutputFile string
+		Start      float64
+		Duration   float64
+	}
+	mock.lockTrim.RLock()
+	calls = mock.calls.Trim
+	mock.lockTrim.RUnlock()
+	return calls
+}
+
+// WriteTags calls WriteTagsFunc.
+func (mock *AudioProcessorMock) WriteTags(filename string, tags audio.Tags) error {
+	callInfo := struct {
+		Filename string
+		Tags     audio.Tags
+	}{
+		Filename: filename,
+		Tags:     tags,
+	}
+	mock.lockWriteTags.Lock()
+	mock.calls.WriteTags = append(mock.calls.WriteTags, callInfo)
+	mock.lockWriteTags.Unlock()
+	if mock.WriteTagsFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.WriteTagsFunc(filename, tags)
+}
+
+// WriteTagsCalls gets all the calls that were made to WriteTags.
+// Check the length with:
+//
+//	len(mockedAudioProcessor.WriteTagsCalls())
+func (mock *AudioProcessorMock) WriteTagsCalls() []struct {
+	Filename string
+	Tags     audio.Tags
+} {
+	var calls []struct {
+		Filename string
+		Tags     audio.Tags
+	}
+	mock.lockWriteTags.RLock()
+	calls = mock.calls.WriteTags
+	mock.lockWriteTags.RUnlock()
+	return calls
+}
@@ -18,7 +18,7 @@ import (
 //			GenerateDiscussionFunc: func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
 //				panic("mock out the GenerateDiscussion method")
 //			},
-//			GenerateSpeechFunc: func(text string, voice string) ([]byte, error) {
+//			GenerateSpeechFunc: func(text string, voice string, emotion string, model string) ([]byte, error) {
 //				panic("mock out the GenerateSpeech method")
 //			},
 //		}
@@ -32,7 +32,7 @@ type OpenAIClientMock struct {
 	GenerateDiscussionFunc func(params podcast.GenerateDiscussionParams) (podcast.Discussion, error)
 
 	// GenerateSpeechFunc mocks the GenerateSpeech method.
-	GenerateSpeechFunc func(text string, voice string) ([]byte, error)
+	GenerateSpeechFunc func(text string, voice string, emotion string, model string) ([]byte, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
@@ -47,6 +47,10 @@ type OpenAIClientMock struct {
 			Text string
 			// Voice is the voice argument value.
 			Voice string
+			// Emotion is the emotion argument value.
+			Emotion string
+			// Model is the model argument value.
+			Model string
 		}
 	}
 	lockGenerateDiscussion sync.RWMutex
@@ -90,13 +94,17 @@ func (mock *OpenAIClientMock) GenerateDiscussionCalls() []struct {
 }
 
 // GenerateSpeech calls GenerateSpeechFunc.
-func (mock *OpenAIClientMock) GenerateSpeech(text string, voice string) ([]byte, error) {
+func (mock *OpenAIClientMock) GenerateSpeech(text string, voice string, emotion string, model string) ([]byte, error) {
 	callInfo := struct {
-		Text  string
-		Voice string
+		Text    string
+		Voice   string
+		Emotion string
+		Model   string
 	}{
-		Text:  text,
-		Voice: voice,
+		Text:    text,
+		Voice:   voice,
+		Emotion: emotion,
+		Model:   model,
 	}
 	mock.lockGenerateSpeech.Lock()
 	mock.calls.GenerateSpeech = append(mock.calls.GenerateSpeech, callInfo)
@@ -108,7 +116,7 @@ func (mock *OpenAIClientMock) GenerateSpeech(text string, voice string) ([]byte,
 		)
 		return bytesOut, errOut
 	}
-	return mock.GenerateSpeechFunc(text, voice)
+	return mock.GenerateSpeechFunc(text, voice, emotion, model)
 }
 
 // GenerateSpeechCalls gets all the calls that were made to GenerateSpeech.
@@ -116,12 +124,16 @@ func (mock *OpenAIClientMock) GenerateSpeech(text string, voice string) ([]byte,
 //
 //	len(mockedOpenAIClient.GenerateSpeechCalls())
 func (mock *OpenAIClientMock) GenerateSpeechCalls() []struct {
-	Text  string
-	Voice string
+	Text    string
+	Voice   string
+	Emotion string
+	Model   string
 } {
 	var calls []struct {
-		Text  string
-		Voice string
+		Text    string
+		Voice   string
+		Emotion string
+		Model   string
 	}
 	mock.lockGenerateSpeech.RLock()
 	calls = mock.calls.GenerateSpeech
@@ -4,7 +4,14 @@ go 1.24.1
 
 require (
 	github.com/PuerkitoBio/goquery v1.10.3
-	github.com/stretchr/testify v1.10.0
+	github.com/go-pdf/fpdf v0.9.0
+	github.com/go-pkgz/lgr v0.12.4
+	github.com/go-pkgz/routegroup v1.6.0
+	github.com/ledongthuc/pdf v0.0.0-20240201131950-da5b75280b06
+	github.com/markusmobius/go-trafilatura v1.12.2
+	github.com/stretchr/testify v1.12.0
+	golang.org/x/image v0.12.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -23,7 +30,6 @@ require (
 	github.com/markusmobius/go-dateparser v1.2.3 // indirect
 	github.com/markusmobius/go-domdistiller v0.0.0-20240926050704-25b8d046ffb4 // indirect
 	github.com/markusmobius/go-htmldate v1.9.1 // indirect
-	github.com/markusmobius/go-trafilatura v1.12.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
@@ -37,5 +43,4 @@ require (
 	golang.org/x/net v0.41.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
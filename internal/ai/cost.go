@@ -0,0 +1,144 @@
+package ai
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+)
+
+// ModelPrice is the price of a model in USD. chat models are billed by tokens, speech is estimated
+// by the characters of text sent to synthesis.
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`  // per million prompt tokens
+	OutputPerMillion float64 `json:"output_per_million"` // per million completion tokens
+	CharsPerMillion  float64 `json:"chars_per_million"`  // per million characters sent to speech synthesis
+}
+
+// PriceTable maps model names to their prices
+type PriceTable map[string]ModelPrice
+
+// DefaultPrices are the list prices of the default models when this was written. the audio models are billed
+// by audio tokens, their price per character is an approximation for Russian speech.
+var DefaultPrices = PriceTable{
+	"gpt-4o":                    {InputPerMillion: 2.5, OutputPerMillion: 10},
+	"gpt-4o-mini":               {InputPerMillion: 0.15, OutputPerMillion: 0.6},
+	"gpt-4.1":                   {InputPerMillion: 2, OutputPerMillion: 8},
+	"gpt-4.1-mini":              {InputPerMillion: 0.4, OutputPerMillion: 1.6},
+	"gpt-4o-audio-preview":      {CharsPerMillion: 60},
+	"gpt-4o-mini-audio-preview": {CharsPerMillion: 15},
+	"gpt-4o-mini-tts":           {CharsPerMillion: 12},
+	"tts-1":                     {CharsPerMillion: 15},
+	"tts-1-hd":                  {CharsPerMillion: 30},
+}
+
+// LoadPrices returns the default prices overridden by the prices of a JSON file mapping model names to prices,
+// e.g. {"gpt-4o": {"input_per_million": 2.5, "output_per_million": 10}}. an empty path gives the defaults.
+func LoadPrices(path string) (PriceTable, error) {
+	prices := maps.Clone(DefaultPrices)
+	if path == "" {
+		return prices, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the command line
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prices: %w", err)
+	}
+	var overrides PriceTable
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&overrides); err != nil {
+		return nil, fmt.Errorf("failed to decode prices from %s: %w", path, err)
+	}
+	maps.Copy(prices, overrides)
+	return prices, nil
+}
+
+// ModelUsage is what was sent to and received from a model during the run
+type ModelUsage struct {
+	Model            string `json:"model"`
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TTSChars         int    `json:"tts_chars"`
+}
+
+// ModelCost is the usage of a model with its estimated cost, Priced is false for a model missing from the price table
+type ModelCost struct {
+	ModelUsage
+	CostUSD float64 `json:"cost_usd"`
+	Priced  bool    `json:"priced"`
+}
+
+// CostReport is the estimated cost of the run by model
+type CostReport struct {
+	Models   []ModelCost `json:"models"`
+	TotalUSD float64     `json:"total_usd"`
+}
+
+// Cost returns the estimated cost of the usage in USD and whether the model has a price
+func (t PriceTable) Cost(usage ModelUsage) (float64, bool) {
+	price, ok := t[usage.Model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(usage.PromptTokens)*price.InputPerMillion +
+		float64(usage.CompletionTokens)*price.OutputPerMillion +
+		float64(usage.TTSChars)*price.CharsPerMillion) / 1e6, true
+}
+
+// Report estimates the cost of the usages, sorted by model name
+func (t PriceTable) Report(usages []ModelUsage) CostReport {
+	var report CostReport
+	for _, usage := range usages {
+		cost, priced := t.Cost(usage)
+		report.Models = append(report.Models, ModelCost{ModelUsage: usage, CostUSD: cost, Priced: priced})
+		report.TotalUSD += cost
+	}
+	slices.SortFunc(report.Models, func(a, b ModelCost) int { return cmp.Compare(a.Model, b.Model) })
+	return report
+}
+
+// apiUsage is the token usage reported with a chat completions response
+type apiUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// usageMeter accumulates the usage of every model, it's safe for concurrent use
+type usageMeter struct {
+	mu     sync.Mutex
+	models map[string]*ModelUsage
+}
+
+// add records a request to the model
+func (m *usageMeter) add(model string, promptTokens, completionTokens, ttsChars int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.models == nil {
+		m.models = make(map[string]*ModelUsage)
+	}
+	usage, ok := m.models[model]
+	if !ok {
+		usage = &ModelUsage{Model: model}
+		m.models[model] = usage
+	}
+	usage.Requests++
+	usage.PromptTokens += promptTokens
+	usage.CompletionTokens += completionTokens
+	usage.TTSChars += ttsChars
+}
+
+// usages returns a copy of the recorded usage of every model
+func (m *usageMeter) usages() []ModelUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]ModelUsage, 0, len(m.models))
+	for _, usage := range m.models {
+		result = append(result, *usage)
+	}
+	return result
+}
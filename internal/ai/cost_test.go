@@ -0,0 +1,118 @@
+package ai
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/ai/mocks"
+)
+
+func TestPriceTable_Cost(t *testing.T) {
+	prices := PriceTable{
+		"chat":   {InputPerMillion: 2.5, OutputPerMillion: 10},
+		"speech": {CharsPerMillion: 15},
+	}
+	tests := []struct {
+		name     string
+		usage    ModelUsage
+		expected float64
+		priced   bool
+	}{
+		{name: "chat tokens", usage: ModelUsage{Model: "chat", PromptTokens: 10_000, CompletionTokens: 3_000}, expected: 0.055, priced: true},
+		{name: "speech chars", usage: ModelUsage{Model: "speech", TTSChars: 20_000}, expected: 0.3, priced: true},
+		{name: "million of each", usage: ModelUsage{Model: "chat", PromptTokens: 1e6, CompletionTokens: 1e6, TTSChars: 1e6},
+			expected: 12.5, priced: true},
+		{name: "nothing used", usage: ModelUsage{Model: "chat"}, expected: 0, priced: true},
+		{name: "unknown model", usage: ModelUsage{Model: "local", PromptTokens: 1000}, expected: 0, priced: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cost, priced := prices.Cost(test.usage)
+			assert.InDelta(t, test.expected, cost, 1e-9)
+			assert.Equal(t, test.priced, priced)
+		})
+	}
+}
+
+func TestPriceTable_Report(t *testing.T) {
+	report := DefaultPrices.Report([]ModelUsage{
+		{Model: "gpt-4o-audio-preview", Requests: 40, TTSChars: 50_000},
+		{Model: "local-llama", Requests: 1, PromptTokens: 5000, CompletionTokens: 1000},
+		{Model: "gpt-4o", Requests: 2, PromptTokens: 8000, CompletionTokens: 2000},
+	})
+
+	require.Len(t, report.Models, 3)
+	assert.Equal(t, []string{"gpt-4o", "gpt-4o-audio-preview", "local-llama"},
+		[]string{report.Models[0].Model, report.Models[1].Model, report.Models[2].Model}, "sorted by model")
+	assert.InDelta(t, 0.04, report.Models[0].CostUSD, 1e-9)
+	assert.InDelta(t, 3.0, report.Models[1].CostUSD, 1e-9)
+	assert.False(t, report.Models[2].Priced)
+	assert.InDelta(t, 3.04, report.TotalUSD, 1e-9, "unpriced models add nothing")
+
+	assert.Empty(t, DefaultPrices.Report(nil).Models)
+}
+
+func TestLoadPrices(t *testing.T) {
+	prices, err := LoadPrices("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPrices, prices)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"gpt-4o": {"input_per_million": 2, "output_per_million": 8},
+		"llama3": {"input_per_million": 0.1, "output_per_million": 0.1}
+	}`), 0o600))
+	prices, err = LoadPrices(path)
+	require.NoError(t, err)
+	assert.Equal(t, ModelPrice{InputPerMillion: 2, OutputPerMillion: 8}, prices["gpt-4o"], "overridden")
+	assert.Equal(t, ModelPrice{InputPerMillion: 0.1, OutputPerMillion: 0.1}, prices["llama3"], "added")
+	assert.Equal(t, DefaultPrices["gpt-4o-audio-preview"], prices["gpt-4o-audio-preview"], "others kept")
+	assert.Equal(t, ModelPrice{InputPerMillion: 2.5, OutputPerMillion: 10}, DefaultPrices["gpt-4o"], "defaults untouched")
+
+	bad := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(bad, []byte(`{"gpt-4o": {"input": 2}}`), 0o600))
+	_, err = LoadPrices(bad)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown field "input"`)
+
+	_, err = LoadPrices(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read prices")
+}
+
+func TestOpenAIService_CostReport(t *testing.T) {
+	mockClient := &mocks.HTTPClientMock{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body := `{"choices": [{"message": {"content": "Alice: hi", "audio": {"data": "b2s="}}}],
+				"usage": {"prompt_tokens": 1000, "completion_tokens": 200}}`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+		},
+	}
+	service := NewOpenAIService("test-key", mockClient)
+	service.SetPrices(PriceTable{"gpt-4o": {InputPerMillion: 2.5, OutputPerMillion: 10}, "gpt-4o-audio-preview": {CharsPerMillion: 60}})
+
+	_, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+	require.NoError(t, err)
+	_, err = service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+	require.NoError(t, err)
+	_, err = service.GenerateSpeech("Привет, мир", "nova", "", "")
+	require.NoError(t, err)
+
+	report := service.CostReport()
+	require.Len(t, report.Models, 2)
+	assert.Equal(t, ModelUsage{Model: "gpt-4o", Requests: 2, PromptTokens: 2000, CompletionTokens: 400}, report.Models[0].ModelUsage)
+	assert.InDelta(t, 0.009, report.Models[0].CostUSD, 1e-9)
+	assert.Equal(t, ModelUsage{Model: "gpt-4o-audio-preview", Requests: 1, PromptTokens: 1000, CompletionTokens: 200, TTSChars: 11},
+		report.Models[1].ModelUsage, "characters of the line, not bytes")
+	assert.InDelta(t, 0.00066, report.Models[1].CostUSD, 1e-9)
+	assert.InDelta(t, 0.00966, report.TotalUSD, 1e-9)
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/radio-t/ai-podcast/internal/content"
+)
+
+// openAI error codes returned with 429 responses
+const (
+	errCodeRateLimit         = "rate_limit_exceeded"
+	errCodeInsufficientQuota = "insufficient_quota"
+)
+
+// errCodeContextLength is returned with a 400 response when the request doesn't fit into the model context
+const errCodeContextLength = "context_length_exceeded"
+
+// APIError represents a non-200 response from the OpenAI API
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	Body       string
+	RetryAfter time.Duration // delay requested by the Retry-After header, 0 when absent
+}
+
+// Error returns an actionable description of the API failure
+func (e *APIError) Error() string {
+	if e.QuotaExhausted() {
+		return fmt.Sprintf("status %d: OpenAI quota exhausted, add credits to your OpenAI account or raise billing limits: %s",
+			e.StatusCode, e.Message)
+	}
+	if e.RateLimited() {
+		return fmt.Sprintf("status %d: OpenAI rate limit exceeded, slow down or retry later: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+// QuotaExhausted reports whether the account ran out of credits, retrying won't help
+func (e *APIError) QuotaExhausted() bool {
+	return e.Code == errCodeInsufficientQuota || e.Type == errCodeInsufficientQuota
+}
+
+// RateLimited reports whether the request was throttled and can be retried later
+func (e *APIError) RateLimited() bool {
+	if e.QuotaExhausted() {
+		return false
+	}
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// ContextLengthExceeded reports whether the request is too long for the model, a shorter request
+// or a model with a larger context may succeed
+func (e *APIError) ContextLengthExceeded() bool {
+	return e.Code == errCodeContextLength
+}
+
+// Retryable reports whether the request may succeed when repeated: rate limits and server errors
+func (e *APIError) Retryable() bool {
+	return e.RateLimited() || e.StatusCode >= http.StatusInternalServerError
+}
+
+// parseRetryAfter returns the delay of a Retry-After header given in seconds or as an HTTP date,
+// capped by content.OpenAIRetryAfterMax. a missing or malformed header gives 0.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		delay = at.Sub(now)
+	}
+	return min(max(delay, 0), content.OpenAIRetryAfterMax)
+}
+
+// parseAPIError builds an APIError from the status code and response body
+func parseAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: string(body)}
+
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		apiErr.Type = payload.Error.Type
+		apiErr.Code = payload.Error.Code
+		apiErr.Message = payload.Error.Message
+	}
+	return apiErr
+}
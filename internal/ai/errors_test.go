@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/ai/mocks"
+	"github.com/radio-t/ai-podcast/internal/content"
+)
+
+const (
+	rateLimitBody = `{"error": {"message": "Rate limit reached for gpt-4o", "type": "requests", "code": "rate_limit_exceeded"}}`
+	quotaBody     = `{"error": {"message": "You exceeded your current quota", "type": "insufficient_quota", "code": "insufficient_quota"}}`
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCode     int
+		body           string
+		quotaExhausted bool
+		rateLimited    bool
+		expectedMsg    string
+	}{
+		{
+			name:        "rate limit exceeded",
+			statusCode:  http.StatusTooManyRequests,
+			body:        rateLimitBody,
+			rateLimited: true,
+			expectedMsg: "status 429: OpenAI rate limit exceeded, slow down or retry later: Rate limit reached for gpt-4o",
+		},
+		{
+			name:           "insufficient quota",
+			statusCode:     http.StatusTooManyRequests,
+			body:           quotaBody,
+			quotaExhausted: true,
+			expectedMsg:    "status 429: OpenAI quota exhausted, add credits to your OpenAI account or raise billing limits: You exceeded your current quota",
+		},
+		{
+			name:        "429 without error details",
+			statusCode:  http.StatusTooManyRequests,
+			body:        `too many requests`,
+			rateLimited: true,
+			expectedMsg: "status 429: OpenAI rate limit exceeded",
+		},
+		{
+			name:        "other error",
+			statusCode:  http.StatusBadRequest,
+			body:        `{"error": "bad request"}`,
+			expectedMsg: `status 400: {"error": "bad request"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiErr := parseAPIError(test.statusCode, []byte(test.body))
+			assert.Equal(t, test.quotaExhausted, apiErr.QuotaExhausted())
+			assert.Equal(t, test.rateLimited, apiErr.RateLimited())
+			assert.Contains(t, apiErr.Error(), test.expectedMsg)
+		})
+	}
+}
+
+func TestOpenAIService_RateLimitHandling(t *testing.T) {
+	response := func(status int, body string) *http.Response {
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+	}
+
+	t.Run("rate limit is retried", func(t *testing.T) {
+		calls := 0
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				calls++
+				if calls == 1 {
+					return response(http.StatusTooManyRequests, rateLimitBody), nil
+				}
+				return response(http.StatusOK, `{"choices": [{"message": {"audio": {"data": "dGVzdCBhdWRpbyBkYXRh"}}}]}`), nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.retryPolicy.BaseDelay = time.Millisecond
+
+		audioData, err := service.GenerateSpeech("test", "echo", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("test audio data"), audioData)
+		assert.Len(t, mockClient.DoCalls(), 2)
+	})
+
+	t.Run("rate limit retries are bounded", func(t *testing.T) {
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return response(http.StatusTooManyRequests, rateLimitBody), nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.retryPolicy.BaseDelay = time.Millisecond
+
+		_, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "API request failed with status 429: OpenAI rate limit exceeded")
+		assert.Len(t, mockClient.DoCalls(), 4)
+	})
+
+	t.Run("rate limited twice then succeeds, honoring Retry-After", func(t *testing.T) {
+		var stamps []time.Time
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				stamps = append(stamps, time.Now())
+				if len(stamps) <= 2 {
+					resp := response(http.StatusTooManyRequests, rateLimitBody)
+					resp.Header.Set("Retry-After", "1")
+					return resp, nil
+				}
+				return response(http.StatusOK, `{"choices": [{"message": {"content": "ok"}}]}`), nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.retryPolicy.BaseDelay = time.Millisecond
+
+		content, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+		require.NoError(t, err)
+		assert.Equal(t, "ok", content)
+		require.Len(t, stamps, 3)
+		for i := 1; i < len(stamps); i++ {
+			assert.GreaterOrEqual(t, stamps[i].Sub(stamps[i-1]), time.Second, "waits for Retry-After, not the policy delay")
+		}
+	})
+
+	t.Run("server errors and network failures are retried", func(t *testing.T) {
+		calls := 0
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				calls++
+				switch calls {
+				case 1:
+					return response(http.StatusBadGateway, "bad gateway"), nil
+				case 2:
+					return nil, errors.New("connection reset by peer")
+				default:
+					return response(http.StatusOK, `{"choices": [{"message": {"audio": {"data": "dGVzdCBhdWRpbyBkYXRh"}}}]}`), nil
+				}
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.retryPolicy.BaseDelay = time.Millisecond
+
+		audioData, err := service.GenerateSpeech("test", "echo", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("test audio data"), audioData)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("client errors fail immediately", func(t *testing.T) {
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return response(http.StatusBadRequest, `{"error": {"message": "bad request"}}`), nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.retryPolicy.BaseDelay = time.Millisecond
+
+		_, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "status 400")
+		assert.Len(t, mockClient.DoCalls(), 1)
+	})
+
+	t.Run("max retries", func(t *testing.T) {
+		for _, retries := range []int{0, 1, 5} {
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					return response(http.StatusServiceUnavailable, "unavailable"), nil
+				},
+			}
+			service := NewOpenAIService("test-key", mockClient)
+			service.retryPolicy.BaseDelay = time.Millisecond
+			service.SetMaxRetries(retries)
+
+			_, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "API request failed with status 503")
+			assert.Len(t, mockClient.DoCalls(), retries+1)
+		}
+	})
+
+	t.Run("quota exhausted fails immediately", func(t *testing.T) {
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return response(http.StatusTooManyRequests, quotaBody), nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.retryPolicy.BaseDelay = time.Millisecond
+
+		_, err := service.GenerateSpeech("test", "echo", "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TTS request failed with status 429")
+		assert.Contains(t, err.Error(), "add credits")
+		assert.Len(t, mockClient.DoCalls(), 1)
+
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.True(t, apiErr.QuotaExhausted())
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		header   string
+		expected time.Duration
+	}{
+		{name: "missing", header: "", expected: 0},
+		{name: "seconds", header: "7", expected: 7 * time.Second},
+		{name: "http date", header: now.Add(20 * time.Second).Format(http.TimeFormat), expected: 20 * time.Second},
+		{name: "date in the past", header: now.Add(-time.Minute).Format(http.TimeFormat), expected: 0},
+		{name: "capped", header: "86400", expected: content.OpenAIRetryAfterMax},
+		{name: "negative", header: "-5", expected: 0},
+		{name: "malformed", header: "soon", expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, parseRetryAfter(test.header, now))
+		})
+	}
+}
+
+func TestAPIError_Retryable(t *testing.T) {
+	assert.True(t, parseAPIError(http.StatusTooManyRequests, []byte(rateLimitBody)).Retryable())
+	assert.True(t, parseAPIError(http.StatusInternalServerError, nil).Retryable())
+	assert.True(t, parseAPIError(http.StatusServiceUnavailable, nil).Retryable())
+	assert.False(t, parseAPIError(http.StatusTooManyRequests, []byte(quotaBody)).Retryable())
+	assert.False(t, parseAPIError(http.StatusBadRequest, nil).Retryable())
+	assert.False(t, parseAPIError(http.StatusUnauthorized, nil).Retryable())
+}
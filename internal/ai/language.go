@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/radio-t/ai-podcast/internal/content"
+)
+
+// promptLanguage holds the language-specific parts of the prompts. instructions to the model stay in English,
+// the samples of the dialog format are in the episode language, so the model doesn't drift into another one.
+type promptLanguage struct {
+	name     string            // english name used in the instructions, e.g. Russian
+	aName    string            // the name with the indefinite article, e.g. an English
+	line     string            // sample dialog line
+	reply    string            // sample reply to it
+	callback string            // sample reference to the previous episode of a miniseries
+	tts      string            // speech system prompt, %s is the speaking style of the voice
+	emotion  string            // appended to the speech system prompt for a tagged line, %s is the emotion
+	styles   map[string]string // speaking styles of the voices of the default hosts
+	anyHost  string            // speaking style of a voice without a style of its own
+}
+
+var promptLanguages = map[content.Language]promptLanguage{
+	content.LanguageRussian: {
+		name:     "Russian",
+		aName:    "a Russian",
+		line:     "Имя: что говорит",
+		reply:    "Имя: ответ",
+		callback: "как мы обсуждали в прошлый раз",
+		tts:      "Ты %s в подкасте о технологиях. Говори естественно по-русски, как обычный человек.",
+		emotion:  " Произнеси эту реплику с эмоцией: %s.",
+		styles: map[string]string{
+			"onyx": "молодой техно-оптимист",
+			"nova": "аналитик, любит данные",
+			"echo": "скептик, видел всякое",
+		},
+		anyHost: "ведущий",
+	},
+	content.LanguageEnglish: {
+		name:     "English",
+		aName:    "an English",
+		line:     "Name: what they say",
+		reply:    "Name: the reply",
+		callback: "as we discussed last time",
+		tts:      "You are %s on a tech podcast. Speak naturally in English, like a regular person.",
+		emotion:  " Deliver this line with the emotion: %s.",
+		styles: map[string]string{
+			"onyx": "a young tech optimist",
+			"nova": "an analyst who loves data",
+			"echo": "a skeptic who has seen it all",
+		},
+		anyHost: "a host",
+	},
+	content.LanguageSpanish: {
+		name:     "Spanish",
+		aName:    "a Spanish",
+		line:     "Nombre: lo que dice",
+		reply:    "Nombre: la respuesta",
+		callback: "como comentamos la última vez",
+		tts:      "Eres %s en un podcast de tecnología. Habla con naturalidad en español, como una persona normal.",
+		emotion:  " Pronuncia esta frase con la emoción: %s.",
+		styles: map[string]string{
+			"onyx": "un joven tecnooptimista",
+			"nova": "una analista a la que le encantan los datos",
+			"echo": "un escéptico que lo ha visto todo",
+		},
+		anyHost: "un presentador",
+	},
+}
+
+// promptsFor returns the prompt parts of the language, Russian for an unknown or empty one
+func promptsFor(lang content.Language) promptLanguage {
+	if prompts, ok := promptLanguages[lang]; ok {
+		return prompts
+	}
+	return promptLanguages[content.LanguageRussian]
+}
+
+// taggedLine returns the sample line with the tag after the name, e.g. "Имя [excited]: что говорит"
+func (p promptLanguage) taggedLine(tag string) string {
+	return strings.Replace(p.line, ":", " ["+tag+"]:", 1)
+}
@@ -2,13 +2,21 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/radio-t/ai-podcast/internal/backoff"
 	"github.com/radio-t/ai-podcast/internal/content"
 	"github.com/radio-t/ai-podcast/podcast"
 )
@@ -22,18 +30,181 @@ type HTTPClient interface {
 
 // OpenAIService implements OpenAI API interactions
 type OpenAIService struct {
-	apiKey     string
-	httpClient HTTPClient
+	ctx         context.Context // cancels requests in flight and retry waits
+	apiKey      string
+	httpClient  HTTPClient
+	retryPolicy backoff.RetryPolicy
+	userAgent   string
+	baseURL     string // API root, chat completions are posted to baseURL/chat/completions
+	ttsBackend  TTSBackend
+	chatModel   string
+	ttsModel    string
+	chatTimeout time.Duration // deadline of every chat attempt, streamed responses included
+	ttsTimeout  time.Duration // deadline of every speech attempt
+	prices      PriceTable
+	usage       usageMeter
+	// chat model with a larger context, tried when the article doesn't fit into the context of chatModel
+	fallbackChatModel string
+	// times the model is asked to fix a discussion which can't be parsed
+	parseRetries int
+	// language of the discussion and the speech, Russian when empty
+	language content.Language
+	// speaking styles of the voices without a built-in style, e.g. the characters of the hosts
+	voiceStyles map[string]string
+	// messages of the discussion requested per minute of the target duration
+	messagesPerMinute int
+	// destination of retries, revisions and other progress messages
+	logger *slog.Logger
 }
 
 // NewOpenAIService creates a new OpenAI service
 func NewOpenAIService(apiKey string, httpClient HTTPClient) *OpenAIService {
 	if httpClient == nil {
-		httpClient = &http.Client{Timeout: content.OpenAIHTTPTimeout}
+		// requests get per-attempt deadlines, see post
+		httpClient = &http.Client{}
 	}
 	return &OpenAIService{
 		apiKey:     apiKey,
 		httpClient: httpClient,
+		retryPolicy: backoff.RetryPolicy{
+			MaxAttempts: content.OpenAIRateLimitRetries + 1,
+			BaseDelay:   content.OpenAIRateLimitDelay,
+			MaxDelay:    content.OpenAIRateLimitMaxDelay,
+			Jitter:      content.RetryJitter,
+		},
+		userAgent:    content.OpenAIUserAgent,
+		baseURL:      content.OpenAIBaseURL,
+		chatModel:    content.OpenAIChatModel,
+		ttsModel:     content.OpenAITTSModel,
+		ttsBackend:   TTSBackendChat,
+		chatTimeout:  content.OpenAIChatTimeout,
+		ttsTimeout:   content.OpenAITTSTimeout,
+		parseRetries: content.DiscussionParseRetries,
+		prices:       DefaultPrices,
+
+		messagesPerMinute: content.MessagesPerMinute,
+		logger:            slog.Default(),
+	}
+}
+
+// SetContext sets the context of every request, canceling it aborts the request in flight and stops retries
+func (s *OpenAIService) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// context returns the context of the requests, the background one when it's not set
+func (s *OpenAIService) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// SetRetryPolicy overrides how rate limited OpenAI requests are retried
+func (s *OpenAIService) SetRetryPolicy(policy backoff.RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// SetMaxRetries sets how many times a failed OpenAI request is retried, 0 disables retries
+func (s *OpenAIService) SetMaxRetries(retries int) {
+	s.retryPolicy.MaxAttempts = max(retries, 0) + 1
+}
+
+// SetLogger sets the logger of retries, revisions and other progress messages, nil keeps the default one
+func (s *OpenAIService) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		s.logger = logger
+	}
+}
+
+// SetUserAgent overrides the User-Agent header sent with every OpenAI request
+func (s *OpenAIService) SetUserAgent(userAgent string) {
+	s.userAgent = userAgent
+}
+
+// SetBaseURL points requests to an OpenAI-compatible API, e.g. a local vLLM or Ollama server
+// at http://localhost:11434/v1 or a gateway. an empty URL keeps the OpenAI API.
+func (s *OpenAIService) SetBaseURL(baseURL string) {
+	if baseURL != "" {
+		s.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// SetPrices replaces the price table estimating the cost of the run
+func (s *OpenAIService) SetPrices(prices PriceTable) {
+	s.prices = prices
+}
+
+// CostReport returns the usage of every model so far with the estimated cost
+func (s *OpenAIService) CostReport() CostReport {
+	return s.prices.Report(s.usage.usages())
+}
+
+// SetChatModel overrides the model generating the discussion, an empty model keeps the default
+func (s *OpenAIService) SetChatModel(model string) {
+	if model != "" {
+		s.chatModel = model
+	}
+}
+
+// SetFallbackChatModel sets the chat model retried when the request exceeds the context of the main one,
+// usually a model with a larger context. without it the article is trimmed instead.
+func (s *OpenAIService) SetFallbackChatModel(model string) {
+	s.fallbackChatModel = model
+}
+
+// SetTTSModel overrides the model synthesizing speech, an empty model keeps the default
+func (s *OpenAIService) SetTTSModel(model string) {
+	if model != "" {
+		s.ttsModel = model
+	}
+}
+
+// SetTTSBackend selects the endpoint synthesizing speech, the chat completions audio output by default.
+// the speech backend switches the default audio model to one of the speech endpoint.
+func (s *OpenAIService) SetTTSBackend(backend TTSBackend) {
+	s.ttsBackend = backend
+	if backend == TTSBackendSpeech && s.ttsModel == content.OpenAITTSModel {
+		s.ttsModel = content.OpenAISpeechModel
+	}
+}
+
+// SetParseRetries sets how many times the model is asked to fix a discussion which can't be parsed, 0 disables it
+func (s *OpenAIService) SetParseRetries(retries int) {
+	s.parseRetries = max(retries, 0)
+}
+
+// SetMessagesPerMinute sets how many messages are requested per minute of the target duration,
+// fewer make longer monologues, more a livelier back and forth. zero keeps the default MessagesPerMinute.
+func (s *OpenAIService) SetMessagesPerMinute(perMinute int) {
+	s.messagesPerMinute = content.MessagesPerMinute
+	if perMinute > 0 {
+		s.messagesPerMinute = perMinute
+	}
+}
+
+// SetLanguage sets the language the discussion is requested in and the speech is instructed to use
+func (s *OpenAIService) SetLanguage(lang content.Language) {
+	s.language = lang
+}
+
+// SetVoiceStyles sets the speaking styles of voices without a built-in style, voice to style,
+// e.g. the characters of hosts speaking with alloy or coral
+func (s *OpenAIService) SetVoiceStyles(styles map[string]string) {
+	s.voiceStyles = styles
+}
+
+// SetChatTimeout sets the deadline of each attempt of a chat request, a zero timeout keeps the default
+func (s *OpenAIService) SetChatTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.chatTimeout = timeout
+	}
+}
+
+// SetTTSTimeout sets the deadline of each attempt of a speech request, a zero timeout keeps the default
+func (s *OpenAIService) SetTTSTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.ttsTimeout = timeout
 	}
 }
 
@@ -45,10 +216,17 @@ type OpenAIMessage struct {
 
 // OpenAIRequest represents a request to the OpenAI API
 type OpenAIRequest struct {
-	Model       string          `json:"model"`
-	Messages    []OpenAIMessage `json:"messages"`
-	Temperature float64         `json:"temperature"`
-	MaxTokens   int             `json:"max_tokens"`
+	Model         string          `json:"model"`
+	Messages      []OpenAIMessage `json:"messages"`
+	Temperature   float64         `json:"temperature"`
+	MaxTokens     int             `json:"max_tokens"`
+	Stream        bool            `json:"stream,omitempty"`
+	StreamOptions *StreamOptions  `json:"stream_options,omitempty"`
+}
+
+// StreamOptions configures a streamed response
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // send the token usage in the last chunk
 }
 
 // OpenAITTSRequest represents the request structure for OpenAI TTS API
@@ -65,54 +243,292 @@ type OpenAITTSRequest struct {
 
 // GenerateDiscussion uses OpenAI API to create a discussion between hosts
 func (s *OpenAIService) GenerateDiscussion(params podcast.GenerateDiscussionParams) (podcast.Discussion, error) {
-	// calculate target number of messages based on duration
-	targetMessages := params.TargetDuration * content.MessagesPerMinute
+	// calculate target number of messages based on duration, the length is set by the word budget of the prompt
+	targetMessages := podcast.EstimateMessageCount(params.TargetDuration, s.messagesPerMinute)
 
 	// create the system prompt
 	systemPrompt := s.createDiscussionPrompt(params.Hosts, targetMessages, params.TargetDuration)
+	if params.TotalParts > 1 {
+		systemPrompt += "\n\n" + createSeriesPrompt(params.Part, params.TotalParts, s.language)
+	}
+	if params.IntroHost != "" {
+		systemPrompt += "\n\n" + createIntroPrompt(params.IntroHost)
+	}
+	if shares := podcast.TurnShares(params.Hosts); shares != nil {
+		systemPrompt += "\n\n" + createTurnWeightsPrompt(params.Hosts, shares)
+	}
 
 	// prepare the API request
 	request := OpenAIRequest{
-		Model: "gpt-4o",
+		Model: s.chatModel,
 		Messages: []OpenAIMessage{
 			{Role: "system", Content: systemPrompt},
-			{
-				Role: "user",
-				Content: fmt.Sprintf("Article Title: %s\n\nArticle Content: %s\n\nPlease respond in Russian language only.",
-					params.Title, params.ArticleText),
-			},
+			{Role: "user", Content: createArticlePrompt(params.Title, params.ArticleText, s.language)},
 		},
 		Temperature: content.OpenAITemperature,
 		MaxTokens:   content.OpenAIMaxTokens,
 	}
 
 	// call the OpenAI API
-	responseContent, err := s.callChatAPI(request)
+	request, responseContent, err := s.callChatWithFallback(request, params)
 	if err != nil {
 		return podcast.Discussion{}, fmt.Errorf("failed to generate discussion: %w", err)
 	}
 
-	// extract and parse the JSON response
-	messages, err := s.extractMessages(responseContent)
+	// extract and parse the response, asking the model to fix it when it can't be parsed
+	messages, responseContent, err := s.extractMessagesWithRetry(request, responseContent)
 	if err != nil {
 		return podcast.Discussion{}, fmt.Errorf("failed to parse discussion: %w", err)
 	}
 
+	if params.FillToTarget {
+		if messages, err = s.fillToTarget(request, responseContent, messages, targetMessages); err != nil {
+			return podcast.Discussion{}, err
+		}
+	}
+
+	if params.AdjustRounds > 0 {
+		if messages, err = s.adjustDuration(request, messages, params); err != nil {
+			return podcast.Discussion{}, err
+		}
+	}
+
+	if params.BalanceQuotes {
+		messages = content.NewTextProcessor().BalanceQuotesMessages(messages)
+	}
+	s.checkDuration(messages, params.TargetDuration)
+
 	return podcast.Discussion{
 		Title:    params.Title,
 		Messages: messages,
 	}, nil
 }
 
-// GenerateSpeech generates speech audio for the given text
-func (s *OpenAIService) GenerateSpeech(text, voice string) ([]byte, error) {
+// checkDuration reports a discussion whose estimated speech is far off the target duration,
+// by more than DurationWarnDeviation of it
+func (s *OpenAIService) checkDuration(messages []podcast.Message, targetDuration int) {
+	if targetDuration <= 0 {
+		return
+	}
+	tp := content.NewTextProcessor()
+	tp.SetLanguage(s.language)
+	estimated := tp.EstimateTotalDuration(messages) / 60
+	deviation := (estimated - float64(targetDuration)) / float64(targetDuration)
+	if math.Abs(deviation) <= content.DurationWarnDeviation {
+		s.logger.Debug("Discussion length is close to the target", "minutes", fmt.Sprintf("%.1f", estimated), "target", targetDuration)
+		return
+	}
+	s.logger.Warn("Discussion length is far off the target, the speech speed is adjusted within limits",
+		"minutes", fmt.Sprintf("%.1f", estimated), "target", targetDuration, "off", fmt.Sprintf("%+.0f%%", deviation*100))
+}
+
+// callChatWithFallback calls the chat API with the discussion request. when the request exceeds the model context
+// it is retried with the fallback chat model, then with the article cut in half, up to content.MaxContextTrims times.
+// it returns the request that got the response, so follow-ups use the same model and article.
+func (s *OpenAIService) callChatWithFallback(request OpenAIRequest,
+	params podcast.GenerateDiscussionParams) (OpenAIRequest, string, error) {
+	tp := content.NewTextProcessor()
+	articleText := params.ArticleText
+	for trims := 0; ; {
+		response, err := s.chat(request, params)
+		var apiErr *APIError
+		if err == nil || !errors.As(err, &apiErr) || !apiErr.ContextLengthExceeded() {
+			return request, response, err
+		}
+
+		switch {
+		case s.fallbackChatModel != "" && request.Model != s.fallbackChatModel:
+			s.logger.Warn("Article exceeds the model context, retrying with the fallback model", "model", request.Model,
+				"fallback", s.fallbackChatModel)
+			request.Model = s.fallbackChatModel
+		case trims < content.MaxContextTrims:
+			trims++
+			articleText = tp.TruncateString(articleText, utf8.RuneCountInString(articleText)/2)
+			s.logger.Warn("Article exceeds the model context, retrying with the article cut", "model", request.Model,
+				"chars", utf8.RuneCountInString(articleText))
+			// the caller's request keeps the original article
+			request.Messages = slices.Clone(request.Messages)
+			request.Messages[1].Content = createArticlePrompt(params.Title, articleText, s.language)
+		default:
+			return request, "", err
+		}
+	}
+}
+
+// chat calls the chat API with the discussion request. with params.Stream set the response is streamed
+// and every dialog line is sent to the channel while the model is still writing the next ones.
+func (s *OpenAIService) chat(request OpenAIRequest, params podcast.GenerateDiscussionParams) (string, error) {
+	if params.Stream == nil {
+		return s.callChatAPI(request)
+	}
+	tp := content.NewTextProcessor()
+	return s.callChatStream(request, func(msg podcast.Message) {
+		if params.BalanceQuotes {
+			msg.Content = tp.BalanceQuotes(msg.Content)
+		}
+		params.Stream <- msg
+	})
+}
+
+// extractMessagesWithRetry parses the discussion response. when it can't be parsed, e.g. the model answered with prose
+// or a truncated JSON, the malformed response is sent back with a correction request, up to parseRetries times.
+// it returns the messages with the response they were parsed from.
+func (s *OpenAIService) extractMessagesWithRetry(request OpenAIRequest, response string) ([]podcast.Message, string, error) {
+	messages, err := s.extractMessages(response)
+	conversation := request.Messages
+	for retry := 1; err != nil && retry <= s.parseRetries; retry++ {
+		s.logger.Warn("Can't parse the discussion, asking the model to fix it", "err", err,
+			"retry", fmt.Sprintf("%d/%d", retry, s.parseRetries))
+		request.Messages = append(slices.Clone(conversation),
+			OpenAIMessage{Role: "assistant", Content: response},
+			OpenAIMessage{Role: "user", Content: createFixFormatPrompt(err, s.language)},
+		)
+		var apiErr error
+		if response, apiErr = s.callChatAPI(request); apiErr != nil {
+			return nil, "", fmt.Errorf("failed to request a fixed discussion: %w", apiErr)
+		}
+		messages, err = s.extractMessages(response)
+	}
+	return messages, response, err
+}
+
+// createFixFormatPrompt asks to resend the discussion in the expected format after the parse error
+func createFixFormatPrompt(parseErr error, lang content.Language) string {
+	prompts := promptsFor(lang)
+	return fmt.Sprintf(`Your reply can't be parsed as the discussion: %v. Return ONLY the discussion, every line as `+
+		`"%s" or "%s", or ONLY a valid JSON array of {"host", "content"} objects. No other text. %s language only.`,
+		parseErr, prompts.line, prompts.taggedLine("emotion"), prompts.name)
+}
+
+// createArticlePrompt creates the user message with the article to discuss
+func createArticlePrompt(title, articleText string, lang content.Language) string {
+	return fmt.Sprintf("Article Title: %s\n\nArticle Content: %s\n\nPlease respond in %s language only.",
+		title, articleText, promptsFor(lang).name)
+}
+
+// fillToTarget asks the model to continue the conversation while it is noticeably shorter than targetMessages.
+// every follow-up sends the conversation so far, at most content.MaxFillRounds follow-ups are made.
+func (s *OpenAIService) fillToTarget(request OpenAIRequest, response string, messages []podcast.Message,
+	targetMessages int) ([]podcast.Message, error) {
+	minMessages := int(math.Ceil(float64(targetMessages) * content.FillTargetRatio))
+	for round := 1; round <= content.MaxFillRounds && len(messages) < minMessages; round++ {
+		missing := targetMessages - len(messages)
+		s.logger.Info("Discussion is short, requesting more messages", "messages", len(messages), "target", targetMessages,
+			"missing", missing)
+
+		request.Messages = append(request.Messages,
+			OpenAIMessage{Role: "assistant", Content: response},
+			OpenAIMessage{Role: "user", Content: createContinuePrompt(missing, s.language)},
+		)
+		var err error
+		if response, err = s.callChatAPI(request); err != nil {
+			return nil, fmt.Errorf("failed to extend discussion: %w", err)
+		}
+		more, err := s.extractMessages(response)
+		if err != nil {
+			s.logger.Warn("Follow-up didn't continue the discussion", "messages", len(messages), "err", err)
+			break
+		}
+		messages = append(messages, more...)
+	}
+	return messages, nil
+}
+
+// createContinuePrompt asks for more turns of the same conversation, missing is the number of missing lines
+func createContinuePrompt(missing int, lang content.Language) string {
+	return fmt.Sprintf(`The conversation is too short. Continue it from exactly where it stopped with about %d more lines `+
+		`in the same format. Don't greet, don't restart or summarize, don't repeat what was already said. %s language only.`,
+		missing, promptsFor(lang).name)
+}
+
+// adjustDuration asks the model to shorten or lengthen the discussion while its estimated speech is off the target
+// duration by more than params.DurationTolerance, at most params.AdjustRounds times. every round sends the current
+// discussion with the difference in minutes and words, the model returns the whole revised discussion.
+// a revision which can't be parsed ends the rounds with the last good discussion.
+func (s *OpenAIService) adjustDuration(request OpenAIRequest, messages []podcast.Message,
+	params podcast.GenerateDiscussionParams) ([]podcast.Message, error) {
+	if params.TargetDuration <= 0 {
+		return messages, nil
+	}
+	tp := content.NewTextProcessor()
+	tp.SetLanguage(s.language)
+	target := float64(params.TargetDuration)
+	base := request.Messages[:2:2] // system prompt and article, the conversation is rebuilt every round
+	for round := 1; round <= params.AdjustRounds; round++ {
+		estimated := tp.EstimateTotalDuration(messages) / 60
+		if math.Abs(estimated-target) <= target*params.DurationTolerance {
+			break
+		}
+		s.logger.Info("Discussion length is off the target, requesting a revision", "minutes", fmt.Sprintf("%.1f", estimated),
+			"target", params.TargetDuration, "round", fmt.Sprintf("%d/%d", round, params.AdjustRounds))
+
+		request.Messages = append(base,
+			OpenAIMessage{Role: "assistant", Content: formatDialog(messages)},
+			OpenAIMessage{Role: "user", Content: createAdjustPrompt(estimated, params.TargetDuration, s.language)},
+		)
+		response, err := s.callChatAPI(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to adjust discussion length: %w", err)
+		}
+		revised, err := s.extractMessages(response)
+		if err != nil {
+			s.logger.Warn("Revision isn't a discussion, keeping the previous one", "err", err)
+			break
+		}
+		messages = revised
+	}
+	return messages, nil
+}
+
+// createAdjustPrompt asks to shorten or lengthen the discussion of estimated minutes to the target minutes,
+// the difference is given in minutes and in words of the speech rate of the language
+func createAdjustPrompt(estimated float64, target int, lang content.Language) string {
+	tp := content.NewTextProcessor()
+	tp.SetLanguage(lang)
+	delta := estimated - float64(target)
+	words := int(math.Round(math.Abs(delta) * float64(tp.WordBudget(1))))
+	action := fmt.Sprintf("It is %.1f minutes too long. Shorten it by about %d words: condense or cut the sections "+
+		"that drag, such as repeated points and long monologues, keep the opening, the ending and the key points.",
+		delta, words)
+	if delta < 0 {
+		action = fmt.Sprintf("It is %.1f minutes too short. Lengthen it by about %d words: expand the sections that "+
+			"deserve more depth with new points, examples and back and forth between the hosts, don't pad with repetition.",
+			-delta, words)
+	}
+	return fmt.Sprintf("This discussion is about %.1f minutes of speech, the episode has to be about %d minutes. %s "+
+		"Return the whole revised discussion in the same format, nothing else. %s language only.",
+		estimated, target, action, promptsFor(lang).name)
+}
+
+// formatDialog writes the messages in the dialog format of the prompt, "Name: content" or "Name [emotion]: content"
+func formatDialog(messages []podcast.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		sb.WriteString(msg.Host)
+		if msg.Emotion != "" {
+			sb.WriteString(" [" + msg.Emotion + "]")
+		}
+		sb.WriteString(": " + msg.Content + "\n")
+	}
+	return sb.String()
+}
+
+// GenerateSpeech generates speech audio for the given text, emotion is an optional delivery hint for the line.
+// model overrides the TTS model of the service for this line, e.g. a premium model for the main host, empty keeps it.
+func (s *OpenAIService) GenerateSpeech(text, voice, emotion, model string) ([]byte, error) {
 	// get the appropriate speaking style for this voice
-	speakingStyle := getSpeakingStyle(voice)
-	systemPrompt := createTTSSystemPrompt(speakingStyle)
+	speakingStyle := getSpeakingStyle(voice, s.language, s.voiceStyles)
+	systemPrompt := createTTSSystemPrompt(s.language, speakingStyle, emotion)
 
 	// prepare the API request
+	if model == "" {
+		model = s.ttsModel
+	}
+	if s.ttsBackend == TTSBackendSpeech {
+		return s.callSpeechAPI(speechRequest(text, voice, systemPrompt, model))
+	}
 	request := OpenAITTSRequest{
-		Model:      "gpt-4o-audio-preview",
+		Model:      model,
 		Modalities: []string{"text", "audio"},
 		Store:      true,
 		Messages: []OpenAIMessage{
@@ -134,25 +550,16 @@ func (s *OpenAIService) callChatAPI(request OpenAIRequest) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(requestBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.post(chatCompletionsPath, requestBody, s.chatTimeout)
 	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return "", fmt.Errorf("API request failed with %w", apiErr)
+		}
 		return "", fmt.Errorf("API request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	// parse the response
 	var result struct {
 		Choices []struct {
@@ -160,11 +567,13 @@ func (s *OpenAIService) callChatAPI(request OpenAIRequest) (string, error) {
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage apiUsage `json:"usage"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
+	s.usage.add(request.Model, result.Usage.PromptTokens, result.Usage.CompletionTokens, 0)
 
 	if len(result.Choices) == 0 {
 		return "", fmt.Errorf("no response from API")
@@ -180,25 +589,16 @@ func (s *OpenAIService) callTTSAPI(request OpenAITTSRequest) ([]byte, error) {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.post(chatCompletionsPath, requestBody, s.ttsTimeout)
 	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return nil, fmt.Errorf("TTS request failed with %w", apiErr)
+		}
 		return nil, fmt.Errorf("TTS request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("TTS request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	// parse the response
 	var result struct {
 		Choices []struct {
@@ -208,11 +608,17 @@ func (s *OpenAIService) callTTSAPI(request OpenAITTSRequest) ([]byte, error) {
 				} `json:"audio"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage apiUsage `json:"usage"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode TTS response: %w", err)
 	}
+	var ttsChars int
+	if len(request.Messages) > 0 {
+		ttsChars = utf8.RuneCountInString(request.Messages[len(request.Messages)-1].Content) // the line to speak
+	}
+	s.usage.add(request.Model, result.Usage.PromptTokens, result.Usage.CompletionTokens, ttsChars)
 
 	if len(result.Choices) == 0 {
 		return nil, fmt.Errorf("no TTS response from API")
@@ -227,23 +633,129 @@ func (s *OpenAIService) callTTSAPI(request OpenAITTSRequest) ([]byte, error) {
 	return audioData, nil
 }
 
-// createDiscussionPrompt creates the system prompt for the discussion
-func (s *OpenAIService) createDiscussionPrompt(hosts []podcast.Host, _, targetDuration int) string {
+// post sends the request body to the API endpoint at path, retrying with the retry policy on rate limits,
+// server errors and network failures. a Retry-After header sets the wait before the next attempt.
+// a non-200 response is returned as *APIError, quota exhaustion and client errors fail immediately as retries won't help.
+// every attempt gets its own deadline of timeout, which covers reading the response body until it's closed.
+func (s *OpenAIService) post(path string, requestBody []byte, timeout time.Duration) (*http.Response, error) {
+	var resp *http.Response
+	ctx := s.context()
+	err := s.retryPolicy.Do(ctx, func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		req, err := http.NewRequestWithContext(attemptCtx, "POST", s.baseURL+path, bytes.NewBuffer(requestBody))
+		if err != nil {
+			cancel()
+			return backoff.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+		req.Header.Set("User-Agent", s.userAgent)
+
+		r, err := s.httpClient.Do(req)
+		if err != nil {
+			cancel()
+			if ctx.Err() != nil {
+				return backoff.Permanent(err)
+			}
+			s.logger.Warn("OpenAI request failed, retrying", "err", err)
+			return err
+		}
+		if r.StatusCode == http.StatusOK {
+			r.Body = &cancelOnClose{ReadCloser: r.Body, cancel: cancel}
+			resp = r
+			return nil
+		}
+
+		bodyBytes, _ := io.ReadAll(r.Body)
+		r.Body.Close()
+		cancel()
+		apiErr := parseAPIError(r.StatusCode, bodyBytes)
+		apiErr.RetryAfter = parseRetryAfter(r.Header.Get("Retry-After"), time.Now())
+		if !apiErr.Retryable() {
+			return backoff.Permanent(apiErr)
+		}
+		if apiErr.RateLimited() {
+			s.logger.Warn("OpenAI rate limit exceeded, retrying")
+		} else {
+			s.logger.Warn("OpenAI server error, retrying", "status", apiErr.StatusCode)
+		}
+		return backoff.RetryAfter(apiErr, apiErr.RetryAfter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cancelOnClose releases the deadline of the request when its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the request context
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	if err := c.ReadCloser.Close(); err != nil {
+		return fmt.Errorf("failed to close response body: %w", err)
+	}
+	return nil
+}
+
+// createDiscussionPrompt creates the system prompt for the discussion, its length is set by the word budget
+// of the target duration at the speech rate of the language
+func (s *OpenAIService) createDiscussionPrompt(hosts []podcast.Host, targetMessages, targetDuration int) string {
 	hostDescriptions := s.prepareHostDescriptions(hosts)
+	prompts := promptsFor(s.language)
+	tp := content.NewTextProcessor()
+	tp.SetLanguage(s.language)
 
-	basePrompt := `You are hosting a Russian tech podcast discussion about this article. The hosts are:
+	basePrompt := `You are hosting %s tech podcast discussion about this article. The hosts are:
 
 %s
 
 Have a genuine, unscripted conversation about the article. Don't follow any rigid structure - just talk naturally like real people do. Get passionate about things you care about, interrupt each other when excited, disagree when you actually disagree.
 
 Write it as simple dialog format:
-Имя: что говорит
-Имя: ответ
+%s
+%s
+
+When a line calls for a particular delivery, tag it with the intended emotion in square brackets after the name, like "%s". Use short English words such as excited, skeptical, calm. Leave most lines untagged.
 
-Just let the conversation flow naturally for about %d minutes worth of talking.`
+Just let the conversation flow naturally for about %d minutes worth of talking. That is about %d words in total, spread over roughly %d lines - keep close to this length, it sets the duration of the episode.`
 
-	return fmt.Sprintf(basePrompt, hostDescriptions, targetDuration)
+	return fmt.Sprintf(basePrompt, prompts.aName, hostDescriptions, prompts.line, prompts.reply, prompts.taggedLine("excited"),
+		targetDuration, tp.WordBudget(targetDuration), targetMessages)
+}
+
+// createIntroPrompt asks the model to open the episode with the intro delivered by the given host
+func createIntroPrompt(host string) string {
+	return fmt.Sprintf("%s opens the episode: the very first line is %s introducing the article and its topic to the listeners.",
+		host, host)
+}
+
+// createTurnWeightsPrompt asks the model to split the lines between hosts by their turn shares, in host order
+func createTurnWeightsPrompt(hosts []podcast.Host, shares map[string]float64) string {
+	parts := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		parts = append(parts, fmt.Sprintf("%s about %.0f%%", host.Name, shares[host.Name]*100))
+	}
+	return "The hosts don't talk equally. Split the lines between them roughly like this: " +
+		strings.Join(parts, ", ") + ". Keep the conversation natural, the shares are approximate."
+}
+
+// createSeriesPrompt describes the episode's place in a miniseries so hosts can refer to other episodes
+func createSeriesPrompt(part, totalParts int, lang content.Language) string {
+	prompt := fmt.Sprintf("This is episode %d of %d in a miniseries about the article, each episode covers its own part of it.",
+		part, totalParts)
+	if part > 1 {
+		prompt += fmt.Sprintf(` Refer back to the previous episodes naturally, like "%s".`, promptsFor(lang).callback)
+	}
+	if part < totalParts {
+		prompt += " Near the end, hint that the discussion continues in the next episode."
+	}
+	return prompt
 }
 
 // prepareHostDescriptions formats host information for the prompt
@@ -256,12 +768,45 @@ func (s *OpenAIService) prepareHostDescriptions(hosts []podcast.Host) string {
 	return strings.Join(descriptions, "\n")
 }
 
-// extractMessages extracts and parses messages from the OpenAI response
+// extractMessages extracts and parses messages from the OpenAI response, the dialog format or a JSON array.
+// lines or elements which can't be parsed are skipped and reported, as long as some messages are recovered.
 func (s *OpenAIService) extractMessages(responseContent string) ([]podcast.Message, error) {
-	lines := strings.Split(strings.TrimSpace(responseContent), "\n")
-	var messages []podcast.Message
+	messages, skipped, err := parseDiscussion(responseContent)
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 {
+		s.logger.Warn("Skipped unparsable lines of the discussion", "skipped", skipped, "kept", len(messages))
+	}
+	return messages, nil
+}
+
+// ParseScript parses a hand-written or edited discussion in the formats of the model response, "Name: content" lines
+// or a JSON array of {"host", "content", "emotion"} objects. it returns the messages with the number of skipped lines.
+func ParseScript(script string) (messages []podcast.Message, skipped int, err error) {
+	return parseDiscussion(script)
+}
 
-	for _, line := range lines {
+// parseDiscussion parses the response and returns the recovered messages with the number of skipped lines
+func parseDiscussion(responseContent string) (messages []podcast.Message, skipped int, err error) {
+	responseContent = strings.TrimSpace(responseContent)
+	if fenced := strings.TrimPrefix(responseContent, "```json"); fenced != responseContent {
+		responseContent = strings.TrimSpace(strings.TrimSuffix(fenced, "```"))
+	}
+	if strings.HasPrefix(responseContent, "[") {
+		messages, skipped = parseJSONMessages(responseContent)
+	} else {
+		messages, skipped = parseDialogLines(responseContent)
+	}
+	if len(messages) == 0 {
+		return nil, skipped, fmt.Errorf("no valid dialog lines found in response")
+	}
+	return messages, skipped, nil
+}
+
+// parseDialogLines parses "Name: content" or "Name [emotion]: content" lines, empty lines aren't counted as skipped
+func parseDialogLines(responseContent string) (messages []podcast.Message, skipped int) {
+	for _, line := range strings.Split(responseContent, "\n") {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
@@ -270,42 +815,89 @@ func (s *OpenAIService) extractMessages(responseContent string) ([]podcast.Messa
 		// parse format: "Name: content" or "Name : content"
 		parts := strings.SplitN(line, ":", 2)
 		if len(parts) != 2 {
+			skipped++
 			continue
 		}
 
-		host := strings.TrimSpace(parts[0])
+		host, emotion := parseEmotionTag(strings.TrimSpace(parts[0]))
 		msgContent := strings.TrimSpace(parts[1])
+		if host == "" || msgContent == "" {
+			skipped++
+			continue
+		}
+		messages = append(messages, podcast.Message{Host: host, Content: msgContent, Emotion: emotion})
+	}
+	return messages, skipped
+}
 
-		if host != "" && msgContent != "" {
-			messages = append(messages, podcast.Message{
-				Host:    host,
-				Content: msgContent,
-			})
+// parseJSONMessages parses a JSON array of {"host", "content", "emotion"} objects. elements are decoded one by one,
+// so a truncated or broken element keeps the messages before it and counts as a single skipped line.
+func parseJSONMessages(responseContent string) (messages []podcast.Message, skipped int) {
+	dec := json.NewDecoder(strings.NewReader(responseContent))
+	if _, err := dec.Token(); err != nil { // opening bracket
+		return nil, 1
+	}
+	for dec.More() {
+		var elem struct {
+			Host    string `json:"host"`
+			Content string `json:"content"`
+			Emotion string `json:"emotion"`
 		}
+		if err := dec.Decode(&elem); err != nil {
+			return messages, skipped + 1
+		}
+		host, msgContent := strings.TrimSpace(elem.Host), strings.TrimSpace(elem.Content)
+		if host == "" || msgContent == "" {
+			skipped++
+			continue
+		}
+		messages = append(messages, podcast.Message{Host: host, Content: msgContent,
+			Emotion: strings.ToLower(strings.TrimSpace(elem.Emotion))})
 	}
+	return messages, skipped
+}
 
-	if len(messages) == 0 {
-		return nil, fmt.Errorf("no valid dialog lines found in response")
+// parseEmotionTag splits an optional emotion tag from the speaker, "Имя [excited]" gives "Имя" and "excited"
+func parseEmotionTag(speaker string) (host, emotion string) {
+	start := strings.LastIndex(speaker, "[")
+	if start == -1 || !strings.HasSuffix(speaker, "]") {
+		return speaker, ""
 	}
+	host = strings.TrimSpace(speaker[:start])
+	emotion = strings.ToLower(strings.TrimSpace(speaker[start+1 : len(speaker)-1]))
+	return host, emotion
+}
 
-	return messages, nil
+// ttsVoices are the voices supported by the OpenAI TTS API
+var ttsVoices = []string{"alloy", "ash", "ballad", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer", "verse"}
+
+// ValidateVoice checks that the voice is supported by the OpenAI TTS API
+func ValidateVoice(voice string) error {
+	if !slices.Contains(ttsVoices, voice) {
+		return fmt.Errorf("unknown voice %q, expected one of: %s", voice, strings.Join(ttsVoices, ", "))
+	}
+	return nil
 }
 
-// getSpeakingStyle returns the appropriate speaking style based on the voice
-func getSpeakingStyle(voice string) string {
-	switch voice {
-	case "onyx": // алексей
-		return "молодой техно-оптимист"
-	case "nova": // мария
-		return "аналитик, любит данные"
-	case "echo": // дмитрий
-		return "скептик, видел всякое"
-	default:
-		return ""
+// getSpeakingStyle returns the speaking style of the voice in the language: the built-in one, then the one of styles,
+// e.g. the character of the host, otherwise a plain host, so the speech prompt always says who is speaking
+func getSpeakingStyle(voice string, lang content.Language, styles map[string]string) string {
+	prompts := promptsFor(lang)
+	if style, ok := prompts.styles[voice]; ok {
+		return style
 	}
+	if style := strings.TrimSpace(styles[voice]); style != "" {
+		return style
+	}
+	return prompts.anyHost
 }
 
-// createTTSSystemPrompt creates the system prompt for TTS generation
-func createTTSSystemPrompt(speakingStyle string) string {
-	return fmt.Sprintf("Ты %s в подкасте о технологиях. Говори естественно по-русски, как обычный человек.", speakingStyle)
+// createTTSSystemPrompt creates the system prompt for TTS generation in the language
+func createTTSSystemPrompt(lang content.Language, speakingStyle, emotion string) string {
+	prompts := promptsFor(lang)
+	prompt := fmt.Sprintf(prompts.tts, speakingStyle)
+	if emotion != "" {
+		prompt += fmt.Sprintf(prompts.emotion, emotion)
+	}
+	return prompt
 }
@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/radio-t/ai-podcast/internal/ai/mocks"
+	"github.com/radio-t/ai-podcast/internal/backoff"
+	"github.com/radio-t/ai-podcast/internal/content"
+	"github.com/radio-t/ai-podcast/internal/logging"
 	"github.com/radio-t/ai-podcast/podcast"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -102,6 +109,20 @@ func TestOpenAIService_ExtractMessages(t *testing.T) {
 		assert.Contains(t, err.Error(), "no valid dialog lines found")
 	})
 
+	t.Run("dialog with emotion tags", func(t *testing.T) {
+		content := "Alice [Excited]: This is huge!\nBob: Is it?\nAlice [ calm ]: Let me explain"
+		messages, err := service.extractMessages(content)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+		assert.Equal(t, "Alice", messages[0].Host)
+		assert.Equal(t, "excited", messages[0].Emotion)
+		assert.Equal(t, "This is huge!", messages[0].Content)
+		assert.Equal(t, "Bob", messages[1].Host)
+		assert.Empty(t, messages[1].Emotion)
+		assert.Equal(t, "Alice", messages[2].Host)
+		assert.Equal(t, "calm", messages[2].Emotion)
+	})
+
 	t.Run("mixed valid and invalid lines", func(t *testing.T) {
 		content := "Alice: Hello\ninvalid line\nBob: Hi there"
 		messages, err := service.extractMessages(content)
@@ -112,6 +133,99 @@ func TestOpenAIService_ExtractMessages(t *testing.T) {
 		assert.Equal(t, "Bob", messages[1].Host)
 		assert.Equal(t, "Hi there", messages[1].Content)
 	})
+
+	t.Run("skipped lines are reported", func(t *testing.T) {
+		content := "Alice: Hello\ninvalid line\nBob: Hi there\n: no speaker\nAlice:"
+		var messages []podcast.Message
+		out := captureLog(t, service, func() {
+			var err error
+			messages, err = service.extractMessages(content)
+			require.NoError(t, err)
+		})
+		assert.Len(t, messages, 2)
+		assert.Contains(t, out, "WARN  Skipped unparsable lines of the discussion skipped=3 kept=2")
+	})
+
+	t.Run("nothing reported when all lines parse", func(t *testing.T) {
+		out := captureLog(t, service, func() {
+			_, err := service.extractMessages("Alice: Hello\nBob: Hi there")
+			require.NoError(t, err)
+		})
+		assert.Empty(t, out)
+	})
+}
+
+func TestParseDiscussion(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		hosts    []string
+		skipped  int
+		errorMsg string
+	}{
+		{name: "json array", content: `[{"host": "Alice", "content": "Hello", "emotion": "Excited"}, {"host": "Bob", "content": "Hi"}]`,
+			hosts: []string{"Alice", "Bob"}},
+		{name: "fenced json array", content: "```json\n[{\"host\": \"Alice\", \"content\": \"Hello\"}]\n```",
+			hosts: []string{"Alice"}},
+		{name: "json with truncated trailing element",
+			content: `[{"host": "Alice", "content": "Hello"}, {"host": "Bob", "content": "Hi"}, {"host": "Al`,
+			hosts:   []string{"Alice", "Bob"}, skipped: 1},
+		{name: "json with broken trailing element", content: `[{"host": "Alice", "content": "Hello"}, {"host": 42}]`,
+			hosts: []string{"Alice"}, skipped: 1},
+		{name: "json with empty element", content: `[{"host": "Alice", "content": "Hello"}, {"host": "Bob"}, {"host": "Bob", "content": "Hi"}]`,
+			hosts: []string{"Alice", "Bob"}, skipped: 1},
+		{name: "dialog with invalid lines", content: "Alice: Hello\n\ngarbage\nBob: Hi\nmore garbage",
+			hosts: []string{"Alice", "Bob"}, skipped: 2},
+		{name: "json without valid elements", content: `[{"host": "Al`, skipped: 1, errorMsg: "no valid dialog lines found"},
+		{name: "dialog without valid lines", content: "garbage\nmore garbage", skipped: 2, errorMsg: "no valid dialog lines found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, skipped, err := parseDiscussion(tt.content)
+			assert.Equal(t, tt.skipped, skipped)
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+			hosts := make([]string, 0, len(messages))
+			for _, msg := range messages {
+				hosts = append(hosts, msg.Host)
+			}
+			assert.Equal(t, tt.hosts, hosts)
+		})
+	}
+
+	t.Run("json emotion is normalized", func(t *testing.T) {
+		messages, _, err := parseDiscussion(`[{"host": "Alice", "content": "Hello", "emotion": " Excited "}]`)
+		require.NoError(t, err)
+		assert.Equal(t, "excited", messages[0].Emotion)
+	})
+}
+
+// captureLog returns what fn logs with the logger of the service, debug records included
+func captureLog(t *testing.T, service *OpenAIService, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := service.logger
+	service.SetLogger(logging.New(&buf, slog.LevelDebug))
+	defer service.SetLogger(orig)
+
+	fn()
+	return buf.String()
+}
+
+func TestValidateVoice(t *testing.T) {
+	for _, voice := range []string{"alloy", "echo", "nova", "onyx", "shimmer"} {
+		assert.NoError(t, ValidateVoice(voice), voice)
+	}
+	for _, voice := range []string{"", "Nova", "robot"} {
+		err := ValidateVoice(voice)
+		require.Error(t, err, voice)
+		assert.Contains(t, err.Error(), "expected one of: alloy")
+	}
 }
 
 func TestGetSpeakingStyle(t *testing.T) {
@@ -132,25 +246,103 @@ func TestGetSpeakingStyle(t *testing.T) {
 			expected: "скептик, видел всякое",
 		},
 		{
-			voice:    "unknown",
-			expected: "",
+			voice:    "coral",
+			expected: "ведущая, ироничная",
+		},
+		{
+			voice:    "alloy",
+			expected: "ведущий",
+		},
+		{
+			voice:    "sage",
+			expected: "ведущий",
 		},
 	}
 
+	// host characters are used for voices without a built-in style only
+	styles := map[string]string{"coral": "ведущая, ироничная", "onyx": "ворчливый ветеран", "sage": "  "}
 	for _, test := range tests {
 		t.Run(test.voice, func(t *testing.T) {
-			style := getSpeakingStyle(test.voice)
+			style := getSpeakingStyle(test.voice, content.LanguageRussian, styles)
 			assert.Equal(t, test.expected, style)
 		})
 	}
+
+	t.Run("plain host in the episode language", func(t *testing.T) {
+		assert.Equal(t, "a host", getSpeakingStyle("fable", content.LanguageEnglish, nil))
+		assert.Equal(t, "un presentador", getSpeakingStyle("fable", content.LanguageSpanish, nil))
+	})
 }
 
 func TestCreateTTSSystemPrompt(t *testing.T) {
 	speakingStyle := "тестовый стиль"
-	result := createTTSSystemPrompt(speakingStyle)
+	result := createTTSSystemPrompt(content.LanguageRussian, speakingStyle, "")
 	assert.Contains(t, result, "тестовый стиль")
 	assert.Contains(t, result, "подкасте")
 	assert.Contains(t, result, "русски")
+	assert.NotContains(t, result, "эмоци")
+
+	result = createTTSSystemPrompt(content.LanguageRussian, speakingStyle, "skeptical")
+	assert.Contains(t, result, "тестовый стиль")
+	assert.Contains(t, result, "эмоцией: skeptical")
+
+	result = createTTSSystemPrompt(content.LanguageEnglish, getSpeakingStyle("echo", content.LanguageEnglish, nil), "calm")
+	assert.Equal(t, "You are a skeptic who has seen it all on a tech podcast. Speak naturally in English, like a regular person."+
+		" Deliver this line with the emotion: calm.", result)
+
+	result = createTTSSystemPrompt(content.LanguageSpanish, getSpeakingStyle("nova", content.LanguageSpanish, nil), "")
+	assert.Contains(t, result, "una analista a la que le encantan los datos")
+	assert.Contains(t, result, "en español")
+}
+
+func TestOpenAIService_GenerateSpeechWithEmotion(t *testing.T) {
+	mockClient := &mocks.HTTPClientMock{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var request OpenAITTSRequest
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+			require.Len(t, request.Messages, 2)
+			assert.Equal(t, "system", request.Messages[0].Role)
+			assert.Contains(t, request.Messages[0].Content, "эмоцией: excited")
+			assert.Equal(t, "test text", request.Messages[1].Content)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"choices": [{"message": {"audio": {"data": "dGVzdA=="}}}]}`)),
+				Header:     make(http.Header),
+			}, nil
+		},
+	}
+
+	service := NewOpenAIService("test-key", mockClient)
+	audioData, err := service.GenerateSpeech("test text", "echo", "excited", "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test"), audioData)
+	assert.Len(t, mockClient.DoCalls(), 1)
+}
+
+func TestOpenAIService_GenerateSpeechVoiceStyle(t *testing.T) {
+	var systemPrompt string
+	mockClient := &mocks.HTTPClientMock{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var request OpenAITTSRequest
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+			systemPrompt = request.Messages[0].Content
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(`{"choices": [{"message": {"audio": {"data": "dGVzdA=="}}}]}`)),
+				Header:     make(http.Header),
+			}, nil
+		},
+	}
+
+	service := NewOpenAIService("test-key", mockClient)
+	_, err := service.GenerateSpeech("test text", "coral", "", "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(systemPrompt, "Ты ведущий в подкасте"), "plain host without a character")
+
+	service.SetVoiceStyles(map[string]string{"coral": "ведущая, любит хорошие шутки"})
+	_, err = service.GenerateSpeech("test text", "coral", "", "")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(systemPrompt, "Ты ведущая, любит хорошие шутки в подкасте"), systemPrompt)
 }
 
 func TestOpenAIService_CreateDiscussionPrompt(t *testing.T) {
@@ -164,8 +356,341 @@ func TestOpenAIService_CreateDiscussionPrompt(t *testing.T) {
 	assert.Contains(t, prompt, "Alice (female): Tech expert")
 	assert.Contains(t, prompt, "Bob (male): Economist")
 	assert.Contains(t, prompt, "5 minutes")
+	assert.Contains(t, prompt, "about 800 words in total, spread over roughly 10 lines")
 	assert.Contains(t, prompt, "Russian")
 	assert.Contains(t, prompt, "dialog format")
+	assert.Contains(t, prompt, `"Имя [excited]: что говорит"`)
+
+	service.SetLanguage(content.LanguageEnglish)
+	prompt = service.createDiscussionPrompt(hosts, 10, 5)
+	assert.Contains(t, prompt, "You are hosting an English tech podcast")
+	assert.Contains(t, prompt, "Name: what they say\nName: the reply")
+	assert.Contains(t, prompt, `"Name [excited]: what they say"`)
+	assert.Contains(t, prompt, "about 750 words in total", "english speech rate")
+	assert.NotContains(t, prompt, "Russian")
+	assert.NotContains(t, prompt, "Имя")
+}
+
+func TestLanguagePrompts(t *testing.T) {
+	assert.Equal(t, "Article Title: T\n\nArticle Content: text\n\nPlease respond in Russian language only.",
+		createArticlePrompt("T", "text", ""), "russian by default")
+	assert.Contains(t, createArticlePrompt("T", "text", content.LanguageSpanish), "Please respond in Spanish language only.")
+
+	fix := createFixFormatPrompt(errors.New("no messages"), content.LanguageEnglish)
+	assert.Contains(t, fix, "can't be parsed as the discussion: no messages.")
+	assert.Contains(t, fix, `"Name: what they say" or "Name [emotion]: what they say"`)
+	assert.Contains(t, fix, "English language only.")
+	assert.Contains(t, createFixFormatPrompt(errors.New("no messages"), content.LanguageRussian),
+		`"Имя: что говорит" or "Имя [emotion]: что говорит"`)
+
+	assert.Contains(t, createContinuePrompt(7, content.LanguageSpanish), "about 7 more lines")
+	assert.Contains(t, createContinuePrompt(7, content.LanguageSpanish), "Spanish language only.")
+
+	assert.Contains(t, createSeriesPrompt(2, 3, content.LanguageRussian), `"как мы обсуждали в прошлый раз"`)
+	assert.Contains(t, createSeriesPrompt(2, 3, content.LanguageEnglish), `"as we discussed last time"`)
+}
+
+func TestCreateSeriesPrompt(t *testing.T) {
+	first := createSeriesPrompt(1, 3, content.LanguageRussian)
+	assert.Contains(t, first, "episode 1 of 3")
+	assert.Contains(t, first, "next episode")
+	assert.NotContains(t, first, "previous episodes")
+
+	middle := createSeriesPrompt(2, 3, content.LanguageRussian)
+	assert.Contains(t, middle, "episode 2 of 3")
+	assert.Contains(t, middle, "previous episodes")
+	assert.Contains(t, middle, "next episode")
+
+	last := createSeriesPrompt(3, 3, content.LanguageRussian)
+	assert.Contains(t, last, "previous episodes")
+	assert.NotContains(t, last, "next episode")
+}
+
+func TestOpenAIService_GenerateDiscussionFillToTarget(t *testing.T) {
+	chatResponse := func(lines ...string) *http.Response {
+		body, err := json.Marshal(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"content": strings.Join(lines, "\n")}}},
+		})
+		require.NoError(t, err)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Header: make(http.Header)}
+	}
+	lines := func(from, to int) []string {
+		var result []string
+		for i := from; i <= to; i++ {
+			result = append(result, fmt.Sprintf("Alice: line %d", i))
+		}
+		return result
+	}
+	params := podcast.GenerateDiscussionParams{
+		ArticleText:    "test article content",
+		Title:          "test article",
+		Hosts:          []podcast.Host{{Name: "Alice"}, {Name: "Bob"}},
+		TargetDuration: 10, // 20 messages
+		FillToTarget:   true,
+	}
+
+	tests := []struct {
+		name          string
+		responses     []*http.Response
+		fill          bool
+		expectedCalls int
+		expectedCount int
+	}{
+		{name: "short response extended by a follow-up", fill: true,
+			responses:     []*http.Response{chatResponse(lines(1, 5)...), chatResponse(lines(6, 19)...)},
+			expectedCalls: 2, expectedCount: 19},
+		{name: "several follow-ups up to the cap", fill: true,
+			responses: []*http.Response{chatResponse(lines(1, 2)...), chatResponse(lines(3, 4)...),
+				chatResponse(lines(5, 6)...), chatResponse(lines(7, 8)...), chatResponse(lines(9, 10)...)},
+			expectedCalls: 4, expectedCount: 8},
+		{name: "close enough to the target", fill: true,
+			responses: []*http.Response{chatResponse(lines(1, 16)...)}, expectedCalls: 1, expectedCount: 16},
+		{name: "follow-up without dialog lines", fill: true,
+			responses:     []*http.Response{chatResponse(lines(1, 5)...), chatResponse("Sorry, can't continue")},
+			expectedCalls: 2, expectedCount: 5},
+		{name: "disabled", fill: false,
+			responses: []*http.Response{chatResponse(lines(1, 5)...)}, expectedCalls: 1, expectedCount: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests []OpenAIRequest
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					var request OpenAIRequest
+					require.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+					requests = append(requests, request)
+					return test.responses[len(requests)-1], nil
+				},
+			}
+
+			p := params
+			p.FillToTarget = test.fill
+			discussion, err := NewOpenAIService("test-key", mockClient).GenerateDiscussion(p)
+			require.NoError(t, err)
+			require.Len(t, requests, test.expectedCalls)
+			require.Len(t, discussion.Messages, test.expectedCount)
+			for i, msg := range discussion.Messages {
+				assert.Equal(t, fmt.Sprintf("line %d", i+1), msg.Content, "messages keep their order")
+			}
+			if test.expectedCalls < 2 {
+				return
+			}
+
+			// the follow-up continues the same conversation
+			followUp := requests[1].Messages
+			require.Len(t, followUp, 4)
+			assert.Equal(t, "assistant", followUp[2].Role)
+			assert.True(t, strings.HasPrefix(followUp[2].Content, "Alice: line 1\nAlice: line 2"), "first response is sent back")
+			assert.Equal(t, "user", followUp[3].Role)
+			assert.Contains(t, followUp[3].Content, "Continue it from exactly where it stopped")
+		})
+	}
+}
+
+func TestCreateIntroPrompt(t *testing.T) {
+	prompt := createIntroPrompt("Мария")
+	assert.Contains(t, prompt, "Мария opens the episode")
+	assert.Contains(t, prompt, "very first line is Мария introducing the article")
+}
+
+func TestCreateTurnWeightsPrompt(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Алексей", Weight: 2}, {Name: "Мария"}, {Name: "Дмитрий"}}
+	prompt := createTurnWeightsPrompt(hosts, podcast.TurnShares(hosts))
+	assert.Contains(t, prompt, "Алексей about 50%, Мария about 25%, Дмитрий about 25%")
+}
+
+func TestOpenAIService_GenerateDiscussionTurnWeights(t *testing.T) {
+	var systemPrompts []string
+	mockClient := &mocks.HTTPClientMock{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var request OpenAIRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			systemPrompts = append(systemPrompts, request.Messages[0].Content)
+			body := `{"choices": [{"message": {"content": "Alice: hi\nBob: hello"}}]}`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+		},
+	}
+	service := NewOpenAIService("test-key", mockClient)
+
+	params := podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title", TargetDuration: 1,
+		Hosts: []podcast.Host{{Name: "Alice", Weight: 3}, {Name: "Bob", Weight: 1}}}
+	_, err := service.GenerateDiscussion(params)
+	require.NoError(t, err)
+	params.Hosts = []podcast.Host{{Name: "Alice"}, {Name: "Bob"}}
+	_, err = service.GenerateDiscussion(params)
+	require.NoError(t, err)
+
+	require.Len(t, systemPrompts, 2)
+	assert.Contains(t, systemPrompts[0], "Alice about 75%, Bob about 25%")
+	assert.NotContains(t, systemPrompts[1], "don't talk equally", "equal turns without weights")
+}
+
+func TestOpenAIService_GenerateDiscussionWordBudget(t *testing.T) {
+	var systemPrompt string
+	response := "Alice: hi\nBob: hello"
+	mockClient := &mocks.HTTPClientMock{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			var request OpenAIRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			systemPrompt = request.Messages[0].Content
+			body, err := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"content": response}}}})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Header: make(http.Header)}, nil
+		},
+	}
+	service := NewOpenAIService("test-key", mockClient)
+	params := podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title", TargetDuration: 10,
+		Hosts: []podcast.Host{{Name: "Alice"}, {Name: "Bob"}}}
+
+	t.Run("short discussion reported", func(t *testing.T) {
+		out := captureLog(t, service, func() {
+			_, err := service.GenerateDiscussion(params)
+			require.NoError(t, err)
+		})
+		assert.Contains(t, systemPrompt, "about 10 minutes worth of talking. That is about 1600 words in total, spread over roughly 20 lines")
+		assert.Contains(t, out, "WARN  Discussion length is far off the target, the speech speed is adjusted within limits "+
+			"minutes=0.0 target=10 off=-100%")
+	})
+
+	t.Run("configured messages per minute", func(t *testing.T) {
+		service.SetMessagesPerMinute(4)
+		defer service.SetMessagesPerMinute(0)
+		_, err := service.GenerateDiscussion(params)
+		require.NoError(t, err)
+		assert.Contains(t, systemPrompt, "about 1600 words in total, spread over roughly 40 lines", "same words, more lines")
+	})
+
+	t.Run("discussion close to the target", func(t *testing.T) {
+		// 160 words of 5 letters are 800 chars, about 0.9 minutes at 5.5 chars per word and 160 words per minute
+		response = "Alice: " + strings.Repeat("слово ", 80) + "\nBob: " + strings.Repeat("слово ", 80)
+		defer func() { response = "Alice: hi\nBob: hello" }()
+		params := params
+		params.TargetDuration = 1
+		out := captureLog(t, service, func() {
+			_, err := service.GenerateDiscussion(params)
+			require.NoError(t, err)
+		})
+		assert.Contains(t, systemPrompt, "about 160 words in total, spread over roughly 4 lines", "at least the minimum of messages")
+		assert.NotContains(t, out, "far off the target")
+		assert.Contains(t, out, "Discussion length is close to the target minutes=0.9 target=1")
+	})
+
+	t.Run("english speech rate", func(t *testing.T) {
+		service.SetLanguage(content.LanguageEnglish)
+		defer service.SetLanguage("")
+		_, err := service.GenerateDiscussion(params)
+		require.NoError(t, err)
+		assert.Contains(t, systemPrompt, "about 1500 words in total")
+	})
+}
+
+func TestOpenAIService_GenerateDiscussionAdjustDuration(t *testing.T) {
+	// lines of 80 words of 5 letters, each is about 0.45 minutes of russian speech
+	line := strings.TrimSpace(strings.Repeat("слово ", 80))
+	dialog := func(lines int) string {
+		var sb strings.Builder
+		for i := range lines {
+			sb.WriteString([]string{"Alice", "Bob"}[i%2] + ": " + line + "\n")
+		}
+		return sb.String()
+	}
+
+	newService := func(responses ...string) (*OpenAIService, *[]OpenAIRequest) {
+		var requests []OpenAIRequest
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				var request OpenAIRequest
+				if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+					return nil, err
+				}
+				requests = append(requests, request)
+				response := responses[min(len(requests), len(responses))-1]
+				body, err := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]string{"content": response}}}})
+				if err != nil {
+					return nil, err
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Header: make(http.Header)}, nil
+			},
+		}
+		return NewOpenAIService("test-key", mockClient), &requests
+	}
+	params := podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title", TargetDuration: 2,
+		Hosts: []podcast.Host{{Name: "Alice"}, {Name: "Bob"}}, AdjustRounds: 3, DurationTolerance: 0.15}
+
+	t.Run("over-long discussion shortened", func(t *testing.T) {
+		service, requests := newService(dialog(10), dialog(4))
+		discussion, err := service.GenerateDiscussion(params)
+		require.NoError(t, err)
+		assert.Len(t, discussion.Messages, 4, "revised discussion")
+
+		require.Len(t, *requests, 2, "one revision within the tolerance")
+		revision := (*requests)[1].Messages
+		require.Len(t, revision, 4)
+		assert.Equal(t, (*requests)[0].Messages, revision[:2], "same system prompt and article")
+		assert.Equal(t, "assistant", revision[2].Role)
+		assert.Equal(t, dialog(10), revision[2].Content, "current transcript")
+		assert.Equal(t, "user", revision[3].Role)
+		assert.Contains(t, revision[3].Content, "This discussion is about 4.5 minutes of speech, the episode has to be about 2 minutes. "+
+			"It is 2.5 minutes too long. Shorten it by about 407 words")
+		assert.Contains(t, revision[3].Content, "Return the whole revised discussion in the same format")
+	})
+
+	t.Run("short discussion lengthened", func(t *testing.T) {
+		service, requests := newService(dialog(2), dialog(5))
+		discussion, err := service.GenerateDiscussion(params)
+		require.NoError(t, err)
+		assert.Len(t, discussion.Messages, 5)
+		require.Len(t, *requests, 2)
+		assert.Contains(t, (*requests)[1].Messages[3].Content, "It is 1.1 minutes too short. Lengthen it by about 175 words")
+	})
+
+	t.Run("within the tolerance", func(t *testing.T) {
+		service, requests := newService(dialog(4))
+		_, err := service.GenerateDiscussion(params)
+		require.NoError(t, err)
+		assert.Len(t, *requests, 1, "no revision")
+	})
+
+	t.Run("rounds limited", func(t *testing.T) {
+		service, requests := newService(dialog(10), dialog(9), dialog(8))
+		params := params
+		params.AdjustRounds = 2
+		discussion, err := service.GenerateDiscussion(params)
+		require.NoError(t, err)
+		assert.Len(t, discussion.Messages, 8, "last revision kept")
+		require.Len(t, *requests, 3)
+		assert.Equal(t, dialog(9), (*requests)[2].Messages[2].Content, "every round sends the latest discussion")
+		assert.Len(t, (*requests)[2].Messages, 4, "the conversation doesn't grow")
+	})
+
+	t.Run("unparsable revision keeps the discussion", func(t *testing.T) {
+		service, requests := newService(dialog(10), "Sure, here is a shorter version")
+		var discussion podcast.Discussion
+		out := captureLog(t, service, func() {
+			var err error
+			discussion, err = service.GenerateDiscussion(params)
+			require.NoError(t, err)
+		})
+		assert.Len(t, discussion.Messages, 10)
+		assert.Len(t, *requests, 2)
+		assert.Contains(t, out, "Revision isn't a discussion, keeping the previous one")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		service, requests := newService(dialog(10))
+		params := params
+		params.AdjustRounds = 0
+		_, err := service.GenerateDiscussion(params)
+		require.NoError(t, err)
+		assert.Len(t, *requests, 1)
+	})
 }
 
 func TestOpenAIService_CallChatAPI(t *testing.T) {
@@ -431,6 +956,7 @@ func TestOpenAIService_GenerateDiscussion(t *testing.T) {
 			}
 
 			service := NewOpenAIService("test-key", mockClient)
+			service.retryPolicy.BaseDelay = time.Millisecond // network errors are retried
 			params := podcast.GenerateDiscussionParams{
 				ArticleText:    "test article content",
 				Title:          "test article",
@@ -502,8 +1028,9 @@ func TestOpenAIService_GenerateSpeech(t *testing.T) {
 			}
 
 			service := NewOpenAIService("test-key", mockClient)
+			service.retryPolicy.BaseDelay = time.Millisecond // network errors are retried
 
-			audioData, err := service.GenerateSpeech("test text", "echo")
+			audioData, err := service.GenerateSpeech("test text", "echo", "", "")
 
 			if test.expectedError != "" {
 				require.Error(t, err)
@@ -600,7 +1127,7 @@ func TestOpenAIService_CallAPIErrorCases(t *testing.T) {
 		}
 
 		service := NewOpenAIService("test-key", mockClient)
-		_, err := service.GenerateSpeech("test", "echo")
+		_, err := service.GenerateSpeech("test", "echo", "", "")
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "no TTS response from API")
 	})
@@ -622,7 +1149,7 @@ func TestOpenAIService_CallAPIErrorCases(t *testing.T) {
 		}
 
 		service := NewOpenAIService("test-key", mockClient)
-		_, err := service.GenerateSpeech("test", "echo")
+		_, err := service.GenerateSpeech("test", "echo", "", "")
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to decode TTS response")
 	})
@@ -644,8 +1171,387 @@ func TestOpenAIService_CallAPIErrorCases(t *testing.T) {
 		}
 
 		service := NewOpenAIService("test-key", mockClient)
-		_, err := service.GenerateSpeech("test", "echo")
+		_, err := service.GenerateSpeech("test", "echo", "", "")
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to decode audio data")
 	})
 }
+
+func TestOpenAIService_UserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{name: "default user agent", expected: "ai-podcast"},
+		{name: "custom user agent", userAgent: "ai-podcast/1.2.3", expected: "ai-podcast/1.2.3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(strings.NewReader(`{"choices": [{"message": {"content": "ok", "audio": {"data": "b2s="}}}]}`)),
+						Header:     make(http.Header),
+					}, nil
+				},
+			}
+
+			service := NewOpenAIService("test-key", mockClient)
+			if test.userAgent != "" {
+				service.SetUserAgent(test.userAgent)
+			}
+
+			_, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+			require.NoError(t, err)
+			_, err = service.callTTSAPI(OpenAITTSRequest{Model: "gpt-4o-audio-preview"})
+			require.NoError(t, err)
+
+			calls := mockClient.DoCalls()
+			require.Len(t, calls, 2)
+			for _, call := range calls {
+				assert.Equal(t, test.expected, call.Req.Header.Get("User-Agent"))
+			}
+		})
+	}
+}
+
+func TestOpenAIService_BaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		expected string
+	}{
+		{name: "default", expected: "https://api.openai.com/v1/chat/completions"},
+		{name: "local server", baseURL: "http://localhost:11434/v1", expected: "http://localhost:11434/v1/chat/completions"},
+		{name: "trailing slash", baseURL: "https://gateway.example.com/v1/", expected: "https://gateway.example.com/v1/chat/completions"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(strings.NewReader(`{"choices": [{"message": {"content": "ok", "audio": {"data": "b2s="}}}]}`)),
+						Header:     make(http.Header),
+					}, nil
+				},
+			}
+
+			service := NewOpenAIService("test-key", mockClient)
+			service.SetBaseURL(test.baseURL)
+
+			_, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+			require.NoError(t, err)
+			_, err = service.callTTSAPI(OpenAITTSRequest{Model: "gpt-4o-audio-preview"})
+			require.NoError(t, err)
+
+			calls := mockClient.DoCalls()
+			require.Len(t, calls, 2)
+			for _, call := range calls {
+				assert.Equal(t, test.expected, call.Req.URL.String())
+			}
+		})
+	}
+}
+
+func TestOpenAIService_Timeouts(t *testing.T) {
+	okResponse := func() *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"choices": [{"message": {"content": "ok", "audio": {"data": "b2s="}}}]}`)),
+			Header:     make(http.Header),
+		}
+	}
+
+	t.Run("each operation has its deadline", func(t *testing.T) {
+		var deadlines []time.Duration
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				deadline, ok := req.Context().Deadline()
+				require.True(t, ok, "request has a deadline")
+				deadlines = append(deadlines, time.Until(deadline))
+				return okResponse(), nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.SetChatTimeout(5 * time.Minute)
+		service.SetTTSTimeout(20 * time.Second)
+
+		_, err := service.callChatAPI(OpenAIRequest{Model: "gpt-4o"})
+		require.NoError(t, err)
+		_, err = service.callTTSAPI(OpenAITTSRequest{Model: "gpt-4o-audio-preview"})
+		require.NoError(t, err)
+		require.Len(t, deadlines, 2)
+		assert.InDelta(t, 5*time.Minute, deadlines[0], float64(time.Second))
+		assert.InDelta(t, 20*time.Second, deadlines[1], float64(time.Second))
+	})
+
+	t.Run("zero keeps the defaults", func(t *testing.T) {
+		service := NewOpenAIService("test-key", nil)
+		service.SetChatTimeout(0)
+		service.SetTTSTimeout(0)
+		assert.Equal(t, content.OpenAIChatTimeout, service.chatTimeout)
+		assert.Equal(t, content.OpenAITTSTimeout, service.ttsTimeout)
+	})
+
+	t.Run("retry gets a fresh deadline", func(t *testing.T) {
+		var calls int
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				calls++
+				if calls == 1 { // the first attempt hangs until its deadline
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				}
+				require.NoError(t, req.Context().Err(), "retry starts with a live deadline")
+				return okResponse(), nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.SetRetryPolicy(backoff.RetryPolicy{MaxAttempts: 2})
+		service.SetTTSTimeout(50 * time.Millisecond)
+
+		audio, err := service.callTTSAPI(OpenAITTSRequest{Model: "gpt-4o-audio-preview"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("ok"), audio)
+		assert.Equal(t, 2, calls)
+	})
+}
+
+func TestOpenAIService_Models(t *testing.T) {
+	tests := []struct {
+		name              string
+		chatModel         string
+		ttsModel          string
+		hostTTSModel      string
+		expectedChatModel string
+		expectedTTSModel  string
+	}{
+		{name: "defaults", expectedChatModel: "gpt-4o", expectedTTSModel: "gpt-4o-audio-preview"},
+		{name: "custom models", chatModel: "gpt-4o-mini", ttsModel: "gpt-4o-mini-audio-preview",
+			expectedChatModel: "gpt-4o-mini", expectedTTSModel: "gpt-4o-mini-audio-preview"},
+		{name: "custom chat model only", chatModel: "gpt-4.1",
+			expectedChatModel: "gpt-4.1", expectedTTSModel: "gpt-4o-audio-preview"},
+		{name: "host TTS model overrides the service one", ttsModel: "gpt-4o-mini-audio-preview", hostTTSModel: "gpt-4o-audio-preview",
+			expectedChatModel: "gpt-4o", expectedTTSModel: "gpt-4o-audio-preview"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var models []string
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					var request struct {
+						Model string `json:"model"`
+					}
+					if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+						return nil, err
+					}
+					models = append(models, request.Model)
+					body := `{"choices": [{"message": {"content": "Alice: hi", "audio": {"data": "b2s="}}}]}`
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+				},
+			}
+
+			service := NewOpenAIService("test-key", mockClient)
+			service.SetChatModel(test.chatModel)
+			service.SetTTSModel(test.ttsModel)
+
+			_, err := service.GenerateDiscussion(podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title",
+				TargetDuration: 1, Hosts: []podcast.Host{{Name: "Alice"}}})
+			require.NoError(t, err)
+			_, err = service.GenerateSpeech("hi", "nova", "", test.hostTTSModel)
+			require.NoError(t, err)
+			assert.Equal(t, []string{test.expectedChatModel, test.expectedTTSModel}, models)
+		})
+	}
+}
+
+func TestOpenAIService_GenerateDiscussionContextLength(t *testing.T) {
+	contextErr := func() *http.Response {
+		body := `{"error": {"message": "This model's maximum context length is 128000 tokens.", "type": "invalid_request_error",
+			"code": "context_length_exceeded"}}`
+		return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+	}
+	ok := func() *http.Response {
+		body := `{"choices": [{"message": {"content": "Alice: hi\nBob: hello"}}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+	}
+	article := strings.Repeat("абвгд", 200) // 1000 characters
+	params := podcast.GenerateDiscussionParams{ArticleText: article, Title: "title", TargetDuration: 1,
+		Hosts: []podcast.Host{{Name: "Alice"}, {Name: "Bob"}}}
+
+	tests := []struct {
+		name           string
+		fallbackModel  string
+		responses      []*http.Response
+		expectedModels []string
+		expectedChars  []int // article characters sent with each request
+		expectedErr    string
+	}{
+		{name: "fallback model", fallbackModel: "gpt-4.1", responses: []*http.Response{contextErr(), ok()},
+			expectedModels: []string{"gpt-4o", "gpt-4.1"}, expectedChars: []int{1000, 1000}},
+		{name: "article trimmed without a fallback model", responses: []*http.Response{contextErr(), contextErr(), ok()},
+			expectedModels: []string{"gpt-4o", "gpt-4o", "gpt-4o"}, expectedChars: []int{1000, 503, 254}},
+		{name: "article trimmed after the fallback model", fallbackModel: "gpt-4.1",
+			responses:      []*http.Response{contextErr(), contextErr(), ok()},
+			expectedModels: []string{"gpt-4o", "gpt-4.1", "gpt-4.1"}, expectedChars: []int{1000, 1000, 503}},
+		{name: "gives up after the trims", responses: []*http.Response{contextErr(), contextErr(), contextErr()},
+			expectedModels: []string{"gpt-4o", "gpt-4o", "gpt-4o"}, expectedChars: []int{1000, 503, 254},
+			expectedErr: "status 400"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests []OpenAIRequest
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					var request OpenAIRequest
+					require.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+					requests = append(requests, request)
+					return test.responses[len(requests)-1], nil
+				},
+			}
+			service := NewOpenAIService("test-key", mockClient)
+			service.SetFallbackChatModel(test.fallbackModel)
+
+			var discussion podcast.Discussion
+			var err error
+			out := captureLog(t, service, func() { discussion, err = service.GenerateDiscussion(params) })
+			require.Len(t, requests, len(test.expectedModels))
+			for i, request := range requests {
+				assert.Equal(t, test.expectedModels[i], request.Model)
+				prompt := request.Messages[1].Content
+				sent := prompt[strings.Index(prompt, "Article Content: ")+len("Article Content: ") : strings.Index(prompt, "\n\nPlease")]
+				assert.Equal(t, test.expectedChars[i], utf8.RuneCountInString(sent), "request %d", i)
+			}
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, discussion.Messages, 2)
+			assert.Contains(t, out, "Article exceeds the model context, retrying with the ")
+		})
+	}
+}
+
+func TestOpenAIService_GenerateDiscussionParseRetry(t *testing.T) {
+	chatResponse := func(content string) *http.Response {
+		body, err := json.Marshal(map[string]any{"choices": []map[string]any{{"message": map[string]string{"content": content}}}})
+		require.NoError(t, err)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Header: make(http.Header)}
+	}
+	garbage := "Sorry, here is a summary of the article instead."
+	truncated := `[{"host": "Alice", "content": "Привет`
+	valid := "Alice: Привет\nBob: Здравствуйте"
+	params := podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title", TargetDuration: 1,
+		Hosts: []podcast.Host{{Name: "Alice"}, {Name: "Bob"}}}
+
+	tests := []struct {
+		name          string
+		retries       int
+		responses     []string
+		expectedCalls int
+		expectedErr   string
+	}{
+		{name: "garbage fixed by the first retry", retries: 2, responses: []string{garbage, valid}, expectedCalls: 2},
+		{name: "truncated json fixed by the second retry", retries: 2, responses: []string{truncated, garbage, valid},
+			expectedCalls: 3},
+		{name: "gives up after the retries", retries: 1, responses: []string{garbage, truncated}, expectedCalls: 2,
+			expectedErr: "failed to parse discussion"},
+		{name: "disabled", retries: 0, responses: []string{garbage}, expectedCalls: 1, expectedErr: "no valid dialog lines"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests []OpenAIRequest
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					var request OpenAIRequest
+					require.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+					requests = append(requests, request)
+					return chatResponse(test.responses[len(requests)-1]), nil
+				},
+			}
+			service := NewOpenAIService("test-key", mockClient)
+			service.SetParseRetries(test.retries)
+
+			var discussion podcast.Discussion
+			var err error
+			out := captureLog(t, service, func() { discussion, err = service.GenerateDiscussion(params) })
+			require.Len(t, requests, test.expectedCalls)
+			for i, request := range requests[1:] {
+				// every retry sends back only the last malformed response with the correction
+				require.Len(t, request.Messages, 4)
+				assert.Equal(t, OpenAIMessage{Role: "assistant", Content: test.responses[i]}, request.Messages[2])
+				assert.Equal(t, "user", request.Messages[3].Role)
+				assert.Contains(t, request.Messages[3].Content, "Return ONLY the discussion")
+				assert.Contains(t, request.Messages[3].Content, "no valid dialog lines found")
+			}
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []podcast.Message{{Host: "Alice", Content: "Привет"}, {Host: "Bob", Content: "Здравствуйте"}},
+				discussion.Messages)
+			assert.Contains(t, out, "asking the model to fix it err=\"no valid dialog lines found in response\" retry=1/")
+		})
+	}
+
+	t.Run("api error on retry", func(t *testing.T) {
+		var calls int
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(*http.Request) (*http.Response, error) {
+				calls++
+				if calls == 1 {
+					return chatResponse(garbage), nil
+				}
+				return &http.Response{StatusCode: http.StatusBadRequest, Header: make(http.Header),
+					Body: io.NopCloser(strings.NewReader(`{"error": {"message": "bad request"}}`))}, nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		var err error
+		captureLog(t, service, func() { _, err = service.GenerateDiscussion(params) })
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to request a fixed discussion")
+		assert.Equal(t, 2, calls)
+	})
+}
+
+func TestOpenAIService_GenerateDiscussionBalanceQuotes(t *testing.T) {
+	body, err := json.Marshal(map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{
+			"content": "Alice: Книга «Чистый код\nBob: Согласен» (полностью",
+		}}},
+	})
+	require.NoError(t, err)
+
+	for _, balance := range []bool{true, false} {
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Header: make(http.Header)}, nil
+			},
+		}
+		params := podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title", TargetDuration: 1,
+			Hosts: []podcast.Host{{Name: "Alice"}, {Name: "Bob"}}, BalanceQuotes: balance}
+
+		discussion, err := NewOpenAIService("test-key", mockClient).GenerateDiscussion(params)
+		require.NoError(t, err)
+		require.Len(t, discussion.Messages, 2)
+		if balance {
+			assert.Equal(t, "Книга Чистый код", discussion.Messages[0].Content)
+			assert.Equal(t, "Согласен полностью", discussion.Messages[1].Content)
+			continue
+		}
+		assert.Equal(t, "Книга «Чистый код", discussion.Messages[0].Content)
+	}
+}
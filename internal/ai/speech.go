@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// chatCompletionsPath and speechPath are the API endpoints relative to the base URL
+const (
+	chatCompletionsPath = "/chat/completions"
+	speechPath          = "/audio/speech"
+)
+
+// TTSBackend defines which API endpoint synthesizes speech
+type TTSBackend string
+
+// supported TTS backends
+const (
+	TTSBackendChat   TTSBackend = "chat"   // audio output of chat completions, base64 audio in the choices
+	TTSBackendSpeech TTSBackend = "speech" // the dedicated /audio/speech endpoint, raw audio in the response body
+)
+
+// ParseTTSBackend validates the TTS backend name
+func ParseTTSBackend(s string) (TTSBackend, error) {
+	switch backend := TTSBackend(strings.ToLower(strings.TrimSpace(s))); backend {
+	case TTSBackendChat, TTSBackendSpeech:
+		return backend, nil
+	default:
+		return "", fmt.Errorf("unknown TTS backend %q, expected chat or speech", s)
+	}
+}
+
+// OpenAISpeechRequest represents the request structure for the OpenAI speech API
+type OpenAISpeechRequest struct {
+	Model          string `json:"model"`
+	Voice          string `json:"voice"`
+	Input          string `json:"input"`
+	ResponseFormat string `json:"response_format"`
+	Instructions   string `json:"instructions,omitempty"` // speaking style, tts-1 models don't support it
+}
+
+// speechRequest creates the speech API request. the speaking style prompt goes to instructions,
+// which the older tts-1 and tts-1-hd models reject.
+func speechRequest(text, voice, instructions, model string) OpenAISpeechRequest {
+	request := OpenAISpeechRequest{Model: model, Voice: voice, Input: text, ResponseFormat: "mp3"}
+	if !strings.HasPrefix(model, "tts-1") {
+		request.Instructions = instructions
+	}
+	return request
+}
+
+// callSpeechAPI makes a request to the OpenAI speech API, the response body is the audio itself
+func (s *OpenAIService) callSpeechAPI(request OpenAISpeechRequest) ([]byte, error) {
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := s.post(speechPath, requestBody, s.ttsTimeout)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return nil, fmt.Errorf("TTS request failed with %w", apiErr)
+		}
+		return nil, fmt.Errorf("TTS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	audioData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio data: %w", err)
+	}
+	s.usage.add(request.Model, 0, 0, utf8.RuneCountInString(request.Input))
+	if len(audioData) == 0 {
+		return nil, fmt.Errorf("no TTS response from API")
+	}
+	return audioData, nil
+}
@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/ai/mocks"
+	"github.com/radio-t/ai-podcast/internal/content"
+)
+
+func TestParseTTSBackend(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected TTSBackend
+		wantErr  bool
+	}{
+		{input: "chat", expected: TTSBackendChat},
+		{input: " Speech ", expected: TTSBackendSpeech},
+		{input: "", wantErr: true},
+		{input: "audio", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			backend, err := ParseTTSBackend(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "expected chat or speech")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, backend)
+		})
+	}
+}
+
+func TestOpenAIService_GenerateSpeechBackends(t *testing.T) {
+	audio := []byte("ID3\x00\x01raw-mp3-bytes")
+
+	tests := []struct {
+		name     string
+		backend  TTSBackend
+		model    string
+		response string
+		check    func(t *testing.T, path string, body []byte)
+	}{
+		{
+			name:     "chat",
+			backend:  TTSBackendChat,
+			response: `{"choices": [{"message": {"audio": {"data": "` + base64.StdEncoding.EncodeToString(audio) + `"}}}]}`,
+			check: func(t *testing.T, path string, body []byte) {
+				assert.Equal(t, "/v1/chat/completions", path)
+				var request OpenAITTSRequest
+				require.NoError(t, json.Unmarshal(body, &request))
+				assert.Equal(t, content.OpenAITTSModel, request.Model)
+				assert.Equal(t, "nova", request.Audio.Voice)
+			},
+		},
+		{
+			name:     "speech returns raw bytes",
+			backend:  TTSBackendSpeech,
+			response: string(audio),
+			check: func(t *testing.T, path string, body []byte) {
+				assert.Equal(t, "/v1/audio/speech", path)
+				var request OpenAISpeechRequest
+				require.NoError(t, json.Unmarshal(body, &request))
+				assert.Equal(t, OpenAISpeechRequest{Model: content.OpenAISpeechModel, Voice: "nova", Input: "Привет",
+					ResponseFormat: "mp3", Instructions: request.Instructions}, request)
+				assert.Contains(t, request.Instructions, "эмоцией: excited", "speaking style sent as instructions")
+			},
+		},
+		{
+			name:     "speech with tts-1 has no instructions",
+			backend:  TTSBackendSpeech,
+			model:    "tts-1",
+			response: string(audio),
+			check: func(t *testing.T, _ string, body []byte) {
+				assert.NotContains(t, string(body), "instructions")
+				assert.Contains(t, string(body), `"model":"tts-1"`)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var path string
+			var body []byte
+			mockClient := &mocks.HTTPClientMock{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					path = req.URL.Path
+					var err error
+					body, err = io.ReadAll(req.Body)
+					require.NoError(t, err)
+					return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(test.response)), Header: make(http.Header)}, nil
+				},
+			}
+			service := NewOpenAIService("test-key", mockClient)
+			service.SetTTSBackend(test.backend)
+
+			got, err := service.GenerateSpeech("Привет", "nova", "excited", test.model)
+			require.NoError(t, err)
+			assert.Equal(t, audio, got)
+			test.check(t, path, body)
+			usage := service.usage.usages()
+			require.Len(t, usage, 1)
+			assert.Equal(t, len([]rune("Привет")), usage[0].TTSChars)
+		})
+	}
+
+	t.Run("speech with empty body", func(t *testing.T) {
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.SetTTSBackend(TTSBackendSpeech)
+		_, err := service.GenerateSpeech("Привет", "nova", "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no TTS response from API")
+	})
+
+	t.Run("speech api error", func(t *testing.T) {
+		mockClient := &mocks.HTTPClientMock{
+			DoFunc: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 400, Header: make(http.Header),
+					Body: io.NopCloser(strings.NewReader(`{"error": {"message": "unsupported voice", "type": "invalid_request_error"}}`))}, nil
+			},
+		}
+		service := NewOpenAIService("test-key", mockClient)
+		service.SetTTSBackend(TTSBackendSpeech)
+		_, err := service.GenerateSpeech("Привет", "bad", "", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TTS request failed with")
+		assert.Contains(t, err.Error(), "unsupported voice")
+	})
+
+	t.Run("explicit model is kept", func(t *testing.T) {
+		service := NewOpenAIService("test-key", nil)
+		service.SetTTSModel("tts-1-hd")
+		service.SetTTSBackend(TTSBackendSpeech)
+		assert.Equal(t, "tts-1-hd", service.ttsModel)
+	})
+}
@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// sseMaxLine is the longest server-sent event line accepted, a content delta is usually a few tokens
+const sseMaxLine = 1 << 20
+
+// callChatStream makes a streaming request to the chat completions API. the response arrives as server-sent events
+// with content deltas, every dialog line is parsed and passed to emit as soon as its newline arrives.
+// it returns the whole content, which the caller parses like a non-streaming response.
+func (s *OpenAIService) callChatStream(request OpenAIRequest, emit func(podcast.Message)) (string, error) {
+	request.Stream = true
+	request.StreamOptions = &StreamOptions{IncludeUsage: true}
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := s.post(chatCompletionsPath, requestBody, s.chatTimeout)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return "", fmt.Errorf("API request failed with %w", apiErr)
+		}
+		return "", fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	lines := &dialogLineStream{emit: emit}
+	var usage apiUsage
+	err = readSSE(resp.Body, func(data string) error {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *apiUsage `json:"usage"` // set in the last chunk only
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if len(chunk.Choices) > 0 {
+			lines.write(chunk.Choices[0].Delta.Content)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		return nil
+	})
+	s.usage.add(request.Model, usage.PromptTokens, usage.CompletionTokens, 0)
+	if err != nil {
+		return "", err
+	}
+	lines.flush()
+
+	if strings.TrimSpace(lines.content.String()) == "" {
+		return "", fmt.Errorf("no response from API")
+	}
+	return lines.content.String(), nil
+}
+
+// readSSE reads server-sent events from r and calls onData with the payload of every data line until [DONE].
+// comments, event names and blank lines between events are skipped.
+func readSSE(r io.Reader, onData func(data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), sseMaxLine)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+		if err := onData(data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil
+}
+
+// dialogLineStream collects content deltas and emits the dialog lines completed by each delta.
+// a response in the JSON format is only collected, its messages are known once the array is closed.
+type dialogLineStream struct {
+	emit    func(podcast.Message)
+	content strings.Builder
+	pending string // start of the line not terminated by a newline yet
+}
+
+// write adds the delta and emits every line it completes
+func (d *dialogLineStream) write(delta string) {
+	d.content.WriteString(delta)
+	d.pending += delta
+	for {
+		line, rest, ok := strings.Cut(d.pending, "\n")
+		if !ok {
+			return
+		}
+		d.pending = rest
+		d.emitLine(line)
+	}
+}
+
+// flush emits the last line, the response may end without a newline
+func (d *dialogLineStream) flush() {
+	d.emitLine(d.pending)
+	d.pending = ""
+}
+
+// emitLine parses the line and emits it when it's a dialog line of a response in the plain dialog format
+func (d *dialogLineStream) emitLine(line string) {
+	if start := strings.TrimSpace(d.content.String()); strings.HasPrefix(start, "[") || strings.HasPrefix(start, "```") {
+		return
+	}
+	messages, _ := parseDialogLines(line)
+	for _, msg := range messages {
+		d.emit(msg)
+	}
+}
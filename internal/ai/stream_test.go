@@ -0,0 +1,166 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// sseChunk formats a content delta as a server-sent event of the chat completions stream
+func sseChunk(t *testing.T, content string) string {
+	data, err := json.Marshal(map[string]any{"choices": []map[string]any{{"delta": map[string]string{"content": content}}}})
+	require.NoError(t, err)
+	return "data: " + string(data) + "\n\n"
+}
+
+// sseServer streams the deltas as chunked server-sent events, flushing each one. before the delta at pauseAt
+// it waits for resume, so a test can check what was emitted while the response is still being written.
+func sseServer(t *testing.T, deltas []string, pauseAt int, resume <-chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenAIRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.True(t, request.Stream, "stream requested")
+		assert.Equal(t, &StreamOptions{IncludeUsage: true}, request.StreamOptions)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		for i, delta := range deltas {
+			if i == pauseAt {
+				select {
+				case <-resume:
+				case <-time.After(5 * time.Second):
+					t.Error("stream was not resumed")
+				}
+			}
+			_, _ = fmt.Fprint(w, sseChunk(t, delta))
+			w.(http.Flusher).Flush()
+		}
+		_, _ = fmt.Fprint(w, `data: {"choices": [], "usage": {"prompt_tokens": 100, "completion_tokens": 20}}`+"\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestOpenAIService_GenerateDiscussionStream(t *testing.T) {
+	hosts := []podcast.Host{{Name: "Alice"}, {Name: "Bob"}}
+
+	t.Run("lines are emitted while the response is written", func(t *testing.T) {
+		// the first line is split between chunks, the last one ends without a newline
+		deltas := []string{"Ali", "ce [excited]: Привет,", " «друзья\nBo", "b: Здравствуйте!\n", "\nAlice: Начнём"}
+		resume := make(chan struct{})
+		server := sseServer(t, deltas, 3, resume)
+		defer server.Close()
+
+		service := NewOpenAIService("test-key", nil)
+		service.SetBaseURL(server.URL)
+		lines := make(chan podcast.Message, 10)
+		params := podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title", TargetDuration: 1, Hosts: hosts,
+			BalanceQuotes: true, Stream: lines}
+
+		type result struct {
+			discussion podcast.Discussion
+			err        error
+		}
+		done := make(chan result, 1)
+		go func() {
+			discussion, err := service.GenerateDiscussion(params)
+			done <- result{discussion, err}
+		}()
+
+		select {
+		case msg := <-lines:
+			assert.Equal(t, podcast.Message{Host: "Alice", Content: "Привет, друзья", Emotion: "excited"}, msg,
+				"first line arrives before the stream ends, quotes balanced")
+		case <-time.After(5 * time.Second):
+			t.Fatal("no line emitted while the response is streamed")
+		}
+		close(resume)
+
+		res := <-done
+		require.NoError(t, res.err)
+		assert.Equal(t, []podcast.Message{
+			{Host: "Alice", Content: "Привет, друзья", Emotion: "excited"},
+			{Host: "Bob", Content: "Здравствуйте!"},
+			{Host: "Alice", Content: "Начнём"},
+		}, res.discussion.Messages)
+
+		close(lines)
+		var streamed []podcast.Message
+		for msg := range lines {
+			streamed = append(streamed, msg)
+		}
+		assert.Equal(t, res.discussion.Messages[1:], streamed, "every line is emitted once")
+		assert.Equal(t, []ModelUsage{{Model: "gpt-4o", Requests: 1, PromptTokens: 100, CompletionTokens: 20}},
+			service.usage.usages(), "usage of the last chunk")
+	})
+
+	t.Run("json response is parsed at the end", func(t *testing.T) {
+		deltas := []string{`[{"host": "Alice", "content": "Привет"},`, "\n", `{"host": "Bob", "content": "Пока"}]`}
+		server := sseServer(t, deltas, -1, nil)
+		defer server.Close()
+
+		service := NewOpenAIService("test-key", nil)
+		service.SetBaseURL(server.URL)
+		lines := make(chan podcast.Message, 10)
+		discussion, err := service.GenerateDiscussion(podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title",
+			TargetDuration: 1, Hosts: hosts, Stream: lines})
+		require.NoError(t, err)
+		assert.Len(t, discussion.Messages, 2)
+		assert.Empty(t, lines, "json lines are not dialog lines")
+	})
+
+	t.Run("empty stream", func(t *testing.T) {
+		server := sseServer(t, nil, -1, nil)
+		defer server.Close()
+
+		service := NewOpenAIService("test-key", nil)
+		service.SetBaseURL(server.URL)
+		_, err := service.GenerateDiscussion(podcast.GenerateDiscussionParams{ArticleText: "text", Title: "title",
+			TargetDuration: 1, Hosts: hosts, Stream: make(chan podcast.Message, 1)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no response from API")
+	})
+}
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		expectedErr string
+	}{
+		{name: "events", input: "data: one\n\ndata:two\n\ndata: [DONE]\n\ndata: after\n", expected: []string{"one", "two"}},
+		{name: "comments and event names", input: ": ping\nevent: message\ndata: one\nid: 1\n\n", expected: []string{"one"}},
+		{name: "crlf line endings", input: "data: one\r\n\r\ndata: [DONE]\r\n", expected: []string{"one"}},
+		{name: "no done marker", input: "data: one\n", expected: []string{"one"}},
+		{name: "handler error", input: "data: bad\n", expectedErr: "bad data"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			err := readSSE(strings.NewReader(test.input), func(data string) error {
+				if data == "bad" {
+					return fmt.Errorf("bad data")
+				}
+				got = append(got, data)
+				return nil
+			})
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, got)
+		})
+	}
+}
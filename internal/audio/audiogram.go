@@ -0,0 +1,91 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// audiogram rendering parameters
+const (
+	audiogramVideoSeconds = 60 // a video covers the opening minute, short enough to share
+	audiogramMaxSide      = 4096
+	audiogramColor        = "0x4fc3f7"
+	audiogramFrameRate    = 25
+)
+
+// ParseAudiogramSize parses audiogram dimensions given as WIDTHxHEIGHT, e.g. 1280x720.
+// both sides must be even, as the H.264 video of an mp4 audiogram requires.
+func ParseAudiogramSize(s string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid audiogram size %q, expected WIDTHxHEIGHT, e.g. 1280x720", s)
+	}
+	if width, err = strconv.Atoi(w); err == nil {
+		height, err = strconv.Atoi(h)
+	}
+	if err != nil || width <= 0 || height <= 0 || width > audiogramMaxSide || height > audiogramMaxSide {
+		return 0, 0, fmt.Errorf("invalid audiogram size %q, expected WIDTHxHEIGHT up to %d pixels per side", s, audiogramMaxSide)
+	}
+	if width%2 != 0 || height%2 != 0 {
+		return 0, 0, fmt.Errorf("invalid audiogram size %q, width and height must be even", s)
+	}
+	return width, height, nil
+}
+
+// Audiogram renders the waveform of inputFile for sharing on social networks, by the extension of outputFile:
+// a .png picture of the whole episode or an .mp4 video of the opening minute with the waveform moving along the audio.
+func (p *FFmpegAudioProcessor) Audiogram(inputFile, outputFile string, width, height int) error {
+	args, err := audiogramArgs(inputFile, outputFile, width, height)
+	if err != nil {
+		return err
+	}
+
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", args...)
+	cmd.Stdout = p.stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to render audiogram: %w", err)
+	}
+	return nil
+}
+
+// audiogramArgs builds ffmpeg arguments rendering the audiogram. the filter graphs are
+//
+//	png: showwavespic=s=WxH:split_channels=0:colors=C
+//	mp4: [0:a]showwaves=s=WxH:mode=cline:rate=25:colors=C,format=yuv420p[v]
+//
+// showwavespic draws the waveform of the whole input as a single frame. showwaves draws a frame of the waveform
+// every 1/25 s, the video is mapped with the original audio and converted to yuv420p, so common players can show it.
+func audiogramArgs(inputFile, outputFile string, width, height int) ([]string, error) {
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+	}
+	switch ext := strings.ToLower(filepath.Ext(outputFile)); ext {
+	case ".png":
+		graph := fmt.Sprintf("showwavespic=s=%dx%d:split_channels=0:colors=%s", width, height, audiogramColor)
+		return append(args, "-i", inputFile, "-filter_complex", graph, "-frames:v", "1", outputFile), nil
+	case ".mp4":
+		graph := fmt.Sprintf("[0:a]showwaves=s=%dx%d:mode=cline:rate=%d:colors=%s,format=yuv420p[v]",
+			width, height, audiogramFrameRate, audiogramColor)
+		return append(args,
+			"-t", strconv.Itoa(audiogramVideoSeconds),
+			"-i", inputFile,
+			"-filter_complex", graph,
+			"-map", "[v]", "-map", "0:a",
+			"-c:v", "libx264",
+			"-c:a", "aac",
+			"-shortest",
+			"-movflags", "+faststart",
+			outputFile,
+		), nil
+	default:
+		return nil, fmt.Errorf("unsupported audiogram extension %q, expected .png or .mp4", ext)
+	}
+}
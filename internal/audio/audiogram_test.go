@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAudiogramSize(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedWidth  int
+		expectedHeight int
+		expectedErr    string
+	}{
+		{input: "1280x720", expectedWidth: 1280, expectedHeight: 720},
+		{input: " 1080X1080 ", expectedWidth: 1080, expectedHeight: 1080},
+		{input: "1280", expectedErr: "expected WIDTHxHEIGHT"},
+		{input: "wide x tall", expectedErr: "expected WIDTHxHEIGHT"},
+		{input: "0x720", expectedErr: "up to 4096 pixels per side"},
+		{input: "8192x720", expectedErr: "up to 4096 pixels per side"},
+		{input: "1281x720", expectedErr: "width and height must be even"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			width, height, err := ParseAudiogramSize(test.input)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedWidth, width)
+			assert.Equal(t, test.expectedHeight, height)
+		})
+	}
+}
+
+func TestAudiogramArgs(t *testing.T) {
+	t.Run("png", func(t *testing.T) {
+		args, err := audiogramArgs("episode.mp3", "out/wave.PNG", 1280, 720)
+		require.NoError(t, err)
+		cmdline := strings.Join(args, " ")
+		assert.Contains(t, cmdline, "-i episode.mp3 -filter_complex showwavespic=s=1280x720:split_channels=0:colors=0x4fc3f7")
+		assert.Contains(t, cmdline, "-frames:v 1")
+		assert.Equal(t, "out/wave.PNG", args[len(args)-1])
+	})
+
+	t.Run("mp4", func(t *testing.T) {
+		args, err := audiogramArgs("episode.mp3", "wave.mp4", 1080, 1080)
+		require.NoError(t, err)
+		cmdline := strings.Join(args, " ")
+		assert.Contains(t, cmdline, "-t 60 -i episode.mp3")
+		assert.Contains(t, cmdline, "-filter_complex [0:a]showwaves=s=1080x1080:mode=cline:rate=25:colors=0x4fc3f7,format=yuv420p[v]")
+		assert.Contains(t, cmdline, "-map [v] -map 0:a -c:v libx264 -c:a aac")
+		assert.Equal(t, "wave.mp4", args[len(args)-1])
+	})
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		_, err := audiogramArgs("episode.mp3", "wave.gif", 1280, 720)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unsupported audiogram extension ".gif", expected .png or .mp4`)
+	})
+}
+
+func TestFFmpegAudioProcessor_Audiogram(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg records its arguments
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n", argsFile)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0o700)) // #nosec G306 -- test executable
+	t.Setenv("PATH", binDir)
+
+	processor := NewFFmpegAudioProcessor()
+	require.NoError(t, processor.Audiogram("episode.mp3", "episode.png", 1280, 720))
+	args, err := os.ReadFile(argsFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	expected, err := audiogramArgs("episode.mp3", "episode.png", 1280, 720)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(expected, " ")+"\n", string(args))
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		err := processor.Audiogram("episode.mp3", "episode.webm", 1280, 720)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported audiogram extension")
+	})
+
+	t.Run("ffmpeg failure", func(t *testing.T) {
+		failing := "#!/bin/sh\nexit 1\n"
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(failing), 0o700)) // #nosec G306 -- test executable
+		err := processor.Audiogram("episode.mp3", "episode.mp4", 1280, 720)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to render audiogram")
+	})
+}
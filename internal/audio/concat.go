@@ -0,0 +1,207 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ConcatMode defines how ffmpeg joins segments
+type ConcatMode string
+
+// supported concat modes
+const (
+	ConcatAuto     ConcatMode = "auto"     // copy when all segments share codec parameters, re-encode otherwise
+	ConcatCopy     ConcatMode = "copy"     // always copy streams, fast but breaks on mismatched segments
+	ConcatReencode ConcatMode = "reencode" // always re-encode, slower but works for any input
+)
+
+// reencodeBitrate is used when segments have to be re-encoded on concatenation
+const reencodeBitrate = "128k"
+
+// ParseConcatMode validates the concat mode name
+func ParseConcatMode(s string) (ConcatMode, error) {
+	switch mode := ConcatMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case ConcatAuto, ConcatCopy, ConcatReencode:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown concat mode %q, expected auto, copy or reencode", s)
+	}
+}
+
+// streamParams are the audio stream properties that must match for stream copy concatenation
+type streamParams struct {
+	Codec      string
+	SampleRate string
+	Channels   string
+}
+
+// Encoding is the mp3 encoding of the joined episode and the Icecast stream. with a bitrate set the segments
+// are always transcoded instead of copied, so the output is uniform whatever the TTS returned.
+type Encoding struct {
+	Bitrate    string // e.g. 128k, empty keeps the segments as they are
+	SampleRate int    // sample rate in Hz, 0 keeps the rate of the segments
+	Channels   int    // number of channels, 0 keeps the channels of the segments
+}
+
+// args returns the encoder arguments, nil without a bitrate
+func (e Encoding) args() []string {
+	if e.Bitrate == "" {
+		return nil
+	}
+	args := []string{"-c:a", "libmp3lame", "-b:a", e.Bitrate}
+	if e.SampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(e.SampleRate))
+	}
+	if e.Channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(e.Channels))
+	}
+	return args
+}
+
+// codecArgs returns ffmpeg codec arguments for concatenating the files according to the concat mode,
+// the configured encoding takes precedence over the mode
+func (p *FFmpegAudioProcessor) codecArgs(files []string) []string {
+	if args := p.encoding.args(); args != nil {
+		return args
+	}
+	copyArgs := []string{"-c", "copy"}
+	reencodeArgs := []string{"-c:a", "libmp3lame", "-b:a", reencodeBitrate}
+
+	switch p.concatMode {
+	case ConcatCopy:
+		return copyArgs
+	case ConcatReencode:
+		return reencodeArgs
+	}
+
+	probed, errs := p.probeAll(files)
+	var first streamParams
+	for i, file := range files {
+		if errs[i] != nil {
+			p.logger.Warn("Can't probe segment, re-encoding on concat", "file", file, "err", errs[i])
+			return reencodeArgs
+		}
+		params := probed[i]
+		if i == 0 {
+			first = params
+			continue
+		}
+		if params != first {
+			p.logger.Info("Segment format differs from the first one, re-encoding on concat", "file", file,
+				"format", fmt.Sprintf("%s, %s Hz, %s ch", params.Codec, params.SampleRate, params.Channels),
+				"first", fmt.Sprintf("%s, %s Hz, %s ch", first.Codec, first.SampleRate, first.Channels))
+			return reencodeArgs
+		}
+	}
+	return copyArgs
+}
+
+// probeAll probes the files with up to probeWorkers ffprobe processes at a time, results follow the files order
+func (p *FFmpegAudioProcessor) probeAll(files []string) ([]streamParams, []error) {
+	probed := make([]streamParams, len(files))
+	errs := make([]error, len(files))
+	if p.probeWorkers <= 1 {
+		for i, file := range files {
+			if probed[i], errs[i] = p.probe(file); errs[i] != nil {
+				break // the first failure decides re-encoding, later files don't matter
+			}
+		}
+		return probed, errs
+	}
+
+	sem := make(chan struct{}, p.probeWorkers)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			probed[i], errs[i] = p.probe(file)
+		}()
+	}
+	wg.Wait()
+	return probed, errs
+}
+
+// concatFileCodecArgs returns codec arguments for the files listed in the concat file.
+// an unreadable list keeps stream copy, ffmpeg reports the problem with the list itself.
+func (p *FFmpegAudioProcessor) concatFileCodecArgs(concatFile string) []string {
+	if p.encoding.Bitrate != "" || p.concatMode == ConcatCopy || p.concatMode == ConcatReencode {
+		return p.codecArgs(nil)
+	}
+	files, err := readConcatFile(concatFile)
+	if err != nil {
+		return []string{"-c", "copy"}
+	}
+	return p.codecArgs(files)
+}
+
+// probeStream runs ffprobe on the first audio stream of the file
+func (p *FFmpegAudioProcessor) probeStream(filename string) (streamParams, error) {
+	args := []string{
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,sample_rate,channels",
+		"-of", "default=noprint_wrappers=1",
+		filename,
+	}
+
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	out, err := p.command("ffprobe", args...).Output()
+	if err != nil {
+		return streamParams{}, fmt.Errorf("failed to probe audio stream: %w", err)
+	}
+	return parseProbeOutput(string(out))
+}
+
+// parseProbeOutput parses "key=value" lines printed by ffprobe
+func parseProbeOutput(out string) (streamParams, error) {
+	var params streamParams
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "codec_name":
+			params.Codec = value
+		case "sample_rate":
+			params.SampleRate = value
+		case "channels":
+			params.Channels = value
+		}
+	}
+	if params.Codec == "" {
+		return streamParams{}, fmt.Errorf("no audio stream found")
+	}
+	return params, nil
+}
+
+// readConcatFile returns the file paths listed in a concat file written by writeConcatFile
+func readConcatFile(concatFile string) ([]string, error) {
+	f, err := os.Open(concatFile) // #nosec G304 -- concat file is created internally
+	if err != nil {
+		return nil, fmt.Errorf("failed to open concat file: %w", err)
+	}
+	defer f.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "file '") || !strings.HasSuffix(line, "'") {
+			continue
+		}
+		quoted := strings.TrimSuffix(strings.TrimPrefix(line, "file '"), "'")
+		files = append(files, strings.ReplaceAll(quoted, `'\''`, `'`))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read concat file: %w", err)
+	}
+	return files, nil
+}
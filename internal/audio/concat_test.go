@@ -0,0 +1,177 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConcatMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ConcatMode
+		wantErr  bool
+	}{
+		{input: "auto", expected: ConcatAuto},
+		{input: "copy", expected: ConcatCopy},
+		{input: " ReEncode ", expected: ConcatReencode},
+		{input: "accurate", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			mode, err := ParseConcatMode(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown concat mode")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, mode)
+		})
+	}
+}
+
+func TestFFmpegAudioProcessor_CodecArgs(t *testing.T) {
+	copyArgs := []string{"-c", "copy"}
+	reencodeArgs := []string{"-c:a", "libmp3lame", "-b:a", reencodeBitrate}
+	speech := "codec_name=mp3\nsample_rate=24000\nchannels=1\n"
+
+	tests := []struct {
+		name     string
+		mode     ConcatMode
+		probes   map[string]string
+		expected []string
+	}{
+		{
+			name:     "auto with matching segments",
+			mode:     ConcatAuto,
+			probes:   map[string]string{"a.mp3": speech, "b.mp3": speech, "c.mp3": speech},
+			expected: copyArgs,
+		},
+		{
+			name:     "auto with different sample rate",
+			mode:     ConcatAuto,
+			probes:   map[string]string{"a.mp3": speech, "b.mp3": "codec_name=mp3\nsample_rate=44100\nchannels=2\n", "c.mp3": speech},
+			expected: reencodeArgs,
+		},
+		{
+			name:     "auto with different codec",
+			mode:     ConcatAuto,
+			probes:   map[string]string{"a.mp3": speech, "b.mp3": speech, "c.mp3": "codec_name=aac\nsample_rate=24000\nchannels=1\n"},
+			expected: reencodeArgs,
+		},
+		{
+			name:     "auto with probe failure",
+			mode:     ConcatAuto,
+			probes:   map[string]string{"a.mp3": speech, "c.mp3": speech},
+			expected: reencodeArgs,
+		},
+		{
+			name:     "copy skips probing",
+			mode:     ConcatCopy,
+			expected: copyArgs,
+		},
+		{
+			name:     "reencode skips probing",
+			mode:     ConcatReencode,
+			expected: reencodeArgs,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			processor := NewFFmpegAudioProcessor()
+			processor.SetConcatMode(test.mode)
+			var probed []string
+			processor.probe = func(filename string) (streamParams, error) {
+				probed = append(probed, filename)
+				out, ok := test.probes[filename]
+				if !ok {
+					return streamParams{}, fmt.Errorf("probe failed")
+				}
+				return parseProbeOutput(out)
+			}
+
+			args := processor.codecArgs([]string{"a.mp3", "b.mp3", "c.mp3"})
+			assert.Equal(t, test.expected, args)
+			if test.probes == nil {
+				assert.Empty(t, probed)
+			}
+		})
+	}
+}
+
+func TestFFmpegAudioProcessor_CodecArgsConcurrency(t *testing.T) {
+	speech := "codec_name=mp3\nsample_rate=24000\nchannels=1\n"
+	files := make([]string, 12)
+	for i := range files {
+		files[i] = fmt.Sprintf("segment_%03d.mp3", i)
+	}
+
+	probeWith := func(workers int, failing string) (args []string, maxActive int32) {
+		processor := NewFFmpegAudioProcessor()
+		processor.SetConcurrency(workers)
+		var active atomic.Int32
+		var peak atomic.Int32
+		processor.probe = func(filename string) (streamParams, error) {
+			n := active.Add(1)
+			defer active.Add(-1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			if filename == failing {
+				return streamParams{}, fmt.Errorf("probe failed")
+			}
+			return parseProbeOutput(speech)
+		}
+		return processor.codecArgs(files), peak.Load()
+	}
+
+	args, peak := probeWith(4, "")
+	assert.Equal(t, []string{"-c", "copy"}, args)
+	assert.LessOrEqual(t, peak, int32(4))
+	assert.Greater(t, peak, int32(1), "files are probed in parallel")
+
+	args, peak = probeWith(1, "")
+	assert.Equal(t, []string{"-c", "copy"}, args)
+	assert.Equal(t, int32(1), peak)
+
+	args, _ = probeWith(4, "segment_007.mp3")
+	assert.Equal(t, []string{"-c:a", "libmp3lame", "-b:a", reencodeBitrate}, args)
+}
+
+func TestParseProbeOutput(t *testing.T) {
+	params, err := parseProbeOutput("codec_name=mp3\r\nsample_rate=24000\nchannels=1\nunrelated line\n")
+	require.NoError(t, err)
+	assert.Equal(t, streamParams{Codec: "mp3", SampleRate: "24000", Channels: "1"}, params)
+
+	_, err = parseProbeOutput("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no audio stream found")
+}
+
+func TestReadConcatFile(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "a.mp3"), filepath.Join(dir, "it's here.mp3"), `C:\audio\b.mp3`}
+	concatFile := filepath.Join(dir, "concat.txt")
+	require.NoError(t, writeConcatFile(concatFile, files))
+
+	result, err := readConcatFile(concatFile)
+	require.NoError(t, err)
+	assert.Equal(t, files, result)
+
+	_, err = readConcatFile(filepath.Join(dir, "missing.txt"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open concat file")
+}
@@ -0,0 +1,93 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ConcatenateWithCrossfade joins the files into outputFile, fading each file into the next one over fadeMs
+// with the acrossfade filter. a fade takes at most half of either file around it, so a segment shorter than
+// the fade is faded over a shorter time, and a file too short for any fade is joined with a hard cut.
+// a zero fade or a single file is joined by the plain Concatenate.
+func (p *FFmpegAudioProcessor) ConcatenateWithCrossfade(files []string, outputFile string, fadeMs int) error {
+	if fadeMs <= 0 || len(files) < 2 {
+		return p.Concatenate(files, outputFile)
+	}
+
+	durations := make([]float64, len(files))
+	for i, file := range files {
+		duration, err := p.Duration(file)
+		if err != nil {
+			return fmt.Errorf("failed to measure %s for crossfade: %w", file, err)
+		}
+		durations[i] = duration
+	}
+
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", crossfadeArgs(files, outputFile, crossfadeDurations(durations, float64(fadeMs)/1000))...)
+	cmd.Stdout = p.stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to crossfade audio files: %w", err)
+	}
+	return nil
+}
+
+// minCrossfade is the shortest fade in seconds, the graph has millisecond precision
+const minCrossfade = 0.001
+
+// crossfadeDurations returns the fade in seconds between each pair of consecutive files. a file in the middle
+// is faded in and out, so each fade is limited to half of the files around it, zero means a hard cut.
+func crossfadeDurations(durations []float64, fade float64) []float64 {
+	fades := make([]float64, len(durations)-1)
+	for i := range fades {
+		if f := min(fade, durations[i]/2, durations[i+1]/2); f >= minCrossfade {
+			fades[i] = f
+		}
+	}
+	return fades
+}
+
+// crossfadeArgs builds ffmpeg arguments joining the files with the fades between them
+func crossfadeArgs(files []string, outputFile string, fades []float64) []string {
+	args := []string{"-y", "-hide_banner", "-loglevel", "error"}
+	for _, file := range files {
+		args = append(args, "-i", file)
+	}
+	return append(args,
+		"-filter_complex", crossfadeGraph(fades),
+		"-map", "[out]",
+		"-c:a", "libmp3lame",
+		"-b:a", reencodeBitrate,
+		outputFile,
+	)
+}
+
+// crossfadeGraph chains a filter per pair of inputs, each joining the result so far with the next input, e.g.
+//
+//	[0:a][1:a]acrossfade=d=0.150:c1=tri:c2=tri[a1];[a1][2:a]acrossfade=d=0.150:c1=tri:c2=tri[out]
+//
+// a zero fade joins the pair with the concat filter instead, acrossfade needs a positive duration.
+func crossfadeGraph(fades []float64) string {
+	var graph strings.Builder
+	prev := "[0:a]"
+	for i, fade := range fades {
+		out := fmt.Sprintf("[a%d]", i+1)
+		if i == len(fades)-1 {
+			out = "[out]"
+		}
+		if i > 0 {
+			graph.WriteString(";")
+		}
+		filter := "concat=n=2:v=0:a=1"
+		if fade > 0 {
+			filter = "acrossfade=d=" + strconv.FormatFloat(fade, 'f', 3, 64) + ":c1=tri:c2=tri"
+		}
+		fmt.Fprintf(&graph, "%s[%d:a]%s%s", prev, i+1, filter, out)
+		prev = out
+	}
+	return graph.String()
+}
@@ -0,0 +1,97 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossfadeGraph(t *testing.T) {
+	t.Run("three inputs", func(t *testing.T) {
+		assert.Equal(t, "[0:a][1:a]acrossfade=d=0.150:c1=tri:c2=tri[a1];[a1][2:a]acrossfade=d=0.150:c1=tri:c2=tri[out]",
+			crossfadeGraph([]float64{0.15, 0.15}))
+	})
+
+	t.Run("hard cut for a zero fade", func(t *testing.T) {
+		assert.Equal(t, "[0:a][1:a]acrossfade=d=0.200:c1=tri:c2=tri[a1];[a1][2:a]concat=n=2:v=0:a=1[out]",
+			crossfadeGraph([]float64{0.2, 0}))
+	})
+}
+
+func TestCrossfadeDurations(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []float64
+		expected  []float64
+	}{
+		{name: "long files", durations: []float64{5, 3, 4}, expected: []float64{0.3, 0.3}},
+		{name: "short middle file", durations: []float64{5, 0.4, 4}, expected: []float64{0.2, 0.2}},
+		{name: "too short for any fade", durations: []float64{5, 0.001, 4}, expected: []float64{0, 0}},
+		{name: "unmeasured file", durations: []float64{5, 0, 4}, expected: []float64{0, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fades := crossfadeDurations(test.durations, 0.3)
+			require.Len(t, fades, len(test.expected))
+			for i, fade := range fades {
+				assert.InDelta(t, test.expected[i], fade, 0.0001, "fade %d", i)
+			}
+		})
+	}
+}
+
+func TestFFmpegAudioProcessor_ConcatenateWithCrossfade(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffprobe reports every file as 2 seconds long, fake ffmpeg records its arguments
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n", argsFile)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0o700))                   // #nosec G306 -- test executable
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffprobe"), []byte("#!/bin/sh\necho 2.0\n"), 0o700)) // #nosec G306 -- test executable
+	t.Setenv("PATH", binDir)
+
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "a.mp3"), filepath.Join(dir, "b.mp3"), filepath.Join(dir, "c.mp3")}
+	for _, file := range files {
+		require.NoError(t, os.WriteFile(file, []byte("audio"), 0o600))
+	}
+	processor := NewFFmpegAudioProcessor()
+	readArgs := func() string {
+		args, err := os.ReadFile(argsFile) // #nosec G304 -- test file
+		require.NoError(t, err)
+		return string(args)
+	}
+
+	t.Run("crossfaded", func(t *testing.T) {
+		require.NoError(t, processor.ConcatenateWithCrossfade(files, "episode.mp3", 150))
+		assert.Equal(t, strings.Join(crossfadeArgs(files, "episode.mp3", []float64{0.15, 0.15}), " ")+"\n", readArgs())
+	})
+
+	t.Run("zero fade delegates to concatenate", func(t *testing.T) {
+		require.NoError(t, processor.ConcatenateWithCrossfade(files, "episode.mp3", 0))
+		assert.Contains(t, readArgs(), "-f concat")
+	})
+
+	t.Run("single file delegates to concatenate", func(t *testing.T) {
+		require.NoError(t, processor.ConcatenateWithCrossfade(files[:1], "episode.mp3", 150))
+		assert.Contains(t, readArgs(), "-f concat")
+		assert.NotContains(t, readArgs(), "acrossfade")
+	})
+
+	t.Run("duration failure", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffprobe"), []byte("#!/bin/sh\nexit 1\n"), 0o700)) // #nosec G306 -- test executable
+		err := processor.ConcatenateWithCrossfade(files, "episode.mp3", 150)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to measure "+files[0]+" for crossfade")
+	})
+}
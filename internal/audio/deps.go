@@ -0,0 +1,44 @@
+package audio
+
+import "fmt"
+
+// linuxPlayers are the audio players tried on Linux, in order of preference
+var linuxPlayers = []string{"mpv", "mplayer", "ffplay", "aplay"}
+
+// CheckDependencies verifies the external tools are available before the run starts, instead of failing deep into
+// generation: ffmpeg has to be found and run -version, and with play set, on Linux, one of the known audio players
+// has to be installed. the error names the missing tool and how to install it.
+func (p *FFmpegAudioProcessor) CheckDependencies(play bool) error {
+	ffmpeg := p.binary(defaultFFmpeg)
+	if _, err := p.lookPath(ffmpeg); err != nil {
+		return fmt.Errorf("ffmpeg not found at %q, %s, or set its location with -ffmpeg: %w", ffmpeg, installHint(p.goos, "ffmpeg"), err)
+	}
+	if err := p.command(defaultFFmpeg, "-version").Run(); err != nil {
+		return fmt.Errorf("can't run ffmpeg %q, check the installation or set another one with -ffmpeg: %w", ffmpeg, err)
+	}
+
+	if play && p.goos == "linux" {
+		for _, player := range linuxPlayers {
+			if _, err := p.lookPath(player); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("no audio player found to play the episode locally, one of %v is needed, %s",
+			linuxPlayers, installHint(p.goos, "mpv"))
+	}
+	return nil
+}
+
+// installHint returns how to install the package on the OS
+func installHint(goos, pkg string) string {
+	switch goos {
+	case "darwin":
+		return "install it with: brew install " + pkg
+	case "windows":
+		return "install it with: winget install " + pkg
+	case "linux":
+		return "install it with the package manager, e.g. sudo apt install " + pkg
+	default:
+		return "install " + pkg
+	}
+}
@@ -0,0 +1,75 @@
+package audio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFFmpegAudioProcessor_CheckDependencies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	require.NoError(t, os.WriteFile(ffmpeg, []byte("#!/bin/sh\necho ffmpeg version 7.1\n"), 0o700)) // #nosec G306 -- test executable
+
+	// fakeLookPath reports only the listed tools installed
+	fakeLookPath := func(installed ...string) func(string) (string, error) {
+		return func(file string) (string, error) {
+			for _, tool := range installed {
+				if file == tool {
+					return file, nil
+				}
+			}
+			return "", errors.New("executable file not found in $PATH")
+		}
+	}
+
+	tests := []struct {
+		name      string
+		goos      string
+		installed []string
+		play      bool
+		err       string
+	}{
+		{name: "ffmpeg only, streaming", goos: "linux", installed: []string{ffmpeg}},
+		{name: "ffmpeg and a player, playing", goos: "linux", installed: []string{ffmpeg, "aplay"}, play: true},
+		{name: "player not needed on macOS", goos: "darwin", installed: []string{ffmpeg}, play: true},
+		{name: "missing ffmpeg on linux", goos: "linux", err: "ffmpeg not found at \"" + ffmpeg +
+			"\", install it with the package manager, e.g. sudo apt install ffmpeg, or set its location with -ffmpeg"},
+		{name: "missing ffmpeg on macOS", goos: "darwin", err: "install it with: brew install ffmpeg"},
+		{name: "missing ffmpeg on windows", goos: "windows", err: "install it with: winget install ffmpeg"},
+		{name: "missing player", goos: "linux", installed: []string{ffmpeg}, play: true,
+			err: "no audio player found to play the episode locally, one of [mpv mplayer ffplay aplay] is needed, " +
+				"install it with the package manager, e.g. sudo apt install mpv"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			processor := NewFFmpegAudioProcessor(WithFFmpegPath(ffmpeg))
+			processor.goos = test.goos
+			processor.lookPath = fakeLookPath(test.installed...)
+			err := processor.CheckDependencies(test.play)
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+
+	t.Run("ffmpeg found but broken", func(t *testing.T) {
+		broken := filepath.Join(t.TempDir(), "ffmpeg")
+		require.NoError(t, os.WriteFile(broken, []byte("#!/bin/sh\nexit 1\n"), 0o700)) // #nosec G306 -- test executable
+		processor := NewFFmpegAudioProcessor(WithFFmpegPath(broken))
+		err := processor.CheckDependencies(false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't run ffmpeg")
+	})
+}
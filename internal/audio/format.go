@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// StreamFormat defines the codec and container of the Icecast stream
+type StreamFormat string
+
+// supported stream formats
+const (
+	FormatMP3  StreamFormat = "mp3"  // mp3 segments are sent as is
+	FormatOgg  StreamFormat = "ogg"  // segments re-encoded to Vorbis in Ogg
+	FormatOpus StreamFormat = "opus" // segments re-encoded to Opus in Ogg
+)
+
+// opusBitrate is used for Opus streams, the codec holds speech quality at lower bitrates than mp3
+const opusBitrate = "64k"
+
+// ParseStreamFormat validates the stream format name
+func ParseStreamFormat(s string) (StreamFormat, error) {
+	switch format := StreamFormat(strings.ToLower(strings.TrimSpace(s))); format {
+	case FormatMP3, FormatOgg, FormatOpus:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown stream format %q, expected mp3, ogg or opus", s)
+	}
+}
+
+// contentType returns the MIME type announced to Icecast for the format
+func (f StreamFormat) contentType() string {
+	switch f {
+	case FormatOgg, FormatOpus:
+		return "audio/ogg"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// outputArgs returns codec, content type and container arguments of the Icecast output encoded at bitrate.
+// mp3 keeps mp3CodecArgs chosen for the segments unless a bitrate is set, other formats re-encode the mp3 segments.
+// an empty bitrate keeps the default of the format.
+func (f StreamFormat) outputArgs(mp3CodecArgs []string, bitrate string) []string {
+	var args []string
+	switch f {
+	case FormatOgg:
+		args = []string{"-c:a", "libvorbis", "-b:a", cmp.Or(bitrate, reencodeBitrate), "-f", "ogg"}
+	case FormatOpus:
+		args = []string{"-c:a", "libopus", "-b:a", cmp.Or(bitrate, opusBitrate), "-f", "ogg"}
+	default:
+		if bitrate != "" {
+			args = []string{"-c:a", "libmp3lame", "-b:a", bitrate}
+			break
+		}
+		args = append(args, mp3CodecArgs...)
+	}
+	return append(args, "-content_type", f.contentType())
+}
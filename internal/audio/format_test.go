@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreamFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected StreamFormat
+		wantErr  bool
+	}{
+		{input: "mp3", expected: FormatMP3},
+		{input: " OGG ", expected: FormatOgg},
+		{input: "opus", expected: FormatOpus},
+		{input: "aac", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			format, err := ParseStreamFormat(test.input)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown stream format")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, format)
+		})
+	}
+}
+
+func TestStreamFormat_OutputArgs(t *testing.T) {
+	copyArgs := []string{"-c", "copy"}
+	tests := []struct {
+		name        string
+		format      StreamFormat
+		codecArgs   []string
+		bitrate     string
+		expected    []string
+		contentType string
+	}{
+		{
+			name:        "mp3 keeps segment codec args",
+			format:      FormatMP3,
+			codecArgs:   copyArgs,
+			expected:    []string{"-c", "copy", "-content_type", "audio/mpeg"},
+			contentType: "audio/mpeg",
+		},
+		{
+			name:        "mp3 with re-encoded segments",
+			format:      FormatMP3,
+			codecArgs:   []string{"-c:a", "libmp3lame", "-b:a", reencodeBitrate},
+			expected:    []string{"-c:a", "libmp3lame", "-b:a", reencodeBitrate, "-content_type", "audio/mpeg"},
+			contentType: "audio/mpeg",
+		},
+		{
+			name:        "ogg re-encodes to vorbis",
+			format:      FormatOgg,
+			codecArgs:   copyArgs,
+			expected:    []string{"-c:a", "libvorbis", "-b:a", reencodeBitrate, "-f", "ogg", "-content_type", "audio/ogg"},
+			contentType: "audio/ogg",
+		},
+		{
+			name:        "opus re-encodes to opus in ogg",
+			format:      FormatOpus,
+			codecArgs:   copyArgs,
+			expected:    []string{"-c:a", "libopus", "-b:a", opusBitrate, "-f", "ogg", "-content_type", "audio/ogg"},
+			contentType: "audio/ogg",
+		},
+		{
+			name:        "mp3 at a bitrate re-encodes",
+			format:      FormatMP3,
+			codecArgs:   copyArgs,
+			bitrate:     "64k",
+			expected:    []string{"-c:a", "libmp3lame", "-b:a", "64k", "-content_type", "audio/mpeg"},
+			contentType: "audio/mpeg",
+		},
+		{
+			name:        "opus at a bitrate",
+			format:      FormatOpus,
+			bitrate:     "32k",
+			expected:    []string{"-c:a", "libopus", "-b:a", "32k", "-f", "ogg", "-content_type", "audio/ogg"},
+			contentType: "audio/ogg",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.format.outputArgs(test.codecArgs, test.bitrate))
+			assert.Equal(t, test.contentType, test.format.contentType())
+		})
+	}
+
+}
@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/radio-t/ai-podcast/internal/backoff"
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+const (
+	stderrTailSize    = 4096             // how much of the ffmpeg stderr is kept to explain a failed stream
+	maxReconnectDelay = 30 * time.Second // cap of the growing wait between Icecast reconnects
+)
+
+// streamWithReconnect runs the ffmpeg streaming command made by newCmd and starts a new one from the beginning
+// when the connection to Icecast is refused or dropped, up to config.StreamRetries times with a growing delay.
+// other failures, e.g. a missing input file or rejected credentials, are returned right away.
+func (p *FFmpegAudioProcessor) streamWithReconnect(newCmd func() *exec.Cmd, config podcast.Config) error {
+	policy := backoff.RetryPolicy{
+		MaxAttempts: max(config.StreamRetries, 0) + 1,
+		BaseDelay:   config.StreamRetryDelay,
+		MaxDelay:    maxReconnectDelay,
+	}
+	attempt := 0
+	return policy.Do(p.context(), func() error {
+		attempt++
+		if attempt > 1 {
+			p.logger.Warn("Reconnecting to Icecast", "url", config.IcecastURL, "mount", mountList(config),
+				"retry", fmt.Sprintf("%d/%d", attempt-1, policy.MaxAttempts-1))
+		}
+		stderr, err := p.runStreamTail(newCmd(), config)
+		if err == nil {
+			return nil
+		}
+		if p.context().Err() != nil || !reconnectable(stderr) {
+			return backoff.Permanent(err)
+		}
+		return err
+	})
+}
+
+// reconnectable reports whether ffmpeg stderr shows a network failure worth reconnecting after:
+// the server refusing, resetting or timing out the connection. missing files and Icecast rejections are fatal.
+func reconnectable(stderr string) bool {
+	msg := strings.ToLower(stderr)
+	if strings.Contains(msg, "no such file or directory") {
+		return false
+	}
+	for _, marker := range []string{"connection refused", "connection reset", "broken pipe", "connection timed out",
+		"network is unreachable"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// runStream runs the ffmpeg streaming command, passing its stderr through and keeping the tail of it.
+// a failure caused by a known Icecast rejection is reported as an actionable error.
+func (p *FFmpegAudioProcessor) runStream(cmd *exec.Cmd, config podcast.Config) error {
+	_, err := p.runStreamTail(cmd, config)
+	return err
+}
+
+// runStreamTail is runStream also returning the tail of the ffmpeg stderr
+func (p *FFmpegAudioProcessor) runStreamTail(cmd *exec.Cmd, config podcast.Config) (string, error) {
+	stderr := &tailBuffer{max: stderrTailSize}
+	cmd.Stdout = p.stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	if err := cmd.Run(); err != nil {
+		if icecastErr := icecastError(stderr.String(), config, p.streamFormat.contentType()); icecastErr != nil {
+			return stderr.String(), fmt.Errorf("ffmpeg streaming failed: %w: %w", icecastErr, err)
+		}
+		return stderr.String(), fmt.Errorf("ffmpeg streaming failed: %w", err)
+	}
+	return stderr.String(), nil
+}
+
+// icecastError translates Icecast rejections reported in ffmpeg stderr into errors saying what to fix.
+// it returns nil when stderr holds no known Icecast error.
+func icecastError(stderr string, config podcast.Config, contentType string) error {
+	msg := strings.ToLower(stderr)
+	switch {
+	case strings.Contains(msg, "http error 401"), strings.Contains(msg, "unauthorized"):
+		return fmt.Errorf("icecast at %s rejected the credentials of user %q, check -user and -pass",
+			config.IcecastURL, config.IcecastUser)
+	case strings.Contains(msg, "mountpoint in use"):
+		return fmt.Errorf("mount %s is already used by another source, stop it or choose another -mount",
+			mountList(config))
+	case strings.Contains(msg, "content-type not supported"), strings.Contains(msg, "no content-type given"),
+		strings.Contains(msg, "http error 415"):
+		return fmt.Errorf("icecast refused content type %s on mount %s, check that -format matches the mount",
+			contentType, mountList(config))
+	case strings.Contains(msg, "too many sources"):
+		return fmt.Errorf("icecast at %s has too many sources connected, raise its source limit or stop another source",
+			config.IcecastURL)
+	case strings.Contains(msg, "http error 403"), strings.Contains(msg, "forbidden"):
+		return fmt.Errorf("icecast refused mount %s, check that it is allowed for sources", mountList(config))
+	case strings.Contains(msg, "connection refused"):
+		return fmt.Errorf("can't connect to Icecast at %s, check -icecast and that the server is running",
+			config.IcecastURL)
+	}
+	return nil
+}
+
+// mountList returns the mounts of the stream separated by commas, ffmpeg doesn't tell which one failed
+func mountList(config podcast.Config) string {
+	mounts := make([]string, 0, len(config.Mounts()))
+	for _, target := range config.Mounts() {
+		mounts = append(mounts, target.Mount)
+	}
+	return strings.Join(mounts, ", ")
+}
+
+// tailBuffer is a writer keeping only the last max bytes written
+type tailBuffer struct {
+	buf []byte
+	max int
+}
+
+// Write appends p, dropping the oldest bytes over the limit
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = b.buf[over:]
+	}
+	return len(p), nil
+}
+
+// String returns the kept bytes
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,93 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ID3v2.4 layout constants
+const (
+	id3HeaderSize   = 10
+	id3FrameHeader  = 10
+	id3Version      = 4
+	id3EncodingUTF8 = 3
+	id3TitleFrame   = "TIT2"
+)
+
+// WithID3Title prepends an ID3v2.4 tag holding the title to mp3 data.
+// mp3 decoders skip the tag, so the audio is unchanged while the title is visible in any tag reader.
+func WithID3Title(data []byte, title string) []byte {
+	text := append([]byte{id3EncodingUTF8}, title...)
+
+	frame := make([]byte, 0, id3FrameHeader+len(text))
+	frame = append(frame, id3TitleFrame...)
+	frame = append(frame, syncsafe(len(text))...)
+	frame = append(frame, 0, 0) // frame flags
+	frame = append(frame, text...)
+
+	result := make([]byte, 0, id3HeaderSize+len(frame)+len(data))
+	result = append(result, 'I', 'D', '3', id3Version, 0, 0) // version 2.4.0, no flags
+	result = append(result, syncsafe(len(frame))...)
+	result = append(result, frame...)
+	return append(result, data...)
+}
+
+// ReadID3Title returns the title from the ID3v2.4 tag at the start of mp3 data
+func ReadID3Title(data []byte) (string, error) {
+	if len(data) < id3HeaderSize || string(data[:3]) != "ID3" {
+		return "", errors.New("no ID3 tag")
+	}
+	if data[3] != id3Version {
+		return "", fmt.Errorf("unsupported ID3 version 2.%d", data[3])
+	}
+
+	end := id3HeaderSize + unsyncsafe(data[6:10])
+	if end > len(data) {
+		return "", errors.New("truncated ID3 tag")
+	}
+	for pos := id3HeaderSize; pos+id3FrameHeader <= end; {
+		id := string(data[pos : pos+4])
+		size := unsyncsafe(data[pos+4 : pos+8])
+		body := pos + id3FrameHeader
+		if size == 0 || body+size > end {
+			break // padding or malformed frame
+		}
+		if id == id3TitleFrame && data[body] == id3EncodingUTF8 {
+			return string(data[body+1 : body+size]), nil
+		}
+		pos = body + size
+	}
+	return "", errors.New("no title in ID3 tag")
+}
+
+// syncsafe encodes n as a 4-byte ID3 syncsafe integer, 7 bits per byte
+func syncsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// unsyncsafe decodes a 4-byte ID3 syncsafe integer
+func unsyncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// HasMP3Header reports whether data starts with an mp3 frame header, after an ID3v2 tag if there is one.
+// it checks the frame sync and rejects reserved version, layer, bitrate and sample rate values,
+// so a truncated download or an error page saved as .mp3 is not taken for audio.
+func HasMP3Header(data []byte) bool {
+	if len(data) >= id3HeaderSize && string(data[:3]) == "ID3" {
+		skip := id3HeaderSize + unsyncsafe(data[6:10])
+		if data[5]&0x10 != 0 {
+			skip += id3HeaderSize // footer
+		}
+		if skip > len(data) {
+			return false
+		}
+		data = data[skip:]
+	}
+	if len(data) < 4 || data[0] != 0xff || data[1]&0xe0 != 0xe0 {
+		return false
+	}
+	version, layer := data[1]>>3&0x03, data[1]>>1&0x03
+	bitrate, sampleRate := data[2]>>4, data[2]>>2&0x03
+	return version != 0x01 && layer != 0x00 && bitrate != 0x0f && sampleRate != 0x03
+}
@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithID3Title(t *testing.T) {
+	audioData := []byte{0xff, 0xfb, 0x90, 0x64, 0x00} // mp3 frame header start
+
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{name: "ascii", title: "003 host1"},
+		{name: "cyrillic", title: "012 Алексей"},
+		{name: "long title", title: strings.Repeat("x", 300)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// tagged segment fixture written and read back from disk
+			filename := filepath.Join(t.TempDir(), "segment_003.mp3")
+			require.NoError(t, os.WriteFile(filename, WithID3Title(audioData, test.title), 0o600))
+
+			data, err := os.ReadFile(filename) // #nosec G304 -- test file
+			require.NoError(t, err)
+			title, err := ReadID3Title(data)
+			require.NoError(t, err)
+			assert.Equal(t, test.title, title)
+			assert.Equal(t, audioData, data[len(data)-len(audioData):], "audio follows the tag unchanged")
+		})
+	}
+}
+
+func TestReadID3Title(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr string
+	}{
+		{name: "untagged mp3", data: []byte{0xff, 0xfb, 0x90, 0x64, 0, 0, 0, 0, 0, 0}, expectedErr: "no ID3 tag"},
+		{name: "too short", data: []byte("ID3"), expectedErr: "no ID3 tag"},
+		{name: "older version", data: []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 0}, expectedErr: "unsupported ID3 version 2.3"},
+		{name: "truncated", data: []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 1, 0}, expectedErr: "truncated ID3 tag"},
+		{name: "padding only", data: append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 20}, make([]byte, 20)...),
+			expectedErr: "no title in ID3 tag"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadID3Title(test.data)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestSyncsafe(t *testing.T) {
+	for _, n := range []int{0, 1, 127, 128, 300, 1<<21 + 5, 1<<28 - 1} {
+		encoded := syncsafe(n)
+		for _, b := range encoded {
+			assert.Less(t, b, byte(0x80), "high bit is never set")
+		}
+		assert.Equal(t, n, unsyncsafe(encoded))
+	}
+}
+
+func TestHasMP3Header(t *testing.T) {
+	frame := []byte{0xff, 0xfb, 0x90, 0x64, 0x00} // mpeg-1 layer 3, 128 kbps, 44.1 kHz
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{name: "bare frame", data: frame, expected: true},
+		{name: "frame after id3 tag", data: WithID3Title(frame, "001 Alice"), expected: true},
+		{name: "empty", data: nil},
+		{name: "text", data: []byte("<html>rate limited</html>")},
+		{name: "id3 tag only", data: WithID3Title(nil, "001 Alice")},
+		{name: "truncated id3 tag", data: WithID3Title(frame, "001 Alice")[:12]},
+		{name: "reserved version", data: []byte{0xff, 0xeb, 0x90, 0x64}},
+		{name: "reserved layer", data: []byte{0xff, 0xf9, 0x90, 0x64}},
+		{name: "bad bitrate", data: []byte{0xff, 0xfb, 0xf0, 0x64}},
+		{name: "reserved sample rate", data: []byte{0xff, 0xfb, 0x9c, 0x64}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, HasMP3Header(test.data))
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package mocks
 
 import (
+	"context"
 	"os/exec"
 	"sync"
 )
@@ -17,6 +18,15 @@ import (
 //			GetAudioCommandFunc: func(filename string) (*exec.Cmd, error) {
 //				panic("mock out the GetAudioCommand method")
 //			},
+//			GetConcatCommandFunc: func(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+//				panic("mock out the GetConcatCommand method")
+//			},
+//			GetConcatStreamCommandFunc: func(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+//				panic("mock out the GetConcatStreamCommand method")
+//			},
+//			GetStreamCommandFunc: func(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+//				panic("mock out the GetStreamCommand method")
+//			},
 //		}
 //
 //		// use mockedCommandRunner in code that requires audio.CommandRunner
@@ -27,6 +37,15 @@ type CommandRunnerMock struct {
 	// GetAudioCommandFunc mocks the GetAudioCommand method.
 	GetAudioCommandFunc func(filename string) (*exec.Cmd, error)
 
+	// GetConcatCommandFunc mocks the GetConcatCommand method.
+	GetConcatCommandFunc func(ctx context.Context, ffmpeg string, args []string) *exec.Cmd
+
+	// GetConcatStreamCommandFunc mocks the GetConcatStreamCommand method.
+	GetConcatStreamCommandFunc func(ctx context.Context, ffmpeg string, args []string) *exec.Cmd
+
+	// GetStreamCommandFunc mocks the GetStreamCommand method.
+	GetStreamCommandFunc func(ctx context.Context, ffmpeg string, args []string) *exec.Cmd
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// GetAudioCommand holds details about calls to the GetAudioCommand method.
@@ -34,8 +53,38 @@ type CommandRunnerMock struct {
 			// Filename is the filename argument value.
 			Filename string
 		}
+		// GetConcatCommand holds details about calls to the GetConcatCommand method.
+		GetConcatCommand []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ffmpeg is the ffmpeg argument value.
+			Ffmpeg string
+			// Args is the args argument value.
+			Args []string
+		}
+		// GetConcatStreamCommand holds details about calls to the GetConcatStreamCommand method.
+		GetConcatStreamCommand []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ffmpeg is the ffmpeg argument value.
+			Ffmpeg string
+			// Args is the args argument value.
+			Args []string
+		}
+		// GetStreamCommand holds details about calls to the GetStreamCommand method.
+		GetStreamCommand []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Ffmpeg is the ffmpeg argument value.
+			Ffmpeg string
+			// Args is the args argument value.
+			Args []string
+		}
 	}
-	lockGetAudioCommand sync.RWMutex
+	lockGetAudioCommand        sync.RWMutex
+	lockGetConcatCommand       sync.RWMutex
+	lockGetConcatStreamCommand sync.RWMutex
+	lockGetStreamCommand       sync.RWMutex
 }
 
 // GetAudioCommand calls GetAudioCommandFunc.
@@ -69,3 +118,123 @@ func (mock *CommandRunnerMock) GetAudioCommandCalls() []struct {
 	mock.lockGetAudioCommand.RUnlock()
 	return calls
 }
+
+// GetConcatCommand calls GetConcatCommandFunc.
+func (mock *CommandRunnerMock) GetConcatCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+	if mock.GetConcatCommandFunc == nil {
+		panic("CommandRunnerMock.GetConcatCommandFunc: method is nil but CommandRunner.GetConcatCommand was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Ffmpeg string
+		Args   []string
+	}{
+		Ctx:    ctx,
+		Ffmpeg: ffmpeg,
+		Args:   args,
+	}
+	mock.lockGetConcatCommand.Lock()
+	mock.calls.GetConcatCommand = append(mock.calls.GetConcatCommand, callInfo)
+	mock.lockGetConcatCommand.Unlock()
+	return mock.GetConcatCommandFunc(ctx, ffmpeg, args)
+}
+
+// GetConcatCommandCalls gets all the calls that were made to GetConcatCommand.
+// Check the length with:
+//
+//	len(mockedCommandRunner.GetConcatCommandCalls())
+func (mock *CommandRunnerMock) GetConcatCommandCalls() []struct {
+	Ctx    context.Context
+	Ffmpeg string
+	Args   []string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Ffmpeg string
+		Args   []string
+	}
+	mock.lockGetConcatCommand.RLock()
+	calls = mock.calls.GetConcatCommand
+	mock.lockGetConcatCommand.RUnlock()
+	return calls
+}
+
+// GetConcatStreamCommand calls GetConcatStreamCommandFunc.
+func (mock *CommandRunnerMock) GetConcatStreamCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+	if mock.GetConcatStreamCommandFunc == nil {
+		panic("CommandRunnerMock.GetConcatStreamCommandFunc: method is nil but CommandRunner.GetConcatStreamCommand was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Ffmpeg string
+		Args   []string
+	}{
+		Ctx:    ctx,
+		Ffmpeg: ffmpeg,
+		Args:   args,
+	}
+	mock.lockGetConcatStreamCommand.Lock()
+	mock.calls.GetConcatStreamCommand = append(mock.calls.GetConcatStreamCommand, callInfo)
+	mock.lockGetConcatStreamCommand.Unlock()
+	return mock.GetConcatStreamCommandFunc(ctx, ffmpeg, args)
+}
+
+// GetConcatStreamCommandCalls gets all the calls that were made to GetConcatStreamCommand.
+// Check the length with:
+//
+//	len(mockedCommandRunner.GetConcatStreamCommandCalls())
+func (mock *CommandRunnerMock) GetConcatStreamCommandCalls() []struct {
+	Ctx    context.Context
+	Ffmpeg string
+	Args   []string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Ffmpeg string
+		Args   []string
+	}
+	mock.lockGetConcatStreamCommand.RLock()
+	calls = mock.calls.GetConcatStreamCommand
+	mock.lockGetConcatStreamCommand.RUnlock()
+	return calls
+}
+
+// GetStreamCommand calls GetStreamCommandFunc.
+func (mock *CommandRunnerMock) GetStreamCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+	if mock.GetStreamCommandFunc == nil {
+		panic("CommandRunnerMock.GetStreamCommandFunc: method is nil but CommandRunner.GetStreamCommand was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Ffmpeg string
+		Args   []string
+	}{
+		Ctx:    ctx,
+		Ffmpeg: ffmpeg,
+		Args:   args,
+	}
+	mock.lockGetStreamCommand.Lock()
+	mock.calls.GetStreamCommand = append(mock.calls.GetStreamCommand, callInfo)
+	mock.lockGetStreamCommand.Unlock()
+	return mock.GetStreamCommandFunc(ctx, ffmpeg, args)
+}
+
+// GetStreamCommandCalls gets all the calls that were made to GetStreamCommand.
+// Check the length with:
+//
+//	len(mockedCommandRunner.GetStreamCommandCalls())
+func (mock *CommandRunnerMock) GetStreamCommandCalls() []struct {
+	Ctx    context.Context
+	Ffmpeg string
+	Args   []string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Ffmpeg string
+		Args   []string
+	}
+	mock.lockGetStreamCommand.RLock()
+	calls = mock.calls.GetStreamCommand
+	mock.lockGetStreamCommand.RUnlock()
+	return calls
+}
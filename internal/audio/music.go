@@ -0,0 +1,46 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// MixWithBackground mixes musicFile quietly under speechFile into outputFile. the music is lowered by
+// musicGainDB (negative values make it quieter) and looped when it's shorter than the speech.
+func (p *FFmpegAudioProcessor) MixWithBackground(speechFile, musicFile, outputFile string, musicGainDB float64) error {
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", mixArgs(speechFile, musicFile, outputFile, musicGainDB)...)
+	cmd.Stdout = p.stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to mix background music: %w", err)
+	}
+	return nil
+}
+
+// mixArgs builds ffmpeg arguments mixing the music under the speech. the filter graph is
+//
+//	[1:a]volume=GAINdB[bed];[0:a][bed]amix=inputs=2:duration=first:dropout_transition=0:normalize=0[out]
+//
+// volume ducks the music by the gain, amix sums it with the speech. duration=first ends the mix with the speech,
+// normalize=0 keeps the speech at its own level instead of halving both inputs. the music input is read with
+// -stream_loop -1, so a short track repeats until the speech is over.
+func mixArgs(speechFile, musicFile, outputFile string, musicGainDB float64) []string {
+	graph := fmt.Sprintf("[1:a]volume=%sdB[bed];[0:a][bed]amix=inputs=2:duration=first:dropout_transition=0:normalize=0[out]",
+		strconv.FormatFloat(musicGainDB, 'f', 1, 64))
+	return []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", speechFile,
+		"-stream_loop", "-1",
+		"-i", musicFile,
+		"-filter_complex", graph,
+		"-map", "[out]",
+		"-c:a", "libmp3lame",
+		"-b:a", reencodeBitrate,
+		outputFile,
+	}
+}
@@ -0,0 +1,53 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMixArgs(t *testing.T) {
+	args := mixArgs("speech.mp3", "music.mp3", "episode.mp3", -18)
+	cmdline := strings.Join(args, " ")
+	assert.Contains(t, cmdline, "-i speech.mp3 -stream_loop -1 -i music.mp3", "music is looped")
+	assert.Contains(t, cmdline,
+		"-filter_complex [1:a]volume=-18.0dB[bed];[0:a][bed]amix=inputs=2:duration=first:dropout_transition=0:normalize=0[out]")
+	assert.Contains(t, cmdline, "-map [out] -c:a libmp3lame -b:a 128k")
+	assert.Equal(t, "episode.mp3", args[len(args)-1])
+
+	args = mixArgs("speech.mp3", "music.mp3", "episode.mp3", -6.5)
+	assert.Contains(t, strings.Join(args, " "), "volume=-6.5dB[bed]")
+}
+
+func TestFFmpegAudioProcessor_MixWithBackground(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg records its arguments
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n", argsFile)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0o700)) // #nosec G306 -- test executable
+	t.Setenv("PATH", binDir)
+
+	processor := NewFFmpegAudioProcessor()
+	require.NoError(t, processor.MixWithBackground("speech.mp3", "music.mp3", "episode.mp3", -20))
+	args, err := os.ReadFile(argsFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(mixArgs("speech.mp3", "music.mp3", "episode.mp3", -20), " ")+"\n", string(args))
+
+	t.Run("ffmpeg failure", func(t *testing.T) {
+		failing := "#!/bin/sh\nexit 1\n"
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(failing), 0o700)) // #nosec G306 -- test executable
+		err := processor.MixWithBackground("speech.mp3", "music.mp3", "episode.mp3", -20)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to mix background music")
+	})
+}
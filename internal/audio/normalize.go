@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// EBU R128 loudness targets of the normalization, -16 LUFS is the common level of podcasts
+const (
+	loudnessTarget      = -16.0 // integrated loudness, LUFS
+	loudnessRangeTarget = 11.0  // loudness range, LU
+	truePeakTarget      = -1.5  // maximum true peak, dBTP
+)
+
+// ttsSampleRate is the sample rate of synthesized speech, loudnorm resamples to 192 kHz internally,
+// so the normalized segment is written back at the rate of the others and can still be joined by stream copy
+const ttsSampleRate = "24000"
+
+// Normalize brings every file to the same loudness with the ffmpeg loudnorm filter (EBU R128) and returns
+// the normalized files, in the same order, written to tempDir as normalized_<name>. the originals are left untouched.
+// up to the processor concurrency files are normalized in parallel.
+func (p *FFmpegAudioProcessor) Normalize(inputFiles []string, tempDir string) ([]string, error) {
+	outputFiles := make([]string, len(inputFiles))
+	errs := make([]error, len(inputFiles))
+	sem := make(chan struct{}, max(p.probeWorkers, 1))
+	var wg sync.WaitGroup
+	for i, inputFile := range inputFiles {
+		outputFiles[i] = filepath.Join(tempDir, "normalized_"+filepath.Base(inputFile))
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = p.normalizeFile(inputFile, outputFiles[i])
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to normalize %s: %w", inputFiles[i], err)
+		}
+	}
+	return outputFiles, nil
+}
+
+// normalizeFile runs the loudnorm filter on a single file
+func (p *FFmpegAudioProcessor) normalizeFile(inputFile, outputFile string) error {
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", normalizeArgs(inputFile, outputFile)...)
+	cmd.Stdout = p.stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg loudnorm failed: %w", err)
+	}
+	return nil
+}
+
+// normalizeArgs builds ffmpeg arguments normalizing the loudness of inputFile in a single pass,
+// segments are a few seconds long, so the dynamic mode of loudnorm is precise enough
+func normalizeArgs(inputFile, outputFile string) []string {
+	filter := fmt.Sprintf("loudnorm=I=%s:LRA=%s:TP=%s", formatDB(loudnessTarget), formatDB(loudnessRangeTarget),
+		formatDB(truePeakTarget))
+	return []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputFile,
+		"-af", filter,
+		"-ar", ttsSampleRate,
+		"-c:a", "libmp3lame",
+		"-b:a", reencodeBitrate,
+		outputFile,
+	}
+}
+
+// formatDB formats a level for an ffmpeg filter option, e.g. -16 or -1.5
+func formatDB(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
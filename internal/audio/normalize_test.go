@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeArgs(t *testing.T) {
+	args := normalizeArgs("segment_000.mp3", "normalized_segment_000.mp3")
+	cmdline := strings.Join(args, " ")
+	assert.Contains(t, cmdline, "-i segment_000.mp3 -af loudnorm=I=-16:LRA=11:TP=-1.5", "EBU R128 targets")
+	assert.Contains(t, cmdline, "-ar 24000 -c:a libmp3lame -b:a 128k", "written back at the speech sample rate")
+	assert.Equal(t, "normalized_segment_000.mp3", args[len(args)-1])
+}
+
+func TestFFmpegAudioProcessor_Normalize(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg appends its arguments to a log
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\n", argsFile)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0o700)) // #nosec G306 -- test executable
+	t.Setenv("PATH", binDir)
+
+	segmentDir, tempDir := t.TempDir(), t.TempDir()
+	inputs := []string{filepath.Join(segmentDir, "segment_000.mp3"), filepath.Join(segmentDir, "segment_001.mp3")}
+
+	processor := NewFFmpegAudioProcessor()
+	processor.SetConcurrency(2)
+	outputs, err := processor.Normalize(inputs, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(tempDir, "normalized_segment_000.mp3"), filepath.Join(tempDir, "normalized_segment_001.mp3")},
+		outputs, "new files in the input order")
+
+	data, err := os.ReadFile(argsFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	for i, input := range inputs {
+		assert.Contains(t, lines, strings.Join(normalizeArgs(input, outputs[i]), " "))
+	}
+
+	t.Run("ffmpeg failure", func(t *testing.T) {
+		failing := "#!/bin/sh\nexit 1\n"
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(failing), 0o700)) // #nosec G306 -- test executable
+		_, err := processor.Normalize(inputs, tempDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to normalize "+inputs[0])
+	})
+}
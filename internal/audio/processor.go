@@ -1,12 +1,18 @@
 package audio
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/radio-t/ai-podcast/podcast"
@@ -14,21 +20,149 @@ import (
 
 //go:generate moq -out mocks/command_runner.go -pkg mocks -skip-ensure -fmt goimports . CommandRunner
 
-// CommandRunner provides OS-specific command creation for audio playback
+// defaultFFmpeg is the ffmpeg executable looked up in PATH
+const defaultFFmpeg = "ffmpeg"
+
+// silence encoding parameters, matching the mp3 output of OpenAI TTS
+const (
+	silenceSampleRate = 24000
+	silenceBitrate    = "64k"
+)
+
+// CommandRunner creates the commands run by the processor: the OS-specific audio player,
+// and the ffmpeg concatenating segments or streaming them to Icecast, killed when ctx is canceled
 type CommandRunner interface {
 	GetAudioCommand(filename string) (*exec.Cmd, error)
+	GetConcatCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd
+	GetStreamCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd
+	GetConcatStreamCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd
 }
 
 // FFmpegAudioProcessor implements audio processing using ffmpeg
 type FFmpegAudioProcessor struct {
-	cmdRunner CommandRunner
+	FFmpegPath   string          // ffmpeg executable, ffprobe is looked up next to it
+	ctx          context.Context // kills the running ffmpeg, ffprobe or player when canceled
+	cmdRunner    CommandRunner
+	concatMode   ConcatMode
+	streamFormat StreamFormat
+	encoding     Encoding
+	probeWorkers int
+	probe        func(filename string) (streamParams, error)
+	lookPath     func(file string) (string, error)
+	goos         string       // OS the dependencies are checked for
+	stdout       io.Writer    // output of ffmpeg and the player, kept off stdout when it carries the audio
+	logger       *slog.Logger // destination of reconnects and concat re-encoding notes
+}
+
+// Option configures the processor created by NewFFmpegAudioProcessor
+type Option func(*FFmpegAudioProcessor)
+
+// WithFFmpegPath sets the ffmpeg executable, e.g. /opt/ffmpeg/bin/ffmpeg or ffmpeg.exe, empty keeps ffmpeg from PATH
+func WithFFmpegPath(path string) Option {
+	return func(p *FFmpegAudioProcessor) {
+		if path != "" {
+			p.FFmpegPath = path
+		}
+	}
+}
+
+// WithStdout sets the writer receiving the output of ffmpeg and the player, e.g. stderr when stdout carries
+// the audio, nil keeps os.Stdout
+func WithStdout(w io.Writer) Option {
+	return func(p *FFmpegAudioProcessor) {
+		if w != nil {
+			p.stdout = w
+		}
+	}
+}
+
+// WithLogger sets the logger of reconnects and concat re-encoding notes, nil keeps the default one
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *FFmpegAudioProcessor) {
+		if logger != nil {
+			p.logger = logger
+		}
+	}
 }
 
 // NewFFmpegAudioProcessor creates a new FFmpeg audio processor
-func NewFFmpegAudioProcessor() *FFmpegAudioProcessor {
-	return &FFmpegAudioProcessor{
-		cmdRunner: &DefaultCommandRunner{},
+func NewFFmpegAudioProcessor(opts ...Option) *FFmpegAudioProcessor {
+	p := &FFmpegAudioProcessor{
+		FFmpegPath:   defaultFFmpeg,
+		cmdRunner:    &DefaultCommandRunner{},
+		concatMode:   ConcatAuto,
+		streamFormat: FormatMP3,
+		probeWorkers: 1,
+		lookPath:     exec.LookPath,
+		goos:         runtime.GOOS,
+		stdout:       os.Stdout,
+		logger:       slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	p.probe = p.probeStream
+	return p
+}
+
+// SetContext sets the context of every command started by the processor, canceling it kills the command,
+// e.g. on Ctrl-C, so no ffmpeg is left streaming or writing after the program exits
+func (p *FFmpegAudioProcessor) SetContext(ctx context.Context) {
+	p.ctx = ctx
+}
+
+// command creates the command killed when the context of the processor is canceled.
+// ffmpeg and ffprobe are run from the configured path.
+func (p *FFmpegAudioProcessor) command(name string, args ...string) *exec.Cmd {
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	return exec.CommandContext(p.context(), p.binary(name), args...)
+}
+
+// binary returns the executable of the tool: the configured ffmpeg, ffprobe from the same directory
+// with the same extension, e.g. C:\ffmpeg\bin\ffprobe.exe next to C:\ffmpeg\bin\ffmpeg.exe
+func (p *FFmpegAudioProcessor) binary(name string) string {
+	if p.FFmpegPath == "" || p.FFmpegPath == defaultFFmpeg {
+		return name
+	}
+	switch name {
+	case defaultFFmpeg:
+		return p.FFmpegPath
+	case "ffprobe":
+		dir, file := filepath.Split(p.FFmpegPath)
+		if dir == "" {
+			return name + filepath.Ext(file) // a bare ffmpeg.exe is looked up in PATH, so is ffprobe.exe
+		}
+		return filepath.Join(dir, name+filepath.Ext(file))
+	}
+	return name
+}
+
+// context returns the context of the processor, the background one when it's not set
+func (p *FFmpegAudioProcessor) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// SetConcatMode sets how segments are joined by Concatenate and StreamFromConcat
+func (p *FFmpegAudioProcessor) SetConcatMode(mode ConcatMode) {
+	p.concatMode = mode
+}
+
+// SetConcurrency sets how many ffprobe processes inspect segments in parallel before concatenation
+func (p *FFmpegAudioProcessor) SetConcurrency(workers int) {
+	p.probeWorkers = max(workers, 1)
+}
+
+// SetStreamFormat sets the codec and content type of the Icecast stream
+func (p *FFmpegAudioProcessor) SetStreamFormat(format StreamFormat) {
+	p.streamFormat = format
+}
+
+// SetEncoding sets the mp3 encoding of the joined episode and the Icecast stream, replacing stream copy
+func (p *FFmpegAudioProcessor) SetEncoding(encoding Encoding) {
+	p.encoding = encoding
 }
 
 // Play plays an audio file using the system's default audio player
@@ -46,11 +180,16 @@ func (p *FFmpegAudioProcessor) Play(filename string) error {
 		return fmt.Errorf("failed to get audio command: %w", err)
 	}
 
-	// run the command and wait for it to finish
-	cmd.Stdout = os.Stdout
+	// run the command and wait for it to finish, the player comes from the runner, so it's killed on cancel here
+	cmd.Stdout = p.stdout
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error playing audio: %w", err)
+	}
+	stop := context.AfterFunc(p.context(), func() { _ = cmd.Process.Kill() })
+	defer stop()
+	if err := cmd.Wait(); err != nil {
 		return fmt.Errorf("error playing audio: %w", err)
 	}
 
@@ -59,20 +198,24 @@ func (p *FFmpegAudioProcessor) Play(filename string) error {
 
 // Concatenate uses ffmpeg to concatenate audio files into a single output file
 func (p *FFmpegAudioProcessor) Concatenate(files []string, outputFile string) error {
+	return p.concatenate(files, []string{outputFile}, p.stdout)
+}
+
+// ConcatenateTo concatenates multiple audio files and writes the mp3 to w, e.g. stdout for piping.
+// ffmpeg diagnostics go to stderr, so w receives the audio only.
+func (p *FFmpegAudioProcessor) ConcatenateTo(files []string, w io.Writer) error {
+	return p.concatenate(files, []string{"-f", "mp3", "pipe:1"}, w)
+}
+
+// concatenate runs ffmpeg concatenation with the given output arguments and stdout
+func (p *FFmpegAudioProcessor) concatenate(files, outputArgs []string, stdout io.Writer) error {
 	// create a temporary concat file
 	tempDir := os.TempDir()
 	concatFile := fmt.Sprintf("%s/concat_%d.txt", tempDir, time.Now().Unix())
 	defer os.Remove(concatFile)
 
-	// write the concat file
-	var concatContent strings.Builder
-	for _, file := range files {
-		// escape single quotes in filenames for ffmpeg concat format
-		safeFile := strings.ReplaceAll(file, "'", "'\\''")
-		concatContent.WriteString(fmt.Sprintf("file '%s'\n", safeFile))
-	}
-	if err := os.WriteFile(concatFile, []byte(concatContent.String()), 0o600); err != nil {
-		return fmt.Errorf("failed to write concat file: %w", err)
+	if err := writeConcatFile(concatFile, files); err != nil {
+		return err
 	}
 
 	// run ffmpeg to concatenate
@@ -83,13 +226,12 @@ func (p *FFmpegAudioProcessor) Concatenate(files []string, outputFile string) er
 		"-f", "concat",
 		"-safe", "0",
 		"-i", concatFile,
-		"-c", "copy",
-		outputFile,
 	}
+	args = append(args, p.codecArgs(files)...)
+	args = append(args, outputArgs...)
 
-	// #nosec G204 -- Arguments are constructed internally, not from external input
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = os.Stdout
+	cmd := p.cmdRunner.GetConcatCommand(p.context(), p.binary(defaultFFmpeg), args)
+	cmd.Stdout = stdout
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
@@ -99,89 +241,224 @@ func (p *FFmpegAudioProcessor) Concatenate(files []string, outputFile string) er
 	return nil
 }
 
-// StreamToIcecast streams audio to an Icecast server
+// StreamToIcecast streams audio to an Icecast server, reconnecting when the connection is refused or dropped
 func (p *FFmpegAudioProcessor) StreamToIcecast(inputFile string, config podcast.Config) error {
-	// construct Icecast URL with authentication
-	u := url.URL{
-		Scheme: "icecast",
-		User:   url.UserPassword(config.IcecastUser, config.IcecastPass),
-		Host:   config.IcecastURL,
-		Path:   config.IcecastMount,
+	// build the ffmpeg command
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-re", // read input at native frame rate
+		"-i", inputFile,
 	}
-	icecastURL := u.String()
+	args = append(args, p.icecastOutputArgs(config, p.streamCodecArgs)...)
+	return p.streamWithReconnect(func() *exec.Cmd {
+		return p.cmdRunner.GetStreamCommand(p.context(), p.binary(defaultFFmpeg), args)
+	}, config)
+}
 
+// StreamFromConcat streams audio files listed in a concat file to Icecast. when the connection is refused or dropped
+// the stream is restarted from the first file, up to config.StreamRetries times.
+func (p *FFmpegAudioProcessor) StreamFromConcat(concatFile string, config podcast.Config) error {
 	// build the ffmpeg command
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "concat",
+		"-safe", "0",
+		"-i", concatFile,
+	}
+	// segments are probed only when they can be copied
+	args = append(args, p.icecastOutputArgs(config, func() []string { return p.concatFileCodecArgs(concatFile) })...)
+	return p.streamWithReconnect(func() *exec.Cmd {
+		return p.cmdRunner.GetConcatStreamCommand(p.context(), p.binary(defaultFFmpeg), args)
+	}, config)
+}
+
+// StreamFromReader streams MP3 data read from r to Icecast at native rate.
+// reading is paced by playback, so a slow reader applies backpressure to whoever writes into r.
+// the data read can't be replayed, so unlike the file streams it isn't reconnected.
+func (p *FFmpegAudioProcessor) StreamFromReader(r io.Reader, config podcast.Config) error {
 	args := []string{
 		"-hide_banner",
 		"-loglevel", "error",
 		"-re", // read input at native frame rate
-		"-i", inputFile,
-		"-c", "copy",
-		"-content_type", "audio/mpeg",
-		icecastURL,
+		"-f", "mp3",
+		"-i", "pipe:0",
 	}
+	args = append(args, p.icecastOutputArgs(config, p.streamCodecArgs)...)
 
-	// #nosec G204 -- Arguments are constructed internally, not from external input
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := p.cmdRunner.GetStreamCommand(p.context(), p.binary(defaultFFmpeg), args)
+	cmd.Stdin = r
+	return p.runStream(cmd, config)
+}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("ffmpeg streaming failed: %w", err)
+// icecastOutputArgs returns an output of every mount of the config, each encoded at the bitrate of its mount,
+// so one ffmpeg feeds all the mounts. mp3CodecArgs are called once, only when an mp3 mount keeps the segment bitrate.
+func (p *FFmpegAudioProcessor) icecastOutputArgs(config podcast.Config, mp3CodecArgs func() []string) []string {
+	codecArgs := sync.OnceValue(mp3CodecArgs)
+	var args []string
+	for _, target := range config.Mounts() {
+		var mp3Args []string
+		if p.streamFormat == FormatMP3 && target.Bitrate == "" {
+			mp3Args = codecArgs()
+		}
+		args = append(args, p.streamFormat.outputArgs(mp3Args, target.Bitrate)...)
+		args = append(args, buildIcecastURL(config, target.Mount))
 	}
+	return args
+}
 
-	return nil
+// streamCodecArgs returns the codec arguments of an mp3 input streamed as a whole, copied as is
+// unless an encoding is set
+func (p *FFmpegAudioProcessor) streamCodecArgs() []string {
+	if args := p.encoding.args(); args != nil {
+		return args
+	}
+	return []string{"-c", "copy"}
 }
 
-// StreamFromConcat streams audio files listed in a concat file to Icecast
-func (p *FFmpegAudioProcessor) StreamFromConcat(concatFile string, config podcast.Config) error {
-	// construct Icecast URL with authentication
+// buildIcecastURL constructs the Icecast URL of the mount with authentication
+func buildIcecastURL(config podcast.Config, mount string) string {
 	u := url.URL{
 		Scheme: "icecast",
 		User:   url.UserPassword(config.IcecastUser, config.IcecastPass),
 		Host:   config.IcecastURL,
-		Path:   config.IcecastMount,
+		Path:   mount,
 	}
-	icecastURL := u.String()
+	return u.String()
+}
 
-	// build the ffmpeg command
-	args := []string{
+// InsertSilence generates a silent MP3 of the given duration in tempDir and returns its path.
+// the silence is encoded as 24kHz mono, matching the speech returned by OpenAI TTS.
+func (p *FFmpegAudioProcessor) InsertSilence(durationMs int, tempDir string) (string, error) {
+	if durationMs <= 0 {
+		return "", fmt.Errorf("invalid silence duration: %d ms", durationMs)
+	}
+
+	outputFile := fmt.Sprintf("%s/silence_%d.mp3", tempDir, durationMs)
+	args := silenceArgs(durationMs, outputFile)
+
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", args...)
+	cmd.Stdout = p.stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to generate silence: %w", err)
+	}
+
+	return outputFile, nil
+}
+
+// silenceArgs builds the ffmpeg arguments of a silent mp3. the sample rate and the channel layout match the speech
+// segments, otherwise the concat demuxer can't join them with stream copy and the -concat-mode auto re-encodes
+// the whole episode.
+func silenceArgs(durationMs int, outputFile string) []string {
+	return []string{
+		"-y",
 		"-hide_banner",
 		"-loglevel", "error",
-		"-f", "concat",
-		"-safe", "0",
-		"-i", concatFile,
-		"-c", "copy",
-		"-content_type", "audio/mpeg",
-		icecastURL,
+		"-f", "lavfi",
+		"-i", fmt.Sprintf("anullsrc=r=%d:cl=mono", silenceSampleRate),
+		"-t", strconv.FormatFloat(float64(durationMs)/1000, 'f', 3, 64),
+		"-c:a", "libmp3lame",
+		"-b:a", silenceBitrate,
+		outputFile,
+	}
+}
+
+// Duration returns the duration of an audio file in seconds as reported by ffprobe
+func (p *FFmpegAudioProcessor) Duration(filename string) (float64, error) {
+	args := []string{
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		filename,
 	}
 
 	// #nosec G204 -- Arguments are constructed internally, not from external input
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = os.Stdout
+	out, err := p.command("ffprobe", args...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe audio duration: %w", err)
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse audio duration %q: %w", strings.TrimSpace(string(out)), err)
+	}
+	return duration, nil
+}
+
+// Trim cuts duration seconds of inputFile starting at start seconds into a new mp3 outputFile.
+// the clip is re-encoded, so it starts exactly at start rather than at the nearest frame.
+func (p *FFmpegAudioProcessor) Trim(inputFile, outputFile string, start, duration float64) error {
+	if duration <= 0 {
+		return fmt.Errorf("invalid trim duration: %.3f s", duration)
+	}
+
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", trimArgs(inputFile, outputFile, start, duration)...)
+	cmd.Stdout = p.stdout
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("ffmpeg streaming failed: %w", err)
+		return fmt.Errorf("failed to trim audio: %w", err)
 	}
-
 	return nil
 }
 
+// trimArgs builds ffmpeg arguments cutting duration seconds from start of inputFile
+func trimArgs(inputFile, outputFile string, start, duration float64) []string {
+	return []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(duration, 'f', 3, 64),
+		"-i", inputFile,
+		"-c:a", "libmp3lame",
+		"-b:a", reencodeBitrate,
+		outputFile,
+	}
+}
+
 // CreateConcatFile creates a concatenation file for ffmpeg
 func CreateConcatFile(tempDir string, audioFiles []string) (string, error) {
 	concatFile := fmt.Sprintf("%s/concat.txt", tempDir)
+	if err := writeConcatFile(concatFile, audioFiles); err != nil {
+		return "", err
+	}
+	return concatFile, nil
+}
+
+// writeConcatFile writes the list of files in ffmpeg concat demuxer format, one "file" directive per line.
+// every path is single-quoted: inside quotes ffmpeg takes all characters literally, backslashes included,
+// and a quote is written as a closing quote, a backslash-escaped quote and a reopening quote. line breaks can't be represented
+// because the demuxer reads directives line by line, so such paths are rejected.
+func writeConcatFile(concatFile string, files []string) error {
 	var concatContent strings.Builder
-	for _, file := range audioFiles {
-		// escape single quotes in filenames for ffmpeg concat format
-		safeFile := strings.ReplaceAll(file, "'", "'\\''")
-		concatContent.WriteString(fmt.Sprintf("file '%s'\n", safeFile))
+	for _, file := range files {
+		safeFile, err := escapeConcatPath(file)
+		if err != nil {
+			return err
+		}
+		concatContent.WriteString(fmt.Sprintf("file %s\n", safeFile))
 	}
-	err := os.WriteFile(concatFile, []byte(concatContent.String()), 0o600)
-	if err != nil {
-		return "", fmt.Errorf("failed to write concat file: %w", err)
+	if err := os.WriteFile(concatFile, []byte(concatContent.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write concat file: %w", err)
 	}
-	return concatFile, nil
+	return nil
+}
+
+// escapeConcatPath quotes a path for the ffmpeg concat demuxer
+func escapeConcatPath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("empty path in concat list")
+	}
+	if strings.ContainsAny(path, "\n\r\x00") {
+		return "", fmt.Errorf("path %q contains characters unsupported by ffmpeg concat", path)
+	}
+	return "'" + strings.ReplaceAll(path, "'", "'\\''") + "'", nil
 }
 
 // DefaultCommandRunner is the default implementation of CommandRunner
@@ -201,8 +478,7 @@ func (r *DefaultCommandRunner) GetAudioCommand(filename string) (*exec.Cmd, erro
 		return exec.Command("cmd", "/C", "start", filename), nil
 	case "linux":
 		// try several common audio players
-		players := []string{"mpv", "mplayer", "ffplay", "aplay"}
-		for _, player := range players {
+		for _, player := range linuxPlayers {
 			if _, err := exec.LookPath(player); err == nil {
 				if player == "aplay" {
 					// #nosec G204 -- Player is selected from a whitelist of known audio players
@@ -218,3 +494,21 @@ func (r *DefaultCommandRunner) GetAudioCommand(filename string) (*exec.Cmd, erro
 		return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
 	}
 }
+
+// GetConcatCommand returns the ffmpeg command joining the segments listed in a concat file
+func (r *DefaultCommandRunner) GetConcatCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	return exec.CommandContext(ctx, ffmpeg, args...)
+}
+
+// GetStreamCommand returns the ffmpeg command streaming a file or stdin to Icecast
+func (r *DefaultCommandRunner) GetStreamCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	return exec.CommandContext(ctx, ffmpeg, args...)
+}
+
+// GetConcatStreamCommand returns the ffmpeg command streaming the segments listed in a concat file to Icecast
+func (r *DefaultCommandRunner) GetConcatStreamCommand(ctx context.Context, ffmpeg string, args []string) *exec.Cmd {
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	return exec.CommandContext(ctx, ffmpeg, args...)
+}
@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Tags is the metadata written into the ID3 tag of a saved episode
+type Tags struct {
+	Title    string // episode title, the discussion title
+	Artist   string // show or author name, optional
+	Album    string // show name, optional
+	Date     string // publish date, e.g. 2025-06-01
+	Cover    string // jpeg or png cover image attached as the front cover, optional
+	Chapters string // ffmpeg metadata file with the chapters written as ID3 chapter frames, optional
+}
+
+// WriteTags writes the tags into the mp3 file. ffmpeg copies the audio into a file next to it, which replaces
+// the original, so the audio isn't re-encoded.
+func (p *FFmpegAudioProcessor) WriteTags(filename string, tags Tags) error {
+	taggedFile := strings.TrimSuffix(filename, filepath.Ext(filename)) + "_tagged.mp3"
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", tagArgs(filename, taggedFile, tags)...)
+	cmd.Stdout = p.stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(taggedFile)
+		return fmt.Errorf("failed to tag audio: %w", err)
+	}
+	if err := os.Rename(taggedFile, filename); err != nil {
+		_ = os.Remove(taggedFile)
+		return fmt.Errorf("failed to replace tagged audio: %w", err)
+	}
+	return nil
+}
+
+// tagArgs builds ffmpeg arguments copying the audio of inputFile with the tags into outputFile.
+// ID3v2.3 is written as the version most players read cover pictures from, empty tags are skipped.
+func tagArgs(inputFile, outputFile string, tags Tags) []string {
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputFile,
+	}
+	if tags.Cover != "" {
+		args = append(args, "-i", tags.Cover, "-map", "0:a", "-map", "1:v")
+	}
+	if tags.Chapters != "" {
+		// the metadata file is the input after the audio and the cover
+		chaptersInput := 1
+		if tags.Cover != "" {
+			chaptersInput = 2
+		}
+		args = append(args, "-i", tags.Chapters, "-map_chapters", strconv.Itoa(chaptersInput))
+	}
+	args = append(args, "-c", "copy", "-id3v2_version", "3")
+	for _, field := range []struct{ key, value string }{
+		{"title", tags.Title}, {"artist", tags.Artist}, {"album", tags.Album}, {"date", tags.Date},
+	} {
+		if field.value != "" {
+			args = append(args, "-metadata", field.key+"="+field.value)
+		}
+	}
+	if tags.Cover != "" {
+		args = append(args, "-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)",
+			"-disposition:v", "attached_pic")
+	}
+	return append(args, outputFile)
+}
@@ -0,0 +1,88 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagArgs(t *testing.T) {
+	tags := Tags{Title: "Новый релиз Go", Artist: "AI Podcast", Album: "Радио-Т AI", Date: "2025-06-01"}
+	assert.Equal(t, []string{
+		"-y", "-hide_banner", "-loglevel", "error",
+		"-i", "episode.mp3",
+		"-c", "copy", "-id3v2_version", "3",
+		"-metadata", "title=Новый релиз Go",
+		"-metadata", "artist=AI Podcast",
+		"-metadata", "album=Радио-Т AI",
+		"-metadata", "date=2025-06-01",
+		"episode_tagged.mp3",
+	}, tagArgs("episode.mp3", "episode_tagged.mp3", tags))
+
+	t.Run("cover", func(t *testing.T) {
+		tags.Cover = "cover.jpg"
+		cmdline := strings.Join(tagArgs("episode.mp3", "episode_tagged.mp3", tags), " ")
+		assert.Contains(t, cmdline, "-i episode.mp3 -i cover.jpg -map 0:a -map 1:v -c copy -id3v2_version 3")
+		assert.Contains(t, cmdline, "-metadata:s:v title=Album cover -metadata:s:v comment=Cover (front) -disposition:v attached_pic")
+		assert.NotContains(t, cmdline, "libmp3lame", "the audio is copied")
+	})
+
+	t.Run("chapters", func(t *testing.T) {
+		cmdline := strings.Join(tagArgs("episode.mp3", "episode_tagged.mp3", Tags{Title: "Заголовок", Chapters: "chapters.txt"}), " ")
+		assert.Contains(t, cmdline, "-i episode.mp3 -i chapters.txt -map_chapters 1 -c copy")
+
+		tags := Tags{Cover: "cover.png", Chapters: "chapters.txt"}
+		cmdline = strings.Join(tagArgs("episode.mp3", "episode_tagged.mp3", tags), " ")
+		assert.Contains(t, cmdline, "-i episode.mp3 -i cover.png -map 0:a -map 1:v -i chapters.txt -map_chapters 2 -c copy")
+	})
+
+	t.Run("empty tags skipped", func(t *testing.T) {
+		cmdline := strings.Join(tagArgs("episode.mp3", "episode_tagged.mp3", Tags{Title: "Заголовок"}), " ")
+		assert.Contains(t, cmdline, "-metadata title=Заголовок episode_tagged.mp3")
+		assert.NotContains(t, cmdline, "artist=")
+		assert.NotContains(t, cmdline, "-map")
+	})
+}
+
+func TestFFmpegAudioProcessor_WriteTags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg records its arguments and writes the tagged copy to the last argument
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\nfor arg; do out=$arg; done\nprintf 'tagged audio' > \"$out\"\n", argsFile)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0o700)) // #nosec G306 -- test executable
+	t.Setenv("PATH", binDir)
+
+	episode := filepath.Join(t.TempDir(), "episode.mp3")
+	require.NoError(t, os.WriteFile(episode, []byte("audio"), 0o600))
+	processor := NewFFmpegAudioProcessor()
+	require.NoError(t, processor.WriteTags(episode, Tags{Title: "Episode", Date: "2025-06-01"}))
+
+	data, err := os.ReadFile(episode) // #nosec G304 -- test file
+	require.NoError(t, err)
+	assert.Equal(t, "tagged audio", string(data), "the tagged copy replaces the episode")
+	args, err := os.ReadFile(argsFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-i "+episode+" -c copy -id3v2_version 3 -metadata title=Episode -metadata date=2025-06-01")
+	assert.NoFileExists(t, strings.TrimSuffix(episode, ".mp3")+"_tagged.mp3")
+
+	t.Run("ffmpeg failure", func(t *testing.T) {
+		failing := "#!/bin/sh\nexit 1\n"
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(failing), 0o700)) // #nosec G306 -- test executable
+		err := processor.WriteTags(episode, Tags{Title: "Episode"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to tag audio")
+		data, err := os.ReadFile(episode) // #nosec G304 -- test file
+		require.NoError(t, err)
+		assert.Equal(t, "tagged audio", string(data), "the episode is kept")
+	})
+}
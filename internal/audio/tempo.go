@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// ChangeTempo speeds up or slows down every file by the tempo factor with the ffmpeg atempo filter, keeping the pitch,
+// and returns the changed files, in the same order, written to tempDir as tempo_<name>. the originals are left untouched.
+// up to the processor concurrency files are processed in parallel.
+func (p *FFmpegAudioProcessor) ChangeTempo(inputFiles []string, tempo float64, tempDir string) ([]string, error) {
+	if tempo <= 0 {
+		return nil, fmt.Errorf("invalid tempo %v, must be positive", tempo)
+	}
+	outputFiles := make([]string, len(inputFiles))
+	errs := make([]error, len(inputFiles))
+	sem := make(chan struct{}, max(p.probeWorkers, 1))
+	var wg sync.WaitGroup
+	for i, inputFile := range inputFiles {
+		outputFiles[i] = filepath.Join(tempDir, "tempo_"+filepath.Base(inputFile))
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = p.tempoFile(inputFile, outputFiles[i], tempo)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to change tempo of %s: %w", inputFiles[i], err)
+		}
+	}
+	return outputFiles, nil
+}
+
+// tempoFile runs the atempo filter on a single file
+func (p *FFmpegAudioProcessor) tempoFile(inputFile, outputFile string, tempo float64) error {
+	// #nosec G204 -- Arguments are constructed internally, not from external input
+	cmd := p.command("ffmpeg", tempoArgs(inputFile, outputFile, tempo)...)
+	cmd.Stdout = p.stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg atempo failed: %w", err)
+	}
+	return nil
+}
+
+// tempoArgs builds ffmpeg arguments changing the tempo of inputFile, the result is written back
+// at the speech sample rate, so it can still be joined with the other segments by stream copy
+func tempoArgs(inputFile, outputFile string, tempo float64) []string {
+	return []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputFile,
+		"-af", "atempo=" + strconv.FormatFloat(tempo, 'f', 4, 64),
+		"-ar", ttsSampleRate,
+		"-c:a", "libmp3lame",
+		"-b:a", reencodeBitrate,
+		outputFile,
+	}
+}
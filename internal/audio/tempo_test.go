@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempoArgs(t *testing.T) {
+	args := tempoArgs("segment_000.mp3", "tempo_segment_000.mp3", 1.0/1.2)
+	cmdline := strings.Join(args, " ")
+	assert.Contains(t, cmdline, "-i segment_000.mp3 -af atempo=0.8333", "slowed down")
+	assert.Contains(t, cmdline, "-ar 24000 -c:a libmp3lame -b:a 128k", "written back at the speech sample rate")
+	assert.Equal(t, "tempo_segment_000.mp3", args[len(args)-1])
+}
+
+func TestFFmpegAudioProcessor_ChangeTempo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	// fake ffmpeg appends its arguments to a log
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\n", argsFile)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0o700)) // #nosec G306 -- test executable
+	t.Setenv("PATH", binDir)
+
+	segmentDir, tempDir := t.TempDir(), t.TempDir()
+	inputs := []string{filepath.Join(segmentDir, "segment_000.mp3"), filepath.Join(segmentDir, "segment_001.mp3")}
+
+	processor := NewFFmpegAudioProcessor()
+	processor.SetConcurrency(2)
+	outputs, err := processor.ChangeTempo(inputs, 1.25, tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(tempDir, "tempo_segment_000.mp3"), filepath.Join(tempDir, "tempo_segment_001.mp3")},
+		outputs, "new files in the input order")
+
+	data, err := os.ReadFile(argsFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	for i, input := range inputs {
+		assert.Contains(t, lines, strings.Join(tempoArgs(input, outputs[i], 1.25), " "))
+	}
+	assert.Contains(t, lines[0], "atempo=1.2500")
+
+	t.Run("invalid tempo", func(t *testing.T) {
+		_, err := processor.ChangeTempo(inputs, 0, tempDir)
+		require.EqualError(t, err, "invalid tempo 0, must be positive")
+	})
+
+	t.Run("ffmpeg failure", func(t *testing.T) {
+		failing := "#!/bin/sh\nexit 1\n"
+		require.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(failing), 0o700)) // #nosec G306 -- test executable
+		_, err := processor.ChangeTempo(inputs, 1.25, tempDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to change tempo of "+inputs[0])
+	})
+}
@@ -0,0 +1,118 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy defines how many times an operation is attempted and how long to wait between attempts.
+// delays grow exponentially from BaseDelay and are capped by MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first one, values below 1 mean a single attempt
+	BaseDelay   time.Duration // delay before the first retry, doubled on every next retry
+	MaxDelay    time.Duration // upper bound for a single delay, zero means no limit
+	Jitter      float64       // fraction of the delay randomized in both directions, 0..1
+}
+
+// permanentError marks an error that should not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps the error so Do returns it right away without further attempts
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// retryAfterError carries the delay the failed operation asked to wait before the next attempt
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter wraps the error so Do waits the given delay before the next attempt instead of the policy delay,
+// e.g. the delay of a Retry-After header. a non-positive delay keeps the policy delay.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// Do calls fn until it succeeds, returns a permanent error or the attempts are exhausted.
+// the last error is returned as is, cancellation of ctx interrupts the wait between attempts.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	attempts := max(p.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("retry canceled: %w", err)
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		delay := p.Delay(attempt)
+		var after *retryAfterError
+		if errors.As(err, &after) {
+			err = after.err
+			if after.delay > 0 {
+				delay = after.delay
+			}
+		}
+		if attempt >= attempts {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("retry canceled after %d attempts: %w, last error: %w", attempt, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// Delay returns the wait before the given retry, the first retry is 1
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < retry; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		if delay > time.Duration(1<<62)/2 {
+			break // avoid overflow on very large retry numbers
+		}
+		delay *= 2
+	}
+
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		// #nosec G404 -- jitter spreads retries in time, it doesn't need a secure random source
+		delay += time.Duration(float64(delay) * jitter * (2*rand.Float64() - 1))
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
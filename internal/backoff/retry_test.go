@@ -0,0 +1,200 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Do(t *testing.T) {
+	errTemp := errors.New("temporary")
+
+	tests := []struct {
+		name          string
+		policy        RetryPolicy
+		failures      int
+		permanent     bool
+		expectedCalls int
+		expectedErr   string
+	}{
+		{name: "success on first attempt", policy: RetryPolicy{MaxAttempts: 3}, expectedCalls: 1},
+		{name: "success after retries", policy: RetryPolicy{MaxAttempts: 3}, failures: 2, expectedCalls: 3},
+		{name: "attempts exhausted", policy: RetryPolicy{MaxAttempts: 3}, failures: 5, expectedCalls: 3, expectedErr: "temporary"},
+		{name: "zero attempts means single call", policy: RetryPolicy{}, failures: 5, expectedCalls: 1, expectedErr: "temporary"},
+		{name: "permanent error stops retries", policy: RetryPolicy{MaxAttempts: 5}, failures: 5, permanent: true,
+			expectedCalls: 1, expectedErr: "temporary"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.policy.BaseDelay = time.Millisecond
+			calls := 0
+			err := test.policy.Do(context.Background(), func() error {
+				calls++
+				if calls > test.failures {
+					return nil
+				}
+				if test.permanent {
+					return Permanent(errTemp)
+				}
+				return errTemp
+			})
+
+			assert.Equal(t, test.expectedCalls, calls)
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, errTemp)
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestRetryPolicy_DoContextCanceled(t *testing.T) {
+	errTemp := errors.New("temporary")
+
+	t.Run("canceled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}
+		calls := 0
+		start := time.Now()
+		err := policy.Do(ctx, func() error {
+			calls++
+			cancel()
+			return errTemp
+		})
+		require.Error(t, err)
+		require.ErrorIs(t, err, context.Canceled)
+		require.ErrorIs(t, err, errTemp)
+		assert.Equal(t, 1, calls)
+		assert.Less(t, time.Since(start), time.Minute)
+	})
+
+	t.Run("canceled before the first attempt", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		calls := 0
+		err := RetryPolicy{MaxAttempts: 3}.Do(ctx, func() error {
+			calls++
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, calls)
+	})
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		expected []time.Duration
+	}{
+		{name: "exponential growth", policy: RetryPolicy{BaseDelay: time.Second},
+			expected: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{name: "capped by max delay", policy: RetryPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second},
+			expected: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}},
+		{name: "no base delay", policy: RetryPolicy{MaxDelay: time.Second},
+			expected: []time.Duration{0, 0, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, expected := range test.expected {
+				assert.Equal(t, expected, test.policy.Delay(i+1), "retry %d", i+1)
+			}
+		})
+	}
+
+	t.Run("large retry number doesn't overflow", func(t *testing.T) {
+		assert.Positive(t, RetryPolicy{BaseDelay: time.Second}.Delay(200))
+		assert.Equal(t, time.Minute, RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}.Delay(200))
+	})
+}
+
+func TestRetryPolicy_DelayJitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		retry    int
+		min, max time.Duration
+	}{
+		{name: "half jitter", policy: RetryPolicy{BaseDelay: time.Second, Jitter: 0.5},
+			retry: 2, min: time.Second, max: 3 * time.Second},
+		{name: "jitter capped by max delay", policy: RetryPolicy{BaseDelay: time.Second, MaxDelay: 2 * time.Second, Jitter: 0.5},
+			retry: 2, min: time.Second, max: 2 * time.Second},
+		{name: "jitter above 1 is clamped", policy: RetryPolicy{BaseDelay: time.Second, Jitter: 5},
+			retry: 1, min: 0, max: 2 * time.Second},
+		{name: "negative jitter is ignored", policy: RetryPolicy{BaseDelay: time.Second, Jitter: -1},
+			retry: 1, min: time.Second, max: time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for range 100 {
+				delay := test.policy.Delay(test.retry)
+				assert.GreaterOrEqual(t, delay, test.min)
+				assert.LessOrEqual(t, delay, test.max)
+			}
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	require.NoError(t, Permanent(nil))
+
+	errBase := errors.New("bad request")
+	err := Permanent(errBase)
+	require.ErrorIs(t, err, errBase)
+	assert.EqualError(t, err, "bad request")
+}
+
+func TestRetryAfter(t *testing.T) {
+	require.NoError(t, RetryAfter(nil, time.Second))
+
+	errBase := errors.New("throttled")
+	err := RetryAfter(errBase, time.Second)
+	require.ErrorIs(t, err, errBase)
+	assert.EqualError(t, err, "throttled")
+}
+
+func TestRetryPolicy_DoRetryAfter(t *testing.T) {
+	errTemp := errors.New("temporary")
+	// the policy delay is far longer than the test timeout, only the requested delay lets it finish
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}
+
+	var stamps []time.Time
+	err := policy.Do(context.Background(), func() error {
+		stamps = append(stamps, time.Now())
+		if len(stamps) < 3 {
+			return RetryAfter(errTemp, 20*time.Millisecond)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, stamps, 3)
+	for i := 1; i < len(stamps); i++ {
+		assert.GreaterOrEqual(t, stamps[i].Sub(stamps[i-1]), 20*time.Millisecond)
+	}
+
+	t.Run("last error unwrapped", func(t *testing.T) {
+		policy := RetryPolicy{MaxAttempts: 2}
+		err := policy.Do(context.Background(), func() error { return RetryAfter(errTemp, time.Millisecond) })
+		assert.Equal(t, errTemp, err)
+	})
+
+	t.Run("non-positive delay keeps the policy delay", func(t *testing.T) {
+		policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+		calls := 0
+		err := policy.Do(context.Background(), func() error {
+			calls++
+			return RetryAfter(errTemp, 0)
+		})
+		require.ErrorIs(t, err, errTemp)
+		assert.Equal(t, 2, calls)
+	})
+}
@@ -0,0 +1,79 @@
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// articleCache keeps the extracted text and title of fetched articles on disk, one JSON file per URL
+// and request variant, so experiments with the same article don't download it again. the variant holds
+// the request settings changing what the site returns, e.g. the User-Agent, so other settings fetch again.
+type articleCache struct {
+	dir string
+	ttl time.Duration // age after which an entry is fetched again, zero keeps entries forever
+	now func() time.Time
+}
+
+// cachedArticle is the cache file of an article
+type cachedArticle struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// get returns the cached text and title of the URL fetched with the variant, ok is false when there is no fresh entry.
+// unreadable entries are treated as missing and replaced by the next fetch.
+func (c *articleCache) get(urlStr, variant string) (text, title string, ok bool) {
+	data, err := os.ReadFile(c.path(urlStr, variant)) // #nosec G304 -- the file name is a hash in the cache directory
+	if err != nil {
+		return "", "", false
+	}
+	var entry cachedArticle
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != urlStr {
+		return "", "", false
+	}
+	if c.ttl > 0 && c.now().Sub(entry.FetchedAt) > c.ttl {
+		return "", "", false
+	}
+	return entry.Text, entry.Title, true
+}
+
+// put saves the text and title of the URL fetched with the variant, the file is replaced atomically
+// so a concurrent get never sees half of it
+func (c *articleCache) put(urlStr, variant, text, title string) error {
+	if err := os.MkdirAll(c.dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.Marshal(cachedArticle{URL: urlStr, Title: title, Text: text, FetchedAt: c.now()})
+	if err != nil {
+		return fmt.Errorf("failed to encode cached article: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, ".article-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cached article: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write cached article: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(urlStr, variant)); err != nil {
+		return fmt.Errorf("failed to write cached article: %w", err)
+	}
+	return nil
+}
+
+// path returns the cache file of the URL fetched with the variant, named by the hash of both
+func (c *articleCache) path(urlStr, variant string) string {
+	sum := sha256.Sum256([]byte(urlStr + "\n" + variant))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
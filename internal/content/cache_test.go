@@ -0,0 +1,147 @@
+package content
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/logging"
+)
+
+// countingTransport answers every request with the article page and counts the requests
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls.Add(1)
+	page := "<html><head><title>Cached Article</title></head><body><article><p>" +
+		strings.Repeat("The article about caching has enough text to pass the length check. ", 5) +
+		"</p></article></body></html>"
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(strings.NewReader(page)),
+		Request:    req,
+	}, nil
+}
+
+func TestHTTPArticleFetcher_FetchCached(t *testing.T) {
+	const articleURL = "https://example.com/article"
+	dir := t.TempDir()
+	transport := &countingTransport{}
+	fetcher := NewHTTPArticleFetcher(&http.Client{Transport: transport}, WithCache(dir, time.Hour))
+	fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	fetcher.cache.now = func() time.Time { return now }
+
+	content, title, err := fetcher.Fetch(articleURL)
+	require.NoError(t, err)
+	assert.Equal(t, "Cached Article", title)
+	assert.Contains(t, content, "The article about caching")
+	assert.EqualValues(t, 1, transport.calls.Load())
+	assert.FileExists(t, fetcher.cache.path(articleURL, fetcher.cacheVariant()))
+
+	t.Run("second fetch within ttl makes no request", func(t *testing.T) {
+		now = now.Add(59 * time.Minute)
+		cachedContent, cachedTitle, err := fetcher.Fetch(articleURL)
+		require.NoError(t, err)
+		assert.Equal(t, content, cachedContent)
+		assert.Equal(t, title, cachedTitle)
+		assert.EqualValues(t, 1, transport.calls.Load())
+	})
+
+	t.Run("other url is fetched", func(t *testing.T) {
+		_, _, err := fetcher.Fetch(articleURL + "?page=2")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, transport.calls.Load())
+	})
+
+	t.Run("expired entry is fetched again", func(t *testing.T) {
+		now = now.Add(2 * time.Hour)
+		_, _, err := fetcher.Fetch(articleURL)
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, transport.calls.Load())
+	})
+
+	t.Run("broken entry is fetched again", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(fetcher.cache.path(articleURL, fetcher.cacheVariant()), []byte("{broken"), 0o600))
+		_, _, err := fetcher.Fetch(articleURL)
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, transport.calls.Load())
+		_, _, ok := fetcher.cache.get(articleURL, fetcher.cacheVariant())
+		assert.True(t, ok, "replaced by the fresh article")
+	})
+
+	t.Run("other request settings are fetched", func(t *testing.T) {
+		for name, opts := range map[string][]FetcherOption{
+			"user agent": {WithUserAgent("Mozilla/5.0 Firefox")},
+			"header":     {WithHeaders(map[string]string{"Cookie": "session=1"})},
+		} {
+			t.Run(name, func(t *testing.T) {
+				other := &countingTransport{}
+				fetcher := NewHTTPArticleFetcher(&http.Client{Transport: other}, append(opts, WithCache(dir, time.Hour))...)
+				fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+				fetcher.cache.now = func() time.Time { return now }
+				for range 2 {
+					_, _, err := fetcher.Fetch(articleURL)
+					require.NoError(t, err)
+				}
+				assert.EqualValues(t, 1, other.calls.Load(), "fetched once, then cached for these settings")
+			})
+		}
+
+		variant := fetcher.cacheVariant()
+		fetcher.SetRenderURL("http://render.local/render")
+		defer fetcher.SetRenderURL("")
+		assert.NotEqual(t, variant, fetcher.cacheVariant(), "the render service has its own entries")
+		_, _, ok := fetcher.cache.get(articleURL, fetcher.cacheVariant())
+		assert.False(t, ok)
+	})
+
+	t.Run("without cache every fetch makes a request", func(t *testing.T) {
+		uncached := &countingTransport{}
+		plain := NewHTTPArticleFetcher(&http.Client{Transport: uncached}, WithCache("", time.Hour))
+		plain.logger = logging.New(io.Discard, slog.LevelInfo)
+		assert.Nil(t, plain.cache)
+		for range 2 {
+			_, _, err := plain.Fetch(articleURL)
+			require.NoError(t, err)
+		}
+		assert.EqualValues(t, 2, uncached.calls.Load())
+	})
+}
+
+func TestArticleCache(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache := &articleCache{dir: filepath.Join(t.TempDir(), "nested"), now: func() time.Time { return now }}
+
+	_, _, ok := cache.get("https://example.com/a", "ua")
+	assert.False(t, ok, "empty cache")
+
+	require.NoError(t, cache.put("https://example.com/a", "ua", "Текст статьи", "Заголовок"))
+	text, title, ok := cache.get("https://example.com/a", "ua")
+	require.True(t, ok)
+	assert.Equal(t, "Текст статьи", text)
+	assert.Equal(t, "Заголовок", title)
+
+	now = now.Add(365 * 24 * time.Hour)
+	_, _, ok = cache.get("https://example.com/a", "ua")
+	assert.True(t, ok, "zero ttl keeps entries forever")
+
+	assert.NotEqual(t, cache.path("https://example.com/a", "ua"), cache.path("https://example.com/b", "ua"))
+	_, _, ok = cache.get("https://example.com/a", "other ua")
+	assert.False(t, ok, "other request variant")
+	entries, err := os.ReadDir(cache.dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files left")
+}
@@ -0,0 +1,75 @@
+package content
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// chapterMinDuration is the length in seconds below which a message or a chapter is merged
+// with the neighbouring messages of the same host, so short replies don't become chapters of their own
+const chapterMinDuration = 20.0
+
+// chapterSnippetLen is the max characters of the message snippet in a chapter title
+const chapterSnippetLen = 40
+
+// ffmetadataEscaper escapes characters with a special meaning in ffmpeg metadata values
+var ffmetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", `\`+"\n")
+
+// TimedMessage is a message with its place in the episode audio, start and end are seconds from the start
+// of the episode, so jingles, pauses and silences around the message are in the gaps between messages
+type TimedMessage struct {
+	podcast.Message
+	Start, End float64
+}
+
+// chapter is a run of messages of one host
+type chapter struct {
+	host       string
+	text       string // text of the first message, the title snippet
+	start, end float64
+}
+
+// BuildChapters returns an ffmpeg metadata file (FFMETADATA1) with a chapter per message, titled with the host
+// and a snippet of the message. consecutive messages of the same host are merged into one chapter when either
+// the chapter so far or the message is shorter than chapterMinDuration. messages without text are skipped,
+// except ads, which get a chapter titled with the host only.
+func BuildChapters(messages []TimedMessage) string {
+	var chapters []chapter
+	for _, msg := range messages {
+		text := strings.Join(strings.Fields(msg.Content), " ")
+		if text == "" && !msg.Ad {
+			continue
+		}
+		host := strings.Join(strings.Fields(msg.Host), " ")
+		if n := len(chapters); n > 0 && chapters[n-1].host == host &&
+			(chapters[n-1].end-chapters[n-1].start < chapterMinDuration || msg.End-msg.Start < chapterMinDuration) {
+			chapters[n-1].end = msg.End
+			continue
+		}
+		chapters = append(chapters, chapter{host: host, text: text, start: msg.Start, end: msg.End})
+	}
+
+	tp := NewTextProcessor()
+	var sb strings.Builder
+	sb.WriteString(";FFMETADATA1\n")
+	for _, ch := range chapters {
+		title := tp.TruncateText(ch.text, chapterSnippetLen)
+		switch {
+		case title == "":
+			title = ch.host
+		case ch.host != "":
+			title = ch.host + ": " + title
+		}
+		fmt.Fprintf(&sb, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			chapterTime(ch.start), chapterTime(ch.end), ffmetadataEscaper.Replace(title))
+	}
+	return sb.String()
+}
+
+// chapterTime converts seconds to milliseconds, the chapter timebase
+func chapterTime(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
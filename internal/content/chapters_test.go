@@ -0,0 +1,43 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+func TestBuildChapters(t *testing.T) {
+	text := strings.TrimSpace(strings.Repeat("тест ", 11))
+	// a 5s intro jingle, 0.5s pauses between the messages and 1s of silence around the ad
+	messages := []TimedMessage{
+		{Message: podcast.Message{Host: "Алексей", Content: text}, Start: 5, End: 26},
+		{Message: podcast.Message{Host: "Алексей", Content: text}, Start: 26.5, End: 29.5}, // short, merged
+		{Message: podcast.Message{Host: "Мария", Content: text}, Start: 30, End: 33},
+		{Message: podcast.Message{Host: "Мария", Content: text}, Start: 33.5, End: 36.5}, // the chapter is still short
+		{Message: podcast.Message{Host: "Реклама", Ad: true, AudioFile: "ad.mp3"}, Start: 38, End: 68},
+		{Message: podcast.Message{Host: "Дмитрий", Content: text}, Start: 69.5, End: 90.5},
+		{Message: podcast.Message{Host: "Дмитрий", Content: text}, Start: 91, End: 112}, // both long, a chapter of its own
+		{Message: podcast.Message{Host: "Дмитрий"}, Start: 112.5, End: 112.5},           // no text, no chapter
+	}
+
+	snippet := "тест тест тест тест тест тест тест..."
+	expected := ";FFMETADATA1\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=5000\nEND=29500\ntitle=Алексей: " + snippet + "\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=30000\nEND=36500\ntitle=Мария: " + snippet + "\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=38000\nEND=68000\ntitle=Реклама\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=69500\nEND=90500\ntitle=Дмитрий: " + snippet + "\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=91000\nEND=112000\ntitle=Дмитрий: " + snippet + "\n"
+	assert.Equal(t, expected, BuildChapters(messages))
+
+	t.Run("title escaped", func(t *testing.T) {
+		chapters := BuildChapters([]TimedMessage{{Message: podcast.Message{Host: "Мария", Content: "a = b; #c \\ d"}, End: 1}})
+		assert.Contains(t, chapters, `title=Мария: a \= b\; \#c \\ d`+"\n")
+	})
+
+	t.Run("no messages", func(t *testing.T) {
+		assert.Equal(t, ";FFMETADATA1\n", BuildChapters(nil))
+	})
+}
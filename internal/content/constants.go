@@ -4,34 +4,81 @@ import "time"
 
 // http and network timeouts
 const (
-	defaultHTTPTimeout      = 30 * time.Second
-	OpenAIHTTPTimeout       = 2 * time.Minute
-	SpeechGenerationTimeout = 30 * time.Second
+	FetchHTTPTimeout         = 30 * time.Second
+	OpenAIChatTimeout        = 2 * time.Minute
+	OpenAITTSTimeout         = time.Minute
+	OpenAIRateLimitDelay     = 2 * time.Second
+	OpenAIRateLimitMaxDelay  = 30 * time.Second
+	OpenAIRetryAfterMax      = 2 * time.Minute
+	SpeechGenerationTimeout  = 30 * time.Second
+	ControlReadHeaderTimeout = 5 * time.Second
+	IcecastReconnectDelay    = 2 * time.Second
 )
 
+// IcecastReconnects is how many times a refused or dropped Icecast stream is restarted by default
+const IcecastReconnects = 3
+
+// FetchUserAgent is the User-Agent of article requests by default
+const FetchUserAgent = "AI-Podcast/1.0"
+
+// MaxFetchRedirects is how many redirects of an article request are followed by default
+const MaxFetchRedirects = 10
+
 // content processing limits
 const (
-	minArticleTextLength    = 100
-	maxArticleContentLength = 8000
-	DisplayTruncateLength   = 50
+	minArticleTextLength         = 100
+	RecommendedArticleTextLength = 1500
+	MaxArticleChars              = 8000
+	DisplayTruncateLength        = 50
+	maxPDFSize                   = 32 << 20
+	maxTranscriptSize            = 8 << 20
 )
 
 // openai api parameters
 const (
-	OpenAITemperature = 0.7
-	OpenAIMaxTokens   = 4000
-	MessagesPerMinute = 2
+	OpenAITemperature      = 0.7
+	OpenAIMaxTokens        = 4000
+	MessagesPerMinute      = 2
+	OpenAIRateLimitRetries = 3
+	OpenAIUserAgent        = "ai-podcast"
+	OpenAIBaseURL          = "https://api.openai.com/v1"
+	OpenAIChatModel        = "gpt-4o"
+	OpenAITTSModel         = "gpt-4o-audio-preview"
+	OpenAISpeechModel      = "gpt-4o-mini-tts"
+	RetryJitter            = 0.2
+	FillTargetRatio        = 0.8
+	DurationWarnDeviation  = 0.3
+	DurationTolerance      = 0.15
+	MaxFillRounds          = 3
+	MaxContextTrims        = 2
+	DiscussionParseRetries = 2
 )
 
 // text processing constants
 const (
 	avgCharsPerWordRussian   = 5.5
 	avgWordsPerMinuteRussian = 160.0
+	avgCharsPerWordEnglish   = 4.7
+	avgWordsPerMinuteEnglish = 150.0
+	avgCharsPerWordSpanish   = 4.9
+	avgWordsPerMinuteSpanish = 165.0
 	minSpeechSpeed           = 0.8
 	maxSpeechSpeed           = 1.2
+	maxTurnShareDeviation    = 0.15
+	minWeightedTurns         = 10
+)
+
+// token estimation, approximate characters per token of the gpt-4o tokenizer
+const (
+	latinCharsPerToken    = 4.0
+	cyrillicCharsPerToken = 3.0
+	digitsPerToken        = 3.0
+	symbolsPerToken       = 2.0
 )
 
 // audio processing
 const (
 	PreGeneratedSegmentsBuffer = 2
+	SpeedCheckpointSegments    = 3
+	PauseSilenceMs             = 1000
 )
@@ -0,0 +1,96 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// errPrivateAddress is returned for connections to private, loopback and link-local addresses
+var errPrivateAddress = errors.New("blocked private address")
+
+// publicDialer connects to public addresses only, against SSRF through user-supplied article URLs, e.g. to
+// http://169.254.169.254/ of the cloud metadata or to http://localhost. the host is resolved first and every
+// address checked, then one of the checked addresses is dialed, so DNS can't answer differently the second time.
+type publicDialer struct {
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// newPublicDialer creates a dialer on the default resolver
+func newPublicDialer() *publicDialer {
+	dialer := &net.Dialer{Timeout: FetchHTTPTimeout}
+	return &publicDialer{lookup: net.DefaultResolver.LookupIPAddr, dial: dialer.DialContext}
+}
+
+// DialContext resolves the host of addr and connects to it, a host with any private address is refused
+func (d *publicDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range addrs {
+		var conn net.Conn
+		if conn, err = d.dial(ctx, network, net.JoinHostPort(a.IP.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// resolve returns the addresses of the host, an error when it has none or any of them is private
+func (d *publicDialer) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses of %s", host)
+	}
+	for _, a := range addrs {
+		if isPrivateIP(a.IP) {
+			return nil, fmt.Errorf("%w %s of %s", errPrivateAddress, a.IP, host)
+		}
+	}
+	return addrs, nil
+}
+
+// transport returns a copy of the transport connecting through the dialer. requests go directly,
+// without a proxy of the environment, since the address behind a proxy can't be checked.
+// a transport other than *http.Transport can't be checked, every request through it fails.
+func (d *publicDialer) transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return errorTransport{err: fmt.Errorf("can't block private addresses with transport %T", base)}
+	}
+	t = t.Clone()
+	t.Proxy = nil
+	t.DialContext = d.DialContext
+	return t
+}
+
+// errorTransport fails every request with err
+type errorTransport struct {
+	err error
+}
+
+// RoundTrip returns the error of the transport
+func (t errorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// isPrivateIP reports whether the address is loopback, private, link-local or unspecified
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
@@ -0,0 +1,157 @@
+package content
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/logging"
+)
+
+func TestHTTPArticleFetcher_FetchBlockPrivateIPs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><title>Public Article</title></head><body><article><p>" +
+			strings.Repeat("The article is served from a public address and can be fetched. ", 5) +
+			"</p></article></body></html>"))
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	// internal.example resolves to loopback, news.example to a public address served by the test server
+	var dialed []string
+	fetcher := NewHTTPArticleFetcher(nil, WithBlockPrivateIPs())
+	fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+	fetcher.dialer.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "internal.example":
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+		case "mixed.example":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.0.0.5")}}, nil
+		case "news.example":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		}
+		return net.DefaultResolver.LookupIPAddr(ctx, host)
+	}
+	fetcher.dialer.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+
+	t.Run("host resolving to loopback", func(t *testing.T) {
+		_, _, err := fetcher.Fetch("http://internal.example:" + port + "/article")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "blocked private address 127.0.0.1 of internal.example")
+		assert.ErrorIs(t, err, errPrivateAddress)
+	})
+
+	t.Run("host with one private address", func(t *testing.T) {
+		_, _, err := fetcher.Fetch("http://mixed.example:" + port + "/article")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "blocked private address 10.0.0.5 of mixed.example")
+	})
+
+	t.Run("metadata address", func(t *testing.T) {
+		_, _, err := fetcher.Fetch("http://169.254.169.254/latest/meta-data/")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "blocked private address 169.254.169.254")
+	})
+
+	t.Run("localhost", func(t *testing.T) {
+		_, _, err := fetcher.Fetch(server.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "blocked private address 127.0.0.1")
+	})
+
+	assert.Empty(t, dialed, "nothing dialed for private addresses")
+
+	t.Run("public host", func(t *testing.T) {
+		_, title, err := fetcher.Fetch("http://news.example:" + port + "/article")
+		require.NoError(t, err)
+		assert.Equal(t, "Public Article", title)
+		assert.Equal(t, []string{"93.184.216.34:" + port}, dialed, "the checked address is dialed")
+	})
+
+	t.Run("not blocked by default", func(t *testing.T) {
+		plain := NewHTTPArticleFetcher(nil)
+		plain.logger = logging.New(io.Discard, slog.LevelInfo)
+		_, title, err := plain.Fetch(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "Public Article", title)
+	})
+
+	t.Run("render service", func(t *testing.T) {
+		var rendered []string
+		render := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			rendered = append(rendered, string(body))
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html><head><title>Rendered Article</title></head><body><article><p>" +
+				strings.Repeat("The article is rendered by the service on a loopback address. ", 5) +
+				"</p></article></body></html>"))
+		}))
+		defer render.Close()
+		renderer := NewHTTPArticleFetcher(nil, WithBlockPrivateIPs())
+		renderer.logger = logging.New(io.Discard, slog.LevelInfo)
+		renderer.dialer.lookup = fetcher.dialer.lookup
+		renderer.SetRenderURL(render.URL)
+
+		_, _, err := renderer.Fetch("http://internal.example/article")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to check article host: blocked private address 127.0.0.1 of internal.example")
+		_, _, err = renderer.Fetch("http://169.254.169.254/latest/meta-data/")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "blocked private address 169.254.169.254")
+		assert.Empty(t, rendered, "private article URLs never reach the render service")
+
+		// the render service itself is on loopback and still reachable
+		_, title, err := renderer.Fetch("http://news.example/article")
+		require.NoError(t, err)
+		assert.Equal(t, "Rendered Article", title)
+		assert.Equal(t, []string{`{"url":"http://news.example/article"}`}, rendered)
+	})
+
+	t.Run("custom transport can't be checked", func(t *testing.T) {
+		custom := NewHTTPArticleFetcher(&http.Client{Transport: &countingTransport{}}, WithBlockPrivateIPs())
+		_, _, err := custom.Fetch("https://example.com/article")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't block private addresses with transport *content.countingTransport")
+	})
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{ip: "127.0.0.1", private: true},
+		{ip: "10.1.2.3", private: true},
+		{ip: "172.16.0.1", private: true},
+		{ip: "192.168.1.1", private: true},
+		{ip: "169.254.169.254", private: true},
+		{ip: "0.0.0.0", private: true},
+		{ip: "::1", private: true},
+		{ip: "fe80::1", private: true},
+		{ip: "fd00::1", private: true},
+		{ip: "::ffff:127.0.0.1", private: true},
+		{ip: "93.184.216.34", private: false},
+		{ip: "8.8.8.8", private: false},
+		{ip: "2606:4700::1111", private: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			assert.Equal(t, test.private, isPrivateIP(net.ParseIP(test.ip)))
+		})
+	}
+}
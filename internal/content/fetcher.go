@@ -1,10 +1,18 @@
 package content
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/markusmobius/go-trafilatura"
@@ -12,25 +20,158 @@ import (
 
 // HTTPArticleFetcher implements article fetching using HTTP and trafilatura
 type HTTPArticleFetcher struct {
+	ctx           context.Context // parent of the per-fetch timeout, cancels the fetch
 	client        *http.Client
+	renderClient  *http.Client // client of the render service, connects anywhere, the service is set by the operator
 	timeout       time.Duration
 	userAgent     string
+	maxRedirects  int               // redirects followed before the fetch fails
+	headers       map[string]string // extra headers of the requests to the article site, e.g. Cookie or Referer
 	minTextLength int
+	recommended   int          // soft minimum, shorter articles are fetched with a warning
+	maxTokens     int          // estimated token budget of the article text, the char cap applies when zero
+	maxChars      int          // cap of the article text in characters
+	logger        *slog.Logger // destination of soft warnings
+	renderURL     string
+	youtubeURL    string        // base URL of the YouTube transcript and oembed endpoints
+	throttle      *hostThrottle // spaces requests to the same host, nil for no delay
+	cache         *articleCache // extracted articles kept on disk, nil to fetch every time
+	dialer        *publicDialer // refuses private addresses, nil to connect anywhere
+}
+
+// FetcherOption configures the fetcher created by NewHTTPArticleFetcher
+type FetcherOption func(*HTTPArticleFetcher)
+
+// WithCache keeps the extracted text and title of every fetched article in dir for ttl, a fetch of the same URL
+// within ttl makes no request at all. zero ttl keeps the articles until the files are removed, empty dir disables it.
+func WithCache(dir string, ttl time.Duration) FetcherOption {
+	return func(f *HTTPArticleFetcher) {
+		if dir != "" {
+			f.cache = &articleCache{dir: dir, ttl: ttl, now: time.Now}
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent of article requests, for sites refusing the default one. empty keeps the default.
+func WithUserAgent(ua string) FetcherOption {
+	return func(f *HTTPArticleFetcher) {
+		if ua != "" {
+			f.userAgent = ua
+		}
+	}
+}
+
+// WithHeaders adds the headers to every request to the article site, e.g. a Cookie or Referer the site requires.
+// a User-Agent given here overrides the one of WithUserAgent. the headers aren't sent to the render service
+// and the YouTube transcript endpoints.
+func WithHeaders(headers map[string]string) FetcherOption {
+	return func(f *HTTPArticleFetcher) {
+		f.headers = headers
+	}
+}
+
+// WithBlockPrivateIPs refuses requests to hosts resolving to private, loopback or link-local addresses,
+// for services fetching user-supplied URLs. the addresses are checked after DNS resolution, so a public name
+// pointing at 127.0.0.1 is refused too. requests go directly, ignoring proxy environment variables.
+// with a render service the article host is checked before its URL is passed to the service, the service
+// itself may be on a private address. redirects followed by the service can't be checked.
+func WithBlockPrivateIPs() FetcherOption {
+	return func(f *HTTPArticleFetcher) {
+		f.dialer = newPublicDialer()
+	}
+}
+
+// WithLogger sets the logger of soft warnings, e.g. a short article or a failed cache write. nil keeps the default one.
+func WithLogger(logger *slog.Logger) FetcherOption {
+	return func(f *HTTPArticleFetcher) {
+		if logger != nil {
+			f.logger = logger
+		}
+	}
 }
 
 // NewHTTPArticleFetcher creates a new HTTP article fetcher with trafilatura
-func NewHTTPArticleFetcher(client *http.Client) *HTTPArticleFetcher {
+func NewHTTPArticleFetcher(client *http.Client, opts ...FetcherOption) *HTTPArticleFetcher {
 	if client == nil {
-		client = &http.Client{Timeout: defaultHTTPTimeout}
+		client = &http.Client{Timeout: FetchHTTPTimeout}
 	}
-	return &HTTPArticleFetcher{
-		client:        client,
-		timeout:       defaultHTTPTimeout,
-		userAgent:     "AI-Podcast/1.0",
+	f := &HTTPArticleFetcher{
+		ctx:           context.Background(),
+		timeout:       FetchHTTPTimeout,
+		userAgent:     FetchUserAgent,
+		maxRedirects:  MaxFetchRedirects,
 		minTextLength: minArticleTextLength,
+		recommended:   RecommendedArticleTextLength,
+		maxChars:      MaxArticleChars,
+		logger:        slog.Default(),
+		youtubeURL:    youtubeBaseURL,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	// a copy of the client, the one passed in may be shared with other requests
+	checked := *client
+	checked.CheckRedirect = f.checkRedirect
+	render := checked
+	if f.dialer != nil {
+		checked.Transport = f.dialer.transport(client.Transport)
+	}
+	f.client = &checked
+	f.renderClient = &render
+	return f
+}
+
+// SetContext sets the context of every fetch, canceling it aborts the fetch in progress
+func (f *HTTPArticleFetcher) SetContext(ctx context.Context) {
+	f.ctx = ctx
+}
+
+// SetRecommendedLength sets the article length in characters below which a low quality warning is printed.
+// zero disables the warning, articles shorter than the hard minimum fail regardless.
+func (f *HTTPArticleFetcher) SetRecommendedLength(chars int) {
+	f.recommended = chars
+}
+
+// SetMaxArticleTokens limits the article text by its estimated token count instead of characters.
+// zero keeps the character cap.
+func (f *HTTPArticleFetcher) SetMaxArticleTokens(tokens int) {
+	f.maxTokens = tokens
+}
+
+// SetMaxArticleChars sets the cap of the article text in characters, applied when no token budget is set.
+// the text is cut on a sentence or word boundary, zero keeps the default MaxArticleChars.
+func (f *HTTPArticleFetcher) SetMaxArticleChars(chars int) {
+	f.maxChars = MaxArticleChars
+	if chars > 0 {
+		f.maxChars = chars
 	}
 }
 
+// SetMaxRedirects sets how many redirects of an article request are followed, every hop is checked to stay
+// on http or https. zero keeps the default MaxFetchRedirects.
+func (f *HTTPArticleFetcher) SetMaxRedirects(redirects int) {
+	f.maxRedirects = MaxFetchRedirects
+	if redirects > 0 {
+		f.maxRedirects = redirects
+	}
+}
+
+// SetFetchDelay spaces fetches from the same host by at least delay, to avoid rate limits and bans
+// when a URL list has many articles of one site. with a render service the delay applies to the service,
+// the host the requests go to. the delay doesn't count toward the fetch timeout. zero disables it.
+func (f *HTTPArticleFetcher) SetFetchDelay(delay time.Duration) {
+	f.throttle = nil
+	if delay > 0 {
+		f.throttle = newHostThrottle(delay)
+	}
+}
+
+// SetRenderURL makes the fetcher get pages through a headless-render service (Splash, browserless and similar).
+// the target URL is POSTed to the service as {"url": ...} and the returned rendered HTML is used for extraction.
+func (f *HTTPArticleFetcher) SetRenderURL(renderURL string) {
+	f.renderURL = renderURL
+}
+
 // Fetch downloads and extracts text from the given URL using trafilatura
 func (f *HTTPArticleFetcher) Fetch(urlStr string) (content, title string, err error) {
 	// validate URL
@@ -40,25 +181,154 @@ func (f *HTTPArticleFetcher) Fetch(urlStr string) (content, title string, err er
 	}
 
 	// only allow http and https schemes
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return "", "", fmt.Errorf("unsupported URL scheme: %s (only http and https are allowed)", parsedURL.Scheme)
+	if err := checkScheme(parsedURL); err != nil {
+		return "", "", err
+	}
+
+	rawText, rawTitle, err := f.fetchCached(urlStr, parsedURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	// strip invisible characters and collapse irregular whitespace before measuring the text
+	tp := NewTextProcessor()
+	content = tp.NormalizeWhitespace(tp.Sanitize(rawText))
+
+	// validate content length
+	if len(content) < f.minTextLength {
+		return "", "", fmt.Errorf("extracted content too short (%d chars, minimum %d)",
+			len(content), f.minTextLength)
+	}
+
+	// short but valid articles still work, though the discussion is likely to be thin
+	if length := len([]rune(content)); length < f.recommended {
+		f.logger.Warn("Article is short, the discussion may be thin", "chars", length, "recommended", f.recommended)
+	}
+
+	title = strings.TrimSpace(tp.Sanitize(rawTitle))
+	if title == "" {
+		title = "Untitled Article"
+	}
+
+	// limit article length for API calls
+	if f.maxTokens > 0 {
+		content = tp.TruncateTokens(content, f.maxTokens)
+	} else {
+		content = tp.TruncateText(content, f.maxChars)
+	}
+
+	return content, title, nil
+}
+
+// checkScheme allows http and https URLs only
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme: %s (only http and https are allowed)", u.Scheme)
+	}
+	return nil
+}
+
+// checkRedirect is the CheckRedirect of the client: it stops after maxRedirects hops and refuses a hop
+// to a scheme other than http and https, e.g. a site redirecting to ftp:// or file://
+func (f *HTTPArticleFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", f.maxRedirects)
+	}
+	from := via[len(via)-1].URL
+	if err := checkScheme(req.URL); err != nil {
+		return fmt.Errorf("redirected from %s to %s: %w", from.Redacted(), req.URL.Redacted(), err)
+	}
+	f.logger.Debug("Following redirect", "from", from.Redacted(), "to", req.URL.Redacted(), "hop", len(via))
+	return nil
+}
+
+// fetchCached returns the extracted text and title of the URL from the cache, or downloads them and caches them.
+// a cache that can't be written is reported and the fetched article is used anyway.
+func (f *HTTPArticleFetcher) fetchCached(urlStr string, parsedURL *url.URL) (text, title string, err error) {
+	variant := f.cacheVariant()
+	if f.cache != nil {
+		if text, title, ok := f.cache.get(urlStr, variant); ok {
+			f.logger.Debug("Article loaded from the cache", "url", urlStr, "file", f.cache.path(urlStr, variant))
+			return text, title, nil
+		}
+	}
+
+	// the delay is waited before the timeout starts, so queueing behind other fetches of the host doesn't eat it
+	videoID, isVideo := youtubeVideoID(parsedURL)
+	if err = f.throttle.Wait(f.ctx, f.requestHost(parsedURL, isVideo)); err != nil {
+		return "", "", fmt.Errorf("failed to wait for fetch delay: %w", err)
 	}
 
 	// create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	ctx, cancel := context.WithTimeout(f.ctx, f.timeout)
 	defer cancel()
 
+	// videos are discussed from their transcript
+	if isVideo {
+		text, title, err = f.fetchYouTube(ctx, videoID, urlStr)
+	} else {
+		text, title, err = f.fetchDocument(ctx, urlStr, parsedURL)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if f.cache != nil {
+		if err := f.cache.put(urlStr, variant, text, title); err != nil {
+			f.logger.Warn("Can't cache the article", "url", urlStr, "err", err)
+		}
+	}
+	return text, title, nil
+}
+
+// cacheVariant returns the request settings the cached articles depend on: the User-Agent, the extra headers
+// and the render service. a site may return another page to another User-Agent or cookie, so each gets its own entry.
+func (f *HTTPArticleFetcher) cacheVariant() string {
+	var sb strings.Builder
+	sb.WriteString("user-agent=" + f.userAgent + "\nrender-url=" + f.renderURL)
+	for _, name := range slices.Sorted(maps.Keys(f.headers)) {
+		sb.WriteString("\n" + http.CanonicalHeaderKey(name) + "=" + f.headers[name])
+	}
+	return sb.String()
+}
+
+// requestHost returns the host the fetch of the article sends its requests to: the render service when set,
+// the YouTube endpoints for a video, the article host otherwise
+func (f *HTTPArticleFetcher) requestHost(parsedURL *url.URL, isVideo bool) string {
+	switch {
+	case isVideo:
+		if u, err := url.Parse(f.youtubeURL); err == nil {
+			return u.Hostname()
+		}
+	case f.renderURL != "":
+		if u, err := url.Parse(f.renderURL); err == nil {
+			return u.Hostname()
+		}
+	}
+	return parsedURL.Hostname()
+}
+
+// fetchDocument downloads the article and extracts its text and title from HTML or PDF
+func (f *HTTPArticleFetcher) fetchDocument(ctx context.Context, urlStr string, parsedURL *url.URL) (text, title string, err error) {
 	// create HTTP request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, http.NoBody)
+	req, err := f.newRequest(ctx, urlStr)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// set user agent
-	req.Header.Set("User-Agent", f.userAgent)
+	// the render service fetches the article itself, so its host is checked here, before the service gets the URL
+	client := f.client
+	if f.renderURL != "" {
+		client = f.renderClient
+		if f.dialer != nil {
+			if _, err = f.dialer.resolve(ctx, parsedURL.Hostname()); err != nil {
+				return "", "", fmt.Errorf("failed to check article host: %w", err)
+			}
+		}
+	}
 
 	// perform HTTP request
-	resp, err := f.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to fetch URL: %w", err)
 	}
@@ -68,7 +338,27 @@ func (f *HTTPArticleFetcher) Fetch(urlStr string) (content, title string, err er
 		return "", "", fmt.Errorf("failed to fetch article: status code %d", resp.StatusCode)
 	}
 
-	// extract content using trafilatura
+	// PDF documents are extracted directly, other binary content like images confuses the HTML extractor
+	contentType := resp.Header.Get("Content-Type")
+	if isPDF(contentType, parsedURL) {
+		return extractPDF(resp.Body)
+	}
+	if err := checkContentType(contentType); err != nil {
+		return "", "", err
+	}
+	return extractHTML(resp.Body, parsedURL)
+}
+
+// invisibleChars are the byte order mark and zero-width characters stripped from the HTML before extraction,
+// a leading BOM or a zero-width space inside the title tag makes trafilatura miss the title
+var invisibleChars = strings.NewReplacer("\ufeff", "", "\u200b", "", "\u200c", "", "\u200d", "", "\u2060", "")
+
+// extractHTML extracts the article text with trafilatura, the title falls back to the site name
+func extractHTML(body io.Reader, pageURL *url.URL) (text, title string, err error) {
+	page, err := io.ReadAll(body)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read article: %w", err)
+	}
 	options := trafilatura.Options{
 		EnableFallback:  true,
 		ExcludeComments: true,
@@ -76,34 +366,64 @@ func (f *HTTPArticleFetcher) Fetch(urlStr string) (content, title string, err er
 		IncludeImages:   false,
 		IncludeLinks:    false,
 		Deduplicate:     true,
-		OriginalURL:     parsedURL,
+		OriginalURL:     pageURL,
 	}
 
-	result, err := trafilatura.Extract(resp.Body, options)
+	result, err := trafilatura.Extract(strings.NewReader(invisibleChars.Replace(string(page))), options)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to extract content: %w", err)
 	}
 
-	// validate content length
-	if len(result.ContentText) < f.minTextLength {
-		return "", "", fmt.Errorf("extracted content too short (%d chars, minimum %d)",
-			len(result.ContentText), f.minTextLength)
-	}
-
-	// get title from trafilatura result metadata
 	title = result.Metadata.Title
-	if title == "" {
+	if strings.TrimSpace(title) == "" {
 		title = result.Metadata.Sitename
 	}
-	if title == "" {
-		title = "Untitled Article"
+	return result.ContentText, title, nil
+}
+
+// checkContentType accepts HTML and text responses, a missing header is left for the extractor to handle
+func checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %w", contentType, err)
 	}
+	if strings.HasPrefix(mediaType, "text/") || mediaType == "application/xhtml+xml" || mediaType == "application/xml" {
+		return nil
+	}
+	return fmt.Errorf("unsupported content type %s, the URL must point to an HTML article", mediaType)
+}
 
-	content = result.ContentText
+// newRequest creates a direct GET for the article, or a POST to the render service when configured
+func (f *HTTPArticleFetcher) newRequest(ctx context.Context, urlStr string) (*http.Request, error) {
+	if f.renderURL == "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", urlStr, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		f.setHeaders(req)
+		return req, nil
+	}
 
-	// limit article length for API calls
-	tp := NewTextProcessor()
-	content = tp.TruncateString(content, maxArticleContentLength)
+	body, err := json.Marshal(map[string]string{"url": urlStr})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", f.renderURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", f.userAgent)
+	return req, nil
+}
 
-	return content, title, nil
+// setHeaders sets the User-Agent and the extra headers of a request to the article site
+func (f *HTTPArticleFetcher) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", f.userAgent)
+	for name, value := range f.headers {
+		req.Header.Set(name, value)
+	}
 }
@@ -1,14 +1,22 @@
 package content
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/logging"
 )
 
 func TestHTTPArticleFetcher_Fetch(t *testing.T) {
@@ -82,7 +90,7 @@ func TestHTTPArticleFetcher_Fetch(t *testing.T) {
 			statusCode:       http.StatusOK,
 			expectedTitle:    "Long Article",
 			expectError:      false,
-			minContentLength: 8000,
+			minContentLength: 6400,
 		},
 	}
 
@@ -115,8 +123,9 @@ func TestHTTPArticleFetcher_Fetch(t *testing.T) {
 				}
 				// verify content truncation for long articles
 				if tc.name == "content length limit" {
-					assert.Contains(t, content, "...")
-					assert.LessOrEqual(t, len(content), 8003) // 8000 + "..."
+					assert.LessOrEqual(t, len(content), MaxArticleChars)
+					assert.True(t, strings.HasSuffix(content, "the maximum length limit."), "cut after a complete sentence")
+					assert.NotContains(t, content, "...")
 				}
 			}
 		})
@@ -188,6 +197,409 @@ func TestHTTPArticleFetcher_FetchWithNetworkError(t *testing.T) {
 	assert.Empty(t, title)
 }
 
+func TestHTTPArticleFetcher_FetchSanitizesText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("\ufeff<html><head><title>\u200bZero\u200b Width</title></head><body><article>" +
+			"<p>This para\u200bgraph hides zero-width spaces\u200c and a joiner\u200d inside the words.</p>" +
+			"<p>The second paragraph is clean and long enough to pass the length check.</p>" +
+			"</article></body></html>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPArticleFetcher(nil)
+	fetcher.minTextLength = 50 // lower for testing
+
+	content, title, err := fetcher.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Zero Width", title)
+	assert.Contains(t, content, "This paragraph hides zero-width spaces and a joiner inside the words.")
+	assert.NotContains(t, content, "\u200b")
+	assert.NotContains(t, content, "\ufeff")
+}
+
+func TestHTTPArticleFetcher_FetchNormalizesWhitespace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><title>Spaces</title></head><body><article>" +
+			"<p>First   paragraph&nbsp;&nbsp;with irregular   spacing.   </p>\n\n\n" +
+			"<p>   Second paragraph\twith a tab and enough text to pass the length check.</p>" +
+			"</article></body></html>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPArticleFetcher(nil)
+	fetcher.minTextLength = 50 // lower for testing
+	fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+
+	content, _, err := fetcher.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "First paragraph with irregular spacing. Second paragraph with a tab and enough text to pass the length check.",
+		content)
+}
+
+func TestHTTPArticleFetcher_FetchShortArticleWarning(t *testing.T) {
+	paragraph := "<p>This paragraph is a perfectly valid piece of article text about software and podcasts.</p>"
+	tests := []struct {
+		name        string
+		paragraphs  int
+		recommended int
+		expectWarn  bool
+	}{
+		{name: "short but valid article", paragraphs: 2, recommended: 1000, expectWarn: true},
+		{name: "long article", paragraphs: 20, recommended: 1000, expectWarn: false},
+		{name: "warning disabled", paragraphs: 2, recommended: 0, expectWarn: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = w.Write([]byte("<html><head><title>Article</title></head><body><article>" +
+					strings.Repeat(paragraph, test.paragraphs) + "</article></body></html>"))
+			}))
+			defer server.Close()
+
+			var warnings bytes.Buffer
+			fetcher := NewHTTPArticleFetcher(nil)
+			fetcher.minTextLength = 50 // lower for testing
+			fetcher.logger = logging.New(&warnings, slog.LevelInfo)
+			fetcher.SetRecommendedLength(test.recommended)
+
+			content, _, err := fetcher.Fetch(server.URL)
+			require.NoError(t, err)
+			assert.NotEmpty(t, content)
+			if !test.expectWarn {
+				assert.Empty(t, warnings.String())
+				return
+			}
+			assert.Contains(t, warnings.String(), "WARN  Article is short")
+			assert.Contains(t, warnings.String(), "recommended=1000")
+		})
+	}
+}
+
+func TestHTTPArticleFetcher_FetchMaxArticleTokens(t *testing.T) {
+	paragraph := "<p>Разработчики обсуждают новую версию языка программирования и её влияние на проекты.</p>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><title>Article</title></head><body><article>" +
+			strings.Repeat(paragraph, 200) + "</article></body></html>"))
+	}))
+	defer server.Close()
+
+	tp := NewTextProcessor()
+	fetcher := NewHTTPArticleFetcher(nil)
+	fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+
+	content, _, err := fetcher.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len([]rune(content)), MaxArticleChars, "char cap by default")
+	assert.Greater(t, len([]rune(content)), MaxArticleChars*4/5)
+
+	fetcher.SetMaxArticleTokens(500)
+	content, _, err = fetcher.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, tp.EstimateTokens(content), 500)
+	assert.Greater(t, tp.EstimateTokens(content), 450)
+}
+
+func TestHTTPArticleFetcher_FetchMaxArticleChars(t *testing.T) {
+	paragraph := "<p>Разработчики обсуждают новую версию языка программирования и её влияние на проекты.</p>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><title>Article</title></head><body><article>" +
+			strings.Repeat(paragraph, 20) + "</article></body></html>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPArticleFetcher(nil)
+	fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+
+	full, _, err := fetcher.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.False(t, strings.HasSuffix(full, "..."), "nothing cut, no ellipsis")
+
+	fetcher.SetMaxArticleChars(500)
+	content, _, err := fetcher.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len([]rune(content)), 500)
+	assert.True(t, strings.HasSuffix(content, "на проекты."), "cut after a complete sentence")
+	assert.True(t, strings.HasPrefix(full, content))
+
+	fetcher.SetMaxArticleChars(0)
+	content, _, err = fetcher.Fetch(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, full, content, "zero keeps the default cap")
+}
+
+func TestHTTPArticleFetcher_FetchHeaders(t *testing.T) {
+	page := "<html><head><title>Article</title></head><body><article><p>" +
+		strings.Repeat("Only visitors with the right headers get the text of this article. ", 5) +
+		"</p></article></body></html>"
+
+	tests := []struct {
+		name      string
+		opts      []FetcherOption
+		userAgent string
+		referer   string
+		cookie    string
+	}{
+		{name: "default user agent", userAgent: FetchUserAgent},
+		{name: "custom user agent", opts: []FetcherOption{WithUserAgent("Mozilla/5.0 (X11; Linux x86_64)")},
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64)"},
+		{name: "empty user agent keeps the default", opts: []FetcherOption{WithUserAgent("")}, userAgent: FetchUserAgent},
+		{name: "extra headers", opts: []FetcherOption{
+			WithUserAgent("Mozilla/5.0"),
+			WithHeaders(map[string]string{"Referer": "https://news.example.com/", "Cookie": "consent=yes"}),
+		}, userAgent: "Mozilla/5.0", referer: "https://news.example.com/", cookie: "consent=yes"},
+		{name: "user agent of headers wins", opts: []FetcherOption{
+			WithUserAgent("Mozilla/5.0"),
+			WithHeaders(map[string]string{"User-Agent": "Custom/2.0"}),
+		}, userAgent: "Custom/2.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("User-Agent") != test.userAgent || r.Header.Get("Referer") != test.referer ||
+					r.Header.Get("Cookie") != test.cookie {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = w.Write([]byte(page))
+			}))
+			defer server.Close()
+
+			fetcher := NewHTTPArticleFetcher(nil, test.opts...)
+			fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+			content, title, err := fetcher.Fetch(server.URL)
+			require.NoError(t, err)
+			assert.Equal(t, "Article", title)
+			assert.Contains(t, content, "Only visitors with the right headers")
+		})
+	}
+}
+
+func TestHTTPArticleFetcher_FetchNonHTMLContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		expectedErr string
+	}{
+		{name: "zip archive", contentType: "application/zip", body: []byte("PK\x03\x04"),
+			expectedErr: "unsupported content type application/zip, the URL must point to an HTML article"},
+		{name: "png image", contentType: "image/png", body: []byte("\x89PNG\r\n\x1a\n"),
+			expectedErr: "unsupported content type image/png, the URL must point to an HTML article"},
+		{name: "malformed header", contentType: "text/html; charset", body: []byte("<html></html>"),
+			expectedErr: `invalid content type "text/html; charset"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				_, _ = w.Write(test.body)
+			}))
+			defer server.Close()
+
+			_, _, err := NewHTTPArticleFetcher(nil).Fetch(server.URL)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestHTTPArticleFetcher_FetchPDF(t *testing.T) {
+	report, err := os.ReadFile("testdata/report.pdf")
+	require.NoError(t, err)
+	untitled, err := os.ReadFile("testdata/untitled.pdf")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		path          string
+		contentType   string
+		body          []byte
+		expectedTitle string
+		expectedErr   string
+	}{
+		{name: "pdf content type", path: "/download", contentType: "application/pdf", body: report,
+			expectedTitle: "Go Services Report"},
+		{name: "pdf extension with generic type", path: "/papers/report.PDF", contentType: "application/octet-stream",
+			body: report, expectedTitle: "Go Services Report"},
+		{name: "title from the first line", path: "/download", contentType: "application/pdf", body: untitled,
+			expectedTitle: "Scaling Go services in production"},
+		{name: "malformed pdf", path: "/report.pdf", contentType: "application/pdf", body: []byte("%PDF-1.4 broken"),
+			expectedErr: "PDF"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				_, _ = w.Write(test.body)
+			}))
+			defer server.Close()
+
+			fetcher := NewHTTPArticleFetcher(nil)
+			fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+			content, title, err := fetcher.Fetch(server.URL + test.path)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedTitle, title)
+			assert.Contains(t, content, "Scaling Go services in production\n")
+			assert.Contains(t, content, "moved a monolith to a set of small Go services")
+			assert.Contains(t, content, "The second page explains the results")
+		})
+	}
+}
+
+func TestIsPDF(t *testing.T) {
+	tests := []struct {
+		contentType, path string
+		expected          bool
+	}{
+		{contentType: "application/pdf", path: "/doc", expected: true},
+		{contentType: "application/pdf; charset=binary", path: "/doc", expected: true},
+		{contentType: "", path: "/paper.pdf", expected: true},
+		{contentType: "application/octet-stream", path: "/paper.pdf", expected: true},
+		{contentType: "text/html", path: "/paper.pdf", expected: false},
+		{contentType: "text/html", path: "/article", expected: false},
+	}
+	for _, test := range tests {
+		u := &url.URL{Path: test.path}
+		assert.Equal(t, test.expected, isPDF(test.contentType, u), "%s %s", test.contentType, test.path)
+	}
+}
+
+func TestCheckContentType(t *testing.T) {
+	for _, contentType := range []string{"", "text/html", "text/html; charset=utf-8", "TEXT/HTML", "text/plain",
+		"application/xhtml+xml"} {
+		assert.NoError(t, checkContentType(contentType), contentType)
+	}
+	for _, contentType := range []string{"application/pdf", "image/jpeg", "application/octet-stream", "audio/mpeg"} {
+		assert.Error(t, checkContentType(contentType), contentType)
+	}
+}
+
+func TestHTTPArticleFetcher_FetchWithRenderService(t *testing.T) {
+	// the article itself is a JS shell without any text
+	articleServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("article should be fetched through the render service")
+		_, _ = w.Write([]byte(`<html><body><div id="root"></div><script src="app.js"></script></body></html>`))
+	}))
+	defer articleServer.Close()
+
+	renderServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req struct {
+			URL string `json:"url"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, articleServer.URL+"/post", req.URL)
+
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html>
+			<head><title>Rendered Article</title></head>
+			<body>
+				<article>
+					<p>This paragraph was rendered by JavaScript and is only visible in the rendered page.</p>
+					<p>The second rendered paragraph carries enough text to pass the length check.</p>
+				</article>
+			</body>
+		</html>`))
+	}))
+	defer renderServer.Close()
+
+	fetcher := NewHTTPArticleFetcher(nil)
+	fetcher.minTextLength = 50 // lower for testing
+	fetcher.SetRenderURL(renderServer.URL)
+
+	content, title, err := fetcher.Fetch(articleServer.URL + "/post")
+	require.NoError(t, err)
+	assert.Equal(t, "Rendered Article", title)
+	assert.Contains(t, content, "rendered by JavaScript")
+}
+
+func TestHTTPArticleFetcher_FetchRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	for i := range 3 {
+		mux.HandleFunc(fmt.Sprintf("/hop%d", i), func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, fmt.Sprintf("/hop%d", i+1), http.StatusMovedPermanently)
+		})
+	}
+	mux.HandleFunc("/hop3", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><title>Moved Article</title></head><body><article><p>" +
+			strings.Repeat("The article moved a few times before landing at its final address. ", 5) +
+			"</p></article></body></html>"))
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ftp-hop", http.StatusFound)
+	})
+	mux.HandleFunc("/ftp-hop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://files.example.com/article.txt", http.StatusFound)
+	})
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var logs bytes.Buffer
+	fetcher := NewHTTPArticleFetcher(nil)
+	fetcher.logger = logging.New(&logs, slog.LevelDebug)
+
+	t.Run("chain within the limit", func(t *testing.T) {
+		_, title, err := fetcher.Fetch(server.URL + "/hop0")
+		require.NoError(t, err)
+		assert.Equal(t, "Moved Article", title)
+		assert.Contains(t, logs.String(), "Following redirect from="+server.URL+"/hop2 to="+server.URL+"/hop3 hop=3")
+	})
+
+	t.Run("redirect to ftp", func(t *testing.T) {
+		_, _, err := fetcher.Fetch(server.URL + "/ftp")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "redirected from "+server.URL+"/ftp-hop to ftp://files.example.com/article.txt: "+
+			"unsupported URL scheme: ftp (only http and https are allowed)")
+	})
+
+	t.Run("redirect to file", func(t *testing.T) {
+		_, _, err := fetcher.Fetch(server.URL + "/file")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported URL scheme: file")
+	})
+
+	t.Run("too many redirects", func(t *testing.T) {
+		fetcher.SetMaxRedirects(2)
+		_, _, err := fetcher.Fetch(server.URL + "/hop0")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "stopped after 2 redirects")
+
+		_, _, err = fetcher.Fetch(server.URL + "/hop1")
+		require.NoError(t, err, "two hops are followed")
+	})
+
+	t.Run("zero keeps the default", func(t *testing.T) {
+		fetcher.SetMaxRedirects(0)
+		assert.Equal(t, MaxFetchRedirects, fetcher.maxRedirects)
+	})
+
+	t.Run("client passed in is not changed", func(t *testing.T) {
+		client := &http.Client{}
+		NewHTTPArticleFetcher(client)
+		assert.Nil(t, client.CheckRedirect)
+	})
+}
+
 // failingTransport is a custom transport that always returns an error
 type failingTransport struct{}
 
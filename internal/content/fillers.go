@@ -0,0 +1,174 @@
+package content
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// FillerIntensity controls how aggressively ReduceFillers thins out filler words
+type FillerIntensity string
+
+// supported filler reduction intensities
+const (
+	FillersOff    FillerIntensity = ""       // fillers are kept as generated
+	FillersLight  FillerIntensity = "light"  // up to 2 of each filler per 100 words
+	FillersMedium FillerIntensity = "medium" // up to 1 of each filler per 100 words
+	FillersStrong FillerIntensity = "strong" // up to 1 of each filler per 200 words
+)
+
+// fillerRates are the allowed occurrences of each filler per 100 words
+var fillerRates = map[FillerIntensity]float64{
+	FillersLight:  2,
+	FillersMedium: 1,
+	FillersStrong: 0.5,
+}
+
+// minFillersKept is the number of each filler kept regardless of intensity, so the dialog still sounds natural
+const minFillersKept = 2
+
+var wordRe = regexp.MustCompile(`\p{L}+`)
+
+// ParseFillerIntensity validates the filler reduction intensity, an empty string disables the reduction
+func ParseFillerIntensity(s string) (FillerIntensity, error) {
+	switch intensity := FillerIntensity(strings.ToLower(strings.TrimSpace(s))); intensity {
+	case FillersOff, FillersLight, FillersMedium, FillersStrong:
+		return intensity, nil
+	default:
+		return "", fmt.Errorf("unknown filler intensity %q, expected light, medium or strong", s)
+	}
+}
+
+// fillerSpan is a filler occurrence in a message content, as byte offsets
+type fillerSpan struct {
+	msg        int
+	start, end int
+}
+
+// ReduceFillers thins out filler words of the language repeated across the discussion. each filler is capped
+// by the intensity rate per 100 words, kept occurrences are spread evenly over the discussion and at least
+// minFillersKept remain. fillers are matched as whole words, case-insensitively.
+func (tp *TextProcessor) ReduceFillers(messages []podcast.Message, intensity FillerIntensity) []podcast.Message {
+	rate, ok := fillerRates[intensity]
+	if !ok {
+		return messages
+	}
+
+	fillers := tp.lang.phrases().fillers
+	words := 0
+	found := make(map[string][]fillerSpan)
+	for i, msg := range messages {
+		if msg.AudioFile != "" {
+			continue
+		}
+		spans := wordRe.FindAllStringIndex(msg.Content, -1)
+		words += len(spans)
+		for filler, occurrences := range findFillers(msg.Content, spans, fillers) {
+			for _, span := range occurrences {
+				found[filler] = append(found[filler], fillerSpan{msg: i, start: span[0], end: span[1]})
+			}
+		}
+	}
+
+	limit := max(minFillersKept, int(float64(words)*rate/100))
+	removals := make(map[int][]fillerSpan)
+	for _, occurrences := range found {
+		if len(occurrences) <= limit {
+			continue
+		}
+		keep := make(map[int]bool, limit)
+		for j := range limit {
+			keep[j*len(occurrences)/limit] = true
+		}
+		for j, span := range occurrences {
+			if !keep[j] {
+				removals[span.msg] = append(removals[span.msg], span)
+			}
+		}
+	}
+	if len(removals) == 0 {
+		return messages
+	}
+
+	result := make([]podcast.Message, len(messages))
+	copy(result, messages)
+	for i, spans := range removals {
+		result[i].Content = removeFillers(result[i].Content, spans)
+	}
+	return result
+}
+
+// findFillers returns byte spans of filler occurrences in text, grouped by filler, for the given word spans
+func findFillers(text string, words [][]int, fillers [][]string) map[string][][2]int {
+	result := make(map[string][][2]int)
+	for i := 0; i < len(words); i++ {
+		for _, filler := range fillers {
+			if i+len(filler) > len(words) || !matchWords(text, words[i:i+len(filler)], filler) {
+				continue
+			}
+			last := words[i+len(filler)-1]
+			result[strings.Join(filler, " ")] = append(result[strings.Join(filler, " ")], [2]int{words[i][0], last[1]})
+			i += len(filler) - 1
+			break
+		}
+	}
+	return result
+}
+
+// matchWords reports whether consecutive words equal the filler words, separated by whitespace only
+func matchWords(text string, words [][]int, filler []string) bool {
+	for j, w := range words {
+		if !strings.EqualFold(text[w[0]:w[1]], filler[j]) {
+			return false
+		}
+		if j > 0 && strings.TrimSpace(text[words[j-1][1]:w[0]]) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// removeFillers cuts filler spans from text together with the commas setting them off,
+// and capitalizes the next word when the filler opened a sentence
+func removeFillers(text string, spans []fillerSpan) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	for _, span := range spans {
+		before := strings.TrimRightFunc(text[:span.start], unicode.IsSpace)
+		after := strings.TrimLeftFunc(text[span.end:], unicode.IsSpace)
+		commaAfter := strings.HasPrefix(after, ",")
+		after = strings.TrimLeftFunc(strings.TrimPrefix(after, ","), unicode.IsSpace)
+
+		switch {
+		case before == "" || strings.HasSuffix(before, ".") || strings.HasSuffix(before, "!") ||
+			strings.HasSuffix(before, "?"):
+			after = capitalize(after)
+		case strings.HasSuffix(before, ",") && (commaAfter || after == "" || strings.ContainsAny(after[:1], ".!?")):
+			// "это, как бы, важно" becomes "это важно", the commas only set off the filler
+			before = strings.TrimSuffix(before, ",")
+		}
+
+		switch {
+		case before == "":
+			text = after
+		case after == "" || strings.ContainsAny(after[:1], ".,!?;:"):
+			text = before + after
+		default:
+			text = before + " " + after
+		}
+	}
+	return text
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
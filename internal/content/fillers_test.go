@@ -0,0 +1,140 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+func TestParseFillerIntensity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected FillerIntensity
+		err      bool
+	}{
+		{input: "", expected: FillersOff},
+		{input: "light", expected: FillersLight},
+		{input: " Medium ", expected: FillersMedium},
+		{input: "strong", expected: FillersStrong},
+		{input: "max", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			intensity, err := ParseFillerIntensity(test.input)
+			if test.err {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown filler intensity")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, intensity)
+		})
+	}
+}
+
+func TestTextProcessor_ReduceFillers(t *testing.T) {
+	tp := NewTextProcessor()
+
+	// 30 messages of 13 words, each with "ну" and "как бы", 390 words in total
+	var messages []podcast.Message
+	for range 30 {
+		messages = append(messages, podcast.Message{Host: "Алексей",
+			Content: "Ну, это, как бы, важная тема, и мы обсудим её подробно сегодня."})
+	}
+	countFillers := func(msgs []podcast.Message) (nu, kakBy int) {
+		for _, msg := range msgs {
+			nu += strings.Count(msg.Content, "Ну")
+			kakBy += strings.Count(msg.Content, "как бы")
+		}
+		return nu, kakBy
+	}
+
+	t.Run("repeated fillers reduced to the cap", func(t *testing.T) {
+		result := tp.ReduceFillers(messages, FillersStrong)
+		require.Len(t, result, len(messages))
+		nu, kakBy := countFillers(result)
+		assert.Equal(t, minFillersKept, nu, "a couple of fillers remain")
+		assert.Equal(t, minFillersKept, kakBy)
+
+		assert.Equal(t, "Ну, это, как бы, важная тема, и мы обсудим её подробно сегодня.", result[0].Content,
+			"kept occurrences are spread from the start")
+		assert.Equal(t, "Это важная тема, и мы обсудим её подробно сегодня.", result[1].Content)
+
+		nu, kakBy = countFillers(messages)
+		assert.Equal(t, 30, nu, "input is not modified")
+		assert.Equal(t, 30, kakBy)
+	})
+
+	t.Run("cap follows intensity", func(t *testing.T) {
+		nuLight, _ := countFillers(tp.ReduceFillers(messages, FillersLight))
+		nuStrong, _ := countFillers(tp.ReduceFillers(messages, FillersStrong))
+		assert.Less(t, nuLight, 30)
+		assert.Greater(t, nuLight, nuStrong)
+	})
+
+	t.Run("off keeps everything", func(t *testing.T) {
+		assert.Equal(t, messages, tp.ReduceFillers(messages, FillersOff))
+	})
+
+	t.Run("rare fillers are kept", func(t *testing.T) {
+		msgs := []podcast.Message{{Host: "Мария", Content: "Ну, посмотрим. Вот пример."}}
+		assert.Equal(t, msgs, tp.ReduceFillers(msgs, FillersStrong))
+	})
+
+	t.Run("whole words only", func(t *testing.T) {
+		msgs := make([]podcast.Message, 5)
+		for i := range msgs {
+			msgs[i] = podcast.Message{Host: "Мария", Content: "Нужно вот так, типичный вотум."}
+		}
+		result := tp.ReduceFillers(msgs, FillersStrong)
+		assert.Equal(t, "Нужно вот так, типичный вотум.", result[0].Content)
+		assert.Equal(t, "Нужно так, типичный вотум.", result[4].Content)
+	})
+
+	t.Run("fillers of the episode language", func(t *testing.T) {
+		msgs := make([]podcast.Message, 5)
+		for i := range msgs {
+			msgs[i] = podcast.Message{Host: "Mary", Content: "Well, it's, you know, a big deal. Ну вот."}
+		}
+		en := NewTextProcessor()
+		en.SetLanguage(LanguageEnglish)
+		result := en.ReduceFillers(msgs, FillersStrong)
+		assert.Equal(t, "Well, it's, you know, a big deal. Ну вот.", result[0].Content)
+		assert.Equal(t, "It's a big deal. Ну вот.", result[4].Content, "russian fillers are left in an english episode")
+	})
+
+	t.Run("audio messages are skipped", func(t *testing.T) {
+		msgs := []podcast.Message{{Host: "Реклама", Content: "ну ну ну ну", AudioFile: "ad.mp3"}}
+		assert.Equal(t, msgs, tp.ReduceFillers(msgs, FillersStrong))
+	})
+}
+
+func TestRemoveFillers(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		filler   string
+		expected string
+	}{
+		{name: "sentence start", text: "Ну, это так.", filler: "Ну", expected: "Это так."},
+		{name: "set off by commas", text: "Это, как бы, важно.", filler: "как бы", expected: "Это важно."},
+		{name: "after comma", text: "Да, ну это так.", filler: "ну", expected: "Да, это так."},
+		{name: "mid sentence", text: "Это вот важно.", filler: "вот", expected: "Это важно."},
+		{name: "before period", text: "Это важно, короче.", filler: "короче", expected: "Это важно."},
+		{name: "second sentence", text: "Да. Типа это так.", filler: "Типа", expected: "Да. Это так."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := strings.Index(test.text, test.filler)
+			require.GreaterOrEqual(t, start, 0)
+			span := fillerSpan{start: start, end: start + len(test.filler)}
+			assert.Equal(t, test.expected, removeFillers(test.text, []fillerSpan{span}))
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package content
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Language is the language of the episode, the discussion is requested in it and its durations are estimated
+// by its speech rate
+type Language string
+
+// supported episode languages
+const (
+	LanguageRussian Language = "ru"
+	LanguageEnglish Language = "en"
+	LanguageSpanish Language = "es"
+)
+
+// speechRate is the average word length without spaces and the speaking speed of a language
+type speechRate struct {
+	charsPerWord   float64
+	wordsPerMinute float64
+}
+
+var speechRates = map[Language]speechRate{
+	LanguageRussian: {charsPerWord: avgCharsPerWordRussian, wordsPerMinute: avgWordsPerMinuteRussian},
+	LanguageEnglish: {charsPerWord: avgCharsPerWordEnglish, wordsPerMinute: avgWordsPerMinuteEnglish},
+	LanguageSpanish: {charsPerWord: avgCharsPerWordSpanish, wordsPerMinute: avgWordsPerMinuteSpanish},
+}
+
+// phrases are the fixed lines and labels of a language, spoken or printed outside the generated discussion
+type phrases struct {
+	greeting   string     // voice sample line, %s is the host name
+	greetingAs string     // voice sample line of a host with a character, %s are the name and the character
+	check      string     // line synthesized by the preflight check, short to keep it cheap
+	ad         string     // speaker label of advertisement breaks
+	hosts      string     // heading of the host list in the printed script
+	fillers    [][]string // filler words thinned out by ReduceFillers, multi-word fillers go first
+}
+
+var languagePhrases = map[Language]phrases{
+	LanguageRussian: {
+		greeting:   "Привет, я %s.",
+		greetingAs: "Привет, я %s, %s.",
+		check:      "Проверка.",
+		ad:         "Реклама",
+		hosts:      "Ведущие",
+		fillers: [][]string{
+			{"как", "бы"}, {"в", "общем"}, {"так", "сказать"}, {"короче"}, {"типа"}, {"ну"}, {"вот"},
+		},
+	},
+	LanguageEnglish: {
+		greeting:   "Hi, I'm %s.",
+		greetingAs: "Hi, I'm %s, %s.",
+		check:      "Check.",
+		ad:         "Advertisement",
+		hosts:      "Hosts",
+		fillers: [][]string{
+			{"you", "know"}, {"i", "mean"}, {"sort", "of"}, {"kind", "of"}, {"basically"}, {"actually"}, {"well"},
+		},
+	},
+	LanguageSpanish: {
+		greeting:   "Hola, soy %s.",
+		greetingAs: "Hola, soy %s, %s.",
+		check:      "Prueba.",
+		ad:         "Publicidad",
+		hosts:      "Presentadores",
+		fillers: [][]string{
+			{"o", "sea"}, {"en", "plan"}, {"digamos"}, {"bueno"}, {"pues"}, {"vale"},
+		},
+	},
+}
+
+// ParseLanguage validates the language code, an empty string is Russian
+func ParseLanguage(s string) (Language, error) {
+	lang := Language(strings.ToLower(strings.TrimSpace(s)))
+	if lang == "" {
+		return LanguageRussian, nil
+	}
+	if _, ok := speechRates[lang]; !ok {
+		return "", fmt.Errorf("unknown language %q, expected ru, en or es", s)
+	}
+	return lang, nil
+}
+
+// rate returns the speech rate of the language, Russian for an unknown one
+func (l Language) rate() speechRate {
+	if rate, ok := speechRates[l]; ok {
+		return rate
+	}
+	return speechRates[LanguageRussian]
+}
+
+// phrases returns the fixed lines of the language, Russian for an unknown one
+func (l Language) phrases() phrases {
+	if p, ok := languagePhrases[l]; ok {
+		return p
+	}
+	return languagePhrases[LanguageRussian]
+}
+
+// Greeting returns the self-introduction line of a host, with the character when it's set
+func (l Language) Greeting(name, character string) string {
+	if character != "" {
+		return fmt.Sprintf(l.phrases().greetingAs, name, character)
+	}
+	return fmt.Sprintf(l.phrases().greeting, name)
+}
+
+// CheckText returns the short line synthesized to check the TTS voices
+func (l Language) CheckText() string {
+	return l.phrases().check
+}
+
+// AdHost returns the speaker label of advertisement break messages
+func (l Language) AdHost() string {
+	return l.phrases().ad
+}
+
+// HostsHeading returns the heading of the host list in the printed script
+func (l Language) HostsHeading() string {
+	return l.phrases().hosts
+}
@@ -0,0 +1,61 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Language
+		err      string
+	}{
+		{input: "", expected: LanguageRussian},
+		{input: "ru", expected: LanguageRussian},
+		{input: "EN", expected: LanguageEnglish},
+		{input: " es ", expected: LanguageSpanish},
+		{input: "de", err: `unknown language "de", expected ru, en or es`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			lang, err := ParseLanguage(test.input)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, lang)
+		})
+	}
+}
+
+func TestLanguagePhrases(t *testing.T) {
+	tests := []struct {
+		lang                        Language
+		greeting, greetingAs, check string
+		adHost, hostsHeading        string
+	}{
+		{lang: LanguageRussian, greeting: "Привет, я Анна.", greetingAs: "Привет, я Анна, аналитик.", check: "Проверка.",
+			adHost: "Реклама", hostsHeading: "Ведущие"},
+		{lang: LanguageEnglish, greeting: "Hi, I'm Анна.", greetingAs: "Hi, I'm Анна, аналитик.", check: "Check.",
+			adHost: "Advertisement", hostsHeading: "Hosts"},
+		{lang: LanguageSpanish, greeting: "Hola, soy Анна.", greetingAs: "Hola, soy Анна, аналитик.", check: "Prueba.",
+			adHost: "Publicidad", hostsHeading: "Presentadores"},
+		{lang: "", greeting: "Привет, я Анна.", greetingAs: "Привет, я Анна, аналитик.", check: "Проверка.",
+			adHost: "Реклама", hostsHeading: "Ведущие"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.lang), func(t *testing.T) {
+			assert.Equal(t, test.greeting, test.lang.Greeting("Анна", ""))
+			assert.Equal(t, test.greetingAs, test.lang.Greeting("Анна", "аналитик"))
+			assert.Equal(t, test.check, test.lang.CheckText())
+			assert.Equal(t, test.adHost, test.lang.AdHost())
+			assert.Equal(t, test.hostsHeading, test.lang.HostsHeading())
+		})
+	}
+}
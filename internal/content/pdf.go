@@ -0,0 +1,75 @@
+package content
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// isPDF reports whether the response holds a PDF document, by its content type or by the .pdf URL extension.
+// the extension is trusted only when the server doesn't claim a text type, so HTML landing pages stay HTML.
+func isPDF(contentType string, u *url.URL) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/pdf" {
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".pdf") && !strings.HasPrefix(mediaType, "text/")
+}
+
+// extractPDF reads a PDF document and returns its text, one line per text row, and its title.
+// the title comes from the document metadata, or the first text line when the metadata has none.
+func extractPDF(r io.Reader) (text, title string, err error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxPDFSize+1))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if len(data) > maxPDFSize {
+		return "", "", fmt.Errorf("PDF is larger than %d bytes", maxPDFSize)
+	}
+
+	// the parser panics on malformed documents
+	defer func() {
+		if rec := recover(); rec != nil {
+			text, title, err = "", "", fmt.Errorf("malformed PDF: %v", rec)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse PDF: %w", err)
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		writePDFLines(&sb, page.Content().Text)
+	}
+	text = strings.TrimSpace(sb.String())
+
+	title = strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text())
+	if title == "" {
+		title, _, _ = strings.Cut(text, "\n")
+		title = strings.TrimSpace(title)
+	}
+	return text, title, nil
+}
+
+// writePDFLines writes page glyphs in content order, starting a new line whenever the baseline moves
+func writePDFLines(sb *strings.Builder, glyphs []pdf.Text) {
+	for i, glyph := range glyphs {
+		if i > 0 && math.Abs(glyph.Y-glyphs[i-1].Y) > 1 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(glyph.S)
+	}
+	sb.WriteString("\n")
+}
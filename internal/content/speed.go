@@ -0,0 +1,53 @@
+package content
+
+import "math"
+
+// SpeedController recomputes the speech speed factor while an episode is being generated.
+// every few segments it compares measured durations with the estimates and derives the factor
+// needed for the remaining messages to land on the target duration.
+type SpeedController struct {
+	targetSeconds   float64
+	checkpointEvery int
+	speed           float64
+
+	segments         int
+	measuredSeconds  float64 // synthesized duration of the segments so far
+	estimatedSeconds float64
+	playedSeconds    float64 // duration of the segments so far at the speed each of them is played at
+}
+
+// NewSpeedController creates a controller for the target duration starting from the initial speed factor
+func NewSpeedController(targetSeconds, initialSpeed float64, checkpointEvery int) *SpeedController {
+	return &SpeedController{
+		targetSeconds:   targetSeconds,
+		checkpointEvery: checkpointEvery,
+		speed:           initialSpeed,
+	}
+}
+
+// Speed returns the current speed factor
+func (c *SpeedController) Speed() float64 {
+	return c.speed
+}
+
+// Record adds a generated segment with its measured and estimated durations, the segment is played at the current
+// speed. on checkpoints it recomputes the speed for the remaining estimated duration and reports true.
+func (c *SpeedController) Record(measuredSeconds, estimatedSeconds, remainingEstimatedSeconds float64) (float64, bool) {
+	c.segments++
+	c.measuredSeconds += measuredSeconds
+	c.estimatedSeconds += estimatedSeconds
+	c.playedSeconds += measuredSeconds * c.speed
+
+	if c.checkpointEvery <= 0 || c.segments%c.checkpointEvery != 0 || remainingEstimatedSeconds <= 0 {
+		return c.speed, false
+	}
+
+	// scale the remaining estimate by how far off the estimates were so far
+	calibration := 1.0
+	if c.estimatedSeconds > 0 && c.measuredSeconds > 0 {
+		calibration = c.measuredSeconds / c.estimatedSeconds
+	}
+	remainingTarget := c.targetSeconds - c.playedSeconds
+	c.speed = math.Max(minSpeechSpeed, math.Min(maxSpeechSpeed, remainingTarget/(remainingEstimatedSeconds*calibration)))
+	return c.speed, true
+}
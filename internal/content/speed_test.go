@@ -0,0 +1,94 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeedController_Record(t *testing.T) {
+	type segment struct {
+		measured, estimated, remaining float64
+		speed                          float64
+		checkpoint                     bool
+	}
+	tests := []struct {
+		name     string
+		target   float64
+		every    int
+		segments []segment
+	}{
+		{
+			name:   "recomputed on each checkpoint",
+			target: 100,
+			every:  2,
+			segments: []segment{
+				{measured: 10, estimated: 10, remaining: 80, speed: 1.0},
+				{measured: 10, estimated: 10, remaining: 70, speed: 80.0 / 70.0, checkpoint: true},
+				{measured: 10, estimated: 10, remaining: 60, speed: 80.0 / 70.0},
+				// the last two segments are played slower, 20*80/70 seconds of the target are taken
+				{measured: 10, estimated: 10, remaining: 50, speed: (80.0 - 160.0/7.0) / 50.0, checkpoint: true},
+			},
+		},
+		{
+			name:   "measured longer than estimated",
+			target: 100,
+			every:  1,
+			segments: []segment{
+				{measured: 11, estimated: 10, remaining: 80, speed: 89.0 / 88.0, checkpoint: true},
+			},
+		},
+		{
+			name:   "clamped to minimum",
+			target: 60,
+			every:  1,
+			segments: []segment{
+				{measured: 30, estimated: 10, remaining: 50, speed: 0.8, checkpoint: true},
+			},
+		},
+		{
+			name:   "clamped to minimum when target already exceeded",
+			target: 10,
+			every:  1,
+			segments: []segment{
+				{measured: 20, estimated: 20, remaining: 50, speed: 0.8, checkpoint: true},
+			},
+		},
+		{
+			name:   "clamped to maximum",
+			target: 600,
+			every:  1,
+			segments: []segment{
+				{measured: 10, estimated: 10, remaining: 100, speed: 1.2, checkpoint: true},
+			},
+		},
+		{
+			name:   "nothing left to generate",
+			target: 100,
+			every:  1,
+			segments: []segment{
+				{measured: 10, estimated: 10, remaining: 0, speed: 1.0},
+			},
+		},
+		{
+			name:   "checkpoints disabled",
+			target: 100,
+			every:  0,
+			segments: []segment{
+				{measured: 50, estimated: 10, remaining: 10, speed: 1.0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewSpeedController(test.target, 1.0, test.every)
+			for i, seg := range test.segments {
+				speed, checkpoint := c.Record(seg.measured, seg.estimated, seg.remaining)
+				assert.Equal(t, seg.checkpoint, checkpoint, "segment %d", i)
+				assert.InDelta(t, seg.speed, speed, 0.0001, "segment %d", i)
+				assert.InDelta(t, seg.speed, c.Speed(), 0.0001, "segment %d", i)
+			}
+		})
+	}
+}
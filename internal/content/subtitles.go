@@ -0,0 +1,106 @@
+package content
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// supported subtitle formats
+const (
+	SubtitlesSRT = "srt"
+	SubtitlesVTT = "vtt"
+)
+
+// subtitleLineWidth is the max characters of a subtitle line, longer messages are wrapped at word boundaries
+const subtitleLineWidth = 42
+
+// vttEscaper escapes characters with a special meaning in WebVTT cue text
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// BuildSubtitles returns SRT or WebVTT subtitles of the messages, one cue per message.
+// cues follow each other, the duration of each one is estimated by EstimateAudioDuration,
+// so the timeline matches the concatenated audio as far as the estimate for the language does.
+// messages without text are skipped.
+func BuildSubtitles(messages []podcast.Message, format string, lang Language) (string, error) {
+	if format != SubtitlesSRT && format != SubtitlesVTT {
+		return "", fmt.Errorf("unknown subtitle format %q, expected %s or %s", format, SubtitlesSRT, SubtitlesVTT)
+	}
+
+	tp := NewTextProcessor()
+	tp.SetLanguage(lang)
+	var sb strings.Builder
+	if format == SubtitlesVTT {
+		sb.WriteString("WEBVTT\n")
+	}
+	var start float64
+	cue := 0
+	for _, msg := range messages {
+		text := strings.Join(strings.Fields(msg.Content), " ")
+		duration := tp.EstimateAudioDuration(text)
+		if text == "" {
+			start += duration
+			continue
+		}
+		cue++
+		if cue > 1 || format == SubtitlesVTT {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n", cue, subtitleTime(start, format), subtitleTime(start+duration, format))
+		sb.WriteString(cueText(msg.Host, text, format))
+		sb.WriteByte('\n')
+		start += duration
+	}
+	return sb.String(), nil
+}
+
+// cueText returns the wrapped cue lines with the speaker, a voice tag in WebVTT and a "Host:" prefix in SRT
+func cueText(host, text, format string) string {
+	host = strings.Join(strings.Fields(host), " ")
+	if format == SubtitlesVTT {
+		lines := wrapLine(text, subtitleLineWidth)
+		for i, line := range lines {
+			lines[i] = vttEscaper.Replace(line)
+		}
+		return fmt.Sprintf("<v %s>%s", vttEscaper.Replace(host), strings.Join(lines, "\n"))
+	}
+	// an arrow in the text would be taken for a timing line
+	text = strings.ReplaceAll(text, "-->", "->")
+	if host != "" {
+		text = host + ": " + text
+	}
+	return strings.Join(wrapLine(text, subtitleLineWidth), "\n")
+}
+
+// wrapLine splits text into lines of at most width characters at spaces, a longer word gets a line of its own
+func wrapLine(text string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(text) {
+		if line.Len() > 0 && utf8.RuneCountInString(line.String())+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// subtitleTime formats seconds as hh:mm:ss,mmm for SRT and hh:mm:ss.mmm for WebVTT
+func subtitleTime(seconds float64, format string) string {
+	ms := int64(math.Round(seconds * 1000))
+	sep := ","
+	if format == SubtitlesVTT {
+		sep = "."
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
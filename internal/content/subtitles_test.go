@@ -0,0 +1,102 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+func TestBuildSubtitles(t *testing.T) {
+	// 44 characters without spaces take exactly 3 seconds by the estimate
+	messages := []podcast.Message{
+		{Host: "Алексей", Content: strings.TrimSpace(strings.Repeat("тест ", 11))},
+		{Host: "Мария", Content: "a < b\n\n& c > d"},     // 7 characters, 0.477s
+		{Host: "Реклама", Ad: true, AudioFile: "ad.mp3"}, // no text, no cue
+		{Host: "Дмитрий", Content: "x --> y"},            // 5 characters, 0.341s
+	}
+
+	t.Run("srt", func(t *testing.T) {
+		subtitles, err := BuildSubtitles(messages, SubtitlesSRT, LanguageRussian)
+		require.NoError(t, err)
+		expected := "1\n" +
+			"00:00:00,000 --> 00:00:03,000\n" +
+			"Алексей: тест тест тест тест тест тест\n" +
+			"тест тест тест тест тест\n" +
+			"\n" +
+			"2\n" +
+			"00:00:03,000 --> 00:00:03,477\n" +
+			"Мария: a < b & c > d\n" +
+			"\n" +
+			"3\n" +
+			"00:00:03,477 --> 00:00:03,818\n" +
+			"Дмитрий: x -> y\n"
+		assert.Equal(t, expected, subtitles)
+	})
+
+	t.Run("vtt", func(t *testing.T) {
+		subtitles, err := BuildSubtitles(messages, SubtitlesVTT, LanguageRussian)
+		require.NoError(t, err)
+		expected := "WEBVTT\n" +
+			"\n" +
+			"1\n" +
+			"00:00:00.000 --> 00:00:03.000\n" +
+			"<v Алексей>тест тест тест тест тест тест тест тест\n" +
+			"тест тест тест\n" +
+			"\n" +
+			"2\n" +
+			"00:00:03.000 --> 00:00:03.477\n" +
+			"<v Мария>a &lt; b &amp; c &gt; d\n" +
+			"\n" +
+			"3\n" +
+			"00:00:03.477 --> 00:00:03.818\n" +
+			"<v Дмитрий>x --&gt; y\n"
+		assert.Equal(t, expected, subtitles)
+	})
+
+	t.Run("last cue ends at the total estimate", func(t *testing.T) {
+		long := make([]podcast.Message, 200)
+		for i := range long {
+			long[i] = podcast.Message{Host: "Мария", Content: strings.Repeat("слово ", i%7+1)}
+		}
+		subtitles, err := BuildSubtitles(long, SubtitlesSRT, LanguageRussian)
+		require.NoError(t, err)
+		var lastTiming string
+		for _, line := range strings.Split(subtitles, "\n") {
+			if strings.Contains(line, " --> ") {
+				lastTiming = line
+			}
+		}
+		total := NewTextProcessor().EstimateTotalDuration(long)
+		assert.True(t, strings.HasSuffix(lastTiming, " --> "+subtitleTime(total, SubtitlesSRT)), lastTiming)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		subtitles, err := BuildSubtitles(nil, SubtitlesVTT, LanguageRussian)
+		require.NoError(t, err)
+		assert.Equal(t, "WEBVTT\n", subtitles)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := BuildSubtitles(messages, "ass", LanguageRussian)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown subtitle format "ass", expected srt or vtt`)
+	})
+}
+
+func TestSubtitleTime(t *testing.T) {
+	assert.Equal(t, "00:00:00,000", subtitleTime(0, SubtitlesSRT))
+	assert.Equal(t, "00:00:01,500", subtitleTime(1.4996, SubtitlesSRT))
+	assert.Equal(t, "01:02:05,250", subtitleTime(3725.25, SubtitlesSRT))
+	assert.Equal(t, "01:02:05.250", subtitleTime(3725.25, SubtitlesVTT))
+}
+
+func TestWrapLine(t *testing.T) {
+	assert.Equal(t, []string{"one two", "three"}, wrapLine("one two three", 7))
+	assert.Equal(t, []string{"a", "verylongword", "b"}, wrapLine("a verylongword b", 5))
+	assert.Equal(t, []string{"привет мир"}, wrapLine("привет мир", 10), "width counts characters, not bytes")
+	assert.Empty(t, wrapLine("  ", 10))
+}
@@ -2,24 +2,33 @@ package content
 
 import (
 	"math"
+	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/radio-t/ai-podcast/podcast"
 )
 
 // TextProcessor handles text-related operations
-type TextProcessor struct{}
+type TextProcessor struct {
+	lang Language // language of the estimated speech, Russian when empty
+}
 
 // NewTextProcessor creates a new text processor
 func NewTextProcessor() *TextProcessor {
 	return &TextProcessor{}
 }
 
+// SetLanguage sets the language whose speech rate is used by the duration estimates
+func (tp *TextProcessor) SetLanguage(lang Language) {
+	tp.lang = lang
+}
+
 // EstimateAudioDuration estimates the spoken duration of text in seconds
 func (tp *TextProcessor) EstimateAudioDuration(text string) float64 {
-	// average Russian reading speed is about 150-170 words per minute
-	// we'll use 160 words per minute as our baseline
-	// russian has an average of about 5-6 characters per word
+	// average speech is about 160 words per minute for Russian, 150 for English and 165 for Spanish,
+	// russian words are about 5-6 characters long, english and spanish ones a bit shorter
+	rate := tp.lang.rate()
 
 	// count characters excluding spaces
 	charCount := 0
@@ -30,14 +39,23 @@ func (tp *TextProcessor) EstimateAudioDuration(text string) float64 {
 	}
 
 	// estimate word count
-	estimatedWords := float64(charCount) / avgCharsPerWordRussian
+	estimatedWords := float64(charCount) / rate.charsPerWord
 
 	// calculate duration in seconds
-	durationSeconds := estimatedWords / avgWordsPerMinuteRussian * 60.0
+	durationSeconds := estimatedWords / rate.wordsPerMinute * 60.0
 
 	return durationSeconds
 }
 
+// WordBudget returns how many words are spoken in minutes at the speech rate of the language,
+// the same rate EstimateAudioDuration uses, so a discussion of that many words is estimated at about minutes
+func (tp *TextProcessor) WordBudget(minutes int) int {
+	if minutes <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(minutes) * tp.lang.rate().wordsPerMinute))
+}
+
 // EstimateTotalDuration estimates the total duration of all messages
 func (tp *TextProcessor) EstimateTotalDuration(messages []podcast.Message) float64 {
 	var totalDuration float64
@@ -50,7 +68,7 @@ func (tp *TextProcessor) EstimateTotalDuration(messages []podcast.Message) float
 // CalculateSpeechSpeed determines the speech speed factor to match target duration
 func (tp *TextProcessor) CalculateSpeechSpeed(estimatedDuration float64, targetDurationMinutes int) float64 {
 	speechSpeed := 1.0
-	if estimatedDuration <= 0 {
+	if estimatedDuration <= 0 || targetDurationMinutes <= 0 {
 		return speechSpeed
 	}
 
@@ -79,6 +97,341 @@ func (tp *TextProcessor) TruncateString(s string, maxLength int) string {
 	return string(runes[:maxLength]) + "..."
 }
 
+// TruncateText cuts text to at most maxChars characters without breaking words. it ends after the last complete
+// sentence that fits, unless that drops more than a fifth of the allowed text, then it ends on the last whole word
+// with "..." marking the cut. text within the limit is returned as is.
+func (tp *TextProcessor) TruncateText(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	const ellipsis = "..."
+	if maxChars <= len(ellipsis) {
+		return string(runes[:max(maxChars, 0)])
+	}
+
+	for i := maxChars - 1; i >= maxChars*4/5; i-- {
+		if sentenceEnd(runes[i], runes[i+1]) {
+			return string(runes[:i+1])
+		}
+	}
+
+	cut := maxChars - len(ellipsis)
+	if !unicode.IsSpace(runes[cut]) {
+		// back to the start of the word, a word longer than half of the text is cut as is
+		for i := cut - 1; i > cut/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + ellipsis
+}
+
+// sentenceEnd reports whether a sentence or a paragraph ends with r followed by next
+func sentenceEnd(r, next rune) bool {
+	if unicode.IsSpace(r) {
+		return false
+	}
+	return next == '\n' || (strings.ContainsRune(".!?…", r) && unicode.IsSpace(next))
+}
+
+// Sanitize strips the BOM, zero-width and other invisible format characters, and control characters
+// except newlines and tabs. such characters inflate char counts used for duration estimates and confuse TTS.
+func (tp *TextProcessor) Sanitize(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// NormalizeWhitespace collapses runs of spaces, tabs and other horizontal whitespace into a single space
+// and runs of blank lines into one, so extracted text doesn't inflate char counts and duration estimates.
+// lines are trimmed, single line breaks and paragraph breaks are kept.
+func (tp *TextProcessor) NormalizeWhitespace(text string) string {
+	var sb strings.Builder
+	sb.Grow(len(text))
+	blank := false // a blank line is pending before the next non-empty one
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.FieldsFunc(line, unicode.IsSpace), " ")
+		if line == "" {
+			blank = sb.Len() > 0
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+			if blank {
+				sb.WriteByte('\n')
+			}
+		}
+		sb.WriteString(line)
+		blank = false
+	}
+	return sb.String()
+}
+
+// Slug turns text into a lowercase file name of letters and digits joined by dashes, at most maxLen characters.
+// letters of any script are kept, so a Russian title gives a readable Cyrillic slug.
+func (tp *TextProcessor) Slug(text string, maxLen int) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var sb strings.Builder
+	length := 0
+	for _, word := range words {
+		wordLen := utf8.RuneCountInString(word)
+		if length > 0 && length+1+wordLen > maxLen {
+			break
+		}
+		if length == 0 && wordLen > maxLen {
+			return string([]rune(word)[:maxLen])
+		}
+		if length > 0 {
+			sb.WriteByte('-')
+			length++
+		}
+		sb.WriteString(word)
+		length += wordLen
+	}
+	return sb.String()
+}
+
+// SanitizeMessages returns messages with sanitized content, messages left empty are dropped
+func (tp *TextProcessor) SanitizeMessages(messages []podcast.Message) []podcast.Message {
+	result := make([]podcast.Message, 0, len(messages))
+	for _, msg := range messages {
+		msg.Host = strings.TrimSpace(tp.Sanitize(msg.Host))
+		msg.Content = strings.TrimSpace(tp.Sanitize(msg.Content))
+		if msg.Content == "" && msg.AudioFile == "" {
+			continue
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+// TTSChars counts characters sent to TTS for the messages, pre-recorded audio is not counted
+func (tp *TextProcessor) TTSChars(messages []podcast.Message) int {
+	total := 0
+	for _, msg := range messages {
+		if msg.AudioFile != "" {
+			continue
+		}
+		total += utf8.RuneCountInString(msg.Content)
+	}
+	return total
+}
+
+// TrimToTTSChars returns the leading messages whose TTS characters fit into maxChars
+func (tp *TextProcessor) TrimToTTSChars(messages []podcast.Message, maxChars int) []podcast.Message {
+	total := 0
+	for i, msg := range messages {
+		total += tp.TTSChars([]podcast.Message{msg})
+		if total > maxChars {
+			return messages[:i]
+		}
+	}
+	return messages
+}
+
+// MergeConsecutive limits runs of turns by the same host to maxTurns, merging the turns of a longer run
+// into maxTurns messages of similar size. ads and pre-recorded audio break runs and are never merged.
+func (tp *TextProcessor) MergeConsecutive(messages []podcast.Message, maxTurns int) []podcast.Message {
+	if maxTurns <= 0 {
+		return messages
+	}
+
+	result := make([]podcast.Message, 0, len(messages))
+	for start := 0; start < len(messages); {
+		end := start + 1
+		for end < len(messages) && mergeable(messages[start], messages[end]) {
+			end++
+		}
+		run := messages[start:end]
+		if len(run) <= maxTurns {
+			result = append(result, run...)
+			start = end
+			continue
+		}
+		// spread the turns over maxTurns messages, earlier messages take the remainder
+		for i := range maxTurns {
+			from, to := i*len(run)/maxTurns, (i+1)*len(run)/maxTurns
+			merged := run[from]
+			for _, msg := range run[from+1 : to] {
+				merged.Content += " " + msg.Content
+			}
+			result = append(result, merged)
+		}
+		start = end
+	}
+	return result
+}
+
+// mergeable reports whether next continues the run of turns started by first
+func mergeable(first, next podcast.Message) bool {
+	return first.Host == next.Host && !first.Ad && !next.Ad && first.AudioFile == "" && next.AudioFile == ""
+}
+
+// quotePairs are bracket and quote characters balanced by BalanceQuotes, each pair independently
+var quotePairs = []struct {
+	open, close rune
+	numbers     bool // the closing one after a digit numbers a list item, e.g. "1)", and has no pair
+}{
+	{open: '«', close: '»'},
+	{open: '“', close: '”'},
+	{open: '(', close: ')', numbers: true},
+	{open: '[', close: ']', numbers: true},
+}
+
+// BalanceQuotes drops unmatched quotes and brackets, which TTS reads oddly.
+// paired characters are matched by nesting, straight double quotes pair up in order and an odd last one is dropped.
+// a closing bracket right after a digit is kept, it numbers a list item, e.g. "1)".
+func (tp *TextProcessor) BalanceQuotes(text string) string {
+	runes := []rune(text)
+	drop := unbalancedQuotes(runes)
+	if len(drop) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	for i, r := range runes {
+		if drop[i] {
+			continue
+		}
+		// a character dropped between two spaces leaves one of them
+		if r == ' ' && i > 1 && drop[i-1] && runes[i-2] == ' ' {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// unbalancedQuotes returns positions of quotes and brackets without a pair
+func unbalancedQuotes(runes []rune) map[int]bool {
+	drop := make(map[int]bool)
+	for _, pair := range quotePairs {
+		var open []int
+		for i, r := range runes {
+			switch {
+			case r == pair.open:
+				open = append(open, i)
+			case r == pair.close && len(open) > 0:
+				open = open[:len(open)-1]
+			case r == pair.close && pair.numbers && i > 0 && unicode.IsDigit(runes[i-1]):
+				// list numbering, kept
+			case r == pair.close:
+				drop[i] = true
+			}
+		}
+		for _, i := range open {
+			drop[i] = true
+		}
+	}
+
+	lastStraight, straight := -1, 0
+	for i, r := range runes {
+		if r == '"' {
+			lastStraight = i
+			straight++
+		}
+	}
+	if straight%2 == 1 {
+		drop[lastStraight] = true
+	}
+	return drop
+}
+
+// BalanceQuotesMessages returns messages with quotes and brackets balanced by BalanceQuotes
+func (tp *TextProcessor) BalanceQuotesMessages(messages []podcast.Message) []podcast.Message {
+	result := make([]podcast.Message, len(messages))
+	for i, msg := range messages {
+		msg.Content = tp.BalanceQuotes(msg.Content)
+		result[i] = msg
+	}
+	return result
+}
+
+// SplitIntoParts partitions text into up to parts chunks of similar length, cutting on paragraph boundaries.
+// when there are fewer paragraphs than parts, sentences are used instead.
+func (tp *TextProcessor) SplitIntoParts(text string, parts int) []string {
+	text = strings.TrimSpace(text)
+	if parts <= 1 || text == "" {
+		return []string{text}
+	}
+
+	units, sep := splitParagraphs(text), "\n"
+	if len(units) < parts {
+		units, sep = splitSentences(text), " "
+	}
+	if len(units) <= parts {
+		return units
+	}
+
+	total := 0
+	for _, unit := range units {
+		total += utf8.RuneCountInString(unit)
+	}
+
+	result := make([]string, 0, parts)
+	var current []string
+	size := 0
+	for i, unit := range units {
+		current = append(current, unit)
+		size += utf8.RuneCountInString(unit)
+
+		remainingParts := parts - len(result) - 1
+		if remainingParts == 0 {
+			continue // the last part takes everything left
+		}
+		remainingUnits := len(units) - i - 1
+		if size*parts >= total*(len(result)+1) || remainingUnits == remainingParts {
+			result = append(result, strings.Join(current, sep))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		result = append(result, strings.Join(current, sep))
+	}
+	return result
+}
+
+// splitParagraphs returns non-empty lines of the text
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+// splitSentences splits text after sentence-ending punctuation followed by whitespace
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			if sentence := strings.TrimSpace(string(runes[start : i+1])); sentence != "" {
+				sentences = append(sentences, sentence)
+			}
+			start = i + 1
+		}
+	}
+	if tail := strings.TrimSpace(string(runes[start:])); tail != "" {
+		sentences = append(sentences, tail)
+	}
+	return sentences
+}
+
 // Keep these as package-level functions for backward compatibility
 func estimateAudioDuration(text string) float64 {
 	tp := NewTextProcessor()
@@ -1,10 +1,13 @@
 package content
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/radio-t/ai-podcast/podcast"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTextProcessor_EstimateAudioDuration(t *testing.T) {
@@ -79,6 +82,60 @@ func TestTextProcessor_TruncateString(t *testing.T) {
 	}
 }
 
+func TestTextProcessor_TruncateText(t *testing.T) {
+	tp := NewTextProcessor()
+
+	tests := []struct {
+		name     string
+		input    string
+		maxChars int
+		expected string
+	}{
+		{name: "within limit", input: "First sentence. Second one.", maxChars: 27, expected: "First sentence. Second one."},
+		{name: "after the last complete sentence", input: "One two three. Four five six. Seven eight nine.",
+			maxChars: 35, expected: "One two three. Four five six."},
+		{name: "sentence too early, whole words", input: "Short. Then a very long sentence without any end in sight",
+			maxChars: 30, expected: "Short. Then a very long..."},
+		{name: "paragraph end", input: "Заголовок раздела\nПервый абзац текста продолжается", maxChars: 20,
+			expected: "Заголовок раздела"},
+		{name: "russian words not broken", input: "Разработчики обсуждают новую версию языка", maxChars: 25,
+			expected: "Разработчики обсуждают..."},
+		{name: "question and exclamation", input: "Что дальше? Никто не знает! А может и знает", maxChars: 30,
+			expected: "Что дальше? Никто не знает!"},
+		{name: "long word cut as is", input: "Donaudampfschifffahrtsgesellschaftskapitän", maxChars: 13, expected: "Donaudampf..."},
+		{name: "tiny limit", input: "Hello world", maxChars: 2, expected: "He"},
+		{name: "empty", input: "", maxChars: 10, expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := tp.TruncateText(test.input, test.maxChars)
+			assert.Equal(t, test.expected, result)
+			assert.LessOrEqual(t, utf8.RuneCountInString(result), test.maxChars)
+		})
+	}
+}
+
+func TestTextProcessor_EstimateAudioDurationLanguage(t *testing.T) {
+	estimate := func(lang Language, text string) float64 {
+		tp := NewTextProcessor()
+		tp.SetLanguage(lang)
+		return tp.EstimateAudioDuration(text)
+	}
+
+	// 150 english words of 4.7 characters take a minute, the same characters are read faster in russian
+	english := strings.TrimSpace(strings.Repeat("abcd efghi ", 75))
+	assert.InDelta(t, 57.4, estimate(LanguageEnglish, english), 0.1, "675 characters are 143.6 words at 150 wpm")
+	assert.InDelta(t, 46.0, estimate(LanguageRussian, english), 0.1, "675 characters are 122.7 words at 160 wpm")
+	assert.InDelta(t, 50.1, estimate(LanguageSpanish, english), 0.1, "675 characters are 137.8 words at 165 wpm")
+	assert.InDelta(t, estimate(LanguageRussian, english), estimate("", english), 0.0001, "russian by default")
+
+	// the same line is longer in russian characters but takes about as long to say
+	ru := estimate(LanguageRussian, "Искусственный интеллект меняет то, как мы пишем программы.")
+	en := estimate(LanguageEnglish, "Artificial intelligence changes the way we write programs.")
+	assert.InDelta(t, ru, en, 1.0)
+}
+
 func TestTextProcessor_EstimateTotalDuration(t *testing.T) {
 	tp := NewTextProcessor()
 
@@ -93,6 +150,22 @@ func TestTextProcessor_EstimateTotalDuration(t *testing.T) {
 	assert.Less(t, result, 10.0) // should be a few seconds for these short messages
 }
 
+func TestTextProcessor_WordBudget(t *testing.T) {
+	tp := NewTextProcessor()
+	assert.Equal(t, 1600, tp.WordBudget(10))
+	assert.Zero(t, tp.WordBudget(0))
+	assert.Zero(t, tp.WordBudget(-5))
+
+	// the budget spoken is estimated at the target duration
+	words := strings.TrimSpace(strings.Repeat("слово1 ", tp.WordBudget(3)))
+	assert.InDelta(t, 180, tp.EstimateAudioDuration(words), 180*0.1)
+
+	tp.SetLanguage(LanguageEnglish)
+	assert.Equal(t, 1500, tp.WordBudget(10))
+	tp.SetLanguage(LanguageSpanish)
+	assert.Equal(t, 1650, tp.WordBudget(10))
+}
+
 func TestTextProcessor_CalculateSpeechSpeed(t *testing.T) {
 	tp := NewTextProcessor()
 
@@ -132,6 +205,24 @@ func TestTextProcessor_CalculateSpeechSpeed(t *testing.T) {
 			targetDurationMinutes: 10,
 			expected:              1.2, // would be 10.0 but capped at 1.2
 		},
+		{
+			name:                  "zero target",
+			estimatedDuration:     600,
+			targetDurationMinutes: 0,
+			expected:              1.0,
+		},
+		{
+			name:                  "negative target",
+			estimatedDuration:     600,
+			targetDurationMinutes: -5,
+			expected:              1.0,
+		},
+		{
+			name:                  "one minute target",
+			estimatedDuration:     60,
+			targetDurationMinutes: 1,
+			expected:              1.0,
+		},
 	}
 
 	for _, tc := range tests {
@@ -165,3 +256,268 @@ func TestBackwardCompatibilityFunctions(t *testing.T) {
 		assert.Greater(t, result, 0.0)
 	})
 }
+
+func TestTextProcessor_MergeConsecutive(t *testing.T) {
+	tp := NewTextProcessor()
+	messages := []podcast.Message{
+		{Host: "Алексей", Content: "Начнём.", Emotion: "excited"},
+		{Host: "Мария", Content: "Первое."},
+		{Host: "Мария", Content: "Второе."},
+		{Host: "Мария", Content: "Третье."},
+		{Host: "Алексей", Content: "Согласен."},
+	}
+
+	tests := []struct {
+		name     string
+		maxTurns int
+		expected []podcast.Message
+	}{
+		{name: "disabled", maxTurns: 0, expected: messages},
+		{name: "three turns merged into one", maxTurns: 1, expected: []podcast.Message{
+			{Host: "Алексей", Content: "Начнём.", Emotion: "excited"},
+			{Host: "Мария", Content: "Первое. Второе. Третье."},
+			{Host: "Алексей", Content: "Согласен."},
+		}},
+		{name: "three turns merged into two", maxTurns: 2, expected: []podcast.Message{
+			{Host: "Алексей", Content: "Начнём.", Emotion: "excited"},
+			{Host: "Мария", Content: "Первое."},
+			{Host: "Мария", Content: "Второе. Третье."},
+			{Host: "Алексей", Content: "Согласен."},
+		}},
+		{name: "run within the limit", maxTurns: 3, expected: messages},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, tp.MergeConsecutive(messages, test.maxTurns))
+		})
+	}
+
+	t.Run("ads break runs", func(t *testing.T) {
+		withAd := []podcast.Message{
+			{Host: "Мария", Content: "До рекламы."},
+			{Host: "Мария", Content: "Реклама.", Ad: true},
+			{Host: "Мария", Content: "После рекламы."},
+			{Host: "Мария", Content: "Продолжаем."},
+		}
+		assert.Equal(t, []podcast.Message{
+			{Host: "Мария", Content: "До рекламы."},
+			{Host: "Мария", Content: "Реклама.", Ad: true},
+			{Host: "Мария", Content: "После рекламы. Продолжаем."},
+		}, tp.MergeConsecutive(withAd, 1))
+	})
+}
+
+func TestTextProcessor_BalanceQuotes(t *testing.T) {
+	tp := NewTextProcessor()
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "balanced", text: "Книга «Чистый код» (2008) и \"Go\".", expected: "Книга «Чистый код» (2008) и \"Go\"."},
+		{name: "unmatched opening guillemet", text: "Статья «Новый релиз Go вышла вчера.", expected: "Статья Новый релиз Go вышла вчера."},
+		{name: "unmatched closing guillemet", text: "Релиз Go» вышел.", expected: "Релиз Go вышел."},
+		{name: "stray guillemet between spaces", text: "Это « важно.", expected: "Это важно."},
+		{name: "nested guillemets", text: "«Проект «Альфа» закрыт»", expected: "«Проект «Альфа» закрыт»"},
+		{name: "odd straight quotes", text: `Он сказал "да" и "нет.`, expected: `Он сказал "да" и нет.`},
+		{name: "curly quotes", text: "Слово “тест и “пример”.", expected: "Слово тест и “пример”."},
+		{name: "brackets", text: "Версия Go) и (бета", expected: "Версия Go и бета"},
+		{name: "numbered items", text: "Во-первых, 1) скорость, 2) память.", expected: "Во-первых, 1) скорость, 2) память."},
+		{name: "guillemet after digit", text: "Версия 2» вышла.", expected: "Версия 2 вышла."},
+		{name: "leading stray", text: "« Начнём.", expected: "Начнём."},
+		{name: "plain text", text: "Просто текст.", expected: "Просто текст."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, tp.BalanceQuotes(test.text))
+		})
+	}
+
+	messages := []podcast.Message{{Host: "a", Content: "«Цитата"}, {Host: "b", Content: "ok"}}
+	result := tp.BalanceQuotesMessages(messages)
+	assert.Equal(t, []podcast.Message{{Host: "a", Content: "Цитата"}, {Host: "b", Content: "ok"}}, result)
+	assert.Equal(t, "«Цитата", messages[0].Content, "input is not modified")
+}
+
+func TestTextProcessor_SplitIntoParts(t *testing.T) {
+	tp := NewTextProcessor()
+
+	t.Run("paragraphs grouped by length", func(t *testing.T) {
+		text := "first paragraph\nsecond paragraph\nthird paragraph\nfourth paragraph\nfifth paragraph\nsixth paragraph"
+		parts := tp.SplitIntoParts(text, 3)
+		assert.Equal(t, []string{
+			"first paragraph\nsecond paragraph",
+			"third paragraph\nfourth paragraph",
+			"fifth paragraph\nsixth paragraph",
+		}, parts)
+	})
+
+	t.Run("falls back to sentences", func(t *testing.T) {
+		text := "Первое предложение. Второе предложение! Третье предложение? Четвёртое."
+		parts := tp.SplitIntoParts(text, 2)
+		assert.Equal(t, []string{"Первое предложение. Второе предложение!", "Третье предложение? Четвёртое."}, parts)
+	})
+
+	t.Run("fewer units than parts", func(t *testing.T) {
+		parts := tp.SplitIntoParts("single paragraph without sentence breaks", 3)
+		assert.Equal(t, []string{"single paragraph without sentence breaks"}, parts)
+	})
+
+	t.Run("single part", func(t *testing.T) {
+		parts := tp.SplitIntoParts("  a\nb  ", 1)
+		assert.Equal(t, []string{"a\nb"}, parts)
+	})
+
+	t.Run("long first paragraph", func(t *testing.T) {
+		text := strings.Repeat("long ", 100) + "\nshort\nshort\nshort"
+		parts := tp.SplitIntoParts(text, 3)
+		require.Len(t, parts, 3)
+		assert.Equal(t, "short\nshort", parts[2])
+	})
+}
+
+func TestTextProcessor_Sanitize(t *testing.T) {
+	tp := NewTextProcessor()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "clean text unchanged", input: "Привет, мир!\nВторая строка\tс табом", expected: "Привет, мир!\nВторая строка\tс табом"},
+		{name: "bom removed", input: "\ufeffПривет", expected: "Привет"},
+		{name: "zero-width characters removed", input: "При\u200bвет\u200c ми\u200dр\u2060", expected: "Привет мир"},
+		{name: "soft hyphen removed", input: "длин\u00adное", expected: "длинное"},
+		{name: "control characters removed", input: "a\x00b\x07c\r\nd", expected: "abc\nd"},
+		{name: "empty", input: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, tp.Sanitize(test.input))
+		})
+	}
+
+	t.Run("duration estimate matches clean text", func(t *testing.T) {
+		clean := strings.Repeat("слово ", 100)
+		dirty := "\ufeff" + strings.ReplaceAll(clean, "о", "о\u200b")
+		assert.Greater(t, tp.EstimateAudioDuration(dirty), tp.EstimateAudioDuration(clean))
+		assert.InDelta(t, tp.EstimateAudioDuration(clean), tp.EstimateAudioDuration(tp.Sanitize(dirty)), 0.0001)
+	})
+}
+
+func TestTextProcessor_NormalizeWhitespace(t *testing.T) {
+	tp := NewTextProcessor()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "clean text unchanged", input: "Первый абзац.\n\nВторой абзац.\nЕго строка.", expected: "Первый абзац.\n\nВторой абзац.\nЕго строка."},
+		{name: "runs of spaces collapsed", input: "много   пробелов\tи\t\tтабов", expected: "много пробелов и табов"},
+		{name: "unicode spaces collapsed", input: "неразрывный\u00a0\u00a0пробел\u2003и\u2009тонкий", expected: "неразрывный пробел и тонкий"},
+		{name: "trailing and leading spaces trimmed", input: "  строка с хвостом   \n   отступ", expected: "строка с хвостом\nотступ"},
+		{name: "blank lines collapsed to a paragraph break", input: "один\n\n\n\n\nдва\n \t \n\nтри", expected: "один\n\nдва\n\nтри"},
+		{name: "blank lines around text dropped", input: "\n\n  \nтекст\n\n\n", expected: "текст"},
+		{name: "whitespace only", input: " \n\t\n ", expected: ""},
+		{name: "empty", input: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, tp.NormalizeWhitespace(test.input))
+		})
+	}
+
+	t.Run("idempotent", func(t *testing.T) {
+		normalized := tp.NormalizeWhitespace("a  b\n\n\n c \n\nd")
+		assert.Equal(t, normalized, tp.NormalizeWhitespace(normalized))
+	})
+
+	t.Run("duration estimate is stable", func(t *testing.T) {
+		clean := strings.TrimSpace(strings.Repeat("Слово за словом.\n\n", 50))
+		messy := strings.ReplaceAll(strings.ReplaceAll(clean, " ", " \u00a0\u00a0 "), "\n\n", " \n\n\n\t\n  ")
+		assert.Greater(t, tp.EstimateAudioDuration(messy), tp.EstimateAudioDuration(clean), "unicode spaces count as characters")
+		assert.Equal(t, clean, tp.NormalizeWhitespace(messy))
+		assert.InDelta(t, tp.EstimateAudioDuration(clean), tp.EstimateAudioDuration(tp.NormalizeWhitespace(messy)), 0.0001)
+	})
+}
+
+func TestTextProcessor_Slug(t *testing.T) {
+	tp := NewTextProcessor()
+
+	tests := []struct {
+		name     string
+		input    string
+		maxLen   int
+		expected string
+	}{
+		{name: "latin title", input: "Go 1.24: What's New?", maxLen: 60, expected: "go-1-24-what-s-new"},
+		{name: "cyrillic title", input: "  Новый релиз — «Ядро Linux»  ", maxLen: 60, expected: "новый-релиз-ядро-linux"},
+		{name: "cut at a word boundary", input: "один два три четыре", maxLen: 12, expected: "один-два-три"},
+		{name: "long first word cut", input: "Превосходнейший день", maxLen: 5, expected: "прево"},
+		{name: "no letters", input: "!!! ???", maxLen: 60, expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, tp.Slug(test.input, test.maxLen))
+		})
+	}
+}
+
+func TestTextProcessor_SanitizeMessages(t *testing.T) {
+	tp := NewTextProcessor()
+	messages := []podcast.Message{
+		{Host: "\ufeffАлексей", Content: "При\u200bвет", Emotion: "calm"},
+		{Host: "Мария", Content: "\u200b\u200b"},
+		{Host: "Реклама", Ad: true, AudioFile: "ad.mp3"},
+	}
+
+	result := tp.SanitizeMessages(messages)
+	assert.Equal(t, []podcast.Message{
+		{Host: "Алексей", Content: "Привет", Emotion: "calm"},
+		{Host: "Реклама", Ad: true, AudioFile: "ad.mp3"},
+	}, result)
+	assert.Equal(t, "\ufeffАлексей", messages[0].Host, "input is not modified")
+}
+
+func TestTextProcessor_TTSChars(t *testing.T) {
+	tp := NewTextProcessor()
+	messages := []podcast.Message{
+		{Host: "Алексей", Content: "Привет"},
+		{Host: "Реклама", Ad: true, AudioFile: "ad.mp3", Content: "ignored"},
+		{Host: "Мария", Content: "hello"},
+	}
+	assert.Equal(t, 11, tp.TTSChars(messages))
+	assert.Zero(t, tp.TTSChars(nil))
+}
+
+func TestTextProcessor_TrimToTTSChars(t *testing.T) {
+	tp := NewTextProcessor()
+	messages := []podcast.Message{
+		{Host: "a", Content: "12345"},
+		{Host: "b", Content: "12345"},
+		{Host: "a", Content: "12345"},
+	}
+
+	tests := []struct {
+		name     string
+		maxChars int
+		expected int
+	}{
+		{name: "everything fits", maxChars: 15, expected: 3},
+		{name: "cut on message boundary", maxChars: 14, expected: 2},
+		{name: "exactly one message", maxChars: 5, expected: 1},
+		{name: "nothing fits", maxChars: 4, expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Len(t, tp.TrimToTTSChars(messages, test.maxChars), test.expected)
+		})
+	}
+}
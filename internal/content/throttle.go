@@ -0,0 +1,55 @@
+package content
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostThrottle spaces requests to the same host by at least delay.
+// every caller reserves its slot under the lock, so concurrent fetches of one host queue up.
+type hostThrottle struct {
+	delay time.Duration
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu   sync.Mutex
+	next map[string]time.Time // earliest time of the next request per host
+}
+
+// newHostThrottle creates a throttle on the real clock
+func newHostThrottle(delay time.Duration) *hostThrottle {
+	return &hostThrottle{delay: delay, now: time.Now, sleep: sleepContext, next: make(map[string]time.Time)}
+}
+
+// Wait blocks until a request to host is allowed, a nil throttle never waits
+func (t *hostThrottle) Wait(ctx context.Context, host string) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	now := t.now()
+	at := now
+	if next, ok := t.next[host]; ok && next.After(now) {
+		at = next
+	}
+	t.next[host] = at.Add(t.delay)
+	t.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		return t.sleep(ctx, wait)
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package content
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is advanced by sleeps only
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newFakeThrottle(delay time.Duration) (*hostThrottle, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	throttle := newHostThrottle(delay)
+	throttle.now = clock.Now
+	throttle.sleep = clock.Sleep
+	return throttle, clock
+}
+
+func TestHostThrottle_Wait(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("same host spaced by the delay", func(t *testing.T) {
+		throttle, clock := newFakeThrottle(2 * time.Second)
+		var times []time.Time
+		for range 3 {
+			require.NoError(t, throttle.Wait(ctx, "example.com"))
+			times = append(times, clock.Now())
+		}
+		for i := 1; i < len(times); i++ {
+			assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), 2*time.Second)
+		}
+		assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, clock.sleeps)
+	})
+
+	t.Run("other hosts not delayed", func(t *testing.T) {
+		throttle, clock := newFakeThrottle(2 * time.Second)
+		require.NoError(t, throttle.Wait(ctx, "a.example"))
+		require.NoError(t, throttle.Wait(ctx, "b.example"))
+		assert.Empty(t, clock.sleeps)
+	})
+
+	t.Run("only the rest of the delay is waited", func(t *testing.T) {
+		throttle, clock := newFakeThrottle(2 * time.Second)
+		require.NoError(t, throttle.Wait(ctx, "example.com"))
+		clock.Advance(1500 * time.Millisecond)
+		require.NoError(t, throttle.Wait(ctx, "example.com"))
+		clock.Advance(5 * time.Second)
+		require.NoError(t, throttle.Wait(ctx, "example.com"))
+		assert.Equal(t, []time.Duration{500 * time.Millisecond}, clock.sleeps)
+	})
+
+	t.Run("concurrent callers queue up", func(t *testing.T) {
+		throttle, clock := newFakeThrottle(time.Second)
+		// slots are reserved before sleeping, so the clock doesn't advance between the waits
+		throttle.sleep = func(context.Context, time.Duration) error { return nil }
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, throttle.Wait(ctx, "example.com"))
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, clock.Now().Add(4*time.Second), throttle.next["example.com"])
+	})
+
+	t.Run("nil throttle", func(t *testing.T) {
+		var throttle *hostThrottle
+		assert.NoError(t, throttle.Wait(ctx, "example.com"))
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		throttle := newHostThrottle(time.Hour)
+		require.NoError(t, throttle.Wait(ctx, "example.com"))
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.ErrorIs(t, throttle.Wait(canceled, "example.com"), context.Canceled)
+	})
+}
+
+func TestHTTPArticleFetcher_FetchDelay(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><title>Article</title></head><body><article><p>" +
+			strings.Repeat("This is a perfectly valid sentence of the article. ", 5) + "</p></article></body></html>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPArticleFetcher(nil)
+	fetcher.minTextLength = 50 // lower for testing
+	fetcher.SetRecommendedLength(0)
+	fetcher.SetFetchDelay(3 * time.Second)
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fetcher.throttle.now = clock.Now
+	fetcher.throttle.sleep = clock.Sleep
+
+	for range 3 {
+		_, _, err := fetcher.Fetch(server.URL + "/article")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, []time.Duration{3 * time.Second, 3 * time.Second}, clock.sleeps)
+
+	t.Run("waited before the fetch timeout starts", func(t *testing.T) {
+		fetcher.SetFetchDelay(3 * time.Second)
+		var deadlines []bool
+		fetcher.throttle.sleep = func(ctx context.Context, d time.Duration) error {
+			_, ok := ctx.Deadline()
+			deadlines = append(deadlines, ok)
+			return nil
+		}
+		for range 2 {
+			_, _, err := fetcher.Fetch(server.URL + "/article")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []bool{false}, deadlines, "the delay doesn't run down the fetch timeout")
+	})
+
+	t.Run("render service throttled by its host", func(t *testing.T) {
+		render := NewHTTPArticleFetcher(nil)
+		render.minTextLength = 50
+		render.SetRecommendedLength(0)
+		render.SetFetchDelay(3 * time.Second)
+		render.SetRenderURL(strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+		clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+		render.throttle.now = clock.Now
+		render.throttle.sleep = clock.Sleep
+
+		for _, articleURL := range []string{"https://a.example/article", "https://b.example/article"} {
+			_, _, err := render.Fetch(articleURL)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []time.Duration{3 * time.Second}, clock.sleeps, "articles of different sites share the service")
+		assert.Contains(t, render.throttle.next, "localhost")
+		assert.NotContains(t, render.throttle.next, "a.example")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		fetcher.SetFetchDelay(0)
+		assert.Nil(t, fetcher.throttle)
+		_, _, err := fetcher.Fetch(server.URL + "/article")
+		require.NoError(t, err)
+	})
+}
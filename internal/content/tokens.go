@@ -0,0 +1,62 @@
+package content
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenPieceRe splits text into runs tokenized alike: words of one script, numbers and symbols.
+// whitespace is left out, the tokenizer merges it into the following piece.
+var tokenPieceRe = regexp.MustCompile(`\p{Latin}+|\p{Cyrillic}+|\p{L}|\p{N}+|[^\p{L}\p{N}\s]+`)
+
+// EstimateTokens approximates the number of model tokens in text without a tokenizer.
+// latin words take a token per 4 letters, cyrillic per 3, other scripts a token per letter,
+// which keeps russian, english and code closer to the real count than a flat char ratio.
+func (tp *TextProcessor) EstimateTokens(text string) int {
+	tokens := 0
+	for _, piece := range tokenPieceRe.FindAllString(text, -1) {
+		tokens += pieceTokens(piece)
+	}
+	return tokens
+}
+
+// TruncateTokens cuts text at a piece boundary so its estimated token count, including the
+// trailing "..." marking the cut, fits maxTokens. text within the budget is returned as is.
+func (tp *TextProcessor) TruncateTokens(text string, maxTokens int) string {
+	if tp.EstimateTokens(text) <= maxTokens {
+		return text
+	}
+
+	budget := maxTokens - pieceTokens("...")
+	tokens := 0
+	for _, span := range tokenPieceRe.FindAllStringIndex(text, -1) {
+		tokens += pieceTokens(text[span[0]:span[1]])
+		if tokens > budget {
+			return strings.TrimRightFunc(text[:span[0]], unicode.IsSpace) + "..."
+		}
+	}
+	return text
+}
+
+// pieceTokens estimates tokens of a single piece matched by tokenPieceRe
+func pieceTokens(piece string) int {
+	r, _ := utf8.DecodeRuneInString(piece)
+	n := float64(utf8.RuneCountInString(piece))
+	var perToken float64
+	switch {
+	case unicode.In(r, unicode.Latin):
+		perToken = latinCharsPerToken
+	case unicode.In(r, unicode.Cyrillic):
+		perToken = cyrillicCharsPerToken
+	case unicode.IsLetter(r):
+		perToken = 1
+	case unicode.IsNumber(r):
+		perToken = digitsPerToken
+	default:
+		perToken = symbolsPerToken
+	}
+	return int(math.Ceil(n / perToken))
+}
@@ -0,0 +1,60 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextProcessor_EstimateTokens(t *testing.T) {
+	tp := NewTextProcessor()
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{name: "empty", text: "", expected: 0},
+		{name: "english words", text: "hello world", expected: 2 + 2},
+		{name: "russian words", text: "привет мир", expected: 2 + 1},
+		{name: "numbers and punctuation", text: "version 2024, ok!", expected: 2 + 2 + 1 + 1 + 1},
+		{name: "code", text: "func main() {}", expected: 1 + 1 + 1 + 1},
+		{name: "other scripts per letter", text: "日本語", expected: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, tp.EstimateTokens(test.text))
+		})
+	}
+
+	// the same meaning takes more tokens per char in english than the char cap assumes for russian
+	ru := strings.Repeat("Разработчики обсуждают новую версию языка программирования. ", 10)
+	en := strings.Repeat("Developers discuss the new version of the programming language. ", 10)
+	assert.Greater(t, float64(len([]rune(ru)))/float64(tp.EstimateTokens(ru)), 2.5, "russian chars per token")
+	assert.Greater(t, float64(len([]rune(en)))/float64(tp.EstimateTokens(en)), 3.5, "english chars per token")
+}
+
+func TestTextProcessor_TruncateTokens(t *testing.T) {
+	tp := NewTextProcessor()
+	texts := map[string]string{
+		"russian": strings.Repeat("Разработчики обсуждают новую версию языка программирования, и это важно. ", 200),
+		"english": strings.Repeat("Developers discuss the new version of the programming language, and it matters. ", 200),
+		"code":    strings.Repeat("if err := run(ctx); err != nil { return fmt.Errorf(\"run: %w\", err) }\n", 200),
+	}
+
+	for name, text := range texts {
+		for _, budget := range []int{10, 100, 1000} {
+			truncated := tp.TruncateTokens(text, budget)
+			tokens := tp.EstimateTokens(truncated)
+			assert.LessOrEqual(t, tokens, budget, "%s within %d tokens", name, budget)
+			assert.GreaterOrEqual(t, tokens, budget*8/10, "%s fills most of %d tokens", name, budget)
+			assert.True(t, strings.HasSuffix(truncated, "..."), "%s cut is marked", name)
+			assert.True(t, strings.HasPrefix(text, strings.TrimSuffix(truncated, "...")), "%s keeps the start", name)
+		}
+	}
+
+	t.Run("within budget unchanged", func(t *testing.T) {
+		assert.Equal(t, "короткий текст", tp.TruncateTokens("короткий текст", 100))
+	})
+}
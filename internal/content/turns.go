@@ -0,0 +1,73 @@
+package content
+
+import (
+	"math"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// TurnDeviation is a host whose share of speaking turns or time is far from the share expected by the host weights
+type TurnDeviation struct {
+	Host     string
+	Expected float64 // expected share, 0..1
+	Actual   float64 // share in the discussion, 0..1
+}
+
+// CheckTurnWeights compares the hosts' shares of discussion turns with their weights and returns hosts
+// off by more than maxTurnShareDeviation, in host order. it returns nil when no host has a weight
+// or the discussion is too short to judge. ads and pre-recorded audio are not counted as turns.
+func (tp *TextProcessor) CheckTurnWeights(messages []podcast.Message, hosts []podcast.Host) []TurnDeviation {
+	shares := podcast.TurnShares(hosts)
+	if shares == nil {
+		return nil
+	}
+
+	turns := make(map[string]int, len(hosts))
+	total := 0
+	for _, msg := range messages {
+		if msg.Ad || msg.AudioFile != "" {
+			continue
+		}
+		turns[msg.Host]++
+		total++
+	}
+	if total < minWeightedTurns {
+		return nil
+	}
+
+	var result []TurnDeviation
+	for _, host := range hosts {
+		actual := float64(turns[host.Name]) / float64(total)
+		if math.Abs(actual-shares[host.Name]) > maxTurnShareDeviation {
+			result = append(result, TurnDeviation{Host: host.Name, Expected: shares[host.Name], Actual: actual})
+		}
+	}
+	return result
+}
+
+// CheckSpeakingShares compares the hosts' shares of the estimated speaking duration with the shares expected
+// by their weights, equal without weights, and returns hosts off by more than maxTurnShareDeviation, in host order.
+// a host taking few but long turns dominates the episode while the turn counts look balanced.
+// it returns nil when the discussion is too short to judge.
+func (tp *TextProcessor) CheckSpeakingShares(messages []podcast.Message, hosts []podcast.Host) []TurnDeviation {
+	if len(messages) < minWeightedTurns || len(hosts) < 2 {
+		return nil
+	}
+	stats := podcast.Discussion{Messages: messages}.SpeakingStats()
+	if stats == nil {
+		return nil
+	}
+	shares := podcast.TurnShares(hosts)
+
+	var result []TurnDeviation
+	for _, host := range hosts {
+		expected := 1 / float64(len(hosts))
+		if shares != nil {
+			expected = shares[host.Name]
+		}
+		if math.Abs(stats[host.Name]-expected) > maxTurnShareDeviation {
+			result = append(result, TurnDeviation{Host: host.Name, Expected: expected, Actual: stats[host.Name]})
+		}
+	}
+	return result
+}
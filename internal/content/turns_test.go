@@ -0,0 +1,102 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/radio-t/ai-podcast/podcast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextProcessor_CheckTurnWeights(t *testing.T) {
+	tp := NewTextProcessor()
+	turns := func(counts map[string]int) []podcast.Message {
+		var messages []podcast.Message
+		for _, name := range []string{"A", "B", "C"} {
+			for range counts[name] {
+				messages = append(messages, podcast.Message{Host: name, Content: "line"})
+			}
+		}
+		return messages
+	}
+	weighted := []podcast.Host{{Name: "A", Weight: 2}, {Name: "B", Weight: 1}, {Name: "C", Weight: 1}}
+
+	t.Run("close to the weights", func(t *testing.T) {
+		assert.Empty(t, tp.CheckTurnWeights(turns(map[string]int{"A": 9, "B": 6, "C": 5}), weighted))
+	})
+
+	t.Run("large deviation flagged", func(t *testing.T) {
+		deviations := tp.CheckTurnWeights(turns(map[string]int{"A": 6, "B": 7, "C": 7}), weighted)
+		require.Len(t, deviations, 1)
+		assert.Equal(t, "A", deviations[0].Host)
+		assert.InDelta(t, 0.5, deviations[0].Expected, 0.001)
+		assert.InDelta(t, 0.3, deviations[0].Actual, 0.001)
+	})
+
+	t.Run("silent host flagged", func(t *testing.T) {
+		deviations := tp.CheckTurnWeights(turns(map[string]int{"B": 10, "C": 10}), weighted)
+		require.Len(t, deviations, 3)
+		assert.Equal(t, "A", deviations[0].Host)
+		assert.Zero(t, deviations[0].Actual)
+		assert.Equal(t, "B", deviations[1].Host)
+		assert.InDelta(t, 0.5, deviations[1].Actual, 0.001)
+	})
+
+	t.Run("ads and audio files not counted", func(t *testing.T) {
+		messages := turns(map[string]int{"A": 10, "B": 5, "C": 5})
+		for range 10 {
+			messages = append(messages, podcast.Message{Host: "B", Ad: true}, podcast.Message{Host: "C", AudioFile: "x.mp3"})
+		}
+		assert.Empty(t, tp.CheckTurnWeights(messages, weighted))
+	})
+
+	t.Run("no weights", func(t *testing.T) {
+		hosts := []podcast.Host{{Name: "A"}, {Name: "B"}, {Name: "C"}}
+		assert.Nil(t, tp.CheckTurnWeights(turns(map[string]int{"A": 20}), hosts))
+	})
+
+	t.Run("too short to judge", func(t *testing.T) {
+		assert.Nil(t, tp.CheckTurnWeights(turns(map[string]int{"B": 3, "C": 3}), weighted))
+	})
+}
+
+func TestTextProcessor_CheckSpeakingShares(t *testing.T) {
+	tp := NewTextProcessor()
+	discussion := func(lines map[string]string) []podcast.Message {
+		var messages []podcast.Message
+		for range 5 {
+			for _, name := range []string{"A", "B"} {
+				messages = append(messages, podcast.Message{Host: name, Content: lines[name]})
+			}
+		}
+		return messages
+	}
+	hosts := []podcast.Host{{Name: "A"}, {Name: "B"}}
+
+	t.Run("balanced", func(t *testing.T) {
+		assert.Empty(t, tp.CheckSpeakingShares(discussion(map[string]string{"A": "одна реплика", "B": "другая мысль"}), hosts))
+	})
+
+	t.Run("equal turns, one host dominates the time", func(t *testing.T) {
+		messages := discussion(map[string]string{"A": strings.Repeat("долгий монолог ", 5), "B": "Да."})
+		assert.Empty(t, tp.CheckTurnWeights(messages, hosts), "turn counts look fine")
+		deviations := tp.CheckSpeakingShares(messages, hosts)
+		require.Len(t, deviations, 2)
+		assert.Equal(t, "A", deviations[0].Host)
+		assert.InDelta(t, 0.5, deviations[0].Expected, 0.001)
+		assert.InDelta(t, 65.0/68, deviations[0].Actual, 0.001)
+		assert.Equal(t, "B", deviations[1].Host)
+	})
+
+	t.Run("expected by the weights", func(t *testing.T) {
+		weighted := []podcast.Host{{Name: "A", Weight: 3}, {Name: "B", Weight: 1}}
+		messages := discussion(map[string]string{"A": "реплика три", "B": "ок"})
+		assert.Empty(t, tp.CheckSpeakingShares(messages, weighted))
+	})
+
+	t.Run("too short to judge", func(t *testing.T) {
+		messages := []podcast.Message{{Host: "A", Content: strings.Repeat("монолог ", 20)}, {Host: "B", Content: "Да"}}
+		assert.Nil(t, tp.CheckSpeakingShares(messages, hosts))
+	})
+}
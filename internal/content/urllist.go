@@ -0,0 +1,91 @@
+package content
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadURLList reads article URLs from a file, one per line. blank lines and lines starting with # are skipped.
+func ReadURLList(filename string) ([]string, error) {
+	f, err := os.Open(filename) // #nosec G304 -- list path is provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open URL list: %w", err)
+	}
+	defer f.Close()
+
+	lines, err := readLines(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read URL list: %w", err)
+	}
+	return lines, nil
+}
+
+// Checkpoint records processed URLs in a file, so a re-run of a URL list skips completed ones
+type Checkpoint struct {
+	filename string
+	done     map[string]bool
+}
+
+// LoadCheckpoint reads processed URLs from the checkpoint file, a missing file means nothing is processed yet
+func LoadCheckpoint(filename string) (*Checkpoint, error) {
+	cp := &Checkpoint{filename: filename, done: make(map[string]bool)}
+	f, err := os.Open(filename) // #nosec G304 -- checkpoint path is provided by the user
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	defer f.Close()
+
+	lines, err := readLines(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	for _, line := range lines {
+		cp.done[line] = true
+	}
+	return cp, nil
+}
+
+// Done reports whether the URL was processed
+func (c *Checkpoint) Done(url string) bool {
+	return c.done[url]
+}
+
+// Mark records the URL as processed, the checkpoint file is appended right away to survive a crash
+func (c *Checkpoint) Mark(url string) error {
+	f, err := os.OpenFile(c.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 -- checkpoint path is provided by the user
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, url); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint: %w", err)
+	}
+	c.done[url] = true
+	return nil
+}
+
+// readLines returns trimmed non-empty lines, skipping # comments
+func readLines(f *os.File) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
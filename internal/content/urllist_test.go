@@ -0,0 +1,60 @@
+package content
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadURLList(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "urls.txt")
+	require.NoError(t, os.WriteFile(filename, []byte("https://a.example\n\n# skipped\n  https://b.example  \n"), 0o600))
+
+	urls, err := ReadURLList(filename)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, urls)
+
+	_, err = ReadURLList(filepath.Join(t.TempDir(), "missing.txt"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open URL list")
+}
+
+func TestCheckpoint(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "urls.done")
+
+	t.Run("missing file means nothing processed", func(t *testing.T) {
+		cp, err := LoadCheckpoint(filename)
+		require.NoError(t, err)
+		assert.False(t, cp.Done("https://a.example"))
+	})
+
+	t.Run("marked urls survive reload", func(t *testing.T) {
+		cp, err := LoadCheckpoint(filename)
+		require.NoError(t, err)
+		require.NoError(t, cp.Mark("https://a.example"))
+		require.NoError(t, cp.Mark("https://b.example"))
+		assert.True(t, cp.Done("https://a.example"))
+
+		reloaded, err := LoadCheckpoint(filename)
+		require.NoError(t, err)
+		assert.True(t, reloaded.Done("https://a.example"))
+		assert.True(t, reloaded.Done("https://b.example"))
+		assert.False(t, reloaded.Done("https://c.example"))
+
+		data, err := os.ReadFile(filename) // #nosec G304 -- test file
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.example\nhttps://b.example\n", string(data))
+	})
+
+	t.Run("unwritable checkpoint", func(t *testing.T) {
+		cp, err := LoadCheckpoint(filepath.Join(t.TempDir(), "missing", "urls.done"))
+		require.NoError(t, err)
+		err = cp.Mark("https://a.example")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to open checkpoint")
+		assert.False(t, cp.Done("https://a.example"))
+	})
+}
@@ -0,0 +1,146 @@
+package content
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// youtubeBaseURL serves the timedtext and oembed endpoints
+const youtubeBaseURL = "https://www.youtube.com"
+
+// transcriptTracks are caption tracks tried in order, manual captions before automatic ones
+var transcriptTracks = []struct{ lang, kind string }{
+	{lang: "ru"}, {lang: "ru", kind: "asr"}, {lang: "en"}, {lang: "en", kind: "asr"},
+}
+
+// youtubeVideoID returns the video ID of youtube.com and youtu.be video URLs
+func youtubeVideoID(u *url.URL) (string, bool) {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	path := strings.Trim(u.Path, "/")
+	var id string
+	switch host {
+	case "youtu.be":
+		id = path
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		if path == "watch" {
+			id = u.Query().Get("v")
+			break
+		}
+		for _, prefix := range []string{"shorts/", "live/", "embed/"} {
+			if rest, ok := strings.CutPrefix(path, prefix); ok {
+				id = rest
+			}
+		}
+	}
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// fetchYouTube returns the video transcript as the article text and the video title.
+// the title is best effort, a video without captions fails.
+func (f *HTTPArticleFetcher) fetchYouTube(ctx context.Context, videoID, videoURL string) (text, title string, err error) {
+	text, err = f.youtubeTranscript(ctx, videoID)
+	if err != nil {
+		return "", "", err
+	}
+	title, err = f.youtubeTitle(ctx, videoURL)
+	if err != nil {
+		f.logger.Warn("Can't get YouTube video title", "err", err)
+	}
+	return text, title, nil
+}
+
+// youtubeTranscript returns the text of the first available caption track of the video
+func (f *HTTPArticleFetcher) youtubeTranscript(ctx context.Context, videoID string) (string, error) {
+	for _, track := range transcriptTracks {
+		params := url.Values{"v": {videoID}, "lang": {track.lang}}
+		if track.kind != "" {
+			params.Set("kind", track.kind)
+		}
+		body, err := f.get(ctx, f.youtubeURL+"/api/timedtext?"+params.Encode())
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch YouTube transcript: %w", err)
+		}
+		text, err := parseTimedText(body)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse YouTube transcript: %w", err)
+		}
+		if text != "" {
+			return text, nil
+		}
+	}
+	return "", fmt.Errorf("no transcript available for YouTube video %s", videoID)
+}
+
+// youtubeTitle returns the video title from the oembed endpoint
+func (f *HTTPArticleFetcher) youtubeTitle(ctx context.Context, videoURL string) (string, error) {
+	params := url.Values{"url": {videoURL}, "format": {"json"}}
+	body, err := f.get(ctx, f.youtubeURL+"/oembed?"+params.Encode())
+	if err != nil {
+		return "", err
+	}
+	var oembed struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(body, &oembed); err != nil {
+		return "", fmt.Errorf("failed to decode oembed response: %w", err)
+	}
+	return oembed.Title, nil
+}
+
+// parseTimedText joins caption lines of a timedtext XML document, an empty document means no track
+func parseTimedText(data []byte) (string, error) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return "", nil
+	}
+	var transcript struct {
+		Lines []string `xml:"text"`
+	}
+	if err := xml.Unmarshal(data, &transcript); err != nil {
+		return "", err
+	}
+	lines := make([]string, 0, len(transcript.Lines))
+	for _, line := range transcript.Lines {
+		// captions are HTML-escaped inside the XML, e.g. &amp;#39; for an apostrophe
+		if line = strings.Join(strings.Fields(html.UnescapeString(line)), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " "), nil
+}
+
+// get performs a GET request and returns the response body
+func (f *HTTPArticleFetcher) get(ctx context.Context, urlStr string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// the -header values are meant for the article site, not the YouTube endpoints
+	req.Header.Set("User-Agent", f.userAgent)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTranscriptSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > maxTranscriptSize {
+		return nil, errors.New("response is too large")
+	}
+	return body, nil
+}
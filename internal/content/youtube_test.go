@@ -0,0 +1,169 @@
+package content
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/logging"
+)
+
+func TestYoutubeVideoID(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", expected: "dQw4w9WgXcQ"},
+		{url: "https://youtube.com/watch?v=dQw4w9WgXcQ&t=42s", expected: "dQw4w9WgXcQ"},
+		{url: "https://m.youtube.com/watch?v=dQw4w9WgXcQ", expected: "dQw4w9WgXcQ"},
+		{url: "https://youtu.be/dQw4w9WgXcQ?si=share", expected: "dQw4w9WgXcQ"},
+		{url: "https://www.youtube.com/shorts/dQw4w9WgXcQ", expected: "dQw4w9WgXcQ"},
+		{url: "https://www.youtube.com/live/dQw4w9WgXcQ", expected: "dQw4w9WgXcQ"},
+		{url: "https://www.youtube.com/embed/dQw4w9WgXcQ", expected: "dQw4w9WgXcQ"},
+		{url: "https://www.youtube.com/@radio-t"},
+		{url: "https://www.youtube.com/watch"},
+		{url: "https://youtu.be/"},
+		{url: "https://example.com/watch?v=dQw4w9WgXcQ"},
+		{url: "https://notyoutube.com/watch?v=dQw4w9WgXcQ"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			require.NoError(t, err)
+			id, ok := youtubeVideoID(u)
+			assert.Equal(t, test.expected, id)
+			assert.Equal(t, test.expected != "", ok)
+		})
+	}
+}
+
+func TestParseTimedText(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="utf-8" ?><transcript>` +
+		`<text start="0.5" dur="2.1">Всем привет,</text>` +
+		`<text start="2.6" dur="3">it&amp;#39;s   a
+test</text>` +
+		`<text start="5.6" dur="1"> </text>` +
+		`</transcript>`
+
+	text, err := parseTimedText([]byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, "Всем привет, it's a test", text)
+
+	text, err = parseTimedText(nil)
+	require.NoError(t, err)
+	assert.Empty(t, text, "empty response means no such track")
+
+	_, err = parseTimedText([]byte("<transcript><text>broken"))
+	require.Error(t, err)
+}
+
+func TestHTTPArticleFetcher_FetchYouTube(t *testing.T) {
+	videoURL := "https://www.youtube.com/watch?v=abc123"
+	line := "Сегодня мы обсуждаем новый релиз Go и изменения в стандартной библиотеке."
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/api/timedtext":
+			assert.Equal(t, "abc123", r.URL.Query().Get("v"))
+			if r.URL.Query().Get("kind") != "asr" {
+				return // no manual captions, an empty response
+			}
+			_, _ = fmt.Fprintf(w, `<transcript><text start="0" dur="4">%s</text><text start="4" dur="4">%s</text></transcript>`,
+				line, line)
+		case "/oembed":
+			assert.Equal(t, videoURL, r.URL.Query().Get("url"))
+			_, _ = io.WriteString(w, `{"title":"Go 1.24 release","author_name":"Radio-T"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("transcript becomes the article", func(t *testing.T) {
+		requests = nil
+		fetcher := NewHTTPArticleFetcher(nil)
+		fetcher.youtubeURL = server.URL
+		fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+
+		content, title, err := fetcher.Fetch(videoURL)
+		require.NoError(t, err)
+		assert.Equal(t, line+" "+line, content)
+		assert.Equal(t, "Go 1.24 release", title)
+		assert.Equal(t, []string{
+			"/api/timedtext?lang=ru&v=abc123",
+			"/api/timedtext?kind=asr&lang=ru&v=abc123",
+			"/oembed?format=json&url=" + url.QueryEscape(videoURL),
+		}, requests)
+	})
+
+	t.Run("extra headers not sent to youtube", func(t *testing.T) {
+		headers := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get("Cookie"))
+			assert.Equal(t, FetchUserAgent, r.Header.Get("User-Agent"))
+			server.Config.Handler.ServeHTTP(w, r)
+		}))
+		defer headers.Close()
+		fetcher := NewHTTPArticleFetcher(nil, WithHeaders(map[string]string{"Cookie": "session=secret"}))
+		fetcher.youtubeURL = headers.URL
+		fetcher.logger = logging.New(io.Discard, slog.LevelInfo)
+
+		_, _, err := fetcher.Fetch(videoURL)
+		require.NoError(t, err)
+	})
+
+	t.Run("no captions", func(t *testing.T) {
+		empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer empty.Close()
+		fetcher := NewHTTPArticleFetcher(nil)
+		fetcher.youtubeURL = empty.URL
+
+		_, _, err := fetcher.Fetch("https://youtu.be/abc123")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no transcript available for YouTube video abc123")
+	})
+
+	t.Run("title is best effort", func(t *testing.T) {
+		noTitle := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/oembed" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `<transcript><text>%s %s</text></transcript>`, line, line)
+		}))
+		defer noTitle.Close()
+		var warnings strings.Builder
+		fetcher := NewHTTPArticleFetcher(nil)
+		fetcher.youtubeURL = noTitle.URL
+		fetcher.logger = logging.New(&warnings, slog.LevelInfo)
+
+		content, title, err := fetcher.Fetch("https://youtu.be/abc123")
+		require.NoError(t, err)
+		assert.Equal(t, line+" "+line, content)
+		assert.Equal(t, "Untitled Article", title)
+		assert.Contains(t, warnings.String(), `WARN  Can't get YouTube video title err="status code 401"`)
+	})
+
+	t.Run("transcript endpoint error", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer failing.Close()
+		fetcher := NewHTTPArticleFetcher(nil)
+		fetcher.youtubeURL = failing.URL
+
+		_, _, err := fetcher.Fetch("https://youtu.be/abc123")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to fetch YouTube transcript: status code 429")
+	})
+}
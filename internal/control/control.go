@@ -0,0 +1,75 @@
+package control
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/go-pkgz/routegroup"
+)
+
+// Controller keeps the pause state of a live stream and serves the HTTP control endpoint.
+// it is safe for concurrent use by the handler and the stream feeder.
+type Controller struct {
+	mu     sync.RWMutex
+	paused bool
+	logger *slog.Logger // destination of the pause and resume messages
+}
+
+// New creates a controller with the stream running, logging pauses and resumes to logger, nil for the default one
+func New(logger *slog.Logger) *Controller {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Controller{logger: logger}
+}
+
+// Pause stops feeding new segments to the stream until Resume is called
+func (c *Controller) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+}
+
+// Resume continues feeding segments to the stream
+func (c *Controller) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+}
+
+// Paused reports whether the stream is paused
+func (c *Controller) Paused() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.paused
+}
+
+// Handler returns the control endpoint: POST /pause, POST /resume and GET /status.
+// every route responds with the current state as {"paused": bool}.
+func (c *Controller) Handler() http.Handler {
+	router := routegroup.New(http.NewServeMux())
+	router.HandleFunc("POST /pause", func(w http.ResponseWriter, _ *http.Request) {
+		c.Pause()
+		c.logger.Info("Stream paused via control endpoint")
+		c.writeStatus(w)
+	})
+	router.HandleFunc("POST /resume", func(w http.ResponseWriter, _ *http.Request) {
+		c.Resume()
+		c.logger.Info("Stream resumed via control endpoint")
+		c.writeStatus(w)
+	})
+	router.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		c.writeStatus(w)
+	})
+	return router
+}
+
+// writeStatus writes the current pause state as JSON
+func (c *Controller) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Paused bool `json:"paused"`
+	}{Paused: c.Paused()})
+}
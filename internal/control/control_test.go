@@ -0,0 +1,57 @@
+package control
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Handler(t *testing.T) {
+	ctrl := New(nil)
+	server := httptest.NewServer(ctrl.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedPaused bool
+	}{
+		{name: "initial status", method: http.MethodGet, path: "/status", expectedStatus: http.StatusOK},
+		{name: "pause", method: http.MethodPost, path: "/pause", expectedStatus: http.StatusOK, expectedPaused: true},
+		{name: "pause is idempotent", method: http.MethodPost, path: "/pause", expectedStatus: http.StatusOK, expectedPaused: true},
+		{name: "status while paused", method: http.MethodGet, path: "/status", expectedStatus: http.StatusOK, expectedPaused: true},
+		{name: "pause requires POST", method: http.MethodGet, path: "/pause", expectedStatus: http.StatusMethodNotAllowed,
+			expectedPaused: true},
+		{name: "resume", method: http.MethodPost, path: "/resume", expectedStatus: http.StatusOK},
+		{name: "unknown route", method: http.MethodPost, path: "/stop", expectedStatus: http.StatusNotFound},
+	}
+
+	// steps share the controller and run in order
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, server.URL+test.path, http.NoBody)
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			assert.Equal(t, test.expectedPaused, ctrl.Paused())
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			var status struct {
+				Paused bool `json:"paused"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+			assert.Equal(t, test.expectedPaused, status.Paused)
+		})
+	}
+}
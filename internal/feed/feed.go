@@ -0,0 +1,293 @@
+// Package feed maintains the RSS 2.0 podcast feed of the saved episodes
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/radio-t/ai-podcast/internal/script"
+)
+
+// itunesNS is the namespace of the iTunes podcast tags
+const itunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// Channel describes the podcast, it is used only when the feed file is created.
+// an existing feed keeps its channel as is, so it can be edited by hand.
+type Channel struct {
+	Title       string
+	Link        string
+	Description string
+	Language    string
+	Author      string
+	Category    string
+}
+
+// Episode is a saved episode published in the feed
+type Episode struct {
+	GUID        string // stable id of the episode, the enclosure path relative to the feed
+	Title       string
+	Description string
+	URL         string // enclosure url
+	Length      int64  // enclosure size in bytes
+	Duration    time.Duration
+	PubDate     time.Time
+}
+
+// NewEpisode describes the mp3 file of an episode for a feed saved to feedFile. the enclosure url is the path
+// of the file relative to the feed directory, joined with baseURL when set, and the duration is where the last line
+// of the transcript ends. the file must be inside the feed directory to be reachable by that url.
+func NewEpisode(feedFile, mp3File, baseURL string, transcript script.Transcript) (Episode, error) {
+	info, err := os.Stat(mp3File)
+	if err != nil {
+		return Episode{}, fmt.Errorf("failed to read episode file: %w", err)
+	}
+	path, err := relativePath(feedFile, mp3File)
+	if err != nil {
+		return Episode{}, err
+	}
+	link, err := enclosureURL(baseURL, path)
+	if err != nil {
+		return Episode{}, err
+	}
+
+	episode := Episode{GUID: path, Title: transcript.Title, URL: link, Length: info.Size(), PubDate: info.ModTime()}
+	if n := len(transcript.Messages); n > 0 {
+		last := transcript.Messages[n-1]
+		episode.Duration = time.Duration((last.Start + last.Duration) * float64(time.Second)).Round(time.Second)
+	}
+	return episode, nil
+}
+
+// relativePath returns the slash-separated path of the file relative to the feed directory
+func relativePath(feedFile, file string) (string, error) {
+	feedDir, err := filepath.Abs(filepath.Dir(feedFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve feed directory: %w", err)
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve episode file: %w", err)
+	}
+	rel, err := filepath.Rel(feedDir, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("episode %s is outside of the feed directory %s", file, feedDir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// enclosureURL escapes every segment of the relative path and joins it with baseURL, when set
+func enclosureURL(baseURL, path string) (string, error) {
+	segments := strings.Split(path, "/")
+	if baseURL == "" {
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		return strings.Join(segments, "/"), nil
+	}
+	link, err := url.JoinPath(baseURL, segments...)
+	if err != nil {
+		return "", fmt.Errorf("invalid feed url %q: %w", baseURL, err)
+	}
+	return link, nil
+}
+
+// Update adds the episode to the feed file, creating the file with the channel when it doesn't exist.
+// an item with the same guid is replaced, so saving an episode again doesn't duplicate it. other items
+// are kept verbatim and the newest episode goes first.
+func Update(feedFile string, channel Channel, episode Episode) error {
+	feed := newRSS(channel)
+	data, err := os.ReadFile(feedFile) // #nosec G304 -- feed path is provided by the user
+	switch {
+	case err == nil:
+		if feed, err = parseRSS(data); err != nil {
+			return fmt.Errorf("failed to parse feed %s: %w", feedFile, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	newItem, err := episodeItem(episode)
+	if err != nil {
+		return err
+	}
+	items := make([]rawItem, 0, len(feed.Channel.Items)+1)
+	items = append(items, newItem)
+	for _, item := range feed.Channel.Items {
+		if item.GUID != episode.GUID {
+			items = append(items, item)
+		}
+	}
+	feed.Channel.Items = items
+	feed.Channel.LastBuildDate = episode.PubDate.Format(time.RFC1123Z)
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+	out = append([]byte(xml.Header), append(out, '\n')...)
+	if err := os.WriteFile(feedFile, out, 0o600); err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	return nil
+}
+
+// rss is the feed document. the iTunes tags are written with the itunes prefix declared on the root,
+// reading them back goes through readRSS, because encoding/xml matches prefixed names by namespace.
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Channel channelXML `xml:"channel"`
+}
+
+type channelXML struct {
+	Title         string          `xml:"title"`
+	Link          string          `xml:"link"`
+	Description   string          `xml:"description"`
+	Language      string          `xml:"language,omitempty"`
+	LastBuildDate string          `xml:"lastBuildDate,omitempty"`
+	Author        string          `xml:"itunes:author,omitempty"`
+	Category      *itunesCategory `xml:"itunes:category,omitempty"`
+	Image         *itunesImage    `xml:"itunes:image,omitempty"`
+	Explicit      string          `xml:"itunes:explicit"`
+	Items         []rawItem       `xml:"item"`
+}
+
+type itunesCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// rawItem is an item kept as its inner XML, the guid is read only to find the item of a saved episode
+type rawItem struct {
+	GUID  string `xml:"guid"`
+	Inner string `xml:",innerxml"`
+}
+
+// MarshalXML writes the item with its original content
+func (r rawItem) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Inner string `xml:",innerxml"`
+	}{Inner: r.Inner}, start)
+}
+
+// itemXML is a new item of the feed
+type itemXML struct {
+	XMLName     xml.Name     `xml:"item"`
+	Title       string       `xml:"title"`
+	Description string       `xml:"description"`
+	PubDate     string       `xml:"pubDate"`
+	GUID        guidXML      `xml:"guid"`
+	Enclosure   enclosureXML `xml:"enclosure"`
+	Duration    string       `xml:"itunes:duration"`
+	Explicit    string       `xml:"itunes:explicit"`
+}
+
+type guidXML struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type enclosureXML struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// readRSS is the part of an existing feed read back, with the iTunes tags matched by namespace
+type readRSS struct {
+	Channel struct {
+		Title       string          `xml:"title"`
+		Link        string          `xml:"link"`
+		Description string          `xml:"description"`
+		Language    string          `xml:"language"`
+		Author      string          `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		Category    *itunesCategory `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd category"`
+		Image       *itunesImage    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		Explicit    string          `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+		Items       []rawItem       `xml:"item"`
+	} `xml:"channel"`
+}
+
+// newRSS returns an empty feed of the channel
+func newRSS(channel Channel) rss {
+	feed := rss{Version: "2.0", ITunes: itunesNS, Channel: channelXML{
+		Title:       channel.Title,
+		Link:        channel.Link,
+		Description: channel.Description,
+		Language:    channel.Language,
+		Author:      channel.Author,
+		Explicit:    "false",
+	}}
+	if channel.Category != "" {
+		feed.Channel.Category = &itunesCategory{Text: channel.Category}
+	}
+	return feed
+}
+
+// parseRSS reads an existing feed
+func parseRSS(data []byte) (rss, error) {
+	var existing readRSS
+	if err := xml.Unmarshal(data, &existing); err != nil {
+		return rss{}, fmt.Errorf("invalid xml: %w", err)
+	}
+	ch := existing.Channel
+	if ch.Title == "" {
+		return rss{}, errors.New("no rss channel")
+	}
+	explicit := ch.Explicit
+	if explicit == "" {
+		explicit = "false"
+	}
+	return rss{Version: "2.0", ITunes: itunesNS, Channel: channelXML{
+		Title:       ch.Title,
+		Link:        ch.Link,
+		Description: ch.Description,
+		Language:    ch.Language,
+		Author:      ch.Author,
+		Category:    ch.Category,
+		Image:       ch.Image,
+		Explicit:    explicit,
+		Items:       ch.Items,
+	}}, nil
+}
+
+// episodeItem encodes the episode as a feed item
+func episodeItem(episode Episode) (rawItem, error) {
+	item := itemXML{
+		Title:       episode.Title,
+		Description: episode.Description,
+		PubDate:     episode.PubDate.Format(time.RFC1123Z),
+		GUID:        guidXML{IsPermaLink: "false", Value: episode.GUID},
+		Enclosure:   enclosureXML{URL: episode.URL, Length: episode.Length, Type: "audio/mpeg"},
+		Duration:    formatDuration(episode.Duration),
+		Explicit:    "false",
+	}
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("    ", "  ")
+	if err := enc.Encode(item); err != nil {
+		return rawItem{}, fmt.Errorf("failed to encode feed item: %w", err)
+	}
+
+	// keep only the content of the item, the item element itself is written by rawItem
+	inner := strings.TrimPrefix(buf.String(), "    <item>")
+	inner = strings.TrimSuffix(inner, "</item>")
+	return rawItem{GUID: episode.GUID, Inner: inner}, nil
+}
+
+// formatDuration formats the duration as HH:MM:SS for itunes:duration
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,215 @@
+package feed
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/script"
+)
+
+// podcastFeed is the subset of the podcast RSS 2.0 schema with iTunes tags checked by validateFeed
+type podcastFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Language    string `xml:"language"`
+		Explicit    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+		Category    struct {
+			Text string `xml:"text,attr"`
+		} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd category"`
+		Items []struct {
+			Title   string `xml:"title"`
+			PubDate string `xml:"pubDate"`
+			GUID    struct {
+				IsPermaLink string `xml:"isPermaLink,attr"`
+				Value       string `xml:",chardata"`
+			} `xml:"guid"`
+			Enclosure struct {
+				URL    string `xml:"url,attr"`
+				Length string `xml:"length,attr"`
+				Type   string `xml:"type,attr"`
+			} `xml:"enclosure"`
+			Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			Explicit string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd explicit"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// validateFeed checks the elements and formats required by the podcast RSS schema and returns the parsed feed
+func validateFeed(t *testing.T, feedFile string) podcastFeed {
+	t.Helper()
+	data, err := os.ReadFile(feedFile) // #nosec G304 -- test file
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), xml.Header), "xml declaration")
+	assert.Contains(t, string(data), `xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"`)
+
+	var feed podcastFeed
+	require.NoError(t, xml.Unmarshal(data, &feed))
+	assert.Equal(t, "2.0", feed.Version)
+	assert.NotEmpty(t, feed.Channel.Title)
+	assert.NotEmpty(t, feed.Channel.Description)
+	assert.Contains(t, []string{"true", "false"}, feed.Channel.Explicit)
+	for _, item := range feed.Channel.Items {
+		assert.NotEmpty(t, item.Title)
+		_, err := time.Parse(time.RFC1123Z, item.PubDate)
+		require.NoError(t, err, "pubDate in RFC 822 format")
+		assert.NotEmpty(t, item.GUID.Value)
+		assert.NotEmpty(t, item.Enclosure.URL)
+		assert.Regexp(t, `^[0-9]+$`, item.Enclosure.Length)
+		assert.Equal(t, "audio/mpeg", item.Enclosure.Type)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9]{2}:[0-5][0-9]:[0-5][0-9]$`), item.Duration)
+		assert.Contains(t, []string{"true", "false"}, item.Explicit)
+	}
+	return feed
+}
+
+func TestUpdate(t *testing.T) {
+	channel := Channel{Title: "AI Podcast", Link: "https://example.com/podcast/", Description: "Discussions of articles",
+		Language: "ru", Category: "Technology"}
+	pubDate := time.Date(2025, 6, 1, 10, 30, 0, 0, time.UTC)
+	episode := func(guid, title string) Episode {
+		return Episode{GUID: guid, Title: title, Description: "https://example.com/article", URL: "https://example.com/podcast/" + guid,
+			Length: 1234, Duration: 754 * time.Second, PubDate: pubDate}
+	}
+
+	t.Run("creates the feed", func(t *testing.T) {
+		feedFile := filepath.Join(t.TempDir(), "feed.xml")
+		require.NoError(t, Update(feedFile, channel, episode("first/episode.mp3", "Новости & <релизы>")))
+
+		feed := validateFeed(t, feedFile)
+		assert.Equal(t, "AI Podcast", feed.Channel.Title)
+		assert.Equal(t, "Technology", feed.Channel.Category.Text)
+		require.Len(t, feed.Channel.Items, 1)
+		item := feed.Channel.Items[0]
+		assert.Equal(t, "Новости & <релизы>", item.Title)
+		assert.Equal(t, "Sun, 01 Jun 2025 10:30:00 +0000", item.PubDate)
+		assert.Equal(t, "false", item.GUID.IsPermaLink)
+		assert.Equal(t, "https://example.com/podcast/first/episode.mp3", item.Enclosure.URL)
+		assert.Equal(t, "1234", item.Enclosure.Length)
+		assert.Equal(t, "00:12:34", item.Duration)
+	})
+
+	t.Run("appends newest first and replaces a saved episode", func(t *testing.T) {
+		feedFile := filepath.Join(t.TempDir(), "feed.xml")
+		require.NoError(t, Update(feedFile, channel, episode("first/episode.mp3", "first")))
+		require.NoError(t, Update(feedFile, channel, episode("second/episode.mp3", "second")))
+		require.NoError(t, Update(feedFile, channel, episode("first/episode.mp3", "first again")))
+
+		feed := validateFeed(t, feedFile)
+		require.Len(t, feed.Channel.Items, 2)
+		assert.Equal(t, "first again", feed.Channel.Items[0].Title)
+		assert.Equal(t, "second", feed.Channel.Items[1].Title)
+	})
+
+	t.Run("keeps the edited channel and foreign items", func(t *testing.T) {
+		feedFile := filepath.Join(t.TempDir(), "feed.xml")
+		existing := xml.Header + `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Радио-Т AI</title>
+    <link>https://radio-t.com/ai/</link>
+    <description>Edited by hand</description>
+    <itunes:author>Radio-T</itunes:author>
+    <itunes:image href="https://radio-t.com/ai/cover.jpg"/>
+    <itunes:explicit>true</itunes:explicit>
+    <item>
+      <title>Old</title>
+      <pubDate>Sat, 31 May 2025 10:00:00 +0000</pubDate>
+      <guid isPermaLink="false">old.mp3</guid>
+      <enclosure url="https://radio-t.com/ai/old.mp3" length="10" type="audio/mpeg"/>
+      <itunes:duration>00:05:00</itunes:duration>
+      <itunes:explicit>false</itunes:explicit>
+      <itunes:season>1</itunes:season>
+    </item>
+  </channel>
+</rss>
+`
+		require.NoError(t, os.WriteFile(feedFile, []byte(existing), 0o600))
+		require.NoError(t, Update(feedFile, channel, episode("new.mp3", "New")))
+
+		feed := validateFeed(t, feedFile)
+		assert.Equal(t, "Радио-Т AI", feed.Channel.Title)
+		assert.Equal(t, "Edited by hand", feed.Channel.Description)
+		assert.Equal(t, "true", feed.Channel.Explicit)
+		require.Len(t, feed.Channel.Items, 2)
+		assert.Equal(t, "New", feed.Channel.Items[0].Title)
+		assert.Equal(t, "Old", feed.Channel.Items[1].Title)
+
+		data, err := os.ReadFile(feedFile) // #nosec G304 -- test file
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `<itunes:author>Radio-T</itunes:author>`)
+		assert.Contains(t, string(data), `<itunes:image href="https://radio-t.com/ai/cover.jpg"></itunes:image>`)
+		assert.Contains(t, string(data), `<itunes:season>1</itunes:season>`, "unknown item tags are kept")
+	})
+
+	t.Run("invalid feed is not overwritten", func(t *testing.T) {
+		feedFile := filepath.Join(t.TempDir(), "feed.xml")
+		require.NoError(t, os.WriteFile(feedFile, []byte("<html>not a feed</html>"), 0o600))
+		err := Update(feedFile, channel, episode("new.mp3", "New"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse feed")
+
+		data, err := os.ReadFile(feedFile) // #nosec G304 -- test file
+		require.NoError(t, err)
+		assert.Equal(t, "<html>not a feed</html>", string(data))
+	})
+}
+
+func TestNewEpisode(t *testing.T) {
+	dir := t.TempDir()
+	episodeDir := filepath.Join(dir, "2025-06-01-новый-релиз")
+	require.NoError(t, os.MkdirAll(episodeDir, 0o750))
+	mp3File := filepath.Join(episodeDir, "episode.mp3")
+	require.NoError(t, os.WriteFile(mp3File, []byte("mp3 data"), 0o600))
+	feedFile := filepath.Join(dir, "feed.xml")
+	transcript := script.Transcript{Title: "Новый релиз", Duration: 1, Messages: []script.TranscriptLine{
+		{Host: "Алексей", Start: 0, Duration: 30.2}, {Host: "Мария", Start: 30.2, Duration: 45.5},
+	}}
+
+	t.Run("relative enclosure url", func(t *testing.T) {
+		episode, err := NewEpisode(feedFile, mp3File, "", transcript)
+		require.NoError(t, err)
+		assert.Equal(t, "2025-06-01-новый-релиз/episode.mp3", episode.GUID)
+		assert.Equal(t, "2025-06-01-%D0%BD%D0%BE%D0%B2%D1%8B%D0%B9-%D1%80%D0%B5%D0%BB%D0%B8%D0%B7/episode.mp3", episode.URL)
+		assert.Equal(t, "Новый релиз", episode.Title)
+		assert.Equal(t, int64(8), episode.Length)
+		assert.Equal(t, 76*time.Second, episode.Duration, "end of the last line, not the transcript total")
+		assert.False(t, episode.PubDate.IsZero())
+	})
+
+	t.Run("base url", func(t *testing.T) {
+		episode, err := NewEpisode(feedFile, mp3File, "https://example.com/podcast", transcript)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/podcast/2025-06-01-%D0%BD%D0%BE%D0%B2%D1%8B%D0%B9-%D1%80%D0%B5%D0%BB%D0%B8%D0%B7/episode.mp3",
+			episode.URL)
+	})
+
+	t.Run("outside of the feed directory", func(t *testing.T) {
+		_, err := NewEpisode(filepath.Join(episodeDir, "sub", "feed.xml"), mp3File, "", transcript)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is outside of the feed directory")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewEpisode(feedFile, filepath.Join(dir, "missing.mp3"), "", transcript)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read episode file")
+	})
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "00:00:00", formatDuration(0))
+	assert.Equal(t, "00:01:05", formatDuration(65*time.Second))
+	assert.Equal(t, "00:01:06", formatDuration(65600*time.Millisecond))
+	assert.Equal(t, "01:02:03", formatDuration(time.Hour+2*time.Minute+3*time.Second))
+}
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-pkgz/lgr"
+)
+
+// ParseLevel parses the log level name: debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", s)
+	}
+}
+
+// New returns a logger writing the records at the level and above to w with go-pkgz/lgr, one line each.
+// the format is made for people: no timestamps, the level, the message and its attributes as key=value.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(&handler{lgr: lgr.New(lgr.Out(w), lgr.Err(w), lgr.Debug, lgr.Format(format)), level: level})
+}
+
+// format is the lgr layout of the lines, the level is padded to five characters
+const format = `{{.Level}} {{.Message}}`
+
+// handler is the slog handler of New, it formats the attributes and passes the line to lgr
+type handler struct {
+	lgr    lgr.L
+	level  slog.Leveler
+	attrs  string // preformatted attributes added with WithAttrs
+	prefix string // group prefix of the next attribute keys, e.g. "request."
+}
+
+// Enabled reports whether the record level is at the handler level or above
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle passes the record to lgr as a single line with the lgr level prefix
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	var sb strings.Builder
+	sb.WriteString(r.Message)
+	sb.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&sb, h.prefix, a)
+		return true
+	})
+	h.lgr.Logf("%s %s", lgrLevel(r.Level), sb.String())
+	return nil
+}
+
+// lgrLevel returns the lgr level prefix of the slog level
+func lgrLevel(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "ERROR"
+	case level >= slog.LevelWarn:
+		return "WARN"
+	case level >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// WithAttrs returns a handler adding the attributes to every record
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var sb strings.Builder
+	for _, a := range attrs {
+		appendAttr(&sb, h.prefix, a)
+	}
+	clone := *h
+	clone.attrs += sb.String()
+	return &clone
+}
+
+// WithGroup returns a handler qualifying the keys of the following attributes with the group name
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// appendAttr writes the attribute as " key=value", groups are flattened to dotted keys, empty attributes skipped
+func appendAttr(sb *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(sb, prefix, ga)
+		}
+		return
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(prefix + a.Key)
+	sb.WriteByte('=')
+	sb.WriteString(formatValue(a.Value))
+}
+
+// formatValue returns the attribute value, quoted when it has spaces, quotes or is empty
+func formatValue(v slog.Value) string {
+	s := v.String()
+	if v.Kind() == slog.KindFloat64 {
+		s = strconv.FormatFloat(v.Float64(), 'f', -1, 64)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected slog.Level
+		err      string
+	}{
+		{input: "", expected: slog.LevelInfo},
+		{input: "debug", expected: slog.LevelDebug},
+		{input: "INFO", expected: slog.LevelInfo},
+		{input: " warn ", expected: slog.LevelWarn},
+		{input: "warning", expected: slog.LevelWarn},
+		{input: "error", expected: slog.LevelError},
+		{input: "trace", err: `unknown log level "trace", expected debug, info, warn or error`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			level, err := ParseLevel(test.input)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, level)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("human friendly lines", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&buf, slog.LevelInfo)
+		logger.Info("Generating speech", "host", "Мария", "message", 3, "speed", 1.25)
+		logger.Warn("Article is short", "chars", 159)
+		logger.Error("Control endpoint failed", "err", errors.New("address in use"))
+		logger.Info("Podcast saved", "file", "my podcast.mp3", "took", 1500*time.Millisecond, "title", "")
+		assert.Equal(t, "INFO  Generating speech host=Мария message=3 speed=1.25\n"+
+			"WARN  Article is short chars=159\n"+
+			"ERROR Control endpoint failed err=\"address in use\"\n"+
+			"INFO  Podcast saved file=\"my podcast.mp3\" took=1.5s title=\"\"\n", buf.String())
+	})
+
+	t.Run("levels", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&buf, slog.LevelInfo)
+		logger.Debug("hidden")
+		logger.Info("shown")
+		assert.Equal(t, "INFO  shown\n", buf.String())
+
+		buf.Reset()
+		debug := New(&buf, slog.LevelDebug)
+		debug.Debug("details", "segment", 2)
+		assert.Equal(t, "DEBUG details segment=2\n", buf.String())
+	})
+
+	t.Run("quiet logger emits nothing at info", func(t *testing.T) {
+		var buf bytes.Buffer
+		quiet := New(&buf, slog.LevelError)
+		quiet.Debug("debug")
+		quiet.Info("info", "key", "value")
+		quiet.Warn("warn")
+		assert.Empty(t, buf.String())
+		assert.False(t, quiet.Enabled(t.Context(), slog.LevelInfo))
+
+		quiet.Error("failed")
+		assert.Equal(t, "ERROR failed\n", buf.String(), "errors still get through")
+	})
+
+	t.Run("percent signs kept", func(t *testing.T) {
+		var buf bytes.Buffer
+		New(&buf, slog.LevelInfo).Info("Fetched 100%", "url", "https://example.com/a%20b")
+		assert.Equal(t, "INFO  Fetched 100% url=https://example.com/a%20b\n", buf.String())
+	})
+
+	t.Run("attributes and groups", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&buf, slog.LevelInfo).With("episode", 2).WithGroup("tts")
+		logger.Info("Request", "voice", "nova", slog.Group("usage", "chars", 120))
+		assert.Equal(t, "INFO  Request episode=2 tts.voice=nova tts.usage.chars=120\n", buf.String())
+	})
+}
@@ -0,0 +1,75 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// stages of the pipeline reported by the emitter
+const (
+	StageFetch    = "fetch"
+	StageGenerate = "generate"
+	StageTTS      = "tts"
+	StageConcat   = "concat"
+	StageStream   = "stream"
+)
+
+// events of a stage: it starts, reports every segment and ends
+const (
+	EventStart   = "start"
+	EventSegment = "segment"
+	EventEnd     = "end"
+)
+
+// Event is a single line of the progress stream. Index is 1-based for segments and zero for start and end events.
+type Event struct {
+	Stage     string `json:"stage"`
+	Event     string `json:"event"`
+	Index     int    `json:"index"`
+	Total     int    `json:"total"`
+	Host      string `json:"host,omitempty"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+}
+
+// Emitter writes progress events as newline-delimited JSON, for tools and UIs following the run.
+// it is safe for concurrent use, a nil emitter discards the events.
+type Emitter struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	now   func() time.Time
+}
+
+// New creates an emitter writing to w, elapsed time of the events counts from now
+func New(w io.Writer) *Emitter {
+	return &Emitter{enc: json.NewEncoder(w), start: time.Now(), now: time.Now}
+}
+
+// Start reports the start of a stage with total items to process, zero when unknown
+func (e *Emitter) Start(stage string, total int) {
+	e.emit(Event{Stage: stage, Event: EventStart, Total: total})
+}
+
+// Segment reports the item index of total, 0-based, done by the stage
+func (e *Emitter) Segment(stage string, index, total int, host string) {
+	e.emit(Event{Stage: stage, Event: EventSegment, Index: index + 1, Total: total, Host: host})
+}
+
+// End reports the end of a stage with total items processed
+func (e *Emitter) End(stage string, total int) {
+	e.emit(Event{Stage: stage, Event: EventEnd, Total: total})
+}
+
+// emit writes the event stamped with the elapsed time. a failed write is ignored,
+// the progress stream is informational and must not break the run.
+func (e *Emitter) emit(event Event) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	event.ElapsedMs = e.now().Sub(e.start).Milliseconds()
+	_ = e.enc.Encode(event)
+}
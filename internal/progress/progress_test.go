@@ -0,0 +1,45 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := New(&buf)
+	now := emitter.start
+	emitter.now = func() time.Time { return now }
+
+	emitter.Start(StageTTS, 2)
+	now = now.Add(1500 * time.Millisecond)
+	emitter.Segment(StageTTS, 0, 2, "Алексей")
+	now = now.Add(time.Second)
+	emitter.Segment(StageTTS, 1, 2, "Мария")
+	emitter.End(StageTTS, 2)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4, "one line per event")
+	assert.JSONEq(t, `{"stage":"tts","event":"start","index":0,"total":2,"elapsed_ms":0}`, lines[0])
+	assert.JSONEq(t, `{"stage":"tts","event":"segment","index":1,"total":2,"host":"Алексей","elapsed_ms":1500}`, lines[1])
+	assert.JSONEq(t, `{"stage":"tts","event":"segment","index":2,"total":2,"host":"Мария","elapsed_ms":2500}`, lines[2])
+
+	var end Event
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &end))
+	assert.Equal(t, Event{Stage: StageTTS, Event: EventEnd, Total: 2, ElapsedMs: 2500}, end)
+}
+
+func TestEmitter_Nil(t *testing.T) {
+	var emitter *Emitter
+	assert.NotPanics(t, func() {
+		emitter.Start(StageFetch, 0)
+		emitter.Segment(StageTTS, 0, 1, "Алексей")
+		emitter.End(StageFetch, 0)
+	})
+}
@@ -0,0 +1,48 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// WriteDialog writes the messages as "Name: content" lines, "Name [emotion]: content" for lines with a delivery,
+// the format of the model response, so the script can be read, edited and parsed back into messages
+func WriteDialog(w io.Writer, messages []podcast.Message) error {
+	bw := bufio.NewWriter(w)
+	for _, msg := range messages {
+		speaker := msg.Host
+		if msg.Emotion != "" {
+			speaker += " [" + msg.Emotion + "]"
+		}
+		// a message is a single line of the script
+		text := strings.Join(strings.Fields(msg.Content), " ")
+		if _, err := fmt.Fprintf(bw, "%s: %s\n", speaker, text); err != nil {
+			return fmt.Errorf("failed to write dialog: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write dialog: %w", err)
+	}
+	return nil
+}
+
+// SaveDialog writes the messages as "Name: content" lines to a text file
+func SaveDialog(filename string, messages []podcast.Message) error {
+	f, err := os.Create(filename) // #nosec G304 -- output path is provided by the user
+	if err != nil {
+		return fmt.Errorf("failed to create dialog file: %w", err)
+	}
+	if err := WriteDialog(f, messages); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close dialog file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package script
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+func TestWriteDialog(t *testing.T) {
+	messages := []podcast.Message{
+		{Host: "Алексей", Content: "Привет всем, сегодня у нас интересная новость."},
+		{Host: "Мария", Content: "Правда?\nРасскажи  подробнее.", Emotion: "curious"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDialog(&buf, messages))
+	assert.Equal(t, "Алексей: Привет всем, сегодня у нас интересная новость.\n"+
+		"Мария [curious]: Правда? Расскажи подробнее.\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteDialog(&buf, nil))
+	assert.Empty(t, buf.String())
+}
+
+func TestSaveDialog(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "script.txt")
+	require.NoError(t, SaveDialog(filename, []podcast.Message{{Host: "Дмитрий", Content: "Коротко."}}))
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "Дмитрий: Коротко.\n", string(data))
+
+	err = SaveDialog(filepath.Join(t.TempDir(), "missing", "script.txt"), nil)
+	assert.ErrorContains(t, err, "failed to create dialog file")
+}
@@ -0,0 +1,100 @@
+package script
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/radio-t/ai-podcast/internal/content"
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// page layout in millimeters and points
+const (
+	fontFamily    = "go"
+	titleSize     = 18
+	headingSize   = 12
+	textSize      = 11
+	lineHeight    = 6
+	numberWidth   = 12
+	paragraphSkip = 2
+)
+
+// WritePDF renders the discussion as a printable script: title, hosts and numbered dialog lines, the labels
+// are in the episode language. the Go fonts are embedded, they cover Cyrillic so Russian text renders
+// without system fonts.
+func WritePDF(w io.Writer, discussion podcast.Discussion, hosts []podcast.Host, lang content.Language) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	pdf.AddUTF8FontFromBytes(fontFamily, "I", goitalic.TTF)
+	pdf.SetTitle(discussion.Title, true)
+	pdf.SetCreator("ai-podcast", true)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont(fontFamily, "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// title
+	pdf.SetFont(fontFamily, "B", titleSize)
+	pdf.MultiCell(0, 9, discussion.Title, "", "L", false)
+	pdf.Ln(paragraphSkip)
+
+	// hosts
+	if len(hosts) > 0 {
+		pdf.SetFont(fontFamily, "B", headingSize)
+		pdf.CellFormat(0, lineHeight, lang.HostsHeading(), "", 1, "L", false, 0, "")
+		pdf.SetFont(fontFamily, "", textSize)
+		for _, host := range hosts {
+			pdf.MultiCell(0, lineHeight, fmt.Sprintf("%s — %s", host.Name, host.Character), "", "L", false)
+		}
+		pdf.Ln(paragraphSkip * 2)
+	}
+
+	// numbered dialog lines
+	left, _, _, _ := pdf.GetMargins()
+	for i, msg := range discussion.Messages {
+		pdf.SetFont(fontFamily, "", textSize)
+		pdf.SetX(left)
+		pdf.CellFormat(numberWidth, lineHeight, fmt.Sprintf("%d.", i+1), "", 0, "R", false, 0, "")
+
+		style, text := "", fmt.Sprintf("%s: %s", msg.Host, msg.Content)
+		if msg.Ad {
+			style, text = "I", fmt.Sprintf("[%s] %s", lang.AdHost(), strings.TrimSpace(msg.Content))
+		}
+		pdf.SetFont(fontFamily, style, textSize)
+		pdf.SetX(left + numberWidth + 2)
+		pdf.MultiCell(0, lineHeight, text, "", "L", false)
+		pdf.Ln(paragraphSkip)
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to render script PDF: %w", err)
+	}
+	return nil
+}
+
+// SavePDF writes the discussion script to a PDF file
+func SavePDF(filename string, discussion podcast.Discussion, hosts []podcast.Host, lang content.Language) error {
+	f, err := os.Create(filename) // #nosec G304 -- output path is provided by the user
+	if err != nil {
+		return fmt.Errorf("failed to create script file: %w", err)
+	}
+	if err := WritePDF(f, discussion, hosts, lang); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close script file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package script
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/content"
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+func TestWritePDF(t *testing.T) {
+	hosts := []podcast.Host{
+		{Name: "Алексей", Character: "молодой техно-оптимист"},
+		{Name: "Мария", Character: "аналитик, любит данные"},
+	}
+	discussion := podcast.Discussion{
+		Title: "Будущее (и прошлое) ИИ",
+		Messages: []podcast.Message{
+			{Host: "Алексей", Content: "Привет всем! Сегодня говорим про ИИ."},
+			{Host: "Мария", Content: "Да, и у меня есть цифры: рост на 40% за год."},
+			{Host: "Реклама", Content: "Подкаст поддерживает компания Ромашка", Ad: true},
+			{Host: "Алексей", Content: strings.Repeat("Очень длинная реплика, которая займёт несколько строк. ", 10)},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePDF(&buf, discussion, hosts, content.LanguageRussian))
+	require.NotEmpty(t, buf.Bytes())
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+
+	texts := extractText(t, buf.Bytes())
+	numbers := 0
+	lineNumber := regexp.MustCompile(`^\d+\.$`)
+	for _, text := range texts {
+		if lineNumber.MatchString(text) {
+			numbers++
+		}
+	}
+	assert.Equal(t, len(discussion.Messages), numbers, "one numbered line per message")
+
+	all := strings.Join(texts, "\n")
+	assert.Contains(t, all, "Будущее (и прошлое) ИИ")
+	assert.Contains(t, all, "Ведущие")
+	assert.Contains(t, all, "Мария — аналитик, любит данные")
+	assert.Contains(t, all, "Алексей: Привет всем! Сегодня говорим про ИИ.")
+	assert.Contains(t, all, "[Реклама] Подкаст поддерживает компания Ромашка")
+
+	t.Run("english labels", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WritePDF(&buf, discussion, hosts, content.LanguageEnglish))
+		all := strings.Join(extractText(t, buf.Bytes()), "\n")
+		assert.Contains(t, all, "Hosts")
+		assert.Contains(t, all, "[Advertisement] Подкаст поддерживает компания Ромашка")
+		assert.NotContains(t, all, "Ведущие")
+	})
+}
+
+func TestSavePDF(t *testing.T) {
+	discussion := podcast.Discussion{Title: "Тест", Messages: []podcast.Message{{Host: "Мария", Content: "Привет"}}}
+
+	filename := filepath.Join(t.TempDir(), "script.pdf")
+	require.NoError(t, SavePDF(filename, discussion, nil, content.LanguageRussian))
+	data, err := os.ReadFile(filename) // #nosec G304 -- test file
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(extractText(t, data), "\n"), "Мария: Привет")
+
+	err = SavePDF(filepath.Join(t.TempDir(), "missing", "script.pdf"), discussion, nil, content.LanguageRussian)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create script file")
+}
+
+// extractText returns strings drawn with Tj in the page content streams.
+// the embedded UTF-8 fonts write text as UTF-16BE, which is decoded back.
+func extractText(t *testing.T, data []byte) []string {
+	t.Helper()
+	var result []string
+	streamRe := regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	for _, m := range streamRe.FindAllSubmatch(data, -1) {
+		content := m[1]
+		if r, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+			if decoded, err := io.ReadAll(r); err == nil {
+				content = decoded
+			}
+		}
+		if !bytes.Contains(content, []byte("BT")) || !bytes.Contains(content, []byte("Tj")) {
+			continue
+		}
+		result = append(result, tjStrings(content)...)
+	}
+	return result
+}
+
+// tjStrings parses literal strings followed by the Tj operator
+func tjStrings(content []byte) []string {
+	var result []string
+	for i := 0; i < len(content); i++ {
+		if content[i] != '(' {
+			continue
+		}
+		var raw []byte
+		j := i + 1
+		for ; j < len(content) && content[j] != ')'; j++ {
+			if content[j] != '\\' || j+1 >= len(content) {
+				raw = append(raw, content[j])
+				continue
+			}
+			j++
+			switch content[j] {
+			case 'n':
+				raw = append(raw, '\n')
+			case 'r':
+				raw = append(raw, '\r')
+			case 't':
+				raw = append(raw, '\t')
+			default:
+				raw = append(raw, content[j])
+			}
+		}
+		rest := bytes.TrimLeft(content[min(j+1, len(content)):], " ")
+		if bytes.HasPrefix(rest, []byte("Tj")) && len(raw)%2 == 0 {
+			units := make([]uint16, 0, len(raw)/2)
+			for k := 0; k < len(raw); k += 2 {
+				units = append(units, uint16(raw[k])<<8|uint16(raw[k+1]))
+			}
+			result = append(result, string(utf16.Decode(units)))
+		}
+		i = j
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/radio-t/ai-podcast/internal/content"
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+// Transcript is the machine-readable discussion, every line is placed on the audio timeline by its estimated duration
+type Transcript struct {
+	Title    string           `json:"title"`
+	Duration float64          `json:"duration"` // estimated total duration in seconds
+	Messages []TranscriptLine `json:"messages"`
+}
+
+// TranscriptLine is a single message of the transcript
+type TranscriptLine struct {
+	Host     string  `json:"host"`
+	Voice    string  `json:"voice,omitempty"` // empty for pre-recorded audio
+	Content  string  `json:"content"`
+	Start    float64 `json:"start"`    // offset from the start of the episode in seconds
+	Duration float64 `json:"duration"` // estimated duration in seconds
+}
+
+// NewTranscript builds the transcript of the discussion, voice returns the TTS voice of a host.
+// durations come from TextProcessor.EstimateAudioDuration for the language, each line starts where the previous one ends.
+func NewTranscript(discussion podcast.Discussion, voice func(host string) string, lang content.Language) Transcript {
+	tp := content.NewTextProcessor()
+	tp.SetLanguage(lang)
+	transcript := Transcript{Title: discussion.Title, Messages: make([]TranscriptLine, 0, len(discussion.Messages))}
+	for _, msg := range discussion.Messages {
+		line := TranscriptLine{
+			Host:     msg.Host,
+			Content:  msg.Content,
+			Start:    transcript.Duration,
+			Duration: tp.EstimateAudioDuration(msg.Content),
+		}
+		if msg.AudioFile == "" {
+			line.Voice = voice(msg.Host)
+		}
+		transcript.Messages = append(transcript.Messages, line)
+		transcript.Duration += line.Duration
+	}
+	return transcript
+}
+
+// WriteTranscript writes the transcript as indented JSON
+func WriteTranscript(w io.Writer, transcript Transcript) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(transcript); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return nil
+}
+
+// SaveTranscript writes the transcript to a JSON file
+func SaveTranscript(filename string, transcript Transcript) error {
+	f, err := os.Create(filename) // #nosec G304 -- output path is provided by the user
+	if err != nil {
+		return fmt.Errorf("failed to create transcript file: %w", err)
+	}
+	if err := WriteTranscript(f, transcript); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close transcript file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radio-t/ai-podcast/internal/content"
+	"github.com/radio-t/ai-podcast/podcast"
+)
+
+func TestNewTranscript(t *testing.T) {
+	discussion := podcast.Discussion{Title: "Новости", Messages: []podcast.Message{
+		{Host: "Алексей", Content: "Привет всем, сегодня у нас интересная новость."},
+		{Host: "Мария", Content: "Да, давайте разберёмся."},
+		{Host: "Реклама", Content: "Реклама", AudioFile: "ad.mp3", Ad: true},
+		{Host: "Гость", Content: "А я скажу коротко."},
+	}}
+	voices := map[string]string{"Алексей": "onyx", "Мария": "nova"}
+	voice := func(host string) string {
+		if v, ok := voices[host]; ok {
+			return v
+		}
+		return "alloy"
+	}
+
+	transcript := NewTranscript(discussion, voice, content.LanguageRussian)
+	assert.Equal(t, "Новости", transcript.Title)
+	require.Len(t, transcript.Messages, 4)
+	assert.Equal(t, []string{"onyx", "nova", "", "alloy"},
+		[]string{transcript.Messages[0].Voice, transcript.Messages[1].Voice, transcript.Messages[2].Voice, transcript.Messages[3].Voice})
+
+	tp := content.NewTextProcessor()
+	assert.Zero(t, transcript.Messages[0].Start)
+	for i, line := range transcript.Messages {
+		assert.Equal(t, discussion.Messages[i].Content, line.Content)
+		assert.InDelta(t, tp.EstimateAudioDuration(line.Content), line.Duration, 1e-9)
+		if i > 0 {
+			prev := transcript.Messages[i-1]
+			assert.Greater(t, line.Start, prev.Start, "offsets increase monotonically")
+			assert.InDelta(t, prev.Start+prev.Duration, line.Start, 1e-9, "line starts where the previous one ends")
+		}
+	}
+	last := transcript.Messages[len(transcript.Messages)-1]
+	assert.InDelta(t, tp.EstimateTotalDuration(discussion.Messages), last.Start+last.Duration, 1e-9)
+	assert.InDelta(t, tp.EstimateTotalDuration(discussion.Messages), transcript.Duration, 1e-9)
+}
+
+func TestWriteTranscript(t *testing.T) {
+	transcript := Transcript{Title: "Q&A", Duration: 3.5, Messages: []TranscriptLine{
+		{Host: "Мария", Voice: "nova", Content: "<b>Привет</b>", Duration: 3.5},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTranscript(&buf, transcript))
+	assert.Contains(t, buf.String(), `"content": "<b>Привет</b>"`, "html is not escaped")
+
+	var decoded Transcript
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, transcript, decoded)
+}
+
+func TestSaveTranscript(t *testing.T) {
+	transcript := NewTranscript(podcast.Discussion{Title: "Тест", Messages: []podcast.Message{{Host: "Мария", Content: "Привет"}}},
+		func(string) string { return "nova" }, content.LanguageRussian)
+
+	filename := filepath.Join(t.TempDir(), "transcript.json")
+	require.NoError(t, SaveTranscript(filename, transcript))
+	data, err := os.ReadFile(filename) // #nosec G304 -- test file
+	require.NoError(t, err)
+	var decoded Transcript
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, transcript, decoded)
+
+	err = SaveTranscript(filepath.Join(t.TempDir(), "missing", "transcript.json"), transcript)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create transcript file")
+}
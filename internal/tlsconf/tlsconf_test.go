@@ -0,0 +1,75 @@
+package tlsconf
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// the test server certificate is self-signed, so it serves as its own CA
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+
+	get := func(transport *http.Transport) error {
+		client := &http.Client{}
+		if transport != nil {
+			client.Transport = transport
+		}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	t.Run("defaults keep the standard transport", func(t *testing.T) {
+		transport, err := Transport("", false)
+		require.NoError(t, err)
+		assert.Nil(t, transport)
+		require.Error(t, get(transport), "private CA is rejected by default")
+	})
+
+	t.Run("custom CA is trusted", func(t *testing.T) {
+		transport, err := Transport(caFile, false)
+		require.NoError(t, err)
+		require.NotNil(t, transport.TLSClientConfig)
+		assert.NotNil(t, transport.TLSClientConfig.RootCAs)
+		assert.False(t, transport.TLSClientConfig.InsecureSkipVerify)
+		require.NoError(t, get(transport))
+	})
+
+	t.Run("skip verify", func(t *testing.T) {
+		transport, err := Transport("", true)
+		require.NoError(t, err)
+		assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+		assert.Nil(t, transport.TLSClientConfig.RootCAs)
+		require.NoError(t, get(transport))
+	})
+
+	t.Run("missing CA file", func(t *testing.T) {
+		_, err := Transport(filepath.Join(t.TempDir(), "missing.pem"), false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read CA certificate")
+	})
+
+	t.Run("file without certificates", func(t *testing.T) {
+		badFile := filepath.Join(t.TempDir(), "bad.pem")
+		require.NoError(t, os.WriteFile(badFile, []byte("not a certificate"), 0o600))
+		_, err := Transport(badFile, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no PEM certificates found")
+	})
+}
@@ -1,19 +1,47 @@
 package podcast
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
-// Host represents a podcast host with name, gender, and character traits
+	"github.com/radio-t/ai-podcast/internal/progress"
+)
+
+// MaxTargetDuration is the longest accepted target duration in minutes
+const MaxTargetDuration = 180
+
+// MinDiscussionMessages is the fewest messages targeted for any duration, a 1-minute episode still gets
+// an opening line, an exchange between the hosts and a wrap-up instead of a couple of lines
+const MinDiscussionMessages = 4
+
+// Host represents a podcast host with name, gender, and character traits.
+// tags name the fields of the -hosts file, JSON or YAML.
 type Host struct {
-	Name      string
-	Gender    string // "male" or "female"
-	Character string // personality traits and perspective
-	Voice     string // openAI TTS voice to use
+	Name      string  `json:"name" yaml:"name"`
+	Gender    string  `json:"gender" yaml:"gender"`       // "male" or "female"
+	Character string  `json:"character" yaml:"character"` // personality traits and perspective
+	Voice     string  `json:"voice" yaml:"voice"`         // openAI TTS voice to use
+	Intro     string  `json:"intro" yaml:"intro"`         // catchphrase opening the episode, read in character
+	Outro     string  `json:"outro" yaml:"outro"`         // catchphrase closing the episode, read in character
+	Weight    float64 `json:"weight" yaml:"weight"`       // relative share of speaking turns, 0 for the default weight of 1
+	TTSModel  string  `json:"tts_model" yaml:"tts_model"` // openAI TTS model of this host, the global model when empty
 }
 
 // Message represents a single utterance in the discussion
 type Message struct {
-	Host    string
-	Content string
+	Host      string
+	Content   string
+	Emotion   string // optional delivery hint for TTS, e.g. excited, skeptical, calm
+	Ad        bool   // advertisement break, not part of the discussion itself
+	AudioFile string // pre-recorded audio used instead of synthesized speech
 }
 
 // Discussion is the complete podcast discussion
@@ -22,18 +50,335 @@ type Discussion struct {
 	Messages []Message
 }
 
+// SpeakingStats returns each host's share of the total speaking duration, 0..1. the duration of a line is estimated
+// from its characters without whitespace, so a host's share of those characters is the share of estimated duration.
+// ads and pre-recorded audio are not counted, it returns nil when nothing is spoken.
+func (d Discussion) SpeakingStats() map[string]float64 {
+	chars := make(map[string]int)
+	total := 0
+	for _, msg := range d.Messages {
+		if msg.Ad || msg.AudioFile != "" {
+			continue
+		}
+		n := len([]rune(strings.Join(strings.Fields(msg.Content), "")))
+		chars[msg.Host] += n
+		total += n
+	}
+	if total == 0 {
+		return nil
+	}
+	stats := make(map[string]float64, len(chars))
+	for host, n := range chars {
+		stats[host] = float64(n) / float64(total)
+	}
+	return stats
+}
+
 // Config represents the application configuration
 type Config struct {
-	Hosts          []Host
-	ArticleURL     string
-	IcecastURL     string
-	IcecastMount   string
-	IcecastUser    string
-	IcecastPass    string
-	OpenAIAPIKey   string
-	TargetDuration int    // target duration in minutes
-	DryRun         bool   // play locally instead of streaming
-	OutputFile     string // output MP3 file path
+	Hosts             []Host
+	ArticleURL        string
+	URLList           string            // file with article URLs processed one by one, used instead of ArticleURL
+	Checkpoint        string            // file recording processed URLs of the list
+	RenderURL         string            // headless-render service used to fetch the article, direct GET when empty
+	FetchDelay        time.Duration     // minimum delay between article requests to the same host
+	FetchUserAgent    string            // User-Agent of article requests, the default one when empty
+	FetchHeaders      map[string]string // extra headers of article requests, e.g. Cookie or Referer
+	MaxRedirects      int               // redirects of an article request followed, the default when zero
+	BlockPrivateIPs   bool              // refuse article hosts resolving to private, loopback or link-local addresses
+	CacheDir          string            // directory keeping extracted articles, so the same URL isn't downloaded again
+	CacheTTL          time.Duration     // age after which a cached article is downloaded again, 0 to keep it forever
+	NoCache           bool              // ignore CacheDir and download every article
+	IcecastURL        string
+	IcecastMount      string
+	IcecastTargets    []IcecastTarget // mounts streamed at once with their bitrates, IcecastMount alone when empty
+	IcecastUser       string
+	IcecastPass       string
+	StreamRetries     int           // reconnects of an Icecast stream refused or dropped by the server, 0 to fail right away
+	StreamRetryDelay  time.Duration // wait before the first reconnect, doubled on every next one
+	OpenAIAPIKey      string
+	OpenAIUserAgent   string        // User-Agent header for OpenAI requests
+	OpenAIBaseURL     string        // root of an OpenAI-compatible API, https://api.openai.com/v1 when empty
+	OpenAIRetries     int           // retries of OpenAI requests failing with rate limits, server or network errors
+	ParseRetries      int           // times the model is asked to fix a discussion which can't be parsed
+	ChatModel         string        // OpenAI model generating the discussion, gpt-4o when empty
+	FallbackChatModel string        // OpenAI model retried when the article exceeds the context of ChatModel, optional
+	TTSModel          string        // OpenAI model synthesizing speech, the default of TTSBackend when empty
+	TTSBackend        string        // endpoint synthesizing speech: chat (audio output of chat completions) or speech
+	Language          string        // language of the episode: ru, en or es, Russian when empty
+	ChatTimeout       time.Duration // deadline of each chat request attempt, the default when zero
+	TTSTimeout        time.Duration // deadline of each speech request attempt, the default when zero
+	TargetDuration    int           // target duration in minutes
+	MessagesPerMinute int           // lines of the discussion requested per minute of TargetDuration, the default when zero
+	AdjustRounds      int           // re-prompts shortening or lengthening a discussion off the target duration, 0 disables
+	DurationTolerance float64       // allowed deviation of the estimated speech from TargetDuration as a fraction, e.g. 0.15
+	DryRun            bool          // play locally instead of streaming
+	OutputFile        string        // output MP3 file path
+	OutputDir         string        // base directory of per-episode folders with OutputFile and the files saved along with it
+	Teaser            time.Duration // length of the teaser clip cut from the saved episode, 0 to disable
+	Audiogram         string        // output path of the waveform picture (.png) or video (.mp4) of the saved episode
+	AudiogramSize     string        // audiogram dimensions as WIDTHxHEIGHT, e.g. 1280x720
+	ScriptPDF         string        // output path of the discussion script PDF
+	OutputTranscript  string        // output path of the JSON transcript with estimated timings
+	Subtitles         string        // subtitle format saved next to OutputFile: srt or vtt, empty to disable
+	Chapters          bool          // save chapter markers next to OutputFile and write them into its ID3 tag
+	FeedFile          string        // RSS feed the saved episode is added to, created when missing
+	FeedURL           string        // public url of the feed directory, the episode enclosure urls are relative to it
+	Artist            string        // artist tag of the saved episode, e.g. the show or author name, optional
+	Album             string        // album tag of the saved episode, e.g. the show name, optional
+	CoverFile         string        // jpeg or png picture attached to the saved episode as the front cover, optional
+	MusicFile         string        // background music mixed under the saved episode, looped when shorter, optional
+	CleanOutput       string        // the episode without the music bed saved to this file as well, optional
+	MusicGain         float64       // volume of the music bed in dB, negative values duck it under the speech
+	Normalize         bool          // normalize the loudness of every speech segment before streaming or saving
+	CrossfadeMs       int           // crossfade between consecutive segments of the saved episode, 0 for hard cuts
+	PauseMs           int           // silence between the segments of consecutive messages, 0 for none
+	AdBreak           AdBreak
+	IntroFile         string // jingle audio placed before the first segment of every episode, optional
+	OutroFile         string // audio placed after the last segment of every episode, optional
+	SplitEpisodes     int    // number of episodes to split the article into, 0 or 1 for a single episode
+	SampleOnly        bool   // synthesize only the first message as a quality sample
+	ScriptOnly        bool   // generate the discussion and write it as "Name: content" lines, without any speech
+	ScriptOut         string // file the ScriptOnly discussion is written to, stdout when empty
+	ScriptFile        string // discussion voiced instead of a generated one, "Name: content" lines or a JSON array
+	VoiceCompare      string // comma-separated voices to synthesize the same line in for comparison, then exit
+	VoiceCompareText  string // line synthesized by VoiceCompare, the first message of the discussion when empty
+	Preflight         bool   // synthesize a tiny line with each host voice before fetching, to fail fast on bad settings
+	ConcatMode        string // how segments are joined: auto, copy or reencode
+	Bitrate           string // mp3 bitrate the episode and the stream are transcoded to, e.g. 128k, empty to copy segments
+	SampleRate        int    // sample rate in Hz of the transcoded output, 0 keeps the rate of the segments
+	Channels          int    // channels of the transcoded output, 1 or 2, 0 keeps the channels of the segments
+	FFmpegPath        string // ffmpeg executable, ffmpeg from PATH when empty
+	StreamAhead       int    // segments generated ahead of a live stream, 0 generates everything before streaming
+	IntroHost         string // host delivering the article intro, the model picks when empty
+	RecommendedLength int    // article length in characters below which a low quality warning is printed
+	MaxArticleTokens  int    // estimated token budget of the article sent to the model, 0 for the MaxArticleChars cap
+	MaxArticleChars   int    // cap of the article sent to the model in characters, 8000 when zero
+	ControlAddr       string // listen address of the pause/resume control endpoint, disabled when empty
+	MaxTTSChars       int    // cap on characters sent to TTS per episode, 0 for no limit
+	ReduceFillers     string // filler reduction intensity: light, medium or strong, empty to keep fillers
+	MaxConsecutive    int    // max turns in a row by one host, longer runs are merged, 0 for no limit
+	DefaultVoice      string // TTS voice of speakers not among the hosts, nova when empty
+	DefaultGender     string // gender of speakers not among the hosts, female when empty
+	TTSCharsMode      string // what to do over the cap: reject or trim
+	VoiceIntro        bool   // each host introduces themselves in their own voice before the discussion
+	Catchphrases      bool   // hosts open and close each episode with their Intro and Outro catchphrases
+	BalanceQuotes     bool   // drop unmatched quotes and brackets from the generated messages before synthesis
+	StreamFormat      string // Icecast stream format: mp3, ogg or opus
+	FillToTarget      bool   // extend a short discussion with follow-up generations
+	StreamChat        bool   // stream the discussion and synthesize its lines while the rest is written
+	CostReport        string // JSON file the token usage and estimated API cost of the run are saved to, optional
+	ProgressJSON      string // file progress events are written to as newline-delimited JSON, - for stdout, optional
+	PricesFile        string // JSON file with model prices overriding the built-in ones, optional
+	CACert            string // PEM file with extra CA certificates for OpenAI and article requests
+	SkipTLSVerify     bool   // skip TLS certificate verification for OpenAI and article requests
+	TagSegments       bool   // write segment index and host into each segment's ID3 title, for debugging
+	WorkDir           string // directory keeping segment files after the run, a temporary one is used when empty
+	ResumeStream      bool   // stream the segments kept in WorkDir from ResumeFromSegment on, without regenerating
+	ResumeFromSegment int    // first segment of the resumed stream, 0 resumes from the start
+	ResumeDir         string // base directory of segments kept per discussion, valid segments of a failed run are reused
+	Concurrency       ConcurrencyConfig
+
+	Paused     func() bool       `json:"-"` // reports whether the live stream is paused by the control endpoint, set at runtime
+	AudioOut   io.Writer         `json:"-"` // destination of the audio for -mp3 -, set at runtime
+	MessageOut io.Writer         `json:"-"` // destination of messages, reports and ffmpeg output, stderr when stdout is taken, set at runtime
+	Logger     *slog.Logger      `json:"-"` // logger of the progress messages writing to MessageOut, set at runtime
+	Progress   *progress.Emitter `json:"-"` // emitter of the -progress-json events, nil without it, set at runtime
+}
+
+// Validate checks the config before the pipeline starts and returns every problem found at once, joined into one error
+func (c Config) Validate() error {
+	var errs []error
+	// a URL list, a voice comparison of a given line or a script need no article
+	if c.ArticleURL != "" || (c.URLList == "" && c.VoiceCompareText == "" && c.ScriptFile == "") {
+		errs = append(errs, validateArticleURL(c.ArticleURL))
+	}
+	if len(c.Hosts) == 0 {
+		errs = append(errs, errors.New("no hosts defined"))
+	}
+	// errors.Join skips nil, so the checks returning an error are appended as is
+	errs = append(errs, validateBaseURL(c.OpenAIBaseURL), c.validateRanges(), c.validateStreaming(), c.validateOutputs())
+	return errors.Join(errs...)
+}
+
+// validateRanges checks the numeric settings: the target duration and the encoding of the output
+func (c Config) validateRanges() error {
+	return errors.Join(ValidateDuration(c.TargetDuration), c.validateEncoding())
+}
+
+// validateStreaming checks the Icecast server, credentials and mounts when the episode is streamed
+func (c Config) validateStreaming() error {
+	if !c.streams() {
+		return nil
+	}
+	var errs []error
+	for _, field := range []struct{ name, value string }{
+		{"icecast url", c.IcecastURL}, {"icecast mount", c.IcecastMount},
+		{"icecast user", c.IcecastUser}, {"icecast password", c.IcecastPass},
+	} {
+		if field.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required for streaming", field.name))
+		}
+	}
+	errs = append(errs, validateIcecastTargets(c.IcecastTargets))
+	return errors.Join(errs...)
+}
+
+// validateOutputs checks the output file is an mp3 and stdout carries one thing only, "-" writes it to stdout
+func (c Config) validateOutputs() error {
+	var errs []error
+	if c.OutputFile != "" && c.OutputFile != "-" && !strings.EqualFold(filepath.Ext(c.OutputFile), ".mp3") {
+		errs = append(errs, fmt.Errorf("output file %q must have the .mp3 extension", c.OutputFile))
+	}
+	if c.ProgressJSON == "-" && (c.OutputFile == "-" || (c.ScriptOnly && c.ScriptOut == "")) {
+		errs = append(errs, errors.New("progress events and the episode can't both go to stdout"))
+	}
+	return errors.Join(errs...)
+}
+
+// streams reports whether the episode goes to Icecast, rather than played locally or saved to a file
+func (c Config) streams() bool {
+	return !c.DryRun && !c.SampleOnly && !c.ScriptOnly && c.OutputFile == "" && c.OutputDir == "" && c.VoiceCompare == ""
+}
+
+// validateEncoding checks the bitrate, sample rate and channels of the transcoded output, which need the bitrate
+func (c Config) validateEncoding() error {
+	switch {
+	case c.Bitrate != "" && !bitratePattern.MatchString(c.Bitrate):
+		return fmt.Errorf("invalid bitrate %q, expected bits per second like 128k or 128000", c.Bitrate)
+	case c.SampleRate < 0:
+		return fmt.Errorf("invalid sample rate %d", c.SampleRate)
+	case c.Channels < 0 || c.Channels > 2:
+		return fmt.Errorf("invalid channels %d, expected 1 or 2", c.Channels)
+	case c.Bitrate == "" && (c.SampleRate > 0 || c.Channels > 0):
+		return errors.New("sample rate and channels of the output need a bitrate to transcode at")
+	}
+	return nil
+}
+
+// validateIcecastTargets checks every mount is an absolute path streamed once, at a bitrate like 64k or 64000
+func validateIcecastTargets(targets []IcecastTarget) error {
+	seen := map[string]bool{}
+	for _, target := range targets {
+		if !strings.HasPrefix(target.Mount, "/") {
+			return fmt.Errorf("invalid icecast mount %q, expected a path like /podcast.mp3", target.Mount)
+		}
+		if seen[target.Mount] {
+			return fmt.Errorf("icecast mount %s is listed twice", target.Mount)
+		}
+		seen[target.Mount] = true
+		if target.Bitrate != "" && !bitratePattern.MatchString(target.Bitrate) {
+			return fmt.Errorf("invalid bitrate %q of icecast mount %s, expected bits per second like 64k or 64000",
+				target.Bitrate, target.Mount)
+		}
+	}
+	return nil
+}
+
+// validateArticleURL checks the article URL is an absolute http or https URL
+func validateArticleURL(articleURL string) error {
+	if articleURL == "" {
+		return errors.New("article url is required")
+	}
+	u, err := url.Parse(articleURL)
+	if err != nil {
+		return fmt.Errorf("invalid article url %q: %w", articleURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid article url %q, expected an http or https url", articleURL)
+	}
+	return nil
+}
+
+// validateBaseURL checks the API root is an http or https url, an empty one keeps the default
+func validateBaseURL(baseURL string) error {
+	if baseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base url %q, expected an http or https url", baseURL)
+	}
+	return nil
+}
+
+// IcecastTarget is an Icecast mount the episode is streamed to
+type IcecastTarget struct {
+	Mount   string // mount point, e.g. /podcast-low.mp3
+	Bitrate string // bitrate the stream of the mount is encoded at, e.g. 64k, empty for the default of the format
+}
+
+// bitratePattern matches an ffmpeg bitrate in bits per second, optionally in thousands with the k suffix
+var bitratePattern = regexp.MustCompile(`^[1-9][0-9]*k?$`)
+
+// Mounts returns the Icecast targets of the stream, IcecastMount at the default bitrate without IcecastTargets
+func (c Config) Mounts() []IcecastTarget {
+	if len(c.IcecastTargets) == 0 {
+		return []IcecastTarget{{Mount: c.IcecastMount}}
+	}
+	return c.IcecastTargets
+}
+
+// AdBreak describes an advertisement segment inserted into the episode timeline.
+// The ad is placed after AfterMinutes of estimated speech when set, otherwise at the Position fraction of the episode.
+type AdBreak struct {
+	Position     float64 // fraction of the episode duration, 0..1
+	AfterMinutes float64 // minutes of speech before the ad
+	AudioFile    string  // pre-recorded ad audio, takes precedence over Text
+	Text         string  // ad text to synthesize when AudioFile is empty
+	SilenceMs    int     // silence inserted before and after the ad
+}
+
+// Enabled reports whether the ad break has any content to insert
+func (a AdBreak) Enabled() bool {
+	return a.AudioFile != "" || a.Text != ""
+}
+
+// ConcurrencyConfig is the resolved number of parallel operations of each pipeline stage
+type ConcurrencyConfig struct {
+	Fetch  int // articles of a URL list fetched ahead in parallel
+	TTS    int // speech generation workers
+	FFmpeg int // ffprobe processes inspecting segments in parallel
+}
+
+// DefaultTTSConcurrency is the number of speech requests in flight when neither -concurrency nor -tts-concurrency is set
+const DefaultTTSConcurrency = 3
+
+// ResolveConcurrency fills stages without an override from the global value.
+// with neither set a stage runs one operation at a time, except speech generation running DefaultTTSConcurrency requests.
+func ResolveConcurrency(global int, overrides ConcurrencyConfig) ConcurrencyConfig {
+	resolve := func(override, fallback int) int {
+		switch {
+		case override > 0:
+			return override
+		case global > 0:
+			return global
+		default:
+			return fallback
+		}
+	}
+	return ConcurrencyConfig{
+		Fetch:  resolve(overrides.Fetch, 1),
+		TTS:    resolve(overrides.TTS, DefaultTTSConcurrency),
+		FFmpeg: resolve(overrides.FFmpeg, 1),
+	}
+}
+
+// ValidateDuration checks the target duration in minutes is within 1 to MaxTargetDuration
+func ValidateDuration(minutes int) error {
+	if minutes <= 0 || minutes > MaxTargetDuration {
+		return fmt.Errorf("target duration %d is out of range, expected 1 to %d minutes", minutes, MaxTargetDuration)
+	}
+	return nil
+}
+
+// EstimateMessageCount returns the number of discussion messages targeted for a podcast of duration minutes
+// with perMinute messages per minute, at least MinDiscussionMessages, and 0 when either is not positive
+func EstimateMessageCount(duration, perMinute int) int {
+	if duration <= 0 || perMinute <= 0 {
+		return 0
+	}
+	return max(duration*perMinute, MinDiscussionMessages)
 }
 
 // SpeechSegment represents a generated speech segment with its metadata
@@ -43,16 +388,20 @@ type SpeechSegment struct {
 	Index     int
 	Error     error
 	Msg       Message
+	Resumed   bool    // audio read from the segment file of a previous run, the file is already in place
+	Speed     float64 // speech speed factor of the request, the segment file is played at its tempo
 }
 
 // SpeechGenerationRequest contains all parameters needed for TTS generation
 type SpeechGenerationRequest struct {
-	Msg    Message
-	Index  int
-	Gender string
-	Voice  string
-	Speed  float64
-	APIKey string
+	Msg      Message
+	Index    int
+	Gender   string
+	Voice    string
+	Speed    float64 // speech speed factor stretching the duration to the target, 1.0 keeps the pace
+	APIKey   string
+	Resume   string // segment file of a previous run reused instead of synthesizing, if it holds valid mp3
+	TTSModel string // TTS model of the host, empty for the global one
 }
 
 // ProcessSegmentsParams contains parameters for processSegments function
@@ -66,6 +415,7 @@ type ProcessSegmentsParams struct {
 	BufferMutex   *sync.Mutex
 	CurrentIndex  *int
 	TempDir       string
+	Speed         float64 // speech speed factor of the episode
 }
 
 // ProcessOrderedSegmentParams contains parameters for processOrderedSegment function
@@ -79,15 +429,26 @@ type ProcessOrderedSegmentParams struct {
 
 // GenerateAndStreamParams contains parameters for generateAndStreamToIcecast and generateAndPlayLocally
 type GenerateAndStreamParams struct {
-	Discussion Discussion
-	Config     Config
+	Discussion    Discussion
+	Config        Config
+	IntroMessages int // leading messages of the episode intro, the teaser starts after them
 }
 
 // GenerateSpeechSegmentsParams contains parameters for generateSpeechSegments
 type GenerateSpeechSegmentsParams struct {
-	Messages []Message
-	HostMap  map[string]HostInfo
-	TempDir  string
+	Messages       []Message
+	HostMap        map[string]HostInfo
+	Fallback       HostInfo          // gender and voice of speakers missing from HostMap
+	Progress       *progress.Emitter // receives an event per generated segment, nil for none
+	TempDir        string
+	TargetDuration int     // target duration in minutes, enables speed checkpoints when positive
+	Speed          float64 // initial speech speed factor
+	TagSegments    bool    // write segment index and host into each segment's ID3 title
+	Resume         bool    // reuse valid segment files already in TempDir instead of synthesizing them
+	Concurrency    int     // speech requests in flight, 0 or 1 generates one segment at a time
+	Normalize      bool    // bring the segments to the same loudness, the normalized copies are returned
+	Language       string  // language of the duration estimates, Russian when empty
+	Logger         *slog.Logger
 }
 
 // SpeechGenerationWorkerParams contains parameters for speechGenerationWorker
@@ -95,6 +456,7 @@ type SpeechGenerationWorkerParams struct {
 	RequestChan <-chan SpeechGenerationRequest
 	ResultChan  chan<- SpeechSegment
 	StopChan    <-chan struct{}
+	Logger      *slog.Logger
 }
 
 // PlaySegmentParams contains parameters for playSegment
@@ -102,14 +464,18 @@ type PlaySegmentParams struct {
 	Segment  SpeechSegment
 	Index    int
 	Filename string
+	Logger   *slog.Logger
 }
 
 // CreateSpeechRequestParams contains parameters for createSpeechRequest
 type CreateSpeechRequestParams struct {
-	Msg     Message
-	Index   int
-	HostMap map[string]HostInfo
-	APIKey  string
+	Msg      Message
+	Index    int
+	HostMap  map[string]HostInfo
+	Fallback HostInfo // gender and voice of speakers missing from HostMap
+	APIKey   string
+	Resume   string  // segment file of a previous run to reuse, empty to always synthesize
+	Speed    float64 // speech speed factor of the episode, 1.0 when zero
 }
 
 // GenerateDiscussionParams contains parameters for GenerateDiscussion
@@ -118,12 +484,25 @@ type GenerateDiscussionParams struct {
 	Title          string
 	Hosts          []Host
 	TargetDuration int
+	Part           int    // 1-based episode number within a miniseries, zero for a standalone episode
+	TotalParts     int    // number of episodes in the miniseries
+	IntroHost      string // host who opens the episode with the article intro, optional
+	FillToTarget   bool   // request follow-up turns while the discussion is short of the target message count
+	BalanceQuotes  bool   // drop unmatched quotes and brackets from the parsed messages
+	// re-prompts shortening or lengthening a discussion whose estimated speech is off TargetDuration
+	// by more than DurationTolerance, a fraction of it. zero rounds keep the discussion as generated.
+	AdjustRounds      int
+	DurationTolerance float64
+	// receives each dialog line as soon as the model writes it, the response isn't streamed when nil.
+	// nothing is sent after GenerateDiscussion returns, the caller closes the channel then.
+	Stream chan<- Message
 }
 
 // HostInfo contains gender and voice information for a host
 type HostInfo struct {
-	Gender string
-	Voice  string
+	Gender   string
+	Voice    string
+	TTSModel string // empty for the global TTS model
 }
 
 // CreateHostMap maps host names to their gender and voice settings
@@ -131,9 +510,37 @@ func CreateHostMap(hosts []Host) map[string]HostInfo {
 	hostMap := make(map[string]HostInfo)
 	for _, host := range hosts {
 		hostMap[host.Name] = HostInfo{
-			Gender: host.Gender,
-			Voice:  host.Voice,
+			Gender:   host.Gender,
+			Voice:    host.Voice,
+			TTSModel: host.TTSModel,
 		}
 	}
 	return hostMap
 }
+
+// TurnShares returns the expected share of speaking turns of each host from their weights.
+// it returns nil when no host has a weight set, the hosts then take equal turns.
+func TurnShares(hosts []Host) map[string]float64 {
+	weighted := false
+	total := 0.0
+	for _, host := range hosts {
+		weighted = weighted || host.Weight > 0
+		total += hostWeight(host)
+	}
+	if !weighted {
+		return nil
+	}
+	shares := make(map[string]float64, len(hosts))
+	for _, host := range hosts {
+		shares[host.Name] = hostWeight(host) / total
+	}
+	return shares
+}
+
+// hostWeight returns the host's turn weight, unset weights count as 1
+func hostWeight(host Host) float64 {
+	if host.Weight > 0 {
+		return host.Weight
+	}
+	return 1
+}
@@ -1,9 +1,12 @@
 package podcast
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateHostMap(t *testing.T) {
@@ -63,3 +66,201 @@ func TestPodcastCreateHostMap(t *testing.T) {
 	assert.Equal(t, "male", bob.Gender)
 	assert.Equal(t, "echo", bob.Voice)
 }
+
+func TestResolveConcurrency(t *testing.T) {
+	tests := []struct {
+		name      string
+		global    int
+		overrides ConcurrencyConfig
+		expected  ConcurrencyConfig
+	}{
+		{name: "defaults", expected: ConcurrencyConfig{Fetch: 1, TTS: 3, FFmpeg: 1}},
+		{name: "global only", global: 4, expected: ConcurrencyConfig{Fetch: 4, TTS: 4, FFmpeg: 4}},
+		{name: "overrides take precedence over global", global: 4, overrides: ConcurrencyConfig{TTS: 8, FFmpeg: 1},
+			expected: ConcurrencyConfig{Fetch: 4, TTS: 8, FFmpeg: 1}},
+		{name: "overrides without global", overrides: ConcurrencyConfig{Fetch: 2},
+			expected: ConcurrencyConfig{Fetch: 2, TTS: 3, FFmpeg: 1}},
+		{name: "global of one runs speech sequentially", global: 1, expected: ConcurrencyConfig{Fetch: 1, TTS: 1, FFmpeg: 1}},
+		{name: "negative values ignored", global: -2, overrides: ConcurrencyConfig{TTS: -1},
+			expected: ConcurrencyConfig{Fetch: 1, TTS: 3, FFmpeg: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, ResolveConcurrency(test.global, test.overrides))
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{Hosts: []Host{{Name: "Алексей", Voice: "onyx"}}, ArticleURL: "https://example.com/article",
+		TargetDuration: 10, IcecastURL: "localhost:8000", IcecastMount: "/podcast.mp3", IcecastUser: "source", IcecastPass: "hackme"}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		expected []string // empty for a valid config
+	}{
+		{name: "relative url", modify: func(c *Config) { c.ArticleURL = "example.com/article" },
+			expected: []string{`invalid article url "example.com/article", expected an http or https url`}},
+		{name: "ftp url", modify: func(c *Config) { c.ArticleURL = "ftp://example.com/a" }, expected: []string{"expected an http or https url"}},
+		{name: "malformed url", modify: func(c *Config) { c.ArticleURL = "http://exa mple.com" }, expected: []string{"invalid article url"}},
+		{name: "no url", modify: func(c *Config) { c.ArticleURL = "" }, expected: []string{"article url is required"}},
+		{name: "url list needs no url", modify: func(c *Config) { c.ArticleURL, c.URLList = "", "urls.txt" }},
+		{name: "voice compare of a line needs no url", modify: func(c *Config) {
+			c.ArticleURL, c.VoiceCompare, c.VoiceCompareText = "", "onyx", "Привет"
+		}},
+		{name: "script needs no url", modify: func(c *Config) { c.ArticleURL, c.ScriptFile = "", "script.txt" }},
+		{name: "local api", modify: func(c *Config) { c.OpenAIBaseURL = "http://localhost:11434/v1" }},
+		{name: "base url without scheme", modify: func(c *Config) { c.OpenAIBaseURL = "localhost:11434/v1" },
+			expected: []string{`invalid base url "localhost:11434/v1", expected an http or https url`}},
+		{name: "zero duration", modify: func(c *Config) { c.TargetDuration = 0 },
+			expected: []string{"target duration 0 is out of range, expected 1 to 180 minutes"}},
+		{name: "negative duration", modify: func(c *Config) { c.TargetDuration = -3 },
+			expected: []string{"target duration -3 is out of range"}},
+		{name: "one minute", modify: func(c *Config) { c.TargetDuration = 1 }},
+		{name: "longest", modify: func(c *Config) { c.TargetDuration = MaxTargetDuration }},
+		{name: "too long", modify: func(c *Config) { c.TargetDuration = 181 }, expected: []string{"target duration 181 is out of range"}},
+		{name: "no hosts", modify: func(c *Config) { c.Hosts = nil }, expected: []string{"no hosts defined"}},
+		{name: "streaming without icecast", modify: func(c *Config) { c.IcecastURL, c.IcecastPass = "", "" },
+			expected: []string{"icecast url is required for streaming", "icecast password is required for streaming"}},
+		{name: "dry run needs no icecast", modify: func(c *Config) { c.IcecastURL, c.DryRun = "", true }},
+		{name: "saving needs no icecast", modify: func(c *Config) { c.IcecastMount, c.OutputFile = "", "episode.MP3" }},
+		{name: "script only needs no icecast", modify: func(c *Config) { c.IcecastURL, c.ScriptOnly = "", true }},
+		{name: "output dir needs no icecast", modify: func(c *Config) { c.IcecastUser, c.OutputDir = "", "episodes" }},
+		{name: "stdout output", modify: func(c *Config) { c.IcecastURL, c.OutputFile = "", "-" }},
+		{name: "not an mp3", modify: func(c *Config) { c.OutputFile = "episode.wav" },
+			expected: []string{`output file "episode.wav" must have the .mp3 extension`}},
+		{name: "several mounts", modify: func(c *Config) {
+			c.IcecastTargets = []IcecastTarget{{Mount: "/podcast.mp3", Bitrate: "128k"}, {Mount: "/podcast-low.mp3", Bitrate: "64000"}}
+		}},
+		{name: "mount without slash", modify: func(c *Config) { c.IcecastTargets = []IcecastTarget{{Mount: "podcast.mp3"}} },
+			expected: []string{`invalid icecast mount "podcast.mp3", expected a path like /podcast.mp3`}},
+		{name: "mount twice", modify: func(c *Config) {
+			c.IcecastTargets = []IcecastTarget{{Mount: "/podcast.mp3"}, {Mount: "/podcast.mp3", Bitrate: "64k"}}
+		}, expected: []string{"icecast mount /podcast.mp3 is listed twice"}},
+		{name: "bad bitrate", modify: func(c *Config) { c.IcecastTargets = []IcecastTarget{{Mount: "/low.mp3", Bitrate: "64kbps"}} },
+			expected: []string{`invalid bitrate "64kbps" of icecast mount /low.mp3, expected bits per second like 64k or 64000`}},
+		{name: "mounts are not checked without streaming", modify: func(c *Config) {
+			c.IcecastTargets, c.DryRun = []IcecastTarget{{Mount: "low"}}, true
+		}},
+		{name: "transcoded", modify: func(c *Config) { c.Bitrate, c.SampleRate, c.Channels = "96k", 44100, 2 }},
+		{name: "bad output bitrate", modify: func(c *Config) { c.Bitrate = "high" },
+			expected: []string{`invalid bitrate "high", expected bits per second like 128k or 128000`}},
+		{name: "three channels", modify: func(c *Config) { c.Bitrate, c.Channels = "96k", 3 },
+			expected: []string{"invalid channels 3, expected 1 or 2"}},
+		{name: "sample rate without bitrate", modify: func(c *Config) { c.SampleRate = 44100 },
+			expected: []string{"sample rate and channels of the output need a bitrate to transcode at"}},
+		{name: "progress to stdout", modify: func(c *Config) { c.ProgressJSON = "-" }},
+		{name: "progress and audio to stdout", modify: func(c *Config) { c.ProgressJSON, c.OutputFile = "-", "-" },
+			expected: []string{"progress events and the episode can't both go to stdout"}},
+		{name: "progress and script to stdout", modify: func(c *Config) { c.ProgressJSON, c.ScriptOnly = "-", true },
+			expected: []string{"can't both go to stdout"}},
+		{name: "every problem reported", modify: func(c *Config) {
+			c.ArticleURL, c.TargetDuration, c.Hosts, c.IcecastMount = "file:///etc/passwd", -5, nil, ""
+		}, expected: []string{"invalid article url", "target duration -5", "no hosts defined", "icecast mount is required"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := valid
+			test.modify(&config)
+			err := config.Validate()
+			if len(test.expected) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, expected := range test.expected {
+				assert.Contains(t, err.Error(), expected)
+			}
+			assert.Len(t, strings.Split(err.Error(), "\n"), len(test.expected), "one line per problem")
+		})
+	}
+}
+
+func TestConfigMounts(t *testing.T) {
+	config := Config{IcecastMount: "/podcast.mp3"}
+	assert.Equal(t, []IcecastTarget{{Mount: "/podcast.mp3"}}, config.Mounts())
+
+	config.IcecastTargets = []IcecastTarget{{Mount: "/podcast.mp3", Bitrate: "128k"}, {Mount: "/podcast-low.mp3", Bitrate: "64k"}}
+	assert.Equal(t, config.IcecastTargets, config.Mounts())
+}
+
+func TestEstimateMessageCount(t *testing.T) {
+	tests := []struct {
+		duration  int
+		perMinute int
+		expected  int
+	}{
+		{duration: 1, perMinute: 2, expected: MinDiscussionMessages},
+		{duration: 2, perMinute: 2, expected: 4},
+		{duration: 10, perMinute: 2, expected: 20},
+		{duration: 30, perMinute: 2, expected: 60},
+		{duration: 15, perMinute: 3, expected: 45},
+		{duration: 0, perMinute: 2, expected: 0},
+		{duration: -5, perMinute: 2, expected: 0},
+		{duration: 10, perMinute: 0, expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d minutes at %d per minute", test.duration, test.perMinute), func(t *testing.T) {
+			assert.Equal(t, test.expected, EstimateMessageCount(test.duration, test.perMinute))
+		})
+	}
+}
+
+func TestTurnShares(t *testing.T) {
+	tests := []struct {
+		name     string
+		hosts    []Host
+		expected map[string]float64
+	}{
+		{name: "no weights", hosts: []Host{{Name: "A"}, {Name: "B"}}},
+		{name: "all weighted", hosts: []Host{{Name: "A", Weight: 2}, {Name: "B", Weight: 1}, {Name: "C", Weight: 1}},
+			expected: map[string]float64{"A": 0.5, "B": 0.25, "C": 0.25}},
+		{name: "unset weight counts as 1", hosts: []Host{{Name: "A", Weight: 3}, {Name: "B"}},
+			expected: map[string]float64{"A": 0.75, "B": 0.25}},
+		{name: "no hosts"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shares := TurnShares(test.hosts)
+			if test.expected == nil {
+				assert.Nil(t, shares)
+				return
+			}
+			require.Len(t, shares, len(test.expected))
+			for name, share := range test.expected {
+				assert.InDelta(t, share, shares[name], 0.0001, name)
+			}
+		})
+	}
+}
+
+func TestDiscussion_SpeakingStats(t *testing.T) {
+	t.Run("skewed discussion", func(t *testing.T) {
+		// Alice talks in long monologues, Bob only agrees
+		discussion := Discussion{Messages: []Message{
+			{Host: "Alice", Content: strings.Repeat("длинная реплика ", 10)},
+			{Host: "Bob", Content: "Да."},
+			{Host: "Alice", Content: strings.Repeat("ещё одна мысль ", 10)},
+			{Host: "Bob", Content: "Ага, точно"},
+			{Host: "Ad", Content: strings.Repeat("реклама ", 100), Ad: true},
+			{Host: "Bob", Content: "", AudioFile: "jingle.mp3"},
+		}}
+		stats := discussion.SpeakingStats()
+		require.Len(t, stats, 2, "ads and pre-recorded audio are not counted")
+		// 140 + 120 characters without spaces for Alice, 3 + 9 for Bob
+		assert.InDelta(t, 260.0/272, stats["Alice"], 0.0001)
+		assert.InDelta(t, 12.0/272, stats["Bob"], 0.0001)
+		assert.InDelta(t, 1, stats["Alice"]+stats["Bob"], 0.0001)
+	})
+
+	t.Run("nothing spoken", func(t *testing.T) {
+		assert.Nil(t, Discussion{}.SpeakingStats())
+		assert.Nil(t, Discussion{Messages: []Message{{Host: "Alice", Content: "  "}}}.SpeakingStats())
+	})
+}